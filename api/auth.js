@@ -0,0 +1,173 @@
+// Gateway authorization. Callers present an API key (X-API-Key) or a JWT
+// (Authorization: Bearer); either maps to a principal with scopes, and each
+// route requires the scopes it needs. Every decision is logged as one JSON
+// line on stdout.
+//
+// Scopes:
+//   cred:issue       POST /v1/issue, POST /v1/renew
+//   cred:verify      POST /v1/verify, POST /v1/verify/requests
+//   cred:revoke      POST /v1/revoke, revocation notices
+//   cred:break-glass POST /v1/verify/break-glass (emergency responders)
+//   audit:read:own   a holder's own trail, credentials, consents and subscriptions
+//   audit:read:any   any holder's trail, bulk exports, access reviews, heatmaps
+//   audit:link       resolve holder pseudonyms to DIDs (auditors)
+//   registry:admin   registry and configuration changes
+//   events:subscribe /v1/subscriptions: filtered event delivery to consumers
+//
+// Configuration:
+//   API_KEYS='{"<key>": {"sub": "issuer-1", "scopes": ["cred:issue"], "holderDid": "did:..."}}'
+//   JWT_SECRET=...        HS256 shared secret, and/or
+//   JWT_PUBLIC_KEY=...    PEM key for RS256 / ES256 / EdDSA tokens
+//   JWT_ISSUER=...        JWT_AUDIENCE=...   checked when set
+//   JWT_SCOPE_CLAIM=scope claim holding scopes (space-separated string or array)
+//
+// With none of these set the gateway runs open for local development: every
+// caller gets all scopes and X-Holder-DID names the holder. Decisions are
+// still logged.
+//
+// Calls that pass their scope check also go through the OPA policy when one
+// is configured (see policy.js).
+
+import crypto from "node:crypto";
+import { checkPolicy, POLICY_ENABLED } from "./policy.js";
+
+export const SCOPES = [
+  "cred:issue",
+  "cred:verify",
+  "cred:revoke",
+  "cred:break-glass",
+  "cred:relay",
+  "audit:read:own",
+  "audit:read:any",
+  "audit:link",
+  "registry:admin",
+  "events:subscribe",
+];
+
+const API_KEYS = JSON.parse(process.env.API_KEYS || "{}");
+const JWT_SECRET = process.env.JWT_SECRET || "";
+const JWT_PUBLIC_KEY = process.env.JWT_PUBLIC_KEY ? crypto.createPublicKey(process.env.JWT_PUBLIC_KEY) : null;
+const JWT_ISSUER = process.env.JWT_ISSUER || "";
+const JWT_AUDIENCE = process.env.JWT_AUDIENCE || "";
+const JWT_SCOPE_CLAIM = process.env.JWT_SCOPE_CLAIM || "scope";
+
+export const AUTH_OPEN = !Object.keys(API_KEYS).length && !JWT_SECRET && !JWT_PUBLIC_KEY;
+if (AUTH_OPEN) console.warn("No API_KEYS or JWT settings; gateway authorization is open (development only)");
+
+for (const [key, entry] of Object.entries(API_KEYS)) {
+  const unknown = (entry.scopes || []).filter((sc) => !SCOPES.includes(sc));
+  if (unknown.length) throw new Error(`API key for ${entry.sub}: unknown scopes ${unknown.join(", ")}`);
+  if (!entry.sub) throw new Error(`API key ${key.slice(0, 4)}...: sub is required`);
+}
+
+class AuthError extends Error {}
+
+const scopesFrom = (value) => (Array.isArray(value) ? value : String(value || "").split(" ")).filter(Boolean);
+
+const safeEqual = (a, b) => a.length === b.length && crypto.timingSafeEqual(a, b);
+
+const verifyJwt = (token) => {
+  const [h, p, sig] = token.split(".");
+  if (!sig) throw new AuthError("malformed token");
+  let header, claims;
+  try {
+    header = JSON.parse(Buffer.from(h, "base64url"));
+    claims = JSON.parse(Buffer.from(p, "base64url"));
+  } catch {
+    throw new AuthError("malformed token");
+  }
+  const input = Buffer.from(`${h}.${p}`);
+  const signature = Buffer.from(sig, "base64url");
+  let valid = false;
+  if (header.alg === "HS256" && JWT_SECRET) {
+    valid = safeEqual(crypto.createHmac("sha256", JWT_SECRET).update(input).digest(), signature);
+  } else if (JWT_PUBLIC_KEY && header.alg === "EdDSA") {
+    valid = crypto.verify(null, input, JWT_PUBLIC_KEY, signature);
+  } else if (JWT_PUBLIC_KEY && header.alg === "RS256") {
+    valid = crypto.verify("sha256", input, JWT_PUBLIC_KEY, signature);
+  } else if (JWT_PUBLIC_KEY && header.alg === "ES256") {
+    valid = crypto.verify("sha256", input, { key: JWT_PUBLIC_KEY, dsaEncoding: "ieee-p1363" }, signature);
+  } else {
+    throw new AuthError(`unsupported token algorithm ${header.alg}`);
+  }
+  if (!valid) throw new AuthError("invalid token signature");
+
+  const now = Math.floor(Date.now() / 1000);
+  if (claims.exp !== undefined && now >= claims.exp) throw new AuthError("token expired");
+  if (claims.nbf !== undefined && now < claims.nbf) throw new AuthError("token not yet valid");
+  if (JWT_ISSUER && claims.iss !== JWT_ISSUER) throw new AuthError("token issuer mismatch");
+  if (JWT_AUDIENCE && !scopesFrom(claims.aud).includes(JWT_AUDIENCE)) throw new AuthError("token audience mismatch");
+  return claims;
+};
+
+// principalFor resolves the caller. It returns null for anonymous callers.
+const principalFor = (req) => {
+  if (AUTH_OPEN) {
+    const holderDid = req.get("X-Holder-DID") || null;
+    return { sub: holderDid || "anonymous", via: "open", scopes: SCOPES, holderDid };
+  }
+  const apiKey = req.get("X-API-Key");
+  if (apiKey) {
+    const entry = Object.entries(API_KEYS).find(([k]) => safeEqual(Buffer.from(k), Buffer.from(apiKey)))?.[1];
+    if (!entry) throw new AuthError("unknown API key");
+    return { sub: entry.sub, via: "api-key", scopes: entry.scopes || [], holderDid: entry.holderDid || null };
+  }
+  const bearer = /^Bearer (.+)$/.exec(req.get("Authorization") || "")?.[1];
+  if (bearer) {
+    const claims = verifyJwt(bearer);
+    const holderDid = claims.holder_did || (String(claims.sub || "").startsWith("did:") ? claims.sub : null);
+    return { sub: claims.sub || "unknown", via: "jwt", scopes: scopesFrom(claims[JWT_SCOPE_CLAIM]), holderDid };
+  }
+  return null;
+};
+
+const logDecision = (req, principal, required, decision, detail) => {
+  console.log(JSON.stringify({
+    at: new Date().toISOString(),
+    type: "authz",
+    method: req.method,
+    path: req.originalUrl.split("?")[0],
+    principal: principal?.sub || null,
+    via: principal?.via || null,
+    required,
+    decision,
+    ...(detail ? { detail } : {}),
+  }));
+};
+
+// requireScope allows the call when the principal holds any of the scopes
+// and the OPA policy, if any, agrees. The principal is left on req.principal
+// for handlers that narrow further (e.g. audit:read:own versus audit:read:any).
+export const requireScope = (...scopes) => async (req, res, next) => {
+  let principal;
+  try {
+    principal = principalFor(req);
+  } catch (err) {
+    logDecision(req, null, scopes, "deny", err.message);
+    return res.status(401).json({ ok: false, error: err.message });
+  }
+  if (!principal) {
+    logDecision(req, null, scopes, "deny", "no credentials");
+    return res.status(401).json({ ok: false, error: "X-API-Key or Bearer token is required" });
+  }
+  const granted = scopes.filter((sc) => principal.scopes.includes(sc));
+  if (!granted.length) {
+    logDecision(req, principal, scopes, "deny", "missing scope");
+    return res.status(403).json({ ok: false, error: `Requires scope ${scopes.join(" or ")}` });
+  }
+  if (POLICY_ENABLED) {
+    const { allow, reasons } = await checkPolicy(req, principal, scopes);
+    if (!allow) {
+      logDecision(req, principal, scopes, "deny", `policy: ${reasons.join("; ") || "denied"}`);
+      return res.status(403).json({ ok: false, error: "Denied by access policy", reasons });
+    }
+  }
+  logDecision(req, principal, scopes, "allow");
+  req.principal = { ...principal, granted };
+  next();
+};
+
+// canReadHolder is the audit:read:own / audit:read:any split for a holder's data.
+export const canReadHolder = (principal, holderDid) =>
+  principal.granted.includes("audit:read:any") ||
+  (principal.granted.includes("audit:read:own") && principal.holderDid === holderDid);
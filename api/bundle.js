@@ -0,0 +1,118 @@
+// Offline verification bundles, for verifiers at venues without
+// connectivity. A verifier fetches a bundle for a credential while it is
+// online and checks the credential against it later, for as long as the
+// bundle is valid. The bundle is one compact JWS signed with the gateway key
+// (typ audittrail-offline-bundle+jwt) carrying
+//
+//   credential  the credential's current state, status entry included
+//   inclusion   the event that set that state (issue, renewal, revocation)
+//               with its Merkle path under a checkpoint over the holder's
+//               trail, in the shape of chaincode GetAuditTrailIntegrityProof
+//   keys        the gateway key, inline and by jwks URI, and references to
+//               the issuer's DID and the status list issuer's DID
+//   validFrom / validUntil
+//
+// and pkg/receipt VerifyOfflineBundle checks it with a saved JWKS. A bundle
+// is a cached positive result as far as revocation latency is concerned
+// (revocation.js): revoking the credential takes effect everywhere once the
+// bundles handed out before it expire. Each bundle is recorded as a Verify
+// event by the requesting verifier.
+//
+//   OFFLINE_BUNDLE_TTL_SECONDS=86400    default validity
+//   OFFLINE_BUNDLE_MAX_SECONDS=604800   longest validity a caller may ask for
+
+import crypto from "node:crypto";
+import { belongsTo } from "./pseudonym.js";
+import { canonicalJson, jwks, keyId, signJws } from "./signing.js";
+import { PUBLIC_URL, STATUS_LIST_ISSUER } from "./status.js";
+
+export const OFFLINE_BUNDLE_TTL_SECONDS = Number(process.env.OFFLINE_BUNDLE_TTL_SECONDS || 86400);
+const MAX_SECONDS = Number(process.env.OFFLINE_BUNDLE_MAX_SECONDS || 604800);
+const BUNDLE_TYP = "audittrail-offline-bundle+jwt";
+
+// Events after which the credential's recorded state is what it is now.
+const STATE_ACTIONS = ["Issue", "Accept", "Renew", "Revoke", "Expire", "Transfer"];
+
+// The hashing mirrors contracts/merkle.go: sha256(0x00 || event JSON) for
+// leaves, sha256(0x01 || left || right) for nodes, odd nodes carried up.
+const sha256 = (...parts) => crypto.createHash("sha256").update(Buffer.concat(parts)).digest();
+const leafHash = (json) => sha256(Buffer.from([0]), Buffer.from(json));
+const nodeHash = (l, r) => sha256(Buffer.from([1]), l, r);
+
+const merkleLevels = (leaves) => {
+  const levels = [leaves];
+  for (let cur = leaves; cur.length > 1; ) {
+    const next = [];
+    for (let i = 0; i < cur.length; i += 2) next.push(i + 1 === cur.length ? cur[i] : nodeHash(cur[i], cur[i + 1]));
+    levels.push(next);
+    cur = next;
+  }
+  return levels;
+};
+
+const merkleProof = (levels, idx) => {
+  const proof = [];
+  for (const level of levels.slice(0, -1)) {
+    if (idx % 2 === 1) proof.push({ hash: level[idx - 1].toString("hex"), position: "left" });
+    else if (idx + 1 < level.length) proof.push({ hash: level[idx + 1].toString("hex"), position: "right" });
+    idx = Math.floor(idx / 2);
+  }
+  return proof;
+};
+
+// bundleValidity parses the requested validity in seconds, defaulting to
+// OFFLINE_BUNDLE_TTL_SECONDS.
+export const bundleValidity = (validForSeconds) => {
+  if (validForSeconds === undefined) return OFFLINE_BUNDLE_TTL_SECONDS;
+  const n = Number(validForSeconds);
+  if (!Number.isInteger(n) || n < 1 || n > MAX_SECONDS) {
+    throw new Error(`validForSeconds must be an integer between 1 and ${MAX_SECONDS}`);
+  }
+  return n;
+};
+
+// offlineBundle builds and signs the bundle for cred from the audit log
+// events, valid for seconds from now but not past the credential's expiry.
+export const offlineBundle = (cred, events, seconds) => {
+  const now = Date.now();
+  let until = now + seconds * 1000;
+  if (cred.expiresAt) until = Math.min(until, Date.parse(cred.expiresAt));
+
+  const trail = events.filter((e) => belongsTo(e, cred.holderDid));
+  const idx = trail.findLastIndex((e) => e.credId === cred.credId && STATE_ACTIONS.includes(e.action));
+  if (idx < 0) throw new Error("No state event recorded for credential");
+  const eventJson = trail.map((e) => canonicalJson(e));
+  const levels = merkleLevels(eventJson.map(leafHash));
+
+  const bundle = {
+    bundleId: crypto.randomUUID(),
+    validFrom: new Date(now).toISOString(),
+    validUntil: new Date(until).toISOString(),
+    credential: cred,
+    inclusion: {
+      eventJson: eventJson[idx],
+      leaf: levels[0][idx].toString("hex"),
+      proof: merkleProof(levels, idx),
+      checkpoint: {
+        checkpointId: crypto.randomUUID(),
+        holderDid: cred.holderDid,
+        root: levels.at(-1)[0].toString("hex"),
+        eventCount: trail.length,
+        cutoffNanos: now * 1e6,
+        txId: "",
+        createdAt: new Date(now).toISOString(),
+      },
+    },
+    keys: {
+      gateway: { kid: keyId, jwksUri: `${PUBLIC_URL}/.well-known/jwks.json`, jwks: jwks() },
+      issuer: {
+        id: cred.issuerId,
+        ...(cred.issuerId.startsWith("did:") && {
+          resolution: `${PUBLIC_URL}/1.0/identifiers/${encodeURIComponent(cred.issuerId)}`,
+        }),
+      },
+      statusListIssuer: { id: STATUS_LIST_ISSUER, verificationMethod: `${STATUS_LIST_ISSUER}#${keyId}` },
+    },
+  };
+  return { bundle, jws: signJws(bundle, { typ: BUNDLE_TYP }) };
+};
@@ -0,0 +1,165 @@
+// Synthetic monitoring. With CANARY_INTERVAL_SECONDS set the gateway runs a
+// canary against its own public routes on a timer: issue a fresh canary
+// credential, verify it, revoke it, and verify again expecting the
+// revocation. Each step goes through authorization and the ledger like any
+// caller's, so a stalled endorsement or ordering step shows up here before
+// users report it. The last check catches a revoke that was acknowledged but
+// not yet visible to verifiers.
+//
+// Canary credentials live in a monitoring namespace: their IDs are
+// CANARY_NAMESPACE-<time>-<random> and they are held by CANARY_HOLDER_DID, a
+// holder no one else uses, so they never appear in a real holder's trail.
+// Exports and access reviews over every holder do include them.
+//
+// Results are exported in Prometheus text format at GET /metrics:
+//
+//   audittrail_canary_runs_total{outcome}             success | failure
+//   audittrail_canary_step_failures_total{step}       issue | verify | revoke | verify_revoked
+//   audittrail_canary_step_duration_seconds{step}     histogram of successful steps
+//   audittrail_canary_last_success_timestamp_seconds  0 until a run succeeds
+//   audittrail_canary_up                              1 if the last run succeeded
+//
+// Configuration:
+//   CANARY_INTERVAL_SECONDS=0          0 disables the canary
+//   CANARY_TARGET_URL=http://127.0.0.1:$PORT
+//   CANARY_API_KEY=...                 needs cred:issue, cred:verify and
+//                                      cred:revoke when authorization is on
+//   CANARY_NAMESPACE=canary
+//   CANARY_HOLDER_DID=did:web:<PUBLIC_URL host>:canary
+//   CANARY_TIMEOUT_SECONDS=10          per step
+
+import crypto from "node:crypto";
+
+const PORT = process.env.PORT || 3000;
+const PUBLIC_URL = process.env.PUBLIC_URL || `http://localhost:${PORT}`;
+const INTERVAL_SECONDS = Number(process.env.CANARY_INTERVAL_SECONDS || 0);
+const TARGET_URL = process.env.CANARY_TARGET_URL || `http://127.0.0.1:${PORT}`;
+const API_KEY = process.env.CANARY_API_KEY || "";
+export const CANARY_NAMESPACE = process.env.CANARY_NAMESPACE || "canary";
+const HOLDER_DID =
+  process.env.CANARY_HOLDER_DID || `did:web:${encodeURIComponent(new URL(PUBLIC_URL).host)}:${CANARY_NAMESPACE}`;
+const TIMEOUT_SECONDS = Number(process.env.CANARY_TIMEOUT_SECONDS || 10);
+
+const STEPS = ["issue", "verify", "revoke", "verify_revoked"];
+const BUCKETS = [0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10];
+const ACTOR = `${CANARY_NAMESPACE}-monitor`;
+
+const metrics = {
+  runs: { success: 0, failure: 0 },
+  failures: Object.fromEntries(STEPS.map((s) => [s, 0])),
+  durations: Object.fromEntries(STEPS.map((s) => [s, { buckets: BUCKETS.map(() => 0), sum: 0, count: 0 }])),
+  lastSuccess: 0,
+  up: 0,
+};
+
+const observe = (step, seconds) => {
+  const h = metrics.durations[step];
+  BUCKETS.forEach((le, i) => {
+    if (seconds <= le) h.buckets[i]++;
+  });
+  h.sum += seconds;
+  h.count++;
+};
+
+const call = async (path, body) => {
+  const res = await fetch(`${TARGET_URL}/v1${path}`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json", ...(API_KEY && { "X-API-Key": API_KEY }) },
+    body: JSON.stringify(body),
+    signal: AbortSignal.timeout(TIMEOUT_SECONDS * 1000),
+  });
+  const out = await res.json().catch(() => ({}));
+  if (!res.ok || !out.ok) throw new Error(out.error || `HTTP ${res.status}`);
+  return out;
+};
+
+const step = async (name, fn) => {
+  const started = performance.now();
+  try {
+    await fn();
+  } catch (err) {
+    metrics.failures[name]++;
+    err.step = name;
+    throw err;
+  }
+  observe(name, (performance.now() - started) / 1000);
+};
+
+// runCanary makes one pass and records it; it never throws.
+export const runCanary = async () => {
+  const credId = `${CANARY_NAMESPACE}-${Date.now()}-${crypto.randomBytes(4).toString("hex")}`;
+  try {
+    await step("issue", () =>
+      call("/issue", {
+        credId,
+        holderDid: HOLDER_DID,
+        credType: "CanaryCredential",
+        hashedData: crypto.createHash("sha256").update(credId).digest("hex"),
+        issuerId: ACTOR,
+      }),
+    );
+    await step("verify", async () => {
+      const { result } = await call("/verify", { credId, verifierId: ACTOR });
+      if (!result.isActive) throw new Error("fresh canary credential is not active");
+    });
+    await step("revoke", () => call("/revoke", { credId, reason: "canary run complete", revokerId: ACTOR }));
+    await step("verify_revoked", async () => {
+      const { result } = await call("/verify", { credId, verifierId: ACTOR });
+      if (result.isActive) throw new Error("revoked canary credential still verifies as active");
+    });
+    metrics.runs.success++;
+    metrics.lastSuccess = Date.now() / 1000;
+    metrics.up = 1;
+  } catch (err) {
+    metrics.runs.failure++;
+    metrics.up = 0;
+    const failure = { at: new Date().toISOString(), type: "canary", credId, step: err.step, error: err.message };
+    console.log(JSON.stringify(failure));
+  }
+};
+
+// startCanary schedules runs when CANARY_INTERVAL_SECONDS is set.
+export const startCanary = () => {
+  if (!INTERVAL_SECONDS) return;
+  let running = false;
+  const tick = async () => {
+    if (running) return; // a slow run is itself the signal; do not pile up
+    running = true;
+    await runCanary();
+    running = false;
+  };
+  tick();
+  setInterval(tick, INTERVAL_SECONDS * 1000).unref();
+};
+
+// canaryMetrics renders the metrics in Prometheus text format 0.0.4.
+export const canaryMetrics = () => {
+  const lines = [
+    "# HELP audittrail_canary_runs_total Canary runs by outcome.",
+    "# TYPE audittrail_canary_runs_total counter",
+    ...Object.entries(metrics.runs).map(([o, n]) => `audittrail_canary_runs_total{outcome="${o}"} ${n}`),
+    "# HELP audittrail_canary_step_failures_total Canary runs that failed at each step.",
+    "# TYPE audittrail_canary_step_failures_total counter",
+    ...STEPS.map((s) => `audittrail_canary_step_failures_total{step="${s}"} ${metrics.failures[s]}`),
+    "# HELP audittrail_canary_step_duration_seconds Latency of successful canary steps.",
+    "# TYPE audittrail_canary_step_duration_seconds histogram",
+  ];
+  for (const s of STEPS) {
+    const h = metrics.durations[s];
+    BUCKETS.forEach((le, i) => {
+      lines.push(`audittrail_canary_step_duration_seconds_bucket{step="${s}",le="${le}"} ${h.buckets[i]}`);
+    });
+    lines.push(`audittrail_canary_step_duration_seconds_bucket{step="${s}",le="+Inf"} ${h.count}`);
+    lines.push(`audittrail_canary_step_duration_seconds_sum{step="${s}"} ${h.sum}`);
+    lines.push(`audittrail_canary_step_duration_seconds_count{step="${s}"} ${h.count}`);
+  }
+  lines.push(
+    "# HELP audittrail_canary_last_success_timestamp_seconds When the last canary run succeeded.",
+    "# TYPE audittrail_canary_last_success_timestamp_seconds gauge",
+    `audittrail_canary_last_success_timestamp_seconds ${metrics.lastSuccess}`,
+    "# HELP audittrail_canary_up Whether the last canary run succeeded.",
+    "# TYPE audittrail_canary_up gauge",
+    `audittrail_canary_up ${metrics.up}`,
+  );
+  return lines.join("\n") + "\n";
+};
@@ -0,0 +1,373 @@
+// Deployment profiles. Instead of a couple of dozen environment variables
+// set per deployment, the gateway can take one profile file, YAML or JSON,
+// named by GATEWAY_CONFIG:
+//
+//   server:
+//     port: 3000
+//     publicUrl: https://audit.example.com
+//   fabric:
+//     mspId: Org1MSP
+//     channel: audittrail
+//     chaincode: audittrail
+//     peers:
+//       - endpoint: peer0.org1.example.com:7051
+//         hostnameOverride: peer0.org1.example.com
+//     tls:
+//       caCertPath: /etc/audittrail/tls/ca.pem
+//       clientCertPath: /etc/audittrail/tls/client.pem
+//       clientKeyPath: /etc/audittrail/tls/client-key.pem
+//     wallet:
+//       path: /var/lib/audittrail/wallet
+//       identity: gateway
+//   cache:
+//     didCacheTtlSeconds: 300
+//   sinks:
+//     kafkaRestUrl: http://kafka-rest:8082
+//
+// SETTINGS below is the full schema; each setting still has the environment
+// variable it replaces, and a variable that is set wins over the profile,
+// so existing deployments keep working and one value can be overridden
+// without editing the file. Secrets (API_KEYS, JWT_SECRET, signing and
+// pseudonym keys) are not part of the schema and stay in the environment.
+//
+// The profile is validated at startup, and the gateway refuses to start on
+// an invalid one. It and the TLS files it names are then polled, and a
+// change is validated and applied without a restart for the settings
+// marked reload: peers, TLS material and the endpoints of sinks and
+// resolvers, so certificates and endpoints rotate without dropping
+// traffic. A change to any other setting is logged as needing a restart;
+// an invalid change is logged and ignored. GET /v1/admin/config shows the
+// active values and where each came from.
+//
+// The parser takes the YAML subset profiles need: block mappings and
+// sequences, plain and quoted scalars, comments, and JSON-style flow
+// collections. Anchors, tags and multi-line scalars are not supported.
+//
+//   GATEWAY_CONFIG=/etc/audittrail/gateway.yaml
+//   CONFIG_POLL_SECONDS=5
+
+import fs from "node:fs";
+
+const CONFIG_FILE = process.env.GATEWAY_CONFIG || "";
+const POLL_MS = Number(process.env.CONFIG_POLL_SECONDS || 5) * 1000;
+
+// [profile path, environment variable, type, reloads without restart]
+const SETTINGS = [
+  ["server.port", "PORT", "int"],
+  ["server.publicUrl", "PUBLIC_URL", "url"],
+  ["fabric.mspId", "ORG_MSP_ID", "string"],
+  ["fabric.channel", "FABRIC_CHANNEL", "string"],
+  ["fabric.chaincode", "FABRIC_CHAINCODE", "string"],
+  ["fabric.peers", "FABRIC_PEERS", "peers", true],
+  ["fabric.tls.caCertPath", "FABRIC_TLS_CA_CERT", "file", true],
+  ["fabric.tls.clientCertPath", "FABRIC_TLS_CLIENT_CERT", "file", true],
+  ["fabric.tls.clientKeyPath", "FABRIC_TLS_CLIENT_KEY", "file", true],
+  ["fabric.wallet.path", "FABRIC_WALLET_PATH", "dir"],
+  ["fabric.wallet.identity", "FABRIC_IDENTITY", "string"],
+  ["cache.didCacheTtlSeconds", "DID_CACHE_TTL_SECONDS", "int"],
+  ["cache.didCacheMax", "DID_CACHE_MAX", "int"],
+  ["cache.statusListTtlSeconds", "STATUS_LIST_TTL_SECONDS", "int"],
+  ["cache.recheckAfterSeconds", "RECHECK_AFTER_SECONDS", "int"],
+  ["sinks.kafkaRestUrl", "KAFKA_REST_URL", "url", true],
+  ["sinks.webhookAllowHttp", "WEBHOOK_ALLOW_HTTP", "bool"],
+  ["sinks.dispatchMaxAttempts", "DISPATCH_MAX_ATTEMPTS", "int"],
+  ["sinks.apnsUrl", "APNS_URL", "url", true],
+  ["sinks.apnsTopic", "APNS_TOPIC", "string"],
+  ["sinks.pushDeviceFile", "PUSH_DEVICE_FILE", "string"],
+  ["sinks.emailTransport", "EMAIL_TRANSPORT", "string"],
+  ["sinks.emailFrom", "EMAIL_FROM", "string"],
+  ["sinks.sesUrl", "SES_URL", "url", true],
+  ["sinks.smsFrom", "SMS_FROM", "string"],
+  ["sinks.twilioUrl", "TWILIO_URL", "url", true],
+  ["sinks.notifyTemplatesFile", "NOTIFY_TEMPLATES_FILE", "file"],
+  ["sinks.notifyDefaultLocale", "NOTIFY_DEFAULT_LOCALE", "string"],
+  ["reports.digestHour", "DIGEST_HOUR", "int"],
+  ["reports.digestLinkUrl", "DIGEST_LINK_URL", "url"],
+  ["reports.digestStateFile", "DIGEST_STATE_FILE", "string"],
+  ["resolvers.universalResolverUrl", "UNIVERSAL_RESOLVER_URL", "url", true],
+  ["resolvers.ebsiResolverUrl", "EBSI_RESOLVER_URL", "url", true],
+  ["integrations.opaUrl", "OPA_URL", "url"],
+  ["integrations.identityProofingUrl", "IDENTITY_PROOFING_URL", "url", true],
+  ["integrations.documentStoreUrl", "DOCUMENT_STORE_URL", "url", true],
+  ["integrations.walletAttestationVerifierUrl", "WALLET_ATTESTATION_VERIFIER_URL", "url"],
+].map(([path, env, type, reload = false]) => ({ path, env, type, reload }));
+
+// ===== YAML subset =====
+
+const scalar = (raw) => {
+  const s = raw.trim();
+  if (s === "" || s === "~" || s === "null") return null;
+  if (s === "true" || s === "false") return s === "true";
+  if (/^-?\d+(\.\d+)?$/.test(s)) return Number(s);
+  if (s.startsWith("'") && s.endsWith("'") && s.length > 1) return s.slice(1, -1).replace(/''/g, "'");
+  if (/^["[{]/.test(s)) return JSON.parse(s);
+  return s;
+};
+
+// stripComment drops a # comment that is not inside quotes.
+const stripComment = (line) => {
+  let quote = null;
+  for (let i = 0; i < line.length; i++) {
+    const c = line[i];
+    if (quote) {
+      if (c === quote) quote = null;
+    } else if (c === '"' || c === "'") {
+      quote = c;
+    } else if (c === "#" && (i === 0 || /\s/.test(line[i - 1]))) {
+      return line.slice(0, i);
+    }
+  }
+  return line;
+};
+
+export const parseYaml = (text) => {
+  const lines = text
+    .split(/\r?\n/)
+    .map((l, i) => ({ no: i + 1, raw: stripComment(l).trimEnd() }))
+    .filter((l) => l.raw.trim() && l.raw.trim() !== "---")
+    .map((l) => {
+      if (/^\s*\t/.test(l.raw)) throw new Error(`line ${l.no}: tabs are not allowed for indentation`);
+      return { no: l.no, indent: l.raw.length - l.raw.trimStart().length, text: l.raw.trim() };
+    });
+  let i = 0;
+  const isItem = (l) => l.text === "-" || l.text.startsWith("- ");
+  const keyValue = (l) => {
+    const m = /^("[^"]*"|'[^']*'|[^:]+?)\s*:(?:\s+(.*))?$/.exec(l.text);
+    if (!m) throw new Error(`line ${l.no}: expected "key: value"`);
+    return [scalar(m[1]), m[2] ?? ""];
+  };
+  const block = (indent) => (isItem(lines[i]) ? sequence(indent) : mapping(indent));
+  const nested = (indent, l) => {
+    const next = lines[i];
+    if (next && (next.indent > indent || (next.indent === indent && isItem(next) && !isItem(l)))) {
+      return block(next.indent);
+    }
+    return null;
+  };
+  const mapping = (indent) => {
+    const out = {};
+    while (i < lines.length && lines[i].indent === indent && !isItem(lines[i])) {
+      const l = lines[i++];
+      const [key, rest] = keyValue(l);
+      if (key in out) throw new Error(`line ${l.no}: duplicate key ${key}`);
+      out[key] = rest === "" ? nested(indent, l) : scalar(rest);
+    }
+    if (i < lines.length && lines[i].indent > indent) throw new Error(`line ${lines[i].no}: unexpected indentation`);
+    return out;
+  };
+  const sequence = (indent) => {
+    const out = [];
+    while (i < lines.length && lines[i].indent === indent && isItem(lines[i])) {
+      const l = lines[i];
+      const rest = l.text.slice(1).trimStart();
+      if (rest === "") {
+        i++;
+        out.push(nested(indent, l));
+      } else if (/^("[^"]*"|'[^']*'|[^:"'[{]+?)\s*:(\s|$)/.test(rest)) {
+        // "- key: value" opens a mapping indented past the dash.
+        lines[i] = { no: l.no, indent: indent + l.text.length - rest.length, text: rest };
+        out.push(mapping(lines[i].indent));
+      } else {
+        i++;
+        out.push(scalar(rest));
+      }
+    }
+    return out;
+  };
+  if (!lines.length) return {};
+  const doc = block(lines[0].indent);
+  if (i < lines.length) throw new Error(`line ${lines[i].no}: unexpected indentation`);
+  return doc;
+};
+
+// ===== Validation =====
+
+const lookup = (obj, path) => path.split(".").reduce((o, k) => (o == null ? undefined : o[k]), obj);
+
+const fromEnv = (type, raw) => {
+  if (type === "peers") return JSON.parse(raw);
+  if (type === "int") return Number(raw);
+  if (type === "bool") return raw === "true";
+  return raw;
+};
+
+const check = (setting, v) => {
+  const { path, type } = setting;
+  switch (type) {
+    case "int":
+      if (!Number.isInteger(v) || v < 0) throw new Error(`${path} must be a non-negative integer`);
+      break;
+    case "bool":
+      if (typeof v !== "boolean") throw new Error(`${path} must be true or false`);
+      break;
+    case "url":
+      if (typeof v !== "string" || !/^https?:$/.test(URL.canParse(v) ? new URL(v).protocol : "")) {
+        throw new Error(`${path} must be an http(s) URL`);
+      }
+      break;
+    case "file":
+    case "dir": {
+      if (typeof v !== "string") throw new Error(`${path} must be a path`);
+      const stat = fs.statSync(v, { throwIfNoEntry: false });
+      if (!stat || (type === "dir" ? !stat.isDirectory() : !stat.isFile())) {
+        throw new Error(`${path}: no ${type === "dir" ? "directory" : "file"} at ${v}`);
+      }
+      break;
+    }
+    case "peers":
+      if (!Array.isArray(v) || !v.length) throw new Error(`${path} must list at least one peer`);
+      for (const p of v) {
+        if (typeof p?.endpoint !== "string" || !/^[^\s:/]+:\d+$/.test(p.endpoint)) {
+          throw new Error(`${path}: each peer needs an endpoint of the form host:port`);
+        }
+      }
+      break;
+    default:
+      if (typeof v !== "string" || !v) throw new Error(`${path} must be a non-empty string`);
+  }
+};
+
+// Environment variables as set before the profile was applied; empty counts
+// as unset.
+const env = Object.fromEntries(
+  SETTINGS.filter((s) => process.env[s.env]).map((s) => [s.env, process.env[s.env]]),
+);
+
+// resolve validates profile (a parsed file, or {}) merged under the
+// environment and returns path -> { value, source }.
+const resolve = (profile) => {
+  const known = new Set(SETTINGS.map((s) => s.path));
+  const walk = (obj, prefix) => {
+    for (const [k, v] of Object.entries(obj || {})) {
+      const path = prefix ? `${prefix}.${k}` : k;
+      if (known.has(path)) continue;
+      if (v && typeof v === "object" && !Array.isArray(v)) walk(v, path);
+      else throw new Error(`unknown setting ${path}`);
+    }
+  };
+  walk(profile, "");
+
+  const out = {};
+  for (const s of SETTINGS) {
+    let value;
+    let source;
+    if (env[s.env] !== undefined) {
+      value = fromEnv(s.type, env[s.env]);
+      source = `env ${s.env}`;
+    } else if (lookup(profile, s.path) != null) {
+      value = lookup(profile, s.path);
+      source = "profile";
+    } else {
+      continue;
+    }
+    check(s, value);
+    out[s.path] = { value, source };
+  }
+  const fabric = ["fabric.peers", "fabric.channel", "fabric.chaincode"].filter((p) => out[p]);
+  if (fabric.length && fabric.length < 3) throw new Error("fabric needs peers, channel and chaincode together");
+  return out;
+};
+
+const readProfile = () => {
+  const text = fs.readFileSync(CONFIG_FILE, "utf8");
+  return /\.json$/i.test(CONFIG_FILE) ? JSON.parse(text) : parseYaml(text);
+};
+
+const readTls = (values) =>
+  Object.fromEntries(
+    SETTINGS.filter((s) => s.path.startsWith("fabric.tls.") && values[s.path])
+      .map((s) => [s.path.split(".").pop().replace(/Path$/, ""), fs.readFileSync(values[s.path].value, "utf8")]),
+  );
+
+let values = resolve(CONFIG_FILE ? readProfile() : {});
+let tls = readTls(values);
+let loadedAt = new Date().toISOString();
+const listeners = [];
+const pendingRestart = new Map(); // path -> JSON of the value waiting for a restart
+
+// Settings are handed to the modules that still read their environment
+// variable at startup.
+for (const s of SETTINGS) {
+  const v = values[s.path];
+  if (v && env[s.env] === undefined) {
+    process.env[s.env] = s.type === "peers" ? JSON.stringify(v.value) : String(v.value);
+  }
+}
+
+// setting returns the current value at path, or fallback. Reloadable
+// settings must be read through it at the point of use.
+export const setting = (path, fallback) => values[path]?.value ?? fallback;
+
+// fabricTls returns the current TLS material: { caCert, clientCert,
+// clientKey } PEMs for the settings given.
+export const fabricTls = () => tls;
+
+// onConfigReload registers fn(changedPaths) to run after a reload, e.g. to
+// reconnect to peers with new endpoints or certificates.
+export const onConfigReload = (fn) => listeners.push(fn);
+
+// configView is the active configuration for GET /v1/admin/config.
+export const configView = () => ({
+  file: CONFIG_FILE || null,
+  loadedAt,
+  settings: Object.fromEntries(
+    SETTINGS.filter((s) => values[s.path]).map((s) => [
+      s.path,
+      { ...values[s.path], env: s.env, reloadable: s.reload },
+    ]),
+  ),
+});
+
+const reload = () => {
+  let next;
+  let nextTls;
+  try {
+    next = resolve(readProfile());
+    nextTls = readTls(next);
+  } catch (err) {
+    console.error(`${CONFIG_FILE}: not reloaded: ${err.message}`);
+    return;
+  }
+  const same = (a, b) => JSON.stringify(a?.value) === JSON.stringify(b?.value);
+  const changed = SETTINGS.filter((s) => !same(values[s.path], next[s.path]));
+  const tlsChanged = JSON.stringify(tls) !== JSON.stringify(nextTls);
+  if (!changed.length && !tlsChanged) return;
+
+  for (const s of changed.filter((s) => !s.reload)) {
+    const pending = JSON.stringify(next[s.path]?.value ?? null);
+    if (pendingRestart.get(s.path) !== pending) {
+      console.error(`${CONFIG_FILE}: ${s.path} changed; it takes effect on restart`);
+      pendingRestart.set(s.path, pending);
+    }
+    next[s.path] = values[s.path];
+    if (!next[s.path]) delete next[s.path];
+  }
+  values = next;
+  tls = nextTls;
+  loadedAt = new Date().toISOString();
+  const applied = changed.filter((s) => s.reload).map((s) => s.path);
+  if (tlsChanged) applied.push("fabric.tls");
+  const entry = { at: loadedAt, type: "config-reload", file: CONFIG_FILE, applied: [...new Set(applied)] };
+  console.log(JSON.stringify(entry));
+  for (const fn of listeners) fn(applied);
+};
+
+// watchConfig polls the profile and the TLS files for changes. Polling
+// also sees the symlink swaps Kubernetes uses to update mounted secrets.
+export const watchConfig = () => {
+  if (!CONFIG_FILE) return;
+  const watched = new Set();
+  const watch = () => {
+    const tlsFiles = SETTINGS.filter((s) => s.type === "file" && values[s.path]).map((s) => values[s.path].value);
+    const files = [CONFIG_FILE, ...tlsFiles];
+    for (const f of files) {
+      if (watched.has(f)) continue;
+      watched.add(f);
+      fs.watchFile(f, { interval: POLL_MS }, () => {
+        reload();
+        watch();
+      });
+    }
+  };
+  watch();
+};
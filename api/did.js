@@ -0,0 +1,93 @@
+// Holder DID validation. Mirrors the chaincode's didmethod.go so bad DIDs are
+// rejected before a transaction is submitted, and adds resolution checks the
+// chaincode cannot do (it has no network access).
+//
+//   DID_METHODS=key,web     allow-list; empty allows any syntactically valid DID
+//   DID_RESOLVE=true        also require the DID to resolve (see resolver.js);
+//                           methods with no configured resolver are skipped
+
+import { base58Decode, resolveDid } from "./resolver.js";
+
+const DID_METHODS = (process.env.DID_METHODS || "").split(",").map((m) => m.trim()).filter(Boolean);
+const DID_RESOLVE = process.env.DID_RESOLVE === "true";
+
+const DID_SYNTAX = /^did:([a-z0-9]+):((?:[A-Za-z0-9._-]|%[0-9A-Fa-f]{2}|:)*(?:[A-Za-z0-9._-]|%[0-9A-Fa-f]{2}))$/;
+const WEB_SEGMENT = /^(?:[A-Za-z0-9._-]|%[0-9A-Fa-f]{2})+$/;
+const INDY_NAMESPACE = /^[a-z0-9_-]+$/;
+
+// Method-specific syntax checks; each throws on an invalid identifier.
+const syntax = {
+  key(id) {
+    if (!id.startsWith("z")) throw new Error("did:key must use base58btc multibase");
+    if (base58Decode(id.slice(1)).length < 3) throw new Error("did:key is too short");
+  },
+  web(id) {
+    id.split(":").forEach((seg, i) => {
+      if (!WEB_SEGMENT.test(seg)) throw new Error(`did:web segment ${i} is empty or malformed`);
+    });
+    const host = id.split(":")[0].split("%3A")[0];
+    if (!host.includes(".") && host !== "localhost") throw new Error(`did:web host "${host}" is not a domain name`);
+  },
+  ebsi(id) {
+    if (!id.startsWith("z")) throw new Error("did:ebsi must use base58btc multibase");
+    const raw = base58Decode(id.slice(1));
+    if (raw.length !== 17 || raw[0] !== 0x01) throw new Error("did:ebsi must encode version 1 and 16 bytes");
+  },
+  indy(id) {
+    const parts = id.split(":");
+    if (parts.length < 2 || parts.length > 3) {
+      throw new Error("did:indy must be <namespace>[:<sub-namespace>]:<identifier>");
+    }
+    for (const ns of parts.slice(0, -1)) {
+      if (!INDY_NAMESPACE.test(ns)) throw new Error(`did:indy namespace "${ns}" is malformed`);
+    }
+    if (base58Decode(parts.at(-1)).length !== 16) throw new Error("did:indy identifier must encode 16 bytes");
+  },
+};
+
+// validateHolderDid throws if did is malformed, uses a method this deployment
+// does not allow, or (with DID_RESOLVE) fails to resolve.
+export const validateHolderDid = async (did) => {
+  const m = DID_SYNTAX.exec(did);
+  if (!m) throw new Error(`holder DID "${did}" is not a valid DID`);
+  const [, method, id] = m;
+  if (DID_METHODS.length && !DID_METHODS.includes(method)) {
+    throw new Error(`DID method ${method} is not allowed on this deployment`);
+  }
+  try {
+    syntax[method]?.(id);
+  } catch (err) {
+    throw new Error(`holder DID "${did}": ${err.message}`);
+  }
+  if (DID_RESOLVE) {
+    await resolveDid(did).catch((err) => {
+      if (err.error !== "methodNotSupported") throw err;
+    });
+  }
+};
+
+// Holder types, as the chaincode's holdertype.go: Organization holders (legal
+// entities) carry an ISO 17442 LEI and need a DID method the organization
+// controls, so not did:key.
+export const HOLDER_TYPES = ["Individual", "Organization"];
+const ORG_DID_METHODS = ["web", "ebsi", "indy"];
+
+const validLei = (lei) => {
+  if (!/^[A-Z0-9]{18}[0-9]{2}$/.test(lei)) return false;
+  const digits = [...lei].map((c) => parseInt(c, 36)).join("");
+  return BigInt(digits) % 97n === 1n;
+};
+
+// validateHolderType throws if the holder type's rules reject the holder.
+export const validateHolderType = (holderType, did, legalEntityId) => {
+  if (!HOLDER_TYPES.includes(holderType)) throw new Error(`holderType must be ${HOLDER_TYPES.join(" or ")}`);
+  if (holderType === "Individual") {
+    if (legalEntityId) throw new Error("legalEntityId is only for Organization holders");
+    return;
+  }
+  if (!validLei(legalEntityId || "")) throw new Error(`legalEntityId "${legalEntityId}" is not a valid LEI`);
+  const method = did.split(":")[1];
+  if (!ORG_DID_METHODS.includes(method)) {
+    throw new Error(`DID method ${method} is not allowed for Organization holders`);
+  }
+};
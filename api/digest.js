@@ -0,0 +1,304 @@
+// Audit digests for issuer orgs. Every issuer listed in DIGEST_ISSUERS gets
+// an email, daily or weekly, summarizing what happened to its credentials
+// over the last complete period in its time zone:
+//
+//   issued / renewed   Issue and Renew events by any actor
+//   verified           successful verifications, break-glass included
+//   revoked            Revoke events
+//   anomalies          events the issuer should look at, of the kinds
+//
+//     VerificationDenied  a verification that was denied or failed
+//     BreakGlassVerify    an emergency verification, reviewed separately
+//     RevokedByOther      a revocation by someone other than the issuer
+//     RevokedVerified     a verification of a credential already revoked
+//
+// Counts are broken down by credential type. The email links to drill-down
+// views: the full digest with every anomaly (GET /v1/digests/:issuerId, the
+// same report over any window), the verification heatmap of the period, and
+// per anomaly the verifier's heatmap, the break-glass review queue or the
+// revocation's propagation report. Links point at DIGEST_LINK_URL, so a
+// console serving the same paths can take them.
+//
+// Digests are sent at DIGEST_HOUR local time once the period has ended
+// (Monday for weekly digests), through the email transport of notify.js.
+// The period last sent to each issuer is kept in DIGEST_STATE_FILE, so a
+// restart neither repeats nor skips one.
+//
+//   DIGEST_ISSUERS='{"issuer-1": {"to": ["audit@issuer-1.example"], "frequency": "weekly",
+//                    "timeZone": "Europe/Berlin"}}'   frequency daily | weekly, timeZone defaults to UTC
+//   DIGEST_HOUR=7
+//   DIGEST_LINK_URL=$PUBLIC_URL
+//   DIGEST_STATE_FILE=./digests.json   unset keeps the state in memory only
+
+import fs from "node:fs";
+import { CHANNELS, sendEmail } from "./notify.js";
+import { inRange, resolveRange, validateTimeZone } from "./timerange.js";
+
+const PORT = process.env.PORT || 3000;
+const PUBLIC_URL = process.env.PUBLIC_URL || `http://localhost:${PORT}`;
+const LINK_URL = (process.env.DIGEST_LINK_URL || PUBLIC_URL).replace(/\/$/, "");
+const DIGEST_HOUR = Number(process.env.DIGEST_HOUR || 7);
+const STATE_FILE = process.env.DIGEST_STATE_FILE || "";
+const MAX_ATTEMPTS = Number(process.env.DISPATCH_MAX_ATTEMPTS || 5);
+const TICK_MS = 60000;
+const LISTED_ANOMALIES = 20; // in the email; the linked digest has them all
+
+// The timerange.js range naming each frequency's last complete period.
+export const FREQUENCIES = { daily: "yesterday", weekly: "previous-week" };
+const VERIFY_ACTIONS = ["Verify", "VerifyAttribute", "VerifySummary", "BreakGlassVerify"];
+const EMAIL = /^[^\s@<>]+@[^\s@<>]+\.[^\s@<>]+$/;
+
+const ISSUERS = Object.fromEntries(
+  Object.entries(JSON.parse(process.env.DIGEST_ISSUERS || "{}")).map(([id, c]) => {
+    const { to = [], frequency = "daily", timeZone = "UTC" } = c;
+    if (!Array.isArray(to) || !to.length || to.some((a) => !EMAIL.test(a))) {
+      throw new Error(`digest issuer ${id}: to must list email addresses`);
+    }
+    if (!FREQUENCIES[frequency]) throw new Error(`digest issuer ${id}: frequency must be daily or weekly`);
+    validateTimeZone(timeZone);
+    return [id, { to, frequency, timeZone }];
+  }),
+);
+if (Object.keys(ISSUERS).length && !CHANNELS.email) {
+  throw new Error("DIGEST_ISSUERS needs an email transport; see notify.js");
+}
+if (!Number.isInteger(DIGEST_HOUR) || DIGEST_HOUR < 0 || DIGEST_HOUR > 23) {
+  throw new Error("DIGEST_HOUR must be an hour from 0 to 23");
+}
+
+const state = new Map(); // issuerId -> { periodTo, sentAt, delivered, failures }
+
+if (STATE_FILE && fs.existsSync(STATE_FILE)) {
+  for (const [id, s] of Object.entries(JSON.parse(fs.readFileSync(STATE_FILE, "utf8")))) state.set(id, s);
+}
+
+// persist rewrites the state file through a rename, as saga.js does.
+const persist = () => {
+  if (!STATE_FILE) return;
+  const tmp = `${STATE_FILE}.tmp`;
+  fs.writeFileSync(tmp, JSON.stringify(Object.fromEntries(state), null, 2));
+  fs.renameSync(tmp, STATE_FILE);
+};
+
+const link = (path, params) => {
+  const query = new URLSearchParams(Object.entries(params).filter(([, v]) => v));
+  return `${LINK_URL}${path}${query.size ? `?${query}` : ""}`;
+};
+
+// anomalyLink is the drill-down view for one anomaly.
+const anomalyLink = (a, window) => {
+  switch (a.kind) {
+    case "BreakGlassVerify":
+      return link("/v1/reviews/break-glass", {});
+    case "RevokedByOther":
+      return link(`/v1/revocations/${encodeURIComponent(a.credId)}/propagation`, {});
+    default:
+      return link("/v1/analytics/heatmap", {
+        verifierId: a.actorId,
+        from: window.from,
+        to: window.to,
+        tz: window.timeZone,
+      });
+  }
+};
+
+const anomalyOf = (e, cred, revokedAt) => {
+  if (e.action === "BreakGlassVerify") return "BreakGlassVerify";
+  if (VERIFY_ACTIONS.includes(e.action) && e.outcome !== "Success") return "VerificationDenied";
+  if (VERIFY_ACTIONS.includes(e.action) && revokedAt && revokedAt <= e.occurredAt) return "RevokedVerified";
+  if (e.action === "Revoke" && e.outcome === "Success" && e.actorId !== cred.issuerId) return "RevokedByOther";
+  return null;
+};
+
+// buildDigest summarizes events on issuerId's credentials within window, a
+// resolved range with both bounds. credentials maps credId to credential.
+export const buildDigest = ({ events, credentials }, issuerId, window) => {
+  const revokedAt = new Map(); // credId -> first revocation, whenever it was
+  for (const e of events) {
+    if (e.action === "Revoke" && e.outcome === "Success" && !revokedAt.has(e.credId)) {
+      revokedAt.set(e.credId, e.occurredAt);
+    }
+  }
+
+  const totals = { issued: 0, renewed: 0, verified: 0, revoked: 0, anomalies: 0 };
+  const byType = new Map();
+  const anomalies = [];
+  for (const e of events) {
+    const cred = e.credId && credentials.get(e.credId);
+    if (cred?.issuerId !== issuerId || !inRange(window, e.occurredAt)) continue;
+    if (!byType.has(cred.credType)) byType.set(cred.credType, { issued: 0, renewed: 0, verified: 0, revoked: 0 });
+    const t = byType.get(cred.credType);
+    const count = (key) => {
+      totals[key]++;
+      t[key]++;
+    };
+    if (e.action === "Issue" && e.outcome === "Success") count("issued");
+    if (e.action === "Renew" && e.outcome === "Success") count("renewed");
+    if (VERIFY_ACTIONS.includes(e.action) && e.outcome === "Success") count("verified");
+    if (e.action === "Revoke" && e.outcome === "Success") count("revoked");
+
+    const kind = anomalyOf(e, cred, revokedAt.get(e.credId));
+    if (kind) {
+      const a = { kind, eventId: e.eventId, credId: e.credId, actorId: e.actorId, occurredAt: e.occurredAt };
+      anomalies.push({ ...a, link: anomalyLink(a, window) });
+    }
+  }
+  totals.anomalies = anomalies.length;
+
+  const range = { from: window.from, to: window.to, tz: window.timeZone };
+  return {
+    issuerId,
+    window,
+    generatedAt: new Date().toISOString(),
+    totals,
+    byCredType: [...byType.entries()]
+      .sort(([a], [b]) => (a < b ? -1 : 1))
+      .map(([credType, counts]) => ({ credType, ...counts })),
+    anomalies,
+    links: {
+      digest: link(`/v1/digests/${encodeURIComponent(issuerId)}`, range),
+      heatmap: link("/v1/analytics/heatmap", range),
+    },
+  };
+};
+
+// localTime formats an ISO instant as "YYYY-MM-DD HH:mm" in tz.
+const localTime = (iso, tz) => {
+  const p = Object.fromEntries(
+    new Intl.DateTimeFormat("en-US", {
+      timeZone: tz,
+      hourCycle: "h23",
+      year: "numeric",
+      month: "2-digit",
+      day: "2-digit",
+      hour: "2-digit",
+      minute: "2-digit",
+    })
+      .formatToParts(new Date(iso))
+      .map((x) => [x.type, x.value]),
+  );
+  return `${p.year}-${p.month}-${p.day} ${p.hour}:${p.minute}`;
+};
+
+// periodLabel names the local days a window covers; its end is exclusive.
+const periodLabel = ({ from, to, timeZone }) => {
+  const first = localTime(from, timeZone).slice(0, 10);
+  const last = localTime(new Date(Date.parse(to) - 1).toISOString(), timeZone).slice(0, 10);
+  return first === last ? first : `${first} to ${last}`;
+};
+
+// digestText renders digest as the plain-text email.
+export const digestText = (digest) => {
+  const { issuerId, window, totals } = digest;
+  const period = periodLabel(window);
+  const row = (label, n) => `  ${label.padEnd(10)} ${String(n).padStart(8)}`;
+  const lines = [
+    `Audit digest for ${issuerId}`,
+    `${period} (${window.timeZone})`,
+    "",
+    row("Issued", totals.issued),
+    row("Renewed", totals.renewed),
+    row("Verified", totals.verified),
+    row("Revoked", totals.revoked),
+    row("Anomalies", totals.anomalies),
+  ];
+  if (digest.byCredType.length) {
+    lines.push("", "By credential type");
+    for (const t of digest.byCredType) {
+      lines.push(`  ${t.credType}: ${t.issued} issued, ${t.renewed} renewed, ${t.verified} verified, ` +
+        `${t.revoked} revoked`);
+    }
+  }
+  if (digest.anomalies.length) {
+    lines.push("", "Anomalies");
+    for (const a of digest.anomalies.slice(0, LISTED_ANOMALIES)) {
+      lines.push(`  ${localTime(a.occurredAt, window.timeZone)}  ${a.kind}  ${a.credId} by ${a.actorId}`,
+        `    ${a.link}`);
+    }
+    const more = digest.anomalies.length - LISTED_ANOMALIES;
+    if (more > 0) lines.push(`  ... and ${more} more in the full digest`);
+  }
+  lines.push("", `Full digest: ${digest.links.digest}`, `Verification heatmap: ${digest.links.heatmap}`, "");
+  return {
+    subject: `Audit digest for ${issuerId}, ${period}: ${totals.anomalies} anomalies`,
+    text: lines.join("\n"),
+  };
+};
+
+const send = async (to, msg) => {
+  for (let attempt = 1; ; attempt++) {
+    let error;
+    try {
+      const res = await sendEmail(to, msg);
+      if (res.status === 200) return null;
+      error = `HTTP ${res.status}${res.reason ? ` ${res.reason}` : ""}`;
+      if (res.status < 500 && res.status !== 429) return error;
+    } catch (err) {
+      error = err.message;
+    }
+    if (attempt >= MAX_ATTEMPTS) return error;
+    await new Promise((resolve) => setTimeout(resolve, 2 ** attempt * 500));
+  }
+};
+
+const localHour = (ms, tz) =>
+  Number(new Intl.DateTimeFormat("en-US", { timeZone: tz, hourCycle: "h23", hour: "numeric" }).format(ms));
+
+// sendDue sends the digests whose period has ended and not been sent. A
+// period counts as sent once every recipient was tried; failures are kept
+// in the issuer's state rather than retried on later ticks.
+const sendDue = async (store, now = Date.now()) => {
+  for (const [issuerId, c] of Object.entries(ISSUERS)) {
+    const window = resolveRange({ range: FREQUENCIES[c.frequency], tz: c.timeZone }, now);
+    if (state.get(issuerId)?.periodTo === window.to || localHour(now, c.timeZone) < DIGEST_HOUR) continue;
+
+    const msg = digestText(buildDigest(store, issuerId, window));
+    const failures = [];
+    for (const to of c.to) {
+      const error = await send(to, msg);
+      if (error) failures.push({ to, error });
+    }
+    state.set(issuerId, {
+      periodTo: window.to,
+      sentAt: new Date().toISOString(),
+      delivered: c.to.length - failures.length,
+      failures,
+    });
+    persist();
+    if (failures.length) {
+      console.log(JSON.stringify({ at: new Date().toISOString(), type: "digest", issuerId, failures }));
+    }
+  }
+};
+
+// digestSchedule lists the configured issuers with the last period sent.
+export const digestSchedule = () =>
+  Object.entries(ISSUERS).map(([issuerId, c]) => ({
+    issuerId,
+    frequency: c.frequency,
+    timeZone: c.timeZone,
+    recipients: c.to.length,
+    last: state.get(issuerId) || null,
+  }));
+
+// digestPeriod is the window GET /v1/digests/:issuerId reports on when none
+// is asked for: the issuer's last complete period, else yesterday in UTC.
+export const digestPeriod = (issuerId) => {
+  const c = ISSUERS[issuerId];
+  return resolveRange({ range: FREQUENCIES[c?.frequency || "daily"], tz: c?.timeZone || "UTC" });
+};
+
+// startDigests checks every minute for digests due, over the events and
+// credentials in store.
+export const startDigests = (store) => {
+  if (!Object.keys(ISSUERS).length) return;
+  let running = false;
+  const tick = async () => {
+    if (running) return; // a slow mail server must not send a digest twice
+    running = true;
+    await sendDue(store).catch((err) => console.error(`digest: ${err.message}`));
+    running = false;
+  };
+  tick();
+  setInterval(tick, TICK_MS).unref();
+};
@@ -0,0 +1,187 @@
+// Event dispatch to consumers. Downstream systems register a subscription
+// with a filter and receive only the recorded events it matches, instead of
+// pulling the whole trail and filtering themselves. A filter lists allowed
+// values per field; an empty or missing list matches anything, and an event
+// must match every field:
+//
+//   credTypes   credential type of the event's credential
+//   actions     event action (Issue, Verify, Revoke, ...)
+//   issuerIds   issuer of the event's credential
+//   outcomes    Success | Failure | Denied
+//   categories  eventCategory (see taxonomy.js)
+//
+// Events without a credential (consent changes) carry no credential type or
+// issuer, so they only match filters that leave those fields open.
+//
+// Channels:
+//   webhook  target is an https URL; each event is POSTed as
+//            {subscriptionId, event} with X-AuditTrail-Signature:
+//            sha256=<hex HMAC of the body under the subscription's secret>,
+//            which is returned once, at creation
+//   kafka    target is a topic, produced to through the Kafka REST proxy at
+//            KAFKA_REST_URL (Confluent REST v2), keyed by credId
+//
+// Events on a credential also go to its holder: as push notifications to
+// registered devices (push.js) and as email or SMS to holder subscriptions
+// (notify.js).
+//
+// Deliveries are retried DISPATCH_MAX_ATTEMPTS times with exponential
+// backoff; the subscription keeps counts and its last error. Delivery is
+// best-effort and in-memory: events recorded while the gateway is down are
+// not replayed, so consumers that need every event reconcile through
+// GET /v1/audit.
+//
+//   KAFKA_REST_URL=http://kafka-rest:8082
+//   WEBHOOK_ALLOW_HTTP=false   allow plain http webhook targets (development)
+//   DISPATCH_MAX_ATTEMPTS=5
+
+import crypto from "node:crypto";
+import { setting } from "./config.js";
+import { notifySubscribers } from "./notify.js";
+import { notifyHolder } from "./push.js";
+
+// Reloadable; see config.js.
+const kafkaRestUrl = () => setting("sinks.kafkaRestUrl", "").replace(/\/$/, "");
+const WEBHOOK_ALLOW_HTTP = process.env.WEBHOOK_ALLOW_HTTP === "true";
+const MAX_ATTEMPTS = Number(process.env.DISPATCH_MAX_ATTEMPTS || 5);
+const TIMEOUT_MS = 10000;
+
+export const CHANNELS = ["webhook", "kafka"];
+const FILTER_FIELDS = ["credTypes", "actions", "issuerIds", "outcomes", "categories"];
+
+const subscriptions = new Map(); // subscriptionId -> subscription, secret included
+
+const view = ({ secret, ...sub }) => sub;
+
+// parseFilter validates a filter, returning it with every field present.
+const parseFilter = (filter = {}) => {
+  if (typeof filter !== "object" || Array.isArray(filter)) throw new Error("filter must be an object");
+  const unknown = Object.keys(filter).filter((f) => !FILTER_FIELDS.includes(f));
+  if (unknown.length) throw new Error(`unknown filter fields: ${unknown.join(", ")}`);
+  return Object.fromEntries(
+    FILTER_FIELDS.map((f) => {
+      const values = filter[f] ?? [];
+      if (!Array.isArray(values) || values.some((v) => typeof v !== "string" || !v)) {
+        throw new Error(`filter.${f} must be an array of strings`);
+      }
+      return [f, values];
+    }),
+  );
+};
+
+const checkTarget = (channel, target) => {
+  if (channel === "kafka") {
+    if (!kafkaRestUrl()) throw new Error("kafka subscriptions need KAFKA_REST_URL");
+    if (!/^[A-Za-z0-9._-]{1,249}$/.test(target)) throw new Error("target must be a Kafka topic name");
+    return;
+  }
+  let url;
+  try {
+    url = new URL(target);
+  } catch {
+    throw new Error("target must be a URL");
+  }
+  if (url.protocol !== "https:" && !(WEBHOOK_ALLOW_HTTP && url.protocol === "http:")) {
+    throw new Error("webhook target must be an https URL");
+  }
+};
+
+// createSubscription registers a subscription for consumer (the caller's
+// principal) and returns it with its webhook secret.
+export const createSubscription = (consumer, { channel, target, filter }) => {
+  if (!CHANNELS.includes(channel)) throw new Error(`channel must be one of ${CHANNELS.join(", ")}`);
+  if (typeof target !== "string" || !target) throw new Error("target is required");
+  checkTarget(channel, target);
+  const sub = {
+    subscriptionId: crypto.randomUUID(),
+    consumer,
+    channel,
+    target,
+    filter: parseFilter(filter),
+    createdAt: new Date().toISOString(),
+    delivered: 0,
+    failed: 0,
+    lastDeliveredAt: null,
+    lastError: null,
+    ...(channel === "webhook" && { secret: crypto.randomBytes(32).toString("base64url") }),
+  };
+  subscriptions.set(sub.subscriptionId, sub);
+  return { ...view(sub), ...(sub.secret && { secret: sub.secret }) };
+};
+
+export const listSubscriptions = (consumer) =>
+  [...subscriptions.values()].filter((s) => s.consumer === consumer).map(view);
+
+export const getSubscription = (consumer, id) => {
+  const sub = subscriptions.get(id);
+  return sub?.consumer === consumer ? view(sub) : null;
+};
+
+export const deleteSubscription = (consumer, id) =>
+  subscriptions.get(id)?.consumer === consumer && subscriptions.delete(id);
+
+// matches reports whether evt, about cred (undefined for events without a
+// credential), passes filter.
+export const matches = (filter, evt, cred) => {
+  const fields = {
+    credTypes: cred?.credType,
+    actions: evt.action,
+    issuerIds: cred?.issuerId,
+    outcomes: evt.outcome,
+    categories: evt.eventCategory,
+  };
+  return FILTER_FIELDS.every((f) => !filter[f].length || filter[f].includes(fields[f]));
+};
+
+const send = (sub, evt) => {
+  const signal = AbortSignal.timeout(TIMEOUT_MS);
+  if (sub.channel === "kafka") {
+    return fetch(`${kafkaRestUrl()}/topics/${encodeURIComponent(sub.target)}`, {
+      method: "POST",
+      headers: { "Content-Type": "application/vnd.kafka.json.v2+json" },
+      body: JSON.stringify({ records: [{ key: evt.credId || evt.holderDid, value: evt }] }),
+      signal,
+    });
+  }
+  const body = JSON.stringify({ subscriptionId: sub.subscriptionId, event: evt });
+  const mac = crypto.createHmac("sha256", sub.secret).update(body).digest("hex");
+  return fetch(sub.target, {
+    method: "POST",
+    headers: { "Content-Type": "application/json", "X-AuditTrail-Signature": `sha256=${mac}` },
+    body,
+    signal,
+  });
+};
+
+const deliver = async (sub, evt) => {
+  for (let attempt = 1; ; attempt++) {
+    try {
+      const res = await send(sub, evt);
+      if (!res.ok) throw new Error(`HTTP ${res.status}`);
+      sub.delivered++;
+      sub.lastDeliveredAt = new Date().toISOString();
+      return;
+    } catch (err) {
+      if (attempt >= MAX_ATTEMPTS || !subscriptions.has(sub.subscriptionId)) {
+        sub.failed++;
+        sub.lastError = { at: new Date().toISOString(), eventId: evt.eventId, error: err.message };
+        return;
+      }
+      await new Promise((resolve) => setTimeout(resolve, 2 ** attempt * 500));
+    }
+  }
+};
+
+// dispatchEvent hands a recorded event to every subscription it matches,
+// and to the devices and subscriptions of the holder of cred. It returns at once; deliveries
+// run in the background.
+export const dispatchEvent = (evt, cred) => {
+  for (const sub of subscriptions.values()) {
+    if (matches(sub.filter, evt, cred)) deliver(sub, evt);
+  }
+  // evt may name the holder by pseudonym; the credential names the DID.
+  if (cred) {
+    notifyHolder(cred.holderDid, evt);
+    notifySubscribers(cred.holderDid, evt, cred);
+  }
+};
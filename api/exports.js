@@ -0,0 +1,273 @@
+// Bulk audit exports for regulators. Extracts too large for a synchronous
+// request run as jobs: the client posts a spec, polls the job, and downloads
+// a .tar.gz holding the events, a manifest with their sha256, and the
+// manifest signed with the gateway key (manifest.jws) so the archive can be
+// checked offline against /.well-known/jwks.json.
+//
+// The chain format makes the events file verifiable on its own, without the
+// archive around it: JSON Lines where line i is {"seq": i, "event": ...,
+// "chainHash": hex(sha256(prev || canonicalJson(event)))}, prev being the
+// previous line's chainHash as bytes (32 zero bytes before the first), and a
+// last line {"manifest": ..., "signature": ...} whose manifest gives the
+// event count and final chain hash, signed like events are (detached JWS
+// over its canonical JSON). Dropping, reordering or editing a line breaks
+// the chain; pkg/receipt's VerifyChainedExport checks a file offline.
+//
+// A spec naming recipients gets an encrypted archive instead: the events are
+// split by eventCategory, each category is encrypted once (JWE, see jwe.js)
+// with its key wrapped for every named recipient entitled to that category,
+// so one package serves oversight bodies with different remits. The signed
+// manifest says which recipients can open which part.
+//
+//   EXPORT_RETENTION_SECONDS=86400   how long finished archives are kept
+//   EXPORT_RECIPIENTS='{"dpa-eu": {"publicKey": "<X25519 SPKI PEM>", "entitlements": ["Consent", "Access"]}}'
+//                                    entitlements are event categories, or "*" for all
+
+import crypto from "node:crypto";
+import zlib from "node:zlib";
+import { encryptJwe } from "./jwe.js";
+import { toProv } from "./prov.js";
+import { belongsTo } from "./pseudonym.js";
+import { canonicalJson, signDetached, signJws } from "./signing.js";
+import { toCef, toEcs } from "./taxonomy.js";
+import { inRange, resolveRange } from "./timerange.js";
+
+const EXPORT_RETENTION_SECONDS = Number(process.env.EXPORT_RETENTION_SECONDS || 86400);
+const BATCH = 5000; // events filtered between yields to the event loop
+
+const RECIPIENTS = Object.fromEntries(
+  Object.entries(JSON.parse(process.env.EXPORT_RECIPIENTS || "{}")).map(([id, r]) => {
+    const publicKey = crypto.createPublicKey(r.publicKey);
+    if (publicKey.asymmetricKeyType !== "x25519") throw new Error(`export recipient ${id}: key must be X25519`);
+    return [id, { publicKey, entitlements: r.entitlements || [] }];
+  }),
+);
+const entitled = (id, category) =>
+  RECIPIENTS[id].entitlements.includes("*") || RECIPIENTS[id].entitlements.includes(category);
+
+// ecs is ndjson in Elastic Common Schema; cef is one ArcSight CEF line per
+// event; prov is one W3C PROV-O JSON-LD graph of the whole export.
+export const EXPORT_FORMATS = ["json", "ndjson", "csv", "ecs", "cef", "prov", "chain"];
+const EXTENSIONS = { ecs: "ecs.ndjson", cef: "cef", prov: "prov.jsonld", chain: "chain.jsonl" };
+export const CHAIN_FORMAT = "audittrail-chain-v1";
+const CSV_COLUMNS = [
+  "eventId", "credId", "holderDid", "action", "actorId", "outcome", "reason", "occurredAt",
+  "eventCategory", "severity", "sourceComponent", "walletAttestation", "walletPlatform", "signature",
+];
+
+// parseExportSpec validates a POSTed spec. Empty holders means every holder;
+// range, from, to and tz are resolved to UTC bounds as for audit queries
+// (see timerange.js); recipients, when given, are EXPORT_RECIPIENTS IDs to
+// encrypt for.
+export const parseExportSpec = (body) => {
+  const { holders = [], range, from, to, tz, format = "ndjson", recipients = [] } = body;
+  if (!Array.isArray(holders) || holders.some((h) => typeof h !== "string" || !h)) {
+    throw new Error("holders must be an array of DIDs");
+  }
+  const window = resolveRange({ range, from, to, tz });
+  if (!EXPORT_FORMATS.includes(format)) throw new Error(`format must be one of ${EXPORT_FORMATS.join(", ")}`);
+  if (!Array.isArray(recipients)) throw new Error("recipients must be an array of recipient IDs");
+  const unknown = recipients.filter((r) => !RECIPIENTS[r]);
+  if (unknown.length) throw new Error(`unknown export recipients: ${unknown.join(", ")}`);
+  return {
+    holders,
+    from: window?.from ?? null,
+    to: window?.to ?? null,
+    timeZone: window?.timeZone ?? null,
+    format,
+    recipients,
+  };
+};
+
+export const csvCell = (v) => {
+  const s = String(v ?? "");
+  return /[",\n\r]/.test(s) ? `"${s.replace(/"/g, '""')}"` : s;
+};
+
+// chained writes list in the chain format; context goes into the manifest.
+const chained = (list, context) => {
+  let prev = Buffer.alloc(32);
+  const lines = list.map((event, i) => {
+    prev = crypto.createHash("sha256").update(prev).update(canonicalJson(event)).digest();
+    return JSON.stringify({ seq: i + 1, event, chainHash: prev.toString("hex") }) + "\n";
+  });
+  const manifest = {
+    format: CHAIN_FORMAT,
+    ...context,
+    eventCount: list.length,
+    firstEventId: list[0]?.eventId ?? null,
+    lastEventId: list.at(-1)?.eventId ?? null,
+    chainHead: prev.toString("hex"),
+    generatedAt: new Date().toISOString(),
+  };
+  lines.push(JSON.stringify({ manifest, signature: signDetached(canonicalJson(manifest)) }) + "\n");
+  return lines.join("");
+};
+
+const serialize = (list, format, context = {}) => {
+  switch (format) {
+    case "chain":
+      return chained(list, context);
+    case "json":
+      return JSON.stringify(list);
+    case "ecs":
+      return list.map((e) => JSON.stringify(toEcs(e)) + "\n").join("");
+    case "cef":
+      return list.map((e) => toCef(e) + "\n").join("");
+    case "prov":
+      return JSON.stringify(toProv(list), null, 2) + "\n";
+    case "csv":
+      return [CSV_COLUMNS, ...list.map((e) => CSV_COLUMNS.map((c) => e[c]))].map((r) => r.map(csvCell).join(",")).join("\n") + "\n";
+    default:
+      return list.map((e) => JSON.stringify(e) + "\n").join("");
+  }
+};
+
+// tarEntry returns a ustar header plus the padded file body.
+const tarEntry = (name, data, mtime) => {
+  const header = Buffer.alloc(512);
+  const field = (value, offset, length) => header.write(value, offset, length, "ascii");
+  const octal = (n, length) => n.toString(8).padStart(length - 1, "0") + "\0";
+  field(name, 0, 100);
+  field(octal(0o644, 8), 100, 8);
+  field(octal(0, 8), 108, 8);
+  field(octal(0, 8), 116, 8);
+  field(octal(data.length, 12), 124, 12);
+  field(octal(mtime, 12), 136, 12);
+  field("        ", 148, 8); // checksum is computed with this field as spaces
+  field("0", 156, 1);
+  field("ustar\0" + "00", 257, 8);
+  let sum = 0;
+  for (const b of header) sum += b;
+  field(octal(sum, 7) + " ", 148, 8);
+  const pad = Buffer.alloc((512 - (data.length % 512)) % 512);
+  return [header, data, pad];
+};
+
+const fileEntry = (f, extra = {}) => ({
+  name: f.name,
+  ...extra,
+  bytes: f.data.length,
+  sha256: crypto.createHash("sha256").update(f.data).digest("hex"),
+});
+
+// encryptedParts splits list by event category and encrypts each category
+// for the requested recipients entitled to it. Categories no requested
+// recipient may see are left out and listed as withheld.
+const encryptedParts = (jobId, list, format, recipients) => {
+  const ext = EXTENSIONS[format] || format;
+  const byCategory = new Map();
+  for (const e of list) {
+    const category = e.eventCategory || "Uncategorized";
+    if (!byCategory.has(category)) byCategory.set(category, []);
+    byCategory.get(category).push(e);
+  }
+  const parts = [];
+  const withheld = [];
+  for (const [category, events] of [...byCategory.entries()].sort(([a], [b]) => (a < b ? -1 : 1))) {
+    const readers = recipients.filter((id) => entitled(id, category));
+    if (!readers.length) {
+      withheld.push(category);
+      continue;
+    }
+    const keys = readers.map((kid) => ({ kid, publicKey: RECIPIENTS[kid].publicKey }));
+    const jwe = encryptJwe(serialize(events, format, { jobId, category }), keys);
+    const file = { name: `events.${category}.${ext}.jwe`, data: Buffer.from(JSON.stringify(jwe)) };
+    parts.push({ file, entry: fileEntry(file, { category, eventCount: events.length, recipients: readers }) });
+  }
+  return { parts, withheld };
+};
+
+const buildArchive = (job, list) => {
+  const mtime = Math.floor(Date.now() / 1000);
+  const { format, recipients = [] } = job.spec;
+  let eventFiles, entries, withheld;
+  if (recipients.length) {
+    const enc = encryptedParts(job.jobId, list, format, recipients);
+    eventFiles = enc.parts.map((p) => p.file);
+    entries = enc.parts.map((p) => p.entry);
+    withheld = enc.withheld;
+  } else {
+    const data = Buffer.from(serialize(list, format, { jobId: job.jobId }));
+    eventFiles = [{ name: `events.${EXTENSIONS[format] || format}`, data }];
+    entries = eventFiles.map((f) => fileEntry(f));
+  }
+  const manifest = {
+    jobId: job.jobId,
+    requestedBy: job.requestedBy,
+    spec: job.spec,
+    eventCount: list.length,
+    generatedAt: new Date().toISOString(),
+    files: entries,
+    ...(withheld ? { withheld } : {}),
+  };
+  const files = [
+    ...eventFiles,
+    { name: "manifest.json", data: Buffer.from(JSON.stringify(manifest, null, 2) + "\n") },
+    { name: "manifest.jws", data: Buffer.from(signJws(manifest) + "\n") },
+  ];
+  const tar = Buffer.concat([...files.flatMap((f) => tarEntry(f.name, f.data, mtime)), Buffer.alloc(1024)]);
+  return { manifest, archive: zlib.gzipSync(tar) };
+};
+
+const jobs = new Map(); // jobId -> job
+
+// jobView is the job as returned to clients; the archive itself is only
+// served by the download route.
+export const jobView = (job) => {
+  const { archive, ...view } = job;
+  return view;
+};
+
+export const getJob = (jobId) => {
+  const job = jobs.get(jobId);
+  if (job?.expiresAt && Date.parse(job.expiresAt) < Date.now()) {
+    jobs.delete(jobId);
+    return undefined;
+  }
+  return job;
+};
+
+// startExport queues a job over events and returns it at once. Filtering
+// yields to the event loop every BATCH events so the API stays responsive.
+export const startExport = (events, spec, requestedBy) => {
+  const job = {
+    jobId: crypto.randomUUID(),
+    status: "Queued",
+    requestedBy,
+    spec,
+    createdAt: new Date().toISOString(),
+  };
+  jobs.set(job.jobId, job);
+
+  const holders = new Set(spec.holders);
+  const matches = (e) =>
+    (!holders.size || [...holders].some((h) => belongsTo(e, h))) && inRange(spec, e.occurredAt);
+
+  setImmediate(async () => {
+    job.status = "Running";
+    job.startedAt = new Date().toISOString();
+    try {
+      const snapshot = events.slice(); // events appended meanwhile are out of scope
+      const list = [];
+      for (let i = 0; i < snapshot.length; i += BATCH) {
+        for (const e of snapshot.slice(i, i + BATCH)) if (matches(e)) list.push(e);
+        await new Promise(setImmediate);
+      }
+      const { manifest, archive } = buildArchive(job, list);
+      Object.assign(job, {
+        status: "Completed",
+        eventCount: list.length,
+        archive,
+        archiveBytes: archive.length,
+        archiveSha256: crypto.createHash("sha256").update(archive).digest("hex"),
+        manifest,
+        completedAt: new Date().toISOString(),
+      });
+    } catch (err) {
+      Object.assign(job, { status: "Failed", error: err.message, completedAt: new Date().toISOString() });
+    }
+    job.expiresAt = new Date(Date.now() + EXPORT_RETENTION_SECONDS * 1000).toISOString();
+  });
+  return job;
+};
@@ -0,0 +1,52 @@
+// Verification heatmaps for fraud teams. Verifications are counted per
+// verifier and credential type in hour-of-day by day-of-week cells, in the
+// caller's time zone, so a verifier scraping credentials at 3 a.m. or over
+// the weekend stands out against its usual working hours. Only cells with
+// activity are listed; failures and denials are counted alongside, since a
+// scraper probing for valid IDs fails a lot.
+//
+//   dayOfWeek   1 (Monday) to 7 (Sunday), as in ISO 8601
+//   hour        0 to 23
+//
+// The CSV form has one row per cell, for spreadsheets and notebooks.
+
+import { csvCell } from "./exports.js";
+
+const VERIFY_ACTIONS = ["Verify", "BreakGlassVerify"];
+const WEEKDAYS = { Mon: 1, Tue: 2, Wed: 3, Thu: 4, Fri: 5, Sat: 6, Sun: 7 };
+const CSV_COLUMNS = ["verifierId", "credType", "dayOfWeek", "hour", "count", "failures"];
+
+// accessHeatmap buckets the verification events in events, narrowed to
+// verifierId and credType when given, in time zone tz. credentials maps
+// credId to credential, for the credential type.
+export const accessHeatmap = (events, credentials, { verifierId, credType, tz = "UTC" } = {}) => {
+  const local = new Intl.DateTimeFormat("en-US", { timeZone: tz, hourCycle: "h23", weekday: "short", hour: "numeric" });
+  const cells = new Map();
+  for (const e of events) {
+    if (!VERIFY_ACTIONS.includes(e.action)) continue;
+    const type = credentials.get(e.credId)?.credType || "";
+    if ((verifierId && e.actorId !== verifierId) || (credType && type !== credType)) continue;
+
+    const parts = Object.fromEntries(local.formatToParts(new Date(e.occurredAt)).map((p) => [p.type, p.value]));
+    const dayOfWeek = WEEKDAYS[parts.weekday];
+    const hour = Number(parts.hour);
+    const key = JSON.stringify([e.actorId, type, dayOfWeek, hour]);
+    if (!cells.has(key)) {
+      cells.set(key, { verifierId: e.actorId, credType: type, dayOfWeek, hour, count: 0, failures: 0 });
+    }
+    const cell = cells.get(key);
+    cell.count++;
+    if (e.outcome !== "Success") cell.failures++;
+  }
+  return [...cells.values()].sort(
+    (a, b) =>
+      a.verifierId.localeCompare(b.verifierId) ||
+      a.credType.localeCompare(b.credType) ||
+      a.dayOfWeek - b.dayOfWeek ||
+      a.hour - b.hour,
+  );
+};
+
+export const heatmapCsv = (cells) =>
+  [CSV_COLUMNS, ...cells.map((c) => CSV_COLUMNS.map((k) => c[k]))].map((r) => r.map(csvCell).join(",")).join("\n") +
+  "\n";
@@ -0,0 +1,67 @@
+// Multi-recipient encryption for export archives: JWE JSON serialization
+// (RFC 7516 section 7.2) with A256GCM content encryption and the content key
+// wrapped per recipient with ECDH-ES+A256KW over X25519 (RFC 7518, RFC 8037),
+// so any JOSE library can open a part with the recipient's private key.
+
+import crypto from "node:crypto";
+
+const b64u = (buf) => Buffer.from(buf).toString("base64url");
+const WRAP_IV = Buffer.from("A6A6A6A6A6A6A6A6", "hex");
+const ALG = "ECDH-ES+A256KW";
+
+// concatKdf is the single-round Concat KDF of RFC 7518 section 4.6.2 for a
+// 256-bit key with empty PartyUInfo / PartyVInfo.
+const concatKdf = (z) => {
+  const u32 = (n) => {
+    const b = Buffer.alloc(4);
+    b.writeUInt32BE(n);
+    return b;
+  };
+  const alg = Buffer.from(ALG);
+  const otherInfo = Buffer.concat([u32(alg.length), alg, u32(0), u32(0), u32(256)]);
+  return crypto.createHash("sha256").update(Buffer.concat([u32(1), z, otherInfo])).digest();
+};
+
+const wrapKey = (cek, recipientKey) => {
+  const { publicKey, privateKey } = crypto.generateKeyPairSync("x25519");
+  const kek = concatKdf(crypto.diffieHellman({ privateKey, publicKey: recipientKey }));
+  const cipher = crypto.createCipheriv("id-aes256-wrap", kek, WRAP_IV);
+  const epk = publicKey.export({ format: "jwk" });
+  return { epk, encryptedKey: Buffer.concat([cipher.update(cek), cipher.final()]) };
+};
+
+// encryptJwe encrypts plaintext once for recipients, a list of
+// { kid, publicKey } with X25519 KeyObjects, and returns the JWE JSON.
+export const encryptJwe = (plaintext, recipients) => {
+  const cek = crypto.randomBytes(32);
+  const iv = crypto.randomBytes(12);
+  const protectedHeader = b64u(JSON.stringify({ enc: "A256GCM" }));
+  const cipher = crypto.createCipheriv("aes-256-gcm", cek, iv);
+  cipher.setAAD(Buffer.from(protectedHeader, "ascii"));
+  const ciphertext = Buffer.concat([cipher.update(plaintext), cipher.final()]);
+  return {
+    protected: protectedHeader,
+    recipients: recipients.map(({ kid, publicKey }) => {
+      const { epk, encryptedKey } = wrapKey(cek, publicKey);
+      return { header: { alg: ALG, kid, epk }, encrypted_key: b64u(encryptedKey) };
+    }),
+    iv: b64u(iv),
+    ciphertext: b64u(ciphertext),
+    tag: b64u(cipher.getAuthTag()),
+  };
+};
+
+// decryptJwe opens a JWE from encryptJwe for the recipient kid holding
+// privateKey, for recipients without a JOSE library.
+export const decryptJwe = (jwe, kid, privateKey) => {
+  const r = jwe.recipients.find((x) => x.header.kid === kid);
+  if (!r) throw new Error(`no key for recipient ${kid}`);
+  const epk = crypto.createPublicKey({ key: r.header.epk, format: "jwk" });
+  const kek = concatKdf(crypto.diffieHellman({ privateKey, publicKey: epk }));
+  const unwrap = crypto.createDecipheriv("id-aes256-wrap", kek, WRAP_IV);
+  const cek = Buffer.concat([unwrap.update(Buffer.from(r.encrypted_key, "base64url")), unwrap.final()]);
+  const decipher = crypto.createDecipheriv("aes-256-gcm", cek, Buffer.from(jwe.iv, "base64url"));
+  decipher.setAAD(Buffer.from(jwe.protected, "ascii"));
+  decipher.setAuthTag(Buffer.from(jwe.tag, "base64url"));
+  return Buffer.concat([decipher.update(Buffer.from(jwe.ciphertext, "base64url")), decipher.final()]);
+};
@@ -0,0 +1,36 @@
+// Drift check between the routes server.js registers and the paths
+// openapi.js describes. Run with `npm run openapi:check`; it exits non-zero
+// when a route is missing from the spec or the spec names a route that no
+// longer exists, so a route change without a matching spec change fails CI.
+//
+// Routes are read from the source rather than from a running app, which
+// would start the canary, digests and saga recovery. They must be registered
+// as app.<method>("/path", ...) or, under /v1/me, me.<method>("/path", ...).
+
+import { readFileSync } from "node:fs";
+import { openapi } from "./openapi.js";
+
+// Routes deliberately left out of the spec.
+const unlisted = new Set(["get /openapi.json"]);
+
+const methods = ["get", "post", "put", "patch", "delete"];
+const routePattern = new RegExp(`^(app|me)\\.(${methods.join("|")})\\(\\s*"([^"]+)"`, "gm");
+
+const src = readFileSync(new URL("./server.js", import.meta.url), "utf8");
+const routes = new Set();
+for (const [, router, method, path] of src.matchAll(routePattern)) {
+  const full = router === "me" ? `/v1/me${path === "/" ? "" : path}` : path;
+  routes.add(`${method} ${full.replace(/:(\w+)/g, "{$1}")}`);
+}
+
+const documented = new Set();
+for (const [path, ops] of Object.entries(openapi.paths)) {
+  for (const method of Object.keys(ops).filter((k) => methods.includes(k))) documented.add(`${method} ${path}`);
+}
+
+const missing = [...routes].filter((r) => !documented.has(r) && !unlisted.has(r));
+const stale = [...documented].filter((r) => !routes.has(r));
+for (const r of missing) console.error(`not in openapi.js: ${r}`);
+for (const r of stale) console.error(`no route in server.js: ${r}`);
+if (missing.length || stale.length) process.exit(1);
+console.log(`openapi.js covers all ${routes.size - unlisted.size} routes`);
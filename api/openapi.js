@@ -0,0 +1,1398 @@
+// OpenAPI 3 description of the REST surface. Served at /openapi.json and fed
+// to the client generators (see "codegen:*" scripts in package.json).
+// Keep in step with server.js when routes change; `npm run openapi:check`
+// (openapi-check.js) fails when the two drift apart.
+
+const ref = (name) => ({ $ref: `#/components/schemas/${name}` });
+
+const ok = (props) => ({
+  description: "OK",
+  content: {
+    "application/json": {
+      schema: {
+        type: "object",
+        properties: { ok: { type: "boolean" }, ...props },
+        required: ["ok"],
+      },
+    },
+  },
+});
+
+const body = (props, requiredFields) => ({
+  required: true,
+  content: {
+    "application/json": {
+      schema: { type: "object", properties: props, required: requiredFields },
+    },
+  },
+});
+
+const str = { type: "string" };
+const badRequest = { 400: { description: "Bad request", content: { "application/json": { schema: ref("Error") } } } };
+const unauthorized = {
+  401: { description: "Missing or invalid credentials", content: { "application/json": { schema: ref("Error") } } },
+  403: { description: "Missing scope", content: { "application/json": { schema: ref("Error") } } },
+};
+// Scopes the route requires (any one of them); see api/auth.js.
+// Optional on presentations; see wallet.js.
+const walletProps = {
+  walletAttestation: { type: "string", description: "device attestation token (Play Integrity, App Attest, ...)" },
+  walletPlatform: { type: "string", example: "play-integrity" },
+};
+const auth = (...scopes) => ({ security: [{ apiKey: [] }, { bearer: [] }], "x-required-scopes": scopes });
+const formatParam = {
+  name: "format",
+  in: "query",
+  required: false,
+  description: "ndjson streams one event per line (also selected by Accept: application/x-ndjson).",
+  schema: { type: "string", enum: ["json", "ndjson"] },
+};
+// Time window parameters, resolved to half-open UTC bounds (timerange.js).
+const rangeParams = [
+  {
+    name: "range",
+    in: "query",
+    required: false,
+    description:
+      "2024, 2024-Q3, 2024-07, 2024-W05, 2024-07-15, today, yesterday, this-<unit>, previous-<unit> " +
+      "(week, month, quarter, year) or last-<n>h|d|w. Not combined with from/to.",
+    schema: str,
+  },
+  {
+    name: "from",
+    in: "query",
+    required: false,
+    description: "RFC 3339 timestamp with offset, or a date taken at midnight in tz",
+    schema: str,
+  },
+  {
+    name: "to",
+    in: "query",
+    required: false,
+    description: "exclusive; RFC 3339 timestamp with offset, or a date whose whole day is included",
+    schema: str,
+  },
+  { name: "tz", in: "query", required: false, description: "IANA time zone for calendar boundaries", schema: str },
+];
+const timeWindow = {
+  type: "object",
+  description: "the resolved bounds, present when a window was asked for",
+  properties: {
+    from: { type: "string", format: "date-time", nullable: true },
+    to: { type: "string", format: "date-time", nullable: true },
+    timeZone: str,
+  },
+};
+// Large trails are gzip/zstd-compressed per Accept-Encoding.
+const eventsResponse = {
+  description: "OK",
+  content: {
+    "application/json": ok({ window: timeWindow, events: { type: "array", items: ref("AccessEvent") } }).content[
+      "application/json"
+    ],
+    "application/x-ndjson": { schema: ref("AccessEvent") },
+  },
+};
+
+export const openapi = {
+  openapi: "3.0.3",
+  info: {
+    title: "AuditTrail API",
+    version: "0.1.0",
+    description:
+      "Issue, verify and revoke credentials and read their audit trail. The /v1 routes are also served under " +
+      "the deprecated /api prefix, with Deprecation, Sunset and successor-version Link headers, until its sunset.",
+  },
+  paths: {
+    "/v1/issue": {
+      post: {
+        operationId: "issueCredential",
+        ...auth("cred:issue"),
+        requestBody: body(
+          {
+            credId: str,
+            holderDid: str,
+            credType: str,
+            hashedData: str,
+            issuerId: str,
+            holderType: { type: "string", enum: ["Individual", "Organization"], default: "Individual" },
+            legalEntityId: { type: "string", description: "ISO 17442 LEI; required for Organization holders" },
+            expiresAt: {
+              type: "string",
+              format: "date-time",
+              description: "defaults to, and may not exceed, the credential type's validity (VALIDITY_POLICY)",
+            },
+          },
+          ["credId", "holderDid", "credType", "hashedData", "issuerId"],
+        ),
+        responses: {
+          200: ok({ credential: ref("Credential"), event: ref("AccessEvent") }),
+          ...badRequest,
+          ...unauthorized,
+          403: {
+            description: "Missing scope, or the holder is blocked; a Denied Issue event is recorded",
+            content: { "application/json": { schema: ref("Error") } },
+          },
+        },
+      },
+    },
+    "/v1/sagas/issue": {
+      post: {
+        operationId: "issueCredentialSaga",
+        ...auth("cred:issue"),
+        description:
+          "Issue through a saga spanning identity proofing, the document store and the ledger. A failed step " +
+          "rolls back the completed ones (document deleted, reservation aborted); the saga is returned either way.",
+        requestBody: body(
+          {
+            credId: str,
+            holderDid: str,
+            credType: str,
+            hashedData: str,
+            issuerId: str,
+            holderType: { type: "string", enum: ["Individual", "Organization"], default: "Individual" },
+            legalEntityId: str,
+            evidence: { type: "object", description: "passed to the identity proofing service" },
+            document: { description: "credential document for the document store" },
+          },
+          ["credId", "holderDid", "credType", "hashedData", "issuerId"],
+        ),
+        responses: {
+          200: ok({ saga: ref("Saga"), credential: ref("Credential") }),
+          400: {
+            description: "Rolled back",
+            content: {
+              "application/json": { schema: { type: "object", properties: { error: str, saga: ref("Saga") } } },
+            },
+          },
+          ...unauthorized,
+        },
+      },
+    },
+    "/v1/sagas": {
+      get: {
+        operationId: "listSagas",
+        ...auth("cred:issue"),
+        parameters: [
+          {
+            name: "status",
+            in: "query",
+            required: false,
+            schema: { type: "string", enum: ["Running", "Compensating", "Completed", "Aborted", "Stuck"] },
+          },
+        ],
+        responses: { 200: ok({ sagas: { type: "array", items: ref("Saga") } }), ...badRequest, ...unauthorized },
+      },
+    },
+    "/v1/sagas/{id}": {
+      get: {
+        operationId: "getSaga",
+        ...auth("cred:issue"),
+        parameters: [{ name: "id", in: "path", required: true, schema: str }],
+        responses: { 200: ok({ saga: ref("Saga") }), 404: { description: "Not found" }, ...unauthorized },
+      },
+    },
+    "/v1/verify": {
+      post: {
+        operationId: "verifyCredential",
+        ...auth("cred:verify"),
+        requestBody: body({ credId: str, verifierId: str, ...walletProps }, ["credId", "verifierId"]),
+        responses: {
+          200: ok({ result: ref("VerificationResult"), event: ref("AccessEvent") }),
+          ...badRequest,
+          ...unauthorized,
+        },
+      },
+    },
+    "/v1/verify/requests": {
+      post: {
+        operationId: "createVerifyRequest",
+        ...auth("cred:verify"),
+        requestBody: body({ credId: str, verifierId: str }, ["credId", "verifierId"]),
+        responses: {
+          200: ok({ request: ref("VerifyRequest"), deepLink: str, url: str, event: ref("AccessEvent") }),
+          ...badRequest,
+          ...unauthorized,
+        },
+      },
+    },
+    "/v1/verify/offline-bundle": {
+      post: {
+        operationId: "createOfflineBundle",
+        ...auth("cred:verify"),
+        description:
+          "Signed bundle of the credential's state, inclusion proof and key references for verifiers without " +
+          "connectivity. Check it with pkg/receipt VerifyOfflineBundle.",
+        requestBody: body(
+          {
+            credId: str,
+            verifierId: str,
+            validForSeconds: { type: "integer", description: "default OFFLINE_BUNDLE_TTL_SECONDS" },
+          },
+          ["credId", "verifierId"],
+        ),
+        responses: {
+          200: ok({ bundle: ref("OfflineBundle"), jws: str, event: ref("AccessEvent") }),
+          ...badRequest,
+          ...unauthorized,
+        },
+      },
+    },
+    "/v1/verify/break-glass": {
+      post: {
+        operationId: "breakGlassVerify",
+        ...auth("cred:break-glass"),
+        description:
+          "Emergency verification that skips the usual checks. Needs a configured justification code; " +
+          "recorded as a Critical event and queued for review.",
+        requestBody: body({ credId: str, verifierId: str, justificationCode: str, ...walletProps }, [
+          "credId",
+          "verifierId",
+          "justificationCode",
+        ]),
+        responses: {
+          200: ok({ result: ref("VerificationResult"), event: ref("AccessEvent"), review: ref("BreakGlassReview") }),
+          ...badRequest,
+          ...unauthorized,
+        },
+      },
+    },
+    "/v1/verify/requests/{token}": {
+      get: {
+        operationId: "getVerifyRequest",
+        parameters: [{ name: "token", in: "path", required: true, schema: str }],
+        responses: { 200: ok({ request: ref("VerifyRequest") }), 404: { description: "Not found" } },
+      },
+    },
+    "/v1/verify/requests/{token}/complete": {
+      post: {
+        operationId: "completeVerifyRequest",
+        parameters: [{ name: "token", in: "path", required: true, schema: str }],
+        requestBody: body(
+          {
+            challenge: str,
+            signature: {
+              type: "string",
+              description:
+                "base64url signature over the challenge by a holder authentication key; " +
+                "required unless HOLDER_PROOF_REQUIRED=false",
+            },
+            ...walletProps,
+          },
+          ["challenge"],
+        ),
+        responses: {
+          200: ok({ request: ref("VerifyRequest"), result: ref("VerificationResult"), event: ref("AccessEvent") }),
+          ...badRequest,
+        },
+      },
+    },
+    "/v1/revoke": {
+      post: {
+        operationId: "revokeCredential",
+        ...auth("cred:revoke"),
+        requestBody: body({ credId: str, reason: str, revokerId: str }, ["credId", "reason", "revokerId"]),
+        responses: {
+          200: ok({ credential: ref("Credential"), event: ref("AccessEvent") }),
+          ...badRequest,
+          ...unauthorized,
+        },
+      },
+    },
+    "/v1/revocations/{credId}/propagation": {
+      get: {
+        operationId: "getRevocationPropagation",
+        ...auth("cred:revoke", "audit:read:any"),
+        description: "How long the credential's revocation took to commit and to take effect everywhere.",
+        parameters: [{ name: "credId", in: "path", required: true, schema: str }],
+        responses: {
+          200: ok({ report: ref("RevocationPropagation") }),
+          404: { description: "Not found" },
+          ...unauthorized,
+        },
+      },
+    },
+    "/v1/revocations/import": {
+      post: {
+        operationId: "importRevocation",
+        description:
+          "Mirror a revocation relayed from a sister channel, as chaincode ImportRevocation does. The assertion " +
+          "must come from a RELAY_SOURCES channel and be signed by its relay key; a local credential with the " +
+          "same issuer is revoked.",
+        ...auth("cred:relay"),
+        requestBody: body({ assertion: ref("RevocationAssertion") }, ["assertion"]),
+        responses: {
+          201: ok({ mirror: ref("MirroredRevocation"), event: ref("AccessEvent") }),
+          409: { description: "Already imported", content: { "application/json": { schema: ref("Error") } } },
+          ...badRequest,
+          ...unauthorized,
+          403: {
+            description: "Missing scope, unknown source channel or bad signature",
+            content: { "application/json": { schema: ref("Error") } },
+          },
+        },
+      },
+    },
+    "/v1/revocations/mirrored/{channel}/{credId}": {
+      get: {
+        operationId: "getMirroredRevocation",
+        ...auth("cred:revoke", "audit:read:any"),
+        parameters: [
+          { name: "channel", in: "path", required: true, schema: str },
+          { name: "credId", in: "path", required: true, schema: str },
+        ],
+        responses: {
+          200: ok({ mirror: ref("MirroredRevocation") }),
+          404: { description: "Not found" },
+          ...unauthorized,
+        },
+      },
+    },
+    "/v1/renew": {
+      post: {
+        operationId: "renewCredential",
+        ...auth("cred:issue"),
+        requestBody: body(
+          { credId: str, newExpiresAt: { type: "string", format: "date-time" }, newHash: str, issuerId: str },
+          ["credId", "newExpiresAt", "issuerId"],
+        ),
+        responses: {
+          200: ok({ credential: ref("Credential"), event: ref("AccessEvent") }),
+          ...badRequest,
+          ...unauthorized,
+        },
+      },
+    },
+    "/v1/notices": {
+      post: {
+        operationId: "notifyRevocation",
+        ...auth("cred:revoke"),
+        description: "Notify a relying party of a credential's revocation. Each recipient is notified once.",
+        requestBody: body({ credId: str, recipientDid: str }, ["credId", "recipientDid"]),
+        responses: {
+          201: { ...ok({ notice: ref("RevocationNotice"), event: ref("AccessEvent") }), description: "Created" },
+          ...badRequest,
+          ...unauthorized,
+        },
+      },
+      get: {
+        operationId: "listRevocationNotices",
+        ...auth("cred:revoke", "audit:read:any"),
+        parameters: [{ name: "credId", in: "query", required: true, schema: str }],
+        responses: {
+          200: ok({ notices: { type: "array", items: ref("RevocationNotice") } }),
+          ...badRequest,
+          ...unauthorized,
+        },
+      },
+    },
+    "/v1/notices/ack": {
+      post: {
+        operationId: "acknowledgeNotice",
+        description: "Acknowledge a revocation notice with a signature over its ackMessage by a recipient DID key.",
+        requestBody: body(
+          {
+            credId: str,
+            recipientDid: str,
+            signature: { type: "string", description: "base64url signature over ackMessage" },
+          },
+          ["credId", "recipientDid", "signature"],
+        ),
+        responses: {
+          200: ok({ notice: ref("RevocationNotice"), event: ref("AccessEvent") }),
+          ...badRequest,
+        },
+      },
+    },
+    "/v1/audit": {
+      get: {
+        operationId: "getAuditTrail",
+        ...auth("audit:read:any", "audit:read:own"),
+        parameters: [{ name: "holderDid", in: "query", required: true, schema: str }, formatParam, ...rangeParams],
+        responses: { 200: eventsResponse, ...badRequest, ...unauthorized },
+      },
+    },
+    "/v1/analytics/heatmap": {
+      get: {
+        operationId: "getAccessHeatmap",
+        ...auth("audit:read:any"),
+        description:
+          "Verification counts by hour of day and day of week in tz, per verifier and credential type. " +
+          "Only cells with activity are listed.",
+        parameters: [
+          { name: "verifierId", in: "query", required: false, schema: str },
+          { name: "credType", in: "query", required: false, schema: str },
+          {
+            name: "format",
+            in: "query",
+            required: false,
+            description: "csv downloads the cells (also selected by Accept: text/csv).",
+            schema: { type: "string", enum: ["json", "csv"] },
+          },
+          ...rangeParams,
+        ],
+        responses: {
+          200: {
+            description: "OK",
+            content: {
+              "application/json": ok({
+                window: timeWindow,
+                timeZone: str,
+                cells: { type: "array", items: ref("HeatmapCell") },
+              }).content["application/json"],
+              "text/csv": { schema: str },
+            },
+          },
+          ...badRequest,
+          ...unauthorized,
+        },
+      },
+    },
+    "/v1/digests": {
+      get: {
+        operationId: "listDigests",
+        ...auth("audit:read:any"),
+        description: "Issuers that get digest emails, with the last period sent to each.",
+        responses: { 200: ok({ schedule: { type: "array", items: ref("DigestSchedule") } }), ...unauthorized },
+      },
+    },
+    "/v1/digests/{issuerId}": {
+      get: {
+        operationId: "getDigest",
+        ...auth("audit:read:any"),
+        description:
+          "Issued, verified and revoked counts and anomalies on the issuer's credentials, over the window " +
+          "given or the issuer's last digest period. Digest emails link here.",
+        parameters: [
+          { name: "issuerId", in: "path", required: true, schema: str },
+          {
+            name: "format",
+            in: "query",
+            required: false,
+            description: "text shows the digest as emailed.",
+            schema: { type: "string", enum: ["json", "text"] },
+          },
+          ...rangeParams,
+        ],
+        responses: {
+          200: {
+            description: "OK",
+            content: {
+              "application/json": ok({ digest: ref("Digest") }).content["application/json"],
+              "text/plain": { schema: str },
+            },
+          },
+          ...badRequest,
+          ...unauthorized,
+        },
+      },
+    },
+    "/v1/pseudonyms/{pseudonym}": {
+      get: {
+        operationId: "resolvePseudonym",
+        ...auth("audit:link"),
+        description: "Resolve a holder pseudonym from the trail to its DID. The resolution is recorded.",
+        parameters: [{ name: "pseudonym", in: "path", required: true, schema: str }],
+        responses: {
+          200: ok({ linkage: ref("PseudonymLinkage"), event: ref("AccessEvent") }),
+          404: { description: "Not found" },
+          ...unauthorized,
+        },
+      },
+    },
+    "/v1/me/credentials": {
+      get: {
+        operationId: "listMyCredentials",
+        ...auth("audit:read:own"),
+        responses: { 200: ok({ credentials: { type: "array", items: ref("Credential") } }), ...unauthorized },
+      },
+    },
+    "/v1/me/summary": {
+      get: {
+        operationId: "getMySummary",
+        ...auth("audit:read:own"),
+        responses: { 200: ok({ summary: ref("HolderSummary") }), ...unauthorized },
+      },
+    },
+    "/v1/me/audit": {
+      get: {
+        operationId: "listMyAuditTrail",
+        ...auth("audit:read:own"),
+        parameters: [formatParam, ...rangeParams],
+        responses: { 200: eventsResponse, ...badRequest, ...unauthorized },
+      },
+    },
+    "/v1/me/export": {
+      get: {
+        operationId: "exportMyData",
+        ...auth("audit:read:own"),
+        description:
+          "Data portability export: the holder's credentials and trail as one JSON-LD document using the " +
+          "W3C VC 2.0 and PROV-O vocabularies. Recorded as an Export event.",
+        responses: {
+          200: { description: "OK", content: { "application/ld+json": { schema: { type: "object" } } } },
+          ...unauthorized,
+        },
+      },
+    },
+    "/v1/me/consents": {
+      get: {
+        operationId: "listMyConsents",
+        ...auth("audit:read:own"),
+        responses: { 200: ok({ consents: { type: "array", items: ref("Consent") } }), ...unauthorized },
+      },
+      post: {
+        operationId: "grantConsent",
+        ...auth("audit:read:own"),
+        requestBody: body({ verifierId: str, purpose: str }, ["verifierId", "purpose"]),
+        responses: {
+          200: ok({ consent: ref("Consent"), event: ref("AccessEvent"), receipt: ref("ConsentReceiptEnvelope") }),
+          ...badRequest,
+          ...unauthorized,
+        },
+      },
+    },
+    "/v1/me/consents/{verifierId}": {
+      delete: {
+        operationId: "revokeConsent",
+        ...auth("audit:read:own"),
+        parameters: [{ name: "verifierId", in: "path", required: true, schema: str }],
+        responses: {
+          200: ok({ consent: ref("Consent"), event: ref("AccessEvent"), receipt: ref("ConsentReceiptEnvelope") }),
+          ...badRequest,
+          ...unauthorized,
+        },
+      },
+    },
+    "/v1/me/consents/{verifierId}/receipt": {
+      get: {
+        operationId: "getConsentReceipt",
+        description: "Latest signed Kantara consent receipt (compact JWS) for the verifier.",
+        ...auth("audit:read:own"),
+        parameters: [{ name: "verifierId", in: "path", required: true, schema: str }],
+        responses: {
+          200: { description: "OK", content: { "application/jwt": { schema: str } } },
+          404: { description: "Not found" },
+          ...unauthorized,
+        },
+      },
+    },
+    "/v1/me/subscriptions": {
+      get: {
+        operationId: "listMySubscriptions",
+        ...auth("audit:read:own"),
+        responses: { 200: ok({ subscriptions: { type: "array", items: ref("Subscription") } }), ...unauthorized },
+      },
+      post: {
+        operationId: "subscribe",
+        ...auth("audit:read:own"),
+        description: "Email or SMS alerts for events on the holder's credentials, rendered in the given locale.",
+        requestBody: body(
+          {
+            channel: { type: "string", enum: ["email", "sms"] },
+            target: { type: "string", description: "email address, or E.164 phone number for sms" },
+            actions: { type: "array", items: str, description: "event actions to send; empty means all" },
+            locale: { type: "string", description: "BCP 47 tag; default NOTIFY_DEFAULT_LOCALE" },
+            timeZone: { type: "string", description: "IANA time zone for times in messages; default UTC" },
+          },
+          ["channel", "target"],
+        ),
+        responses: { 200: ok({ subscription: ref("Subscription") }), ...badRequest, ...unauthorized },
+      },
+    },
+    "/v1/me/subscriptions/{id}": {
+      delete: {
+        operationId: "unsubscribe",
+        ...auth("audit:read:own"),
+        parameters: [{ name: "id", in: "path", required: true, schema: str }],
+        responses: { 200: ok({}), 404: { description: "Not found" }, ...unauthorized },
+      },
+    },
+    "/v1/me/devices": {
+      get: {
+        operationId: "listMyDevices",
+        ...auth("audit:read:own"),
+        responses: { 200: ok({ devices: { type: "array", items: ref("Device") } }), ...unauthorized },
+      },
+      post: {
+        operationId: "registerDevice",
+        ...auth("audit:read:own"),
+        description:
+          "Register a wallet device's push token for alerts on the holder's credentials. " +
+          "Registering a token again replaces its earlier registration.",
+        requestBody: body(
+          {
+            platform: { type: "string", enum: ["fcm", "apns"] },
+            token: { type: "string", description: "FCM registration token or APNs device token" },
+            actions: { type: "array", items: str, description: "event actions to push; default PUSH_ALERT_ACTIONS" },
+          },
+          ["platform", "token"],
+        ),
+        responses: {
+          201: { ...ok({ device: ref("Device") }), description: "Created" },
+          ...badRequest,
+          ...unauthorized,
+        },
+      },
+    },
+    "/v1/me/devices/{id}": {
+      delete: {
+        operationId: "deleteDevice",
+        ...auth("audit:read:own"),
+        parameters: [{ name: "id", in: "path", required: true, schema: str }],
+        responses: { 200: ok({}), 404: { description: "Not found" }, ...unauthorized },
+      },
+    },
+    "/v1/subscriptions": {
+      get: {
+        operationId: "listEventSubscriptions",
+        ...auth("events:subscribe"),
+        responses: { 200: ok({ subscriptions: { type: "array", items: ref("EventSubscription") } }), ...unauthorized },
+      },
+      post: {
+        operationId: "createEventSubscription",
+        ...auth("events:subscribe"),
+        description:
+          "Deliver newly recorded events matching filter to a webhook or Kafka topic. " +
+          "Webhook subscriptions return their HMAC secret here only.",
+        requestBody: body(
+          {
+            channel: { type: "string", enum: ["webhook", "kafka"] },
+            target: { type: "string", description: "https URL for webhook, topic name for kafka" },
+            filter: ref("EventFilter"),
+          },
+          ["channel", "target"],
+        ),
+        responses: {
+          201: { ...ok({ subscription: ref("EventSubscription") }), description: "Created" },
+          ...badRequest,
+          ...unauthorized,
+        },
+      },
+    },
+    "/v1/subscriptions/{id}": {
+      get: {
+        operationId: "getEventSubscription",
+        ...auth("events:subscribe"),
+        parameters: [{ name: "id", in: "path", required: true, schema: str }],
+        responses: {
+          200: ok({ subscription: ref("EventSubscription") }),
+          404: { description: "Not found" },
+          ...unauthorized,
+        },
+      },
+      delete: {
+        operationId: "deleteEventSubscription",
+        ...auth("events:subscribe"),
+        parameters: [{ name: "id", in: "path", required: true, schema: str }],
+        responses: { 200: ok({}), 404: { description: "Not found" }, ...unauthorized },
+      },
+    },
+    "/v1/exports": {
+      post: {
+        operationId: "createExport",
+        ...auth("audit:read:any"),
+        description: "Queue a bulk audit export. Poll the returned job, then download the archive.",
+        requestBody: body(
+          {
+            requestedBy: { type: "string", description: "only used when the gateway runs without authentication" },
+            holders: { type: "array", items: str, description: "empty exports every holder" },
+            range: { type: "string", description: "as for GET /v1/audit; not combined with from/to" },
+            from: { type: "string", description: "RFC 3339 timestamp with offset, or a date" },
+            to: { type: "string", description: "exclusive; RFC 3339 timestamp with offset, or a date (whole day)" },
+            tz: { type: "string", description: "IANA time zone for range and dates", default: "UTC" },
+            format: {
+              type: "string",
+              enum: ["json", "ndjson", "csv", "ecs", "cef", "prov", "chain"],
+              default: "ndjson",
+              description: "chain is hash-chained JSON Lines ending in a signed manifest",
+            },
+            recipients: {
+              type: "array",
+              items: str,
+              description: "EXPORT_RECIPIENTS IDs; encrypts each event category for the recipients entitled to it",
+            },
+          },
+          [],
+        ),
+        responses: {
+          202: { ...ok({ job: ref("ExportJob") }), description: "Accepted" },
+          ...badRequest,
+          ...unauthorized,
+        },
+      },
+    },
+    "/v1/exports/{jobId}": {
+      get: {
+        operationId: "getExport",
+        ...auth("audit:read:any"),
+        parameters: [{ name: "jobId", in: "path", required: true, schema: str }],
+        responses: { 200: ok({ job: ref("ExportJob") }), 404: { description: "Not found" }, ...unauthorized },
+      },
+    },
+    "/v1/exports/{jobId}/download": {
+      get: {
+        operationId: "downloadExport",
+        ...auth("audit:read:any"),
+        description:
+          "tar.gz with events.<format> (ecs: events.ecs.ndjson, prov: events.prov.jsonld), manifest.json " +
+          "and manifest.jws (the manifest signed with the gateway key). Exports with recipients hold one " +
+          "events.<category>.<format>.jwe per event category instead (JWE JSON, ECDH-ES+A256KW over X25519).",
+        parameters: [{ name: "jobId", in: "path", required: true, schema: str }],
+        responses: {
+          200: { description: "OK", content: { "application/gzip": { schema: { type: "string", format: "binary" } } } },
+          404: { description: "Not found" },
+          409: { description: "Job not completed", content: { "application/json": { schema: ref("Error") } } },
+          ...unauthorized,
+        },
+      },
+    },
+    "/v1/reviews/access": {
+      post: {
+        operationId: "createAccessReview",
+        ...auth("audit:read:any"),
+        description: "Build an issuer's quarterly access review: every verifier that checked its credentials.",
+        requestBody: body({ issuerId: str, quarter: { type: "string", example: "2026-Q3" } }, ["issuerId", "quarter"]),
+        responses: {
+          201: { ...ok({ review: ref("AccessReview") }), description: "Created" },
+          ...badRequest,
+          ...unauthorized,
+        },
+      },
+    },
+    "/v1/reviews/access/{reviewId}": {
+      get: {
+        operationId: "getAccessReview",
+        ...auth("audit:read:any"),
+        parameters: [{ name: "reviewId", in: "path", required: true, schema: str }],
+        responses: { 200: ok({ review: ref("AccessReview") }), 404: { description: "Not found" }, ...unauthorized },
+      },
+    },
+    "/v1/reviews/access/{reviewId}/signoff": {
+      post: {
+        operationId: "signOffAccessReview",
+        ...auth("audit:read:any"),
+        parameters: [{ name: "reviewId", in: "path", required: true, schema: str }],
+        requestBody: body(
+          { decision: { type: "string", enum: ["Approved", "Rejected"] }, comments: str },
+          ["decision"],
+        ),
+        responses: {
+          200: ok({ review: ref("AccessReview"), event: ref("AccessEvent") }),
+          404: { description: "Not found" },
+          ...badRequest,
+          ...unauthorized,
+        },
+      },
+    },
+    "/v1/reviews/break-glass": {
+      get: {
+        operationId: "listBreakGlassReviews",
+        ...auth("audit:read:any"),
+        parameters: [
+          {
+            name: "status",
+            in: "query",
+            schema: { type: "string", enum: ["Pending", "Justified", "Unjustified"], default: "Pending" },
+          },
+        ],
+        responses: { 200: ok({ reviews: { type: "array", items: ref("BreakGlassReview") } }), ...unauthorized },
+      },
+    },
+    "/v1/reviews/break-glass/{eventId}": {
+      post: {
+        operationId: "reviewBreakGlass",
+        ...auth("registry:admin"),
+        parameters: [{ name: "eventId", in: "path", required: true, schema: str }],
+        requestBody: body(
+          { decision: { type: "string", enum: ["Justified", "Unjustified"] }, notes: str },
+          ["decision"],
+        ),
+        responses: {
+          200: ok({ review: ref("BreakGlassReview"), event: ref("AccessEvent") }),
+          404: { description: "Not found" },
+          ...badRequest,
+          ...unauthorized,
+        },
+      },
+    },
+    "/v1/admin/config": {
+      get: {
+        operationId: "getConfig",
+        description: "Effective gateway settings with their source (env, profile or default); secrets are not listed.",
+        ...auth("registry:admin"),
+        responses: { 200: ok({ config: { type: "object" } }), ...unauthorized },
+      },
+    },
+    "/v1/admin/holder-blocks": {
+      get: {
+        operationId: "listHolderBlocks",
+        description: "Holders blocked for fraud cases (chaincode GetBlockedHolders).",
+        ...auth("registry:admin"),
+        responses: { 200: ok({ blocks: { type: "array", items: ref("HolderBlock") } }), ...unauthorized },
+      },
+      post: {
+        operationId: "blockHolder",
+        description:
+          "Block a holder DID under a fraud case: issuance and verification of its credentials are denied " +
+          "and recorded until it is unblocked. The HolderBlock event names the case, not the reason.",
+        ...auth("registry:admin"),
+        requestBody: body({ holderDid: str, caseId: str, reason: str }, ["holderDid", "caseId"]),
+        responses: {
+          201: ok({ block: ref("HolderBlock"), event: ref("AccessEvent") }),
+          409: { description: "Already blocked", content: { "application/json": { schema: ref("Error") } } },
+          ...badRequest,
+          ...unauthorized,
+        },
+      },
+    },
+    "/v1/admin/holder-blocks/{holderDid}": {
+      delete: {
+        operationId: "unblockHolder",
+        ...auth("registry:admin"),
+        parameters: [{ name: "holderDid", in: "path", required: true, schema: str }],
+        responses: {
+          200: ok({ event: ref("AccessEvent") }),
+          404: { description: "Not blocked" },
+          ...unauthorized,
+        },
+      },
+    },
+    "/.well-known/jwks.json": {
+      get: {
+        operationId: "getGatewayKeys",
+        description: "Public keys that sign consent receipts and export manifests.",
+        responses: { 200: { description: "OK", content: { "application/json": { schema: { type: "object" } } } } },
+      },
+    },
+    "/.well-known/credential-status/{listId}": {
+      get: {
+        operationId: "getStatusList",
+        description:
+          "W3C Bitstring Status List credential (revocation) named by credentialStatus.statusListCredential. " +
+          "Public and cacheable; application/vc+jwt returns it signed by the issuer DID's key.",
+        parameters: [{ name: "listId", in: "path", required: true, schema: str }],
+        responses: {
+          200: {
+            description: "OK",
+            content: { "application/vc": { schema: { type: "object" } }, "application/vc+jwt": { schema: str } },
+          },
+          404: { description: "Not found" },
+        },
+      },
+    },
+    "/.well-known/did.json": {
+      get: {
+        operationId: "getIssuerDidDocument",
+        description: "did:web document of the status list issuer (STATUS_LIST_ISSUER), naming the gateway key.",
+        responses: { 200: { description: "OK", content: { "application/did+json": { schema: { type: "object" } } } } },
+      },
+    },
+    "/metrics": {
+      get: {
+        operationId: "getMetrics",
+        description:
+          "Synthetic monitoring (CANARY_INTERVAL_SECONDS) and revocation latency SLO metrics, " +
+          "in Prometheus text format.",
+        responses: { 200: { description: "OK", content: { "text/plain": { schema: str } } } },
+      },
+    },
+    "/1.0/identifiers/{did}": {
+      get: {
+        operationId: "resolveDid",
+        description: "Universal-resolver compatible DID resolution, cached by the gateway.",
+        parameters: [{ name: "did", in: "path", required: true, schema: str }],
+        responses: {
+          200: { description: "OK", content: { "application/did-resolution+json": { schema: ref("DIDResolution") } } },
+          ...Object.fromEntries(
+            [[400, "Invalid DID"], [404, "Not found"], [501, "Method not supported"]].map(([code, description]) => [
+              code,
+              { description, content: { "application/json": { schema: ref("DIDResolution") } } },
+            ]),
+          ),
+        },
+      },
+    },
+  },
+  components: {
+    securitySchemes: {
+      apiKey: { type: "apiKey", in: "header", name: "X-API-Key" },
+      bearer: { type: "http", scheme: "bearer", bearerFormat: "JWT" },
+    },
+    schemas: {
+      Error: {
+        type: "object",
+        properties: { ok: { type: "boolean" }, error: str },
+        required: ["ok", "error"],
+      },
+      Credential: {
+        type: "object",
+        properties: {
+          credId: str,
+          holderDid: str,
+          credType: str,
+          hashedData: str,
+          issuerId: str,
+          holderType: { type: "string", enum: ["Individual", "Organization"] },
+          legalEntityId: str,
+          status: str,
+          expiresAt: { type: "string", format: "date-time" },
+          createdAt: { type: "string", format: "date-time" },
+          updatedAt: { type: "string", format: "date-time" },
+          credentialStatus: ref("CredentialStatusEntry"),
+        },
+      },
+      CredentialStatusEntry: {
+        type: "object",
+        description: "BitstringStatusListEntry to embed in the credential's VC",
+        properties: {
+          id: str,
+          type: { type: "string", enum: ["BitstringStatusListEntry"] },
+          statusPurpose: { type: "string", enum: ["revocation"] },
+          statusListIndex: str,
+          statusListCredential: str,
+        },
+      },
+      AccessEvent: {
+        type: "object",
+        properties: {
+          eventId: str,
+          credId: str,
+          holderDid: { type: "string", description: "holder DID, or its epoch pseudonym (pn:...) if enabled" },
+          action: str,
+          actorId: str,
+          outcome: str,
+          reason: str,
+          occurredAt: { type: "string", format: "date-time" },
+          eventCategory: { type: "string", enum: ["CredentialLifecycle", "Access", "Consent", "Review"] },
+          severity: { type: "string", enum: ["Informational", "Notice", "Warning", "Critical"] },
+          sourceComponent: str,
+          walletAttestation: { type: "string", enum: ["Valid", "Invalid", "Unverified"] },
+          walletPlatform: str,
+          signature: {
+            type: "string",
+            description: "Detached JWS (EdDSA) by the recording org over the event's canonical JSON without this field",
+          },
+        },
+      },
+      HolderSummary: {
+        type: "object",
+        properties: {
+          holderDid: str,
+          credentials: { type: "integer" },
+          byStatus: { type: "object", additionalProperties: { type: "integer" } },
+          byCredType: { type: "object", additionalProperties: { type: "integer" } },
+          latestActivityAt: { type: "string", format: "date-time" },
+          latestEventId: str,
+          consents: { type: "array", items: ref("Consent") },
+        },
+      },
+      ExportJob: {
+        type: "object",
+        properties: {
+          jobId: str,
+          status: { type: "string", enum: ["Queued", "Running", "Completed", "Failed"] },
+          requestedBy: str,
+          spec: { type: "object" },
+          eventCount: { type: "integer" },
+          archiveBytes: { type: "integer" },
+          archiveSha256: str,
+          manifest: { type: "object" },
+          error: str,
+          createdAt: { type: "string", format: "date-time" },
+          startedAt: { type: "string", format: "date-time" },
+          completedAt: { type: "string", format: "date-time" },
+          expiresAt: { type: "string", format: "date-time" },
+        },
+      },
+      AccessReview: {
+        type: "object",
+        properties: {
+          package: {
+            type: "object",
+            properties: {
+              reviewId: str,
+              issuerId: str,
+              quarter: str,
+              from: { type: "string", format: "date-time" },
+              to: { type: "string", format: "date-time", description: "exclusive" },
+              requestedBy: str,
+              generatedAt: { type: "string", format: "date-time" },
+              totals: { type: "object", additionalProperties: { type: "integer" } },
+              verifiers: { type: "array", items: ref("VerifierAccess") },
+            },
+          },
+          jws: { type: "string", description: "the package signed with the gateway key" },
+          signOff: {
+            type: "object",
+            properties: {
+              status: { type: "string", enum: ["Pending", "Approved", "Rejected"] },
+              reviewer: str,
+              comments: str,
+              decidedAt: { type: "string", format: "date-time" },
+            },
+          },
+        },
+      },
+      PseudonymLinkage: {
+        type: "object",
+        properties: { pseudonym: str, holderDid: str, epoch: { type: "integer" } },
+      },
+      RevocationNotice: {
+        type: "object",
+        properties: {
+          credId: str,
+          recipientDid: str,
+          issuerId: str,
+          status: { type: "string", enum: ["Sent", "Acknowledged"] },
+          sentBy: str,
+          sentAt: { type: "string", format: "date-time" },
+          ackMessage: { type: "string", description: "what the recipient signs to acknowledge" },
+          ackKey: { type: "string", description: "DID key that signed the acknowledgment" },
+          ackedAt: { type: "string", format: "date-time" },
+        },
+      },
+      BreakGlassReview: {
+        type: "object",
+        properties: {
+          eventId: str,
+          credId: str,
+          holderDid: str,
+          verifierId: str,
+          justificationCode: str,
+          requestedBy: str,
+          requestedAt: { type: "string", format: "date-time" },
+          status: { type: "string", enum: ["Pending", "Justified", "Unjustified"] },
+          reviewedBy: str,
+          reviewNotes: str,
+          reviewedAt: { type: "string", format: "date-time" },
+        },
+      },
+      VerifierAccess: {
+        type: "object",
+        properties: {
+          verifierId: str,
+          verifications: { type: "integer" },
+          breakGlass: { type: "integer" },
+          denied: { type: "integer" },
+          consentCovered: { type: "integer", description: "allowed checks made under a holder consent in force" },
+          holders: { type: "array", items: str },
+          credentials: { type: "array", items: str },
+          purposes: { type: "array", items: str },
+        },
+      },
+      DIDResolution: {
+        type: "object",
+        properties: {
+          didDocument: { type: "object", nullable: true },
+          didResolutionMetadata: { type: "object", properties: { contentType: str, error: str, errorMessage: str } },
+          didDocumentMetadata: { type: "object" },
+        },
+      },
+      VerificationResult: {
+        type: "object",
+        properties: {
+          credId: str,
+          isActive: { type: "boolean" },
+          hashMatches: { type: "boolean" },
+          denied: { type: "boolean" },
+          denialReason: str,
+          checkedAt: { type: "string", format: "date-time" },
+          // Positive results only; also sent as Cache-Control max-age on /v1/verify.
+          recommendedRecheckAfter: { type: "string", format: "date-time" },
+          validAsOfBlock: { type: "integer", minimum: 0 },
+        },
+      },
+      VerifyRequest: {
+        type: "object",
+        properties: {
+          token: str,
+          credId: str,
+          verifierId: str,
+          challenge: str,
+          status: { type: "string", enum: ["Pending", "Completed", "Expired"] },
+          createdAt: { type: "string", format: "date-time" },
+          expiresAt: { type: "string", format: "date-time" },
+          completedAt: { type: "string", format: "date-time" },
+          holderKey: { type: "string", description: "verification method that signed the challenge" },
+          result: ref("VerificationResult"),
+        },
+      },
+      Consent: {
+        type: "object",
+        properties: {
+          verifierId: str,
+          purpose: str,
+          status: { type: "string", enum: ["Granted", "Revoked"] },
+          grantedAt: { type: "string", format: "date-time" },
+          revokedAt: { type: "string", format: "date-time" },
+          receiptId: str,
+          receipt: { type: "string", description: "latest consent receipt, compact JWS" },
+        },
+      },
+      ConsentReceiptEnvelope: {
+        type: "object",
+        properties: {
+          receipt: {
+            type: "object",
+            description: "Kantara Consent Receipt v1.1 claims, plus an audittrail extension linking the audit event",
+          },
+          jws: str,
+        },
+      },
+      RevocationAssertion: {
+        type: "object",
+        properties: {
+          credId: str,
+          issuerId: str,
+          sourceChannel: str,
+          txId: str,
+          signerMsp: str,
+          signerId: str,
+          revokedAt: { type: "string", format: "date-time" },
+          digest: { type: "string", description: "hex sha256 of the assertion with digest empty and no signature" },
+          signature: { type: "string", description: "relay's base64url Ed25519 signature over digest" },
+        },
+      },
+      MirroredRevocation: {
+        type: "object",
+        properties: {
+          assertion: ref("RevocationAssertion"),
+          importedAt: { type: "string", format: "date-time" },
+          applied: { type: "boolean", description: "a local copy of the credential was revoked" },
+        },
+      },
+      RevocationPropagation: {
+        type: "object",
+        properties: {
+          credId: str,
+          statusList: { type: "integer", nullable: true },
+          requestedAt: { type: "string", format: "date-time" },
+          committedAt: { type: "string", format: "date-time" },
+          publishedAt: {
+            type: "string",
+            format: "date-time",
+            nullable: true,
+            description: "first serving of the status list showing the revocation",
+          },
+          listStaleUntil: { type: "string", format: "date-time", nullable: true },
+          verifyStaleUntil: { type: "string", format: "date-time", nullable: true },
+          effectiveAt: { type: "string", format: "date-time" },
+          commitMs: { type: "integer" },
+          publishMs: { type: "integer", nullable: true },
+          effectiveMs: { type: "integer" },
+          sloSeconds: { type: "integer" },
+          withinSlo: { type: "boolean" },
+        },
+      },
+      OfflineBundle: {
+        type: "object",
+        description: "Payload of the bundle JWS (typ audittrail-offline-bundle+jwt).",
+        properties: {
+          bundleId: str,
+          validFrom: { type: "string", format: "date-time" },
+          validUntil: { type: "string", format: "date-time" },
+          credential: ref("Credential"),
+          inclusion: {
+            type: "object",
+            description: "The credential's latest state event under a checkpoint of the holder's trail",
+            properties: {
+              eventJson: str,
+              leaf: str,
+              proof: { type: "array", items: { type: "object", properties: { hash: str, position: str } } },
+              checkpoint: { type: "object" },
+            },
+          },
+          keys: {
+            type: "object",
+            properties: {
+              gateway: { type: "object" },
+              issuer: { type: "object" },
+              statusListIssuer: { type: "object" },
+            },
+          },
+        },
+      },
+      EventFilter: {
+        type: "object",
+        description: "Allowed values per field; empty or missing lists match anything.",
+        properties: {
+          credTypes: { type: "array", items: str },
+          actions: { type: "array", items: str },
+          issuerIds: { type: "array", items: str },
+          outcomes: { type: "array", items: str },
+          categories: { type: "array", items: str },
+        },
+      },
+      HeatmapCell: {
+        type: "object",
+        properties: {
+          verifierId: str,
+          credType: str,
+          dayOfWeek: { type: "integer", minimum: 1, maximum: 7, description: "ISO 8601, 1 is Monday" },
+          hour: { type: "integer", minimum: 0, maximum: 23 },
+          count: { type: "integer" },
+          failures: { type: "integer", description: "verifications that did not succeed" },
+        },
+      },
+      Digest: {
+        type: "object",
+        properties: {
+          issuerId: str,
+          window: timeWindow,
+          generatedAt: { type: "string", format: "date-time" },
+          totals: {
+            type: "object",
+            properties: {
+              issued: { type: "integer" },
+              renewed: { type: "integer" },
+              verified: { type: "integer" },
+              revoked: { type: "integer" },
+              anomalies: { type: "integer" },
+            },
+          },
+          byCredType: {
+            type: "array",
+            items: {
+              type: "object",
+              properties: {
+                credType: str,
+                issued: { type: "integer" },
+                renewed: { type: "integer" },
+                verified: { type: "integer" },
+                revoked: { type: "integer" },
+              },
+            },
+          },
+          anomalies: {
+            type: "array",
+            items: {
+              type: "object",
+              properties: {
+                kind: {
+                  type: "string",
+                  enum: ["VerificationDenied", "BreakGlassVerify", "RevokedByOther", "RevokedVerified"],
+                },
+                eventId: str,
+                credId: str,
+                actorId: str,
+                occurredAt: { type: "string", format: "date-time" },
+                link: { type: "string", format: "uri", description: "drill-down view" },
+              },
+            },
+          },
+          links: {
+            type: "object",
+            properties: { digest: { type: "string", format: "uri" }, heatmap: { type: "string", format: "uri" } },
+          },
+        },
+      },
+      DigestSchedule: {
+        type: "object",
+        properties: {
+          issuerId: str,
+          frequency: { type: "string", enum: ["daily", "weekly"] },
+          timeZone: str,
+          recipients: { type: "integer" },
+          last: {
+            type: "object",
+            nullable: true,
+            properties: {
+              periodTo: { type: "string", format: "date-time" },
+              sentAt: { type: "string", format: "date-time" },
+              delivered: { type: "integer" },
+              failures: {
+                type: "array",
+                items: { type: "object", properties: { to: str, error: str } },
+              },
+            },
+          },
+        },
+      },
+      HolderBlock: {
+        type: "object",
+        properties: {
+          holderDid: str,
+          caseId: str,
+          reason: str,
+          blockedBy: str,
+          blockedAt: { type: "string", format: "date-time" },
+        },
+      },
+      Saga: {
+        type: "object",
+        properties: {
+          sagaId: str,
+          type: { type: "string", enum: ["issue"] },
+          credId: str,
+          holderDid: str,
+          credType: str,
+          status: { type: "string", enum: ["Running", "Compensating", "Completed", "Aborted", "Stuck"] },
+          steps: {
+            type: "array",
+            items: {
+              type: "object",
+              properties: {
+                name: { type: "string", enum: ["reserve", "proof", "store", "commit"] },
+                status: { type: "string", enum: ["Pending", "Done", "Skipped", "Failed", "Compensated"] },
+                result: { type: "object" },
+                error: str,
+                compensationError: str,
+                at: { type: "string", format: "date-time" },
+              },
+            },
+          },
+          error: str,
+          requestedBy: str,
+          createdAt: { type: "string", format: "date-time" },
+          updatedAt: { type: "string", format: "date-time" },
+        },
+      },
+      EventSubscription: {
+        type: "object",
+        properties: {
+          subscriptionId: str,
+          consumer: str,
+          channel: { type: "string", enum: ["webhook", "kafka"] },
+          target: str,
+          filter: ref("EventFilter"),
+          secret: { type: "string", description: "webhook HMAC-SHA256 key; only in the creation response" },
+          createdAt: { type: "string", format: "date-time" },
+          delivered: { type: "integer" },
+          failed: { type: "integer" },
+          lastDeliveredAt: { type: "string", format: "date-time", nullable: true },
+          lastError: { type: "object", nullable: true },
+        },
+      },
+      Device: {
+        type: "object",
+        properties: {
+          deviceId: str,
+          platform: { type: "string", enum: ["fcm", "apns"] },
+          actions: { type: "array", items: str },
+          createdAt: { type: "string", format: "date-time" },
+          delivered: { type: "integer" },
+          failed: { type: "integer" },
+          lastDeliveredAt: { type: "string", format: "date-time", nullable: true },
+          lastError: { type: "object", nullable: true },
+        },
+      },
+      Subscription: {
+        type: "object",
+        properties: {
+          subscriptionId: str,
+          channel: { type: "string", enum: ["email", "sms"] },
+          target: str,
+          actions: { type: "array", items: str },
+          locale: str,
+          timeZone: str,
+          createdAt: { type: "string", format: "date-time" },
+          delivered: { type: "integer" },
+          failed: { type: "integer" },
+          lastDeliveredAt: { type: "string", format: "date-time", nullable: true },
+          lastError: { type: "object", nullable: true },
+        },
+      },
+    },
+  },
+};
@@ -0,0 +1,63 @@
+// External policy check. When OPA_URL is set, every call that passed its
+// scope check is also put to OPA, so consortiums can express rules beyond
+// scopes (per-principal, per-route, time-based ...) without a gateway
+// release. The bundled policy in ./policy runs as-is:
+//
+//   opa run --server --addr :8181 api/policy       # then OPA_URL=http://localhost:8181
+//
+// or point OPA_URL at a shared OPA serving its own bundle.
+//
+//   OPA_URL=...                  OPA base URL; unset disables the check
+//   OPA_POLICY=audittrail/authz  package path queried under /v1/data
+//   OPA_TIMEOUT_MS=2000
+//   OPA_FAIL_OPEN=true           allow calls when OPA is unreachable (default: deny)
+//
+// The chaincode checks its own, simpler AccessPolicy before every
+// transaction (contracts/policy.go); keep the two rule sets in step.
+
+const OPA_URL = (process.env.OPA_URL || "").replace(/\/$/, "");
+const OPA_POLICY = process.env.OPA_POLICY || "audittrail/authz";
+const OPA_TIMEOUT_MS = Number(process.env.OPA_TIMEOUT_MS || 2000);
+const OPA_FAIL_OPEN = process.env.OPA_FAIL_OPEN === "true";
+
+export const POLICY_ENABLED = Boolean(OPA_URL);
+
+// policyInput is what the policy sees. Request bodies are left out: they
+// can carry personal data, and the route plus principal cover the rules
+// the consortium has asked for so far.
+const policyInput = (req, principal, required) => {
+  const path = req.route ? req.baseUrl + req.route.path : req.originalUrl.split("?")[0];
+  return {
+    method: req.method,
+    route: `${req.method} ${path}`,
+    principal: {
+      sub: principal.sub,
+      via: principal.via,
+      holderDid: principal.holderDid,
+      scopes: principal.scopes,
+    },
+    required,
+    params: req.params || {},
+    query: req.query || {},
+  };
+};
+
+// checkPolicy resolves to { allow, reasons }. OPA errors resolve per
+// OPA_FAIL_OPEN rather than throw.
+export const checkPolicy = async (req, principal, required) => {
+  try {
+    const res = await fetch(`${OPA_URL}/v1/data/${OPA_POLICY}`, {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ input: policyInput(req, principal, required) }),
+      signal: AbortSignal.timeout(OPA_TIMEOUT_MS),
+    });
+    if (!res.ok) throw new Error(`OPA returned ${res.status}`);
+    const { result } = await res.json();
+    if (result === undefined) throw new Error(`OPA policy ${OPA_POLICY} is not loaded`);
+    const allow = typeof result === "boolean" ? result : result.allow === true;
+    return { allow, reasons: Array.isArray(result.reasons) ? result.reasons : [] };
+  } catch (err) {
+    return { allow: OPA_FAIL_OPEN, reasons: [`policy check failed: ${err.message}`] };
+  }
+};
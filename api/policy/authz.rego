@@ -0,0 +1,56 @@
+# Gateway authorization policy, evaluated by OPA for every scoped API call
+# after the gateway's own scope check has passed (see ../policy.js).
+#
+# Input:
+#   method, route ("POST /v1/verify", route patterns not concrete paths),
+#   principal {sub, via, holderDid, scopes}, required (scopes), params, query
+#
+# Consortium rules live in data.audittrail.rules (data.json) so they can be
+# changed without touching this file, in the same shape as the chaincode's
+# AccessPolicy: the first matching rule decides, otherwise data.audittrail.default.
+package audittrail.authz
+
+import rego.v1
+
+default allow := false
+
+allow if decision == "allow"
+
+reasons contains sprintf("rule %s denies %s", [first_match.id, input.route]) if decision == "deny"
+
+reasons contains sprintf("default policy denies %s", [input.route]) if {
+	decision == "deny"
+	not first_match
+}
+
+decision := first_match.effect
+
+decision := object.get(data.audittrail, "default", "allow") if not first_match
+
+first_match := rules[min({i | some i; rule_matches(rules[i])})]
+
+rules := object.get(data.audittrail, "rules", [])
+
+rule_matches(rule) if {
+	route_matches(rule)
+	principal_matches(rule)
+	scope_matches(rule)
+}
+
+route_matches(rule) if not rule.routes
+
+route_matches(rule) if {
+	some pattern in rule.routes
+	glob.match(pattern, [], input.route)
+}
+
+principal_matches(rule) if not rule.principals
+
+principal_matches(rule) if input.principal.sub in rule.principals
+
+scope_matches(rule) if not rule.scopes
+
+scope_matches(rule) if {
+	some sc in rule.scopes
+	sc in input.principal.scopes
+}
@@ -0,0 +1,118 @@
+// W3C PROV-O export. A set of audit events becomes one JSON-LD provenance
+// graph so the trail loads into existing provenance tooling (triple stores,
+// PROV validators, lineage viewers):
+//
+//   credential          prov:Entity
+//   issuer, holder,     prov:Agent (DIDs are used as IRIs as they are;
+//   verifier, ...       other actor IDs become urn:audittrail:actor:<id>)
+//   each event          prov:Activity, typed with its action
+//
+// Issue generates the credential and attributes it to the issuer; Revoke
+// and Expire invalidate it; every other event on a credential uses it.
+// Activities are associated with the event's actor.
+//
+// holderExport wraps a holder's own trail for data portability: their
+// credentials join the graph as W3C Verifiable Credentials data model 2.0
+// nodes (unsigned; the ledger record, not the issued document), and the
+// pseudonyms the trail names them by are tied to their DID with owl:sameAs.
+
+const NS = "urn:audittrail:";
+
+const CONTEXT = {
+  prov: "http://www.w3.org/ns/prov#",
+  xsd: "http://www.w3.org/2001/XMLSchema#",
+  at: NS,
+};
+const VC_CONTEXT = "https://www.w3.org/ns/credentials/v2";
+
+const ref = (id) => ({ "@id": id });
+const agentId = (id) => (id.startsWith("did:") ? id : `${NS}actor:${encodeURIComponent(id)}`);
+const credIri = (credId) => `${NS}cred:${encodeURIComponent(credId)}`;
+
+// toProv returns the PROV-O JSON-LD document for events, oldest first.
+export const toProv = (events) => {
+  const nodes = new Map(); // @id -> node
+  const node = (id, type) => {
+    if (!nodes.has(id)) nodes.set(id, { "@id": id, "@type": type });
+    return nodes.get(id);
+  };
+  const add = (n, prop, value) => {
+    if (n[prop] === undefined) n[prop] = value;
+    else n[prop] = [].concat(n[prop], value);
+  };
+
+  for (const e of [...events].sort((a, b) => (a.occurredAt < b.occurredAt ? -1 : 1))) {
+    const activity = node(`${NS}event:${e.eventId}`, ["prov:Activity", `at:${e.action}`]);
+    activity["prov:startedAtTime"] = { "@value": e.occurredAt, "@type": "xsd:dateTime" };
+    activity["prov:endedAtTime"] = activity["prov:startedAtTime"];
+    activity["at:outcome"] = e.outcome;
+    if (e.reason) activity["at:reason"] = e.reason;
+    if (e.eventCategory) activity["at:eventCategory"] = e.eventCategory;
+    if (e.severity) activity["at:severity"] = e.severity;
+    if (e.signature) activity["at:signature"] = e.signature;
+    if (e.actorId) {
+      node(agentId(e.actorId), "prov:Agent");
+      activity["prov:wasAssociatedWith"] = ref(agentId(e.actorId));
+    }
+    if (e.holderDid) {
+      node(agentId(e.holderDid), "prov:Agent");
+      activity["at:holder"] = ref(agentId(e.holderDid));
+    }
+    if (!e.credId) continue;
+
+    const cred = node(credIri(e.credId), "prov:Entity");
+    cred["at:credId"] = e.credId;
+    if (e.holderDid) cred["at:holder"] = ref(agentId(e.holderDid));
+    if (e.action === "Issue" && e.outcome === "Success") {
+      cred["prov:wasGeneratedBy"] = ref(activity["@id"]);
+      cred["prov:generatedAtTime"] = activity["prov:startedAtTime"];
+      if (e.actorId) cred["prov:wasAttributedTo"] = ref(agentId(e.actorId));
+    } else if (["Revoke", "Expire"].includes(e.action) && e.outcome === "Success") {
+      cred["prov:wasInvalidatedBy"] = ref(activity["@id"]);
+      cred["prov:invalidatedAtTime"] = activity["prov:startedAtTime"];
+    } else {
+      add(activity, "prov:used", ref(cred["@id"]));
+    }
+  }
+  return { "@context": CONTEXT, "@graph": [...nodes.values()] };
+};
+
+// holderExport returns the portability document for holderDid: their
+// credentials and the events of their trail, oldest first.
+export const holderExport = (holderDid, credentials, events, exportId, exportedAt) => {
+  const { "@graph": graph } = toProv(events);
+  const nodes = new Map(graph.map((n) => [n["@id"], n]));
+  const dateTime = (v) => ({ "@value": v, "@type": "xsd:dateTime" });
+
+  for (const c of credentials) {
+    const node = nodes.get(credIri(c.credId)) || { "@id": credIri(c.credId), "at:credId": c.credId };
+    nodes.set(node["@id"], node);
+    Object.assign(node, {
+      "@type": ["VerifiableCredential", "prov:Entity"],
+      issuer: agentId(c.issuerId),
+      validFrom: c.createdAt,
+      ...(c.expiresAt && { validUntil: c.expiresAt }),
+      credentialSubject: { id: holderDid, ...(c.holderType && { "at:holderType": c.holderType }) },
+      ...(c.credentialStatus && { credentialStatus: c.credentialStatus }),
+      "at:credType": c.credType,
+      "at:hashedData": c.hashedData,
+      "at:status": c.status,
+      "at:updatedAt": dateTime(c.updatedAt),
+    });
+  }
+  for (const e of events) {
+    const id = agentId(e.holderDid || "");
+    if (e.holderDid && e.holderDid !== holderDid && nodes.has(id)) nodes.get(id)["owl:sameAs"] = ref(holderDid);
+  }
+
+  const exported = {
+    "@id": `${NS}export:${exportId}`,
+    "@type": "prov:Entity",
+    "prov:generatedAtTime": dateTime(exportedAt),
+    "at:subject": ref(holderDid),
+  };
+  return {
+    "@context": [VC_CONTEXT, { ...CONTEXT, owl: "http://www.w3.org/2002/07/owl#" }],
+    "@graph": [exported, ...nodes.values()],
+  };
+};
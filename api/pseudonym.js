@@ -0,0 +1,70 @@
+// Holder pseudonyms, the gateway's counterpart of contracts/pseudonym.go.
+// With PSEUDONYM_EPOCH_DAYS set, recorded events name the holder (and the
+// holder as actor, e.g. on consent changes) by an HMAC of their DID and the
+// current epoch, so the trail cannot be correlated across epochs by DID.
+// The gateway still finds a holder's events by re-deriving each event's
+// pseudonym. Each pseudonym's linkage to the DID is kept sealed (AES-GCM
+// under a key derived from PSEUDONYM_KEY) and opened only for callers with
+// audit:link, through GET /v1/pseudonyms/:pseudonym.
+//
+//   PSEUDONYM_KEY=...          at least 32 bytes; the same key the gateway
+//                              passes to chaincode as transient pseudonymKey
+//   PSEUDONYM_EPOCH_DAYS=30    rotation period; unset or 0 turns this off
+
+import crypto from "node:crypto";
+
+const KEY = process.env.PSEUDONYM_KEY || "";
+const EPOCH_DAYS = Number(process.env.PSEUDONYM_EPOCH_DAYS || 0);
+export const PSEUDONYMS_ENABLED = EPOCH_DAYS > 0;
+if (PSEUDONYMS_ENABLED && Buffer.byteLength(KEY) < 32) {
+  throw new Error("PSEUDONYM_EPOCH_DAYS needs a PSEUDONYM_KEY of at least 32 bytes");
+}
+
+const PREFIX = "pn:";
+const SEAL_KEY = PSEUDONYMS_ENABLED ? Buffer.from(crypto.hkdfSync("sha256", KEY, "", "linkage", 32)) : null;
+const linkages = new Map(); // pseudonym -> sealed linkage
+
+const epochOf = (at) => Math.floor(Date.parse(at) / 1000 / (EPOCH_DAYS * 86400));
+
+const pseudonymFor = (holderDid, epoch) => {
+  const mac = crypto.createHmac("sha256", KEY).update(`${holderDid}|${epoch}`).digest();
+  return `${PREFIX}${epoch}:${mac.subarray(0, 16).toString("base64url")}`;
+};
+
+const seal = (linkage) => {
+  const iv = crypto.randomBytes(12);
+  const cipher = crypto.createCipheriv("aes-256-gcm", SEAL_KEY, iv);
+  const ct = Buffer.concat([cipher.update(JSON.stringify(linkage)), cipher.final()]);
+  return Buffer.concat([iv, cipher.getAuthTag(), ct]).toString("base64url");
+};
+
+const unseal = (sealed) => {
+  const raw = Buffer.from(sealed, "base64url");
+  const decipher = crypto.createDecipheriv("aes-256-gcm", SEAL_KEY, raw.subarray(0, 12));
+  decipher.setAuthTag(raw.subarray(12, 28));
+  return JSON.parse(Buffer.concat([decipher.update(raw.subarray(28)), decipher.final()]).toString());
+};
+
+// pseudonymize replaces the holder's DID on evt with its epoch pseudonym
+// and keeps the sealed linkage. Call it before the event is signed.
+export const pseudonymize = (evt) => {
+  if (!PSEUDONYMS_ENABLED || !evt.holderDid || evt.holderDid.startsWith(PREFIX)) return evt;
+  const epoch = epochOf(evt.occurredAt);
+  const pseudonym = pseudonymFor(evt.holderDid, epoch);
+  if (!linkages.has(pseudonym)) linkages.set(pseudonym, seal({ pseudonym, holderDid: evt.holderDid, epoch }));
+  if (evt.actorId === evt.holderDid) evt.actorId = pseudonym;
+  evt.holderDid = pseudonym;
+  return evt;
+};
+
+// belongsTo reports whether evt was recorded for holderDid, under its DID
+// or under the pseudonym of the event's epoch.
+export const belongsTo = (evt, holderDid) =>
+  evt.holderDid === holderDid ||
+  (PSEUDONYMS_ENABLED && evt.holderDid === pseudonymFor(holderDid, epochOf(evt.occurredAt)));
+
+// resolvePseudonym opens a pseudonym's linkage, or returns null.
+export const resolvePseudonym = (pseudonym) => {
+  const sealed = linkages.get(pseudonym);
+  return sealed ? unseal(sealed) : null;
+};
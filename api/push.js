@@ -0,0 +1,311 @@
+// Mobile push for holder alerts. A wallet app registers its device's push
+// token for the holder (POST /v1/me/devices), and every event recorded on
+// one of the holder's credentials whose action the device asked for is
+// pushed to it: by default revocations, expiries and verifications, so a
+// holder hears of a verification they did not expect while it happens.
+//
+//   fcm   Firebase Cloud Messaging, HTTP v1 API, authorized with an OAuth
+//         token for the service account in FCM_SERVICE_ACCOUNT_FILE
+//   apns  Apple Push Notification service over HTTP/2, authorized with an
+//         ES256 provider token from APNS_KEY_FILE
+//
+// A push carries a generic text and the eventId, credId, action and
+// outcome; reasons and verifier IDs stay off lock screens and out of the
+// push services, and the app reads them from GET /v1/me/audit.
+//
+// Device tokens are sealed (AES-GCM under a key derived from
+// PUSH_TOKEN_KEY) in the device store and opened only to send. The store
+// lives in PUSH_DEVICE_FILE when set, which then needs PUSH_TOKEN_KEY, and
+// in memory under a per-process key otherwise. A token the push service
+// reports as no longer registered is dropped. Deliveries are retried as
+// dispatch.js retries them.
+//
+//   FCM_SERVICE_ACCOUNT_FILE=/etc/audittrail/fcm.json   Google service account key (JSON)
+//   APNS_KEY_FILE=/etc/audittrail/apns.p8               with APNS_KEY_ID and APNS_TEAM_ID
+//   APNS_TOPIC=com.example.wallet                        the wallet app's bundle ID
+//   APNS_URL=https://api.push.apple.com                  https://api.sandbox.push.apple.com for development builds
+//   PUSH_TOKEN_KEY=...                                   at least 32 bytes
+//   PUSH_DEVICE_FILE=./devices.json
+//   PUSH_ALERT_ACTIONS=Revoke,Expire,Verify,VerifyAttribute,VerifySummary,BreakGlassVerify
+
+import crypto from "node:crypto";
+import fs from "node:fs";
+import http2 from "node:http2";
+import { setting } from "./config.js";
+
+const FCM_ACCOUNT = process.env.FCM_SERVICE_ACCOUNT_FILE
+  ? JSON.parse(fs.readFileSync(process.env.FCM_SERVICE_ACCOUNT_FILE, "utf8"))
+  : null;
+const APNS_KEY = process.env.APNS_KEY_FILE ? crypto.createPrivateKey(fs.readFileSync(process.env.APNS_KEY_FILE)) : null;
+const APNS_KEY_ID = process.env.APNS_KEY_ID || "";
+const APNS_TEAM_ID = process.env.APNS_TEAM_ID || "";
+const APNS_TOPIC = process.env.APNS_TOPIC || "";
+// Reloadable; see config.js.
+const apnsUrl = () => setting("sinks.apnsUrl", "https://api.push.apple.com");
+const TOKEN_KEY = process.env.PUSH_TOKEN_KEY || "";
+const DEVICE_FILE = process.env.PUSH_DEVICE_FILE || "";
+const ALERT_ACTIONS = (
+  process.env.PUSH_ALERT_ACTIONS || "Revoke,Expire,Verify,VerifyAttribute,VerifySummary,BreakGlassVerify"
+).split(",");
+const MAX_ATTEMPTS = Number(process.env.DISPATCH_MAX_ATTEMPTS || 5);
+const TIMEOUT_MS = 10000;
+
+if (DEVICE_FILE && Buffer.byteLength(TOKEN_KEY) < 32) {
+  throw new Error("PUSH_DEVICE_FILE needs a PUSH_TOKEN_KEY of at least 32 bytes");
+}
+if (APNS_KEY && !(APNS_KEY_ID && APNS_TEAM_ID && APNS_TOPIC)) {
+  throw new Error("APNS_KEY_FILE needs APNS_KEY_ID, APNS_TEAM_ID and APNS_TOPIC");
+}
+
+export const PLATFORMS = { fcm: Boolean(FCM_ACCOUNT), apns: Boolean(APNS_KEY) };
+const TOKEN_FORMATS = { fcm: /^[\w:.-]{20,4096}$/, apns: /^[0-9a-fA-F]{64,200}$/ };
+
+const SEAL_KEY = TOKEN_KEY
+  ? Buffer.from(crypto.hkdfSync("sha256", TOKEN_KEY, "", "device-token", 32))
+  : crypto.randomBytes(32);
+
+const seal = (token) => {
+  const iv = crypto.randomBytes(12);
+  const cipher = crypto.createCipheriv("aes-256-gcm", SEAL_KEY, iv);
+  const ct = Buffer.concat([cipher.update(token), cipher.final()]);
+  return Buffer.concat([iv, cipher.getAuthTag(), ct]).toString("base64url");
+};
+
+const unseal = (sealed) => {
+  const raw = Buffer.from(sealed, "base64url");
+  const decipher = crypto.createDecipheriv("aes-256-gcm", SEAL_KEY, raw.subarray(0, 12));
+  decipher.setAuthTag(raw.subarray(12, 28));
+  return Buffer.concat([decipher.update(raw.subarray(28)), decipher.final()]).toString();
+};
+
+// tokenDigest identifies a token without unsealing, to replace an earlier
+// registration of the same device.
+const tokenDigest = (token) => crypto.createHmac("sha256", SEAL_KEY).update(token).digest("base64url");
+
+const devices = new Map(); // deviceId -> device, sealed token included
+
+if (DEVICE_FILE && fs.existsSync(DEVICE_FILE)) {
+  for (const d of JSON.parse(fs.readFileSync(DEVICE_FILE, "utf8"))) devices.set(d.deviceId, d);
+}
+
+// persist rewrites the device file through a rename, as saga.js does.
+const persist = () => {
+  if (!DEVICE_FILE) return;
+  const tmp = `${DEVICE_FILE}.tmp`;
+  fs.writeFileSync(tmp, JSON.stringify([...devices.values()], null, 2));
+  fs.renameSync(tmp, DEVICE_FILE);
+};
+
+const view = ({ holderDid, sealedToken, digest, ...device }) => device;
+
+// registerDevice registers {platform, token, actions} for holderDid,
+// replacing an earlier registration of the same token.
+export const registerDevice = (holderDid, { platform, token, actions }) => {
+  if (!(platform in PLATFORMS)) throw new Error(`platform must be one of ${Object.keys(PLATFORMS).join(", ")}`);
+  if (!PLATFORMS[platform]) throw new Error(`${platform} push is not configured on this gateway`);
+  if (typeof token !== "string" || !TOKEN_FORMATS[platform].test(token)) {
+    throw new Error(`token is not a valid ${platform} device token`);
+  }
+  if (actions !== undefined && (!Array.isArray(actions) || actions.some((a) => typeof a !== "string" || !a))) {
+    throw new Error("actions must be an array of strings");
+  }
+  const digest = tokenDigest(token);
+  for (const [id, d] of devices) if (d.digest === digest) devices.delete(id);
+  const device = {
+    deviceId: crypto.randomUUID(),
+    holderDid,
+    platform,
+    sealedToken: seal(token),
+    digest,
+    actions: actions?.length ? actions : ALERT_ACTIONS,
+    createdAt: new Date().toISOString(),
+    delivered: 0,
+    failed: 0,
+    lastDeliveredAt: null,
+    lastError: null,
+  };
+  devices.set(device.deviceId, device);
+  persist();
+  return view(device);
+};
+
+export const listDevices = (holderDid) =>
+  [...devices.values()].filter((d) => d.holderDid === holderDid).map(view);
+
+export const deleteDevice = (holderDid, deviceId) => {
+  if (devices.get(deviceId)?.holderDid !== holderDid) return false;
+  devices.delete(deviceId);
+  persist();
+  return true;
+};
+
+// ===== Push services =====
+
+const jwt = (header, claims, sign) => {
+  const input = [header, claims].map((p) => Buffer.from(JSON.stringify(p)).toString("base64url")).join(".");
+  return `${input}.${sign(Buffer.from(input)).toString("base64url")}`;
+};
+
+// fcmAccessToken exchanges a service account assertion for an OAuth token
+// (RFC 7523), cached until shortly before it expires.
+let fcmToken = { value: "", expiresAt: 0 };
+const fcmAccessToken = async () => {
+  if (Date.now() < fcmToken.expiresAt - 60000) return fcmToken.value;
+  const now = Math.floor(Date.now() / 1000);
+  const tokenUri = FCM_ACCOUNT.token_uri || "https://oauth2.googleapis.com/token";
+  const assertion = jwt(
+    { alg: "RS256", typ: "JWT", kid: FCM_ACCOUNT.private_key_id },
+    {
+      iss: FCM_ACCOUNT.client_email,
+      scope: "https://www.googleapis.com/auth/firebase.messaging",
+      aud: tokenUri,
+      iat: now,
+      exp: now + 3600,
+    },
+    (data) => crypto.sign("sha256", data, FCM_ACCOUNT.private_key),
+  );
+  const res = await fetch(tokenUri, {
+    method: "POST",
+    headers: { "Content-Type": "application/x-www-form-urlencoded" },
+    body: new URLSearchParams({ grant_type: "urn:ietf:params:oauth:grant-type:jwt-bearer", assertion }),
+    signal: AbortSignal.timeout(TIMEOUT_MS),
+  });
+  const out = await res.json().catch(() => ({}));
+  if (!res.ok) throw new Error(`FCM OAuth token: HTTP ${res.status}`);
+  fcmToken = { value: out.access_token, expiresAt: Date.now() + out.expires_in * 1000 };
+  return fcmToken.value;
+};
+
+const sendFcm = async (token, alert) => {
+  const res = await fetch(`https://fcm.googleapis.com/v1/projects/${FCM_ACCOUNT.project_id}/messages:send`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json", Authorization: `Bearer ${await fcmAccessToken()}` },
+    body: JSON.stringify({ message: { token, notification: alert.notification, data: alert.data } }),
+    signal: AbortSignal.timeout(TIMEOUT_MS),
+  });
+  const out = await res.json().catch(() => ({}));
+  const code = out.error?.details?.find((d) => d.errorCode)?.errorCode;
+  return { status: res.status, gone: res.status === 404 || code === "UNREGISTERED", reason: code || out.error?.status };
+};
+
+// apnsProviderToken is refreshed every 50 minutes: APNs rejects tokens
+// older than an hour, and refreshing more often than every 20 minutes.
+let apnsToken = { value: "", issuedAt: 0 };
+const apnsProviderToken = () => {
+  if (Date.now() - apnsToken.issuedAt < 50 * 60000) return apnsToken.value;
+  const iat = Math.floor(Date.now() / 1000);
+  apnsToken = {
+    value: jwt({ alg: "ES256", kid: APNS_KEY_ID }, { iss: APNS_TEAM_ID, iat }, (data) =>
+      crypto.sign("sha256", data, { key: APNS_KEY, dsaEncoding: "ieee-p1363" }),
+    ),
+    issuedAt: Date.now(),
+  };
+  return apnsToken.value;
+};
+
+// One HTTP/2 connection is kept open to APNs, as Apple asks, and reopened
+// when it drops or APNS_URL changes.
+let apnsSession = null;
+const apnsConnection = () => {
+  if (apnsSession && !apnsSession.closed && !apnsSession.destroyed && apnsSession.url === apnsUrl()) {
+    return apnsSession;
+  }
+  apnsSession?.close();
+  const session = http2.connect(apnsUrl());
+  session.url = apnsUrl();
+  session.on("error", () => session.destroy());
+  session.on("goaway", () => session.close());
+  session.unref();
+  apnsSession = session;
+  return session;
+};
+
+const sendApns = (token, alert) =>
+  new Promise((resolve, reject) => {
+    const req = apnsConnection().request({
+      ":method": "POST",
+      ":path": `/3/device/${token}`,
+      authorization: `bearer ${apnsProviderToken()}`,
+      "apns-topic": APNS_TOPIC,
+      "apns-push-type": "alert",
+      "apns-priority": "10",
+      "content-type": "application/json",
+    });
+    req.setTimeout(TIMEOUT_MS, () => req.close(http2.constants.NGHTTP2_CANCEL));
+    let status = 0;
+    let body = "";
+    req.on("response", (headers) => (status = headers[":status"]));
+    req.on("data", (chunk) => (body += chunk));
+    req.on("end", () => {
+      let reason;
+      try {
+        reason = JSON.parse(body).reason;
+      } catch {
+        // success responses have no body
+      }
+      resolve({ status, gone: status === 410 || reason === "BadDeviceToken", reason });
+    });
+    req.on("error", reject);
+    req.end(JSON.stringify({ aps: { alert: alert.notification, sound: "default" }, ...alert.data }));
+  });
+
+const SENDERS = { fcm: sendFcm, apns: sendApns };
+
+const TEXTS = {
+  Revoke: "One of your credentials was revoked",
+  Expire: "One of your credentials expired",
+  BreakGlassVerify: "One of your credentials was accessed in an emergency",
+};
+
+const alertFor = (evt) => {
+  let body = TEXTS[evt.action];
+  if (!body) {
+    body = evt.outcome === "Success"
+      ? "One of your credentials was verified"
+      : "An attempt to verify one of your credentials was refused";
+  }
+  return {
+    notification: { title: "AuditTrail", body },
+    data: { eventId: evt.eventId, credId: evt.credId, action: evt.action, outcome: evt.outcome },
+  };
+};
+
+const push = async (device, evt) => {
+  const alert = alertFor(evt);
+  for (let attempt = 1; ; attempt++) {
+    let error;
+    try {
+      const res = await SENDERS[device.platform](unseal(device.sealedToken), alert);
+      if (res.status === 200) {
+        device.delivered++;
+        device.lastDeliveredAt = new Date().toISOString();
+        persist();
+        return;
+      }
+      if (res.gone) {
+        devices.delete(device.deviceId);
+        persist();
+        return;
+      }
+      error = `HTTP ${res.status}${res.reason ? ` ${res.reason}` : ""}`;
+      if (res.status < 500 && res.status !== 429) attempt = MAX_ATTEMPTS;
+    } catch (err) {
+      error = err.message;
+    }
+    if (attempt >= MAX_ATTEMPTS || !devices.has(device.deviceId)) {
+      device.failed++;
+      device.lastError = { at: new Date().toISOString(), eventId: evt.eventId, error };
+      persist();
+      return;
+    }
+    await new Promise((resolve) => setTimeout(resolve, 2 ** attempt * 500));
+  }
+};
+
+// notifyHolder pushes evt to holderDid's devices that asked for its action.
+// It returns at once; pushes run in the background.
+export const notifyHolder = (holderDid, evt) => {
+  for (const device of devices.values()) {
+    if (device.holderDid === holderDid && device.actions.includes(evt.action)) push(device, evt);
+  }
+};
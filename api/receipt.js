@@ -0,0 +1,69 @@
+// Consent receipts after the Kantara Initiative Consent Receipt Specification
+// v1.1. Each grant or revocation yields a receipt signed with the gateway key
+// (see signing.js), so holders keep a portable record of what they agreed to.
+// audittrail.eventId ties the receipt to its audit event on chain.
+//
+//   CONSENT_CONTROLLER_NAME=...    PII controller details shown on receipts
+//   CONSENT_CONTROLLER_CONTACT=... CONSENT_CONTROLLER_EMAIL=...
+//   CONSENT_CONTROLLER_URL=...     CONSENT_POLICY_URL=...
+//   CONSENT_JURISDICTION=...       e.g. EU
+
+import crypto from "node:crypto";
+import { publicKeyPem, signJws } from "./signing.js";
+
+const env = process.env;
+const KCR_VERSION = "KI-CR-v1.1.0";
+
+const controller = {
+  piiController: env.CONSENT_CONTROLLER_NAME || "AuditTrail",
+  contact: env.CONSENT_CONTROLLER_CONTACT || "",
+  address: "",
+  email: env.CONSENT_CONTROLLER_EMAIL || "",
+  phone: "",
+  piiControllerUrl: env.CONSENT_CONTROLLER_URL || "",
+};
+
+// consentReceipt builds and signs the receipt for one consent change. The
+// receipt describes the consent as it stands after evt.
+export const consentReceipt = (holderDid, consent, evt) => {
+  const receipt = {
+    version: KCR_VERSION,
+    jurisdiction: env.CONSENT_JURISDICTION || "",
+    consentTimestamp: Math.floor(Date.parse(evt.occurredAt) / 1000),
+    collectionMethod: "Holder wallet via AuditTrail API",
+    consentReceiptID: crypto.randomUUID(),
+    publicKey: publicKeyPem,
+    language: "en",
+    piiPrincipalId: holderDid,
+    piiControllers: [controller],
+    policyUrl: env.CONSENT_POLICY_URL || "",
+    services: [
+      {
+        service: `Credential verification by ${consent.verifierId}`,
+        purposes: [
+          {
+            purpose: consent.purpose,
+            purposeCategory: ["Core Function"],
+            consentType: "EXPLICIT",
+            piiCategory: ["Credential status"],
+            primaryPurpose: true,
+            termination: "Revocation by the holder",
+            thirdPartyDisclosure: true,
+            thirdPartyName: consent.verifierId,
+          },
+        ],
+      },
+    ],
+    sensitive: false,
+    spiCat: [],
+    // Extension: links to the ledger and to the receipt this one replaces.
+    audittrail: {
+      eventId: evt.eventId,
+      action: evt.action,
+      status: consent.status,
+      ...(consent.receiptId ? { supersedes: consent.receiptId } : {}),
+    },
+  };
+  return { receipt, jws: signJws(receipt) };
+};
+
@@ -0,0 +1,111 @@
+// Cross-channel revocation relay, the companion listener of chaincode
+// RevocationBroadcast and ImportRevocation. Each revocation this gateway
+// commits becomes a RevocationAssertion, as the chaincode event carries it,
+// signed with the gateway key (signing.js) and POSTed to the sister
+// gateways in RELAY_TARGETS, whose POST /v1/revocations/import checks it
+// and mirrors it onto their channel. The chaincode holds no private key,
+// so this signature is what ImportRevocation verifies: sister channels list
+// this gateway's key, the "x" of its /.well-known/jwks.json entry, in their
+// revocationSources config.
+//
+//   RELAY_CHANNEL=audittrail    channel this gateway's ledger is on
+//   RELAY_TARGETS='[{"url": "https://gw.sister.example", "apiKey": "..."}]'
+//                               gateways to relay revocations to; the key
+//                               needs scope cred:relay there
+//   RELAY_SOURCES='{"sister-channel": "<base64url Ed25519 key>"}'
+//                               channels imports are accepted from, with
+//                               their relay key
+//   RELAY_MAX_ATTEMPTS=5
+
+import crypto from "node:crypto";
+import { signBytes } from "./signing.js";
+
+export const RELAY_CHANNEL = process.env.RELAY_CHANNEL || "audittrail";
+const RELAY_TARGETS = JSON.parse(process.env.RELAY_TARGETS || "[]");
+const RELAY_SOURCES = JSON.parse(process.env.RELAY_SOURCES || "{}");
+const MAX_ATTEMPTS = Number(process.env.RELAY_MAX_ATTEMPTS || 5);
+const ORG_MSP_ID = process.env.ORG_MSP_ID || "Org1MSP";
+
+const sourceKeys = new Map(
+  Object.entries(RELAY_SOURCES).map(([channel, x]) => [
+    channel,
+    crypto.createPublicKey({ key: { kty: "OKP", crv: "Ed25519", x }, format: "jwk" }),
+  ]),
+);
+
+// assertionDigest is chaincode RevocationAssertion.digest: sha256 over the
+// assertion's JSON, fields in struct order, with digest empty and no
+// signature.
+const assertionDigest = (a) => {
+  const unsigned = {
+    credId: a.credId,
+    issuerId: a.issuerId,
+    sourceChannel: a.sourceChannel,
+    txId: a.txId,
+    signerMsp: a.signerMsp,
+    signerId: a.signerId,
+    revokedAt: a.revokedAt,
+    digest: "",
+  };
+  // Go's encoding/json escapes these; the bytes must match.
+  const json = JSON.stringify(unsigned).replace(/[<>&\u2028\u2029]/g,
+    (c) => `\\u${c.charCodeAt(0).toString(16).padStart(4, "0")}`);
+  return crypto.createHash("sha256").update(json).digest("hex");
+};
+
+const relayError = (status, message) => Object.assign(new Error(message), { status });
+
+// checkAssertion throws unless a comes from a configured source channel
+// other than this one and is signed by that channel's relay key.
+export const checkAssertion = (a) => {
+  if (!a || typeof a !== "object") throw relayError(400, "assertion is required");
+  if (a.sourceChannel === RELAY_CHANNEL) throw relayError(400, "assertion originates from this channel");
+  const key = sourceKeys.get(a.sourceChannel);
+  if (!key) throw relayError(403, `channel ${a.sourceChannel} is not a configured revocation source`);
+  if (a.digest !== assertionDigest(a)) throw relayError(400, "assertion digest mismatch");
+  const sig = Buffer.from(String(a.signature || ""), "base64url");
+  if (!crypto.verify(null, Buffer.from(a.digest), key, sig)) {
+    throw relayError(403, `assertion signature does not verify against the ${a.sourceChannel} relay key`);
+  }
+};
+
+const send = async (target, assertion) => {
+  for (let attempt = 1; ; attempt++) {
+    try {
+      const res = await fetch(`${target.url.replace(/\/$/, "")}/v1/revocations/import`, {
+        method: "POST",
+        headers: { "Content-Type": "application/json", ...(target.apiKey && { "X-API-Key": target.apiKey }) },
+        body: JSON.stringify({ assertion }),
+        signal: AbortSignal.timeout(10000),
+      });
+      // 409: the target already has it, from an earlier attempt.
+      if (res.ok || res.status === 409) return;
+      throw new Error(`HTTP ${res.status}`);
+    } catch (err) {
+      if (attempt >= MAX_ATTEMPTS) {
+        console.error(`revocation relay to ${target.url} failed for ${assertion.credId}: ${err.message}`);
+        return;
+      }
+      await new Promise((resolve) => setTimeout(resolve, 2 ** attempt * 500));
+    }
+  }
+};
+
+// relayRevocation signs the assertion for a revocation recorded as evt and
+// sends it to every RELAY_TARGETS gateway in the background. It returns
+// the signed assertion.
+export const relayRevocation = (cred, evt) => {
+  const assertion = {
+    credId: cred.credId,
+    issuerId: cred.issuerId,
+    sourceChannel: RELAY_CHANNEL,
+    txId: evt.eventId,
+    signerMsp: ORG_MSP_ID,
+    signerId: evt.actorId,
+    revokedAt: cred.updatedAt,
+  };
+  assertion.digest = assertionDigest(assertion);
+  assertion.signature = signBytes(assertion.digest);
+  for (const target of RELAY_TARGETS) send(target, assertion);
+  return assertion;
+};
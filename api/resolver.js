@@ -0,0 +1,179 @@
+// DID resolution for the gateway. Proof checks need holder and issuer public
+// keys, and the chaincode has no network access, so keys are resolved here and
+// only verified results go on chain.
+//
+// Results use the universal resolver's shape ({ didDocument,
+// didResolutionMetadata, didDocumentMetadata }) and are cached in memory.
+//
+//   UNIVERSAL_RESOLVER_URL=...   e.g. https://dev.uniresolver.io; used for any
+//                                method not resolved locally
+//   EBSI_RESOLVER_URL=...        DID registry endpoint for did:ebsi
+//   DID_CACHE_TTL_SECONDS=300    how long a resolved document is reused
+//   DID_CACHE_MAX=1000           cached documents kept before evicting the oldest
+
+import crypto from "node:crypto";
+import { setting } from "./config.js";
+
+// Reloadable; see config.js.
+const universalResolverUrl = () => setting("resolvers.universalResolverUrl", "").replace(/\/$/, "");
+const ebsiResolverUrl = () => setting("resolvers.ebsiResolverUrl", "").replace(/\/$/, "");
+const DID_CACHE_TTL_SECONDS = Number(process.env.DID_CACHE_TTL_SECONDS || 300);
+const DID_CACHE_MAX = Number(process.env.DID_CACHE_MAX || 1000);
+const RESOLVE_TIMEOUT_MS = 5000;
+
+const BASE58 = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz";
+const ED25519_PUB = [0xed, 0x01]; // multicodec prefix
+
+export const base58Decode = (s) => {
+  let n = 0n;
+  for (const c of s) {
+    const idx = BASE58.indexOf(c);
+    if (idx < 0) throw new Error(`invalid base58 character "${c}"`);
+    n = n * 58n + BigInt(idx);
+  }
+  const bytes = [];
+  for (; n > 0n; n >>= 8n) bytes.unshift(Number(n & 0xffn));
+  for (let i = 0; i < s.length && s[i] === "1"; i++) bytes.unshift(0);
+  return Uint8Array.from(bytes);
+};
+
+export class ResolutionError extends Error {
+  constructor(did, error, message) {
+    super(message);
+    this.did = did;
+    this.error = error; // universal resolver error code: invalidDid | notFound | methodNotSupported | internalError
+  }
+}
+
+const fetchJson = async (url, did) => {
+  let res;
+  try {
+    res = await fetch(url, {
+      headers: { accept: "application/did+ld+json, application/did+json, application/json" },
+      signal: AbortSignal.timeout(RESOLVE_TIMEOUT_MS),
+    });
+  } catch (err) {
+    throw new ResolutionError(did, "internalError", `${did}: ${url} unreachable (${err.message})`);
+  }
+  if (res.status === 404) throw new ResolutionError(did, "notFound", `${did} not found at ${url}`);
+  if (!res.ok) throw new ResolutionError(did, "internalError", `${did}: ${url} returned ${res.status}`);
+  return res.json();
+};
+
+const documentOnly = (did, doc) => {
+  if (doc.id !== did) throw new ResolutionError(did, "notFound", `${did} resolved to a document for ${doc.id}`);
+  return {
+    didDocument: doc,
+    didResolutionMetadata: { contentType: "application/did+json" },
+    didDocumentMetadata: {},
+  };
+};
+
+// Drivers resolved in the gateway itself; everything else goes to the
+// universal resolver when one is configured.
+const drivers = {
+  // did:key is self-describing: the document is derived from the identifier.
+  async key(did, id) {
+    const raw = id.startsWith("z") ? base58Decode(id.slice(1)) : null;
+    if (!raw || raw[0] !== ED25519_PUB[0] || raw[1] !== ED25519_PUB[1] || raw.length !== 34) {
+      throw new ResolutionError(did, "methodNotSupported", `${did}: only Ed25519 did:key is supported`);
+    }
+    const vm = {
+      id: `${did}#${id}`,
+      type: "Ed25519VerificationKey2020",
+      controller: did,
+      publicKeyMultibase: id,
+    };
+    return documentOnly(did, {
+      "@context": ["https://www.w3.org/ns/did/v1", "https://w3id.org/security/suites/ed25519-2020/v1"],
+      id: did,
+      verificationMethod: [vm],
+      authentication: [vm.id],
+      assertionMethod: [vm.id],
+    });
+  },
+  async web(did, id) {
+    const [host, ...path] = id.split(":").map(decodeURIComponent);
+    const url = path.length ? `https://${host}/${path.join("/")}/did.json` : `https://${host}/.well-known/did.json`;
+    return documentOnly(did, await fetchJson(url, did));
+  },
+  async ebsi(did) {
+    if (!ebsiResolverUrl()) return universal(did);
+    return documentOnly(did, await fetchJson(`${ebsiResolverUrl()}/${encodeURIComponent(did)}`, did));
+  },
+};
+
+const universal = async (did) => {
+  if (!universalResolverUrl()) {
+    throw new ResolutionError(did, "methodNotSupported", `${did}: no resolver configured for this method`);
+  }
+  const out = await fetchJson(`${universalResolverUrl()}/1.0/identifiers/${encodeURIComponent(did)}`, did);
+  if (!out.didDocument) {
+    const code = out.didResolutionMetadata?.error || "notFound";
+    throw new ResolutionError(did, code, `${did}: ${code}`);
+  }
+  return out;
+};
+
+// did -> { expiresAt, result: Promise }. Caching the promise also collapses
+// concurrent lookups of the same DID into one request; failures are not kept.
+const cache = new Map();
+
+// resolveDid returns a universal-resolver style resolution result or throws a
+// ResolutionError.
+export const resolveDid = (did) => {
+  const hit = cache.get(did);
+  if (hit && hit.expiresAt > Date.now()) return hit.result;
+  cache.delete(did);
+
+  const m = /^did:([a-z0-9]+):(.+)$/.exec(did || "");
+  const result = m
+    ? (drivers[m[1]] || universal)(did, m[2])
+    : Promise.reject(new ResolutionError(did, "invalidDid", `"${did}" is not a DID`));
+  result.catch(() => cache.delete(did));
+
+  cache.set(did, { expiresAt: Date.now() + DID_CACHE_TTL_SECONDS * 1000, result });
+  while (cache.size > DID_CACHE_MAX) cache.delete(cache.keys().next().value);
+  return result;
+};
+
+// keyObject turns a verification method into a Node KeyObject. JWKs and
+// Ed25519 multibase/base58 keys are understood.
+const keyObject = (vm) => {
+  if (vm.publicKeyJwk) return crypto.createPublicKey({ key: vm.publicKeyJwk, format: "jwk" });
+  let raw;
+  if (vm.publicKeyMultibase?.startsWith("z")) {
+    raw = base58Decode(vm.publicKeyMultibase.slice(1));
+    if (raw[0] === ED25519_PUB[0] && raw[1] === ED25519_PUB[1]) raw = raw.subarray(2);
+  } else if (vm.publicKeyBase58) {
+    raw = base58Decode(vm.publicKeyBase58);
+  }
+  if (!raw || raw.length !== 32 || !/Ed25519/.test(vm.type)) return null;
+  const x = Buffer.from(raw).toString("base64url");
+  return crypto.createPublicKey({ key: { kty: "OKP", crv: "Ed25519", x }, format: "jwk" });
+};
+
+// publicKeys lists did's keys for a verification relationship
+// ("authentication" for holders, "assertionMethod" for issuers).
+export const publicKeys = async (did, relationship) => {
+  const { didDocument: doc } = await resolveDid(did);
+  const methods = doc.verificationMethod || [];
+  return (doc[relationship] || [])
+    .map((ref) => (typeof ref === "string" ? methods.find((vm) => vm.id === ref || `${did}${vm.id}` === ref) : ref))
+    .filter(Boolean)
+    .map((vm) => ({ id: vm.id, key: keyObject(vm) }))
+    .filter((k) => k.key);
+};
+
+// verifySignature checks a base64url signature over data against did's keys
+// for the relationship. Ed25519 and JWS-style (IEEE P1363) ECDSA are accepted.
+export const verifySignature = async (did, relationship, data, signature) => {
+  const sig = Buffer.from(signature, "base64url");
+  for (const { id, key } of await publicKeys(did, relationship)) {
+    const ok = key.asymmetricKeyType === "ed25519"
+      ? crypto.verify(null, Buffer.from(data), key, sig)
+      : crypto.verify("sha256", Buffer.from(data), { key, dsaEncoding: "ieee-p1363" }, sig);
+    if (ok) return id;
+  }
+  return null;
+};
@@ -0,0 +1,108 @@
+// Quarterly access reviews, mirroring chaincode GetAccessReview. A review
+// package lists, for one issuer and quarter, every verifier that checked
+// the issuer's credentials: how often, which holders and credentials, the
+// purposes the holders consented to, and how many checks a consent covered.
+// The package is signed with the gateway key (package.jws) so it can travel
+// through a sign-off workflow and still be checked against
+// /.well-known/jwks.json; the sign-off decision itself is recorded on the
+// review and in the audit trail.
+
+import crypto from "node:crypto";
+import { signJws } from "./signing.js";
+
+export const SIGN_OFF_DECISIONS = ["Approved", "Rejected"];
+
+const REVIEWED_ACTIONS = ["Verify", "VerifyAttribute", "BreakGlassVerify"];
+const reviews = new Map(); // reviewId -> review
+
+// quarterBounds returns the ISO bounds of "YYYY-Qn", the end exclusive.
+export const quarterBounds = (quarter) => {
+  const m = /^(\d{4})-Q([1-4])$/.exec(quarter || "");
+  if (!m) throw new Error("quarter must look like 2026-Q3");
+  const from = new Date(Date.UTC(Number(m[1]), 3 * (Number(m[2]) - 1), 1));
+  const to = new Date(Date.UTC(from.getUTCFullYear(), from.getUTCMonth() + 3, 1));
+  return { from: from.toISOString(), to: to.toISOString() };
+};
+
+// coveredAt reports whether consent was in force at the ISO time at.
+const coveredAt = (consent, at) =>
+  Boolean(consent?.grantedAt) &&
+  consent.grantedAt <= at &&
+  (consent.status === "Granted" || (consent.revokedAt || "") > at);
+
+// buildAccessReview summarizes events on issuerId's credentials within the
+// quarter and stores the review with a pending sign-off.
+export const buildAccessReview = ({ events, credentials, consents }, issuerId, quarter, requestedBy) => {
+  const { from, to } = quarterBounds(quarter);
+  const byVerifier = new Map();
+  for (const e of events) {
+    if (!REVIEWED_ACTIONS.includes(e.action) || e.occurredAt < from || e.occurredAt >= to) continue;
+    if (credentials.get(e.credId)?.issuerId !== issuerId) continue;
+    if (!byVerifier.has(e.actorId)) {
+      byVerifier.set(e.actorId, {
+        verifications: 0,
+        breakGlass: 0,
+        denied: 0,
+        consentCovered: 0,
+        holders: new Set(),
+        creds: new Set(),
+      });
+    }
+    const t = byVerifier.get(e.actorId);
+    const holderDid = credentials.get(e.credId).holderDid; // e.holderDid may be a pseudonym
+    t.verifications++;
+    if (e.action === "BreakGlassVerify") t.breakGlass++;
+    t.holders.add(holderDid);
+    t.creds.add(e.credId);
+    if (e.outcome === "Denied") t.denied++;
+    else if (coveredAt(consents.get(holderDid)?.get(e.actorId), e.occurredAt)) t.consentCovered++;
+  }
+
+  const verifiers = [...byVerifier.entries()]
+    .sort(([a], [b]) => (a < b ? -1 : 1))
+    .map(([verifierId, t]) => {
+      const holders = [...t.holders].sort();
+      const purposes = new Set(holders.map((h) => consents.get(h)?.get(verifierId)?.purpose).filter(Boolean));
+      return {
+        verifierId,
+        verifications: t.verifications,
+        breakGlass: t.breakGlass,
+        denied: t.denied,
+        consentCovered: t.consentCovered,
+        holders,
+        credentials: [...t.creds].sort(),
+        purposes: [...purposes].sort(),
+      };
+    });
+
+  const pkg = {
+    reviewId: crypto.randomUUID(),
+    issuerId,
+    quarter,
+    from,
+    to,
+    requestedBy,
+    generatedAt: new Date().toISOString(),
+    totals: {
+      verifiers: verifiers.length,
+      verifications: verifiers.reduce((n, v) => n + v.verifications, 0),
+      consentCovered: verifiers.reduce((n, v) => n + v.consentCovered, 0),
+    },
+    verifiers,
+  };
+  const review = { package: pkg, jws: signJws(pkg), signOff: { status: "Pending" } };
+  reviews.set(pkg.reviewId, review);
+  return review;
+};
+
+export const getReview = (reviewId) => reviews.get(reviewId);
+
+// signOff records the reviewer's decision. A review is decided once.
+export const signOff = (review, reviewer, decision, comments = "") => {
+  if (!SIGN_OFF_DECISIONS.includes(decision)) {
+    throw new Error(`decision must be one of ${SIGN_OFF_DECISIONS.join(", ")}`);
+  }
+  if (review.signOff.status !== "Pending") throw new Error(`Review is already ${review.signOff.status}`);
+  review.signOff = { status: decision, reviewer, comments, decidedAt: new Date().toISOString() };
+  return review;
+};
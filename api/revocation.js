@@ -0,0 +1,143 @@
+// Revocation latency. Regulators ask how fast a revocation takes effect, and
+// the answer has more parts than the ledger commit: relying parties may hold
+// a cached status list (STATUS_LIST_TTL_SECONDS) or a cached positive
+// verification (recommendedRecheckAfter) from before it. For each revoked
+// credential the gateway records
+//
+//   requestedAt     the revoke request reached the gateway
+//   committedAt     the revocation committed on the ledger
+//   publishedAt     the status list showing it was first served
+//   staleUntil      when the last copies predating the commit expire: the
+//                   status list served last before commit plus its TTL, or
+//                   the latest recommendedRecheckAfter handed out before
+//                   commit, whichever is later
+//   effectiveAt     max(committedAt, staleUntil); from then on no relying
+//                   party that honors cache lifetimes accepts the credential
+//
+// The spans are exported at GET /metrics as histograms, with the share of
+// revocations effective within REVOCATION_SLO_SECONDS, and per credential at
+// GET /v1/revocations/:credId/propagation.
+//
+//   REVOCATION_SLO_SECONDS=900   target from request to effectiveAt
+
+import { STATUS_LIST_TTL_SECONDS, statusPosition } from "./status.js";
+
+export const REVOCATION_SLO_SECONDS = Number(process.env.REVOCATION_SLO_SECONDS || 900);
+
+const BUCKETS = [0.1, 0.5, 1, 5, 15, 60, 300, 900, 3600, 86400];
+const SPANS = {
+  commit: "request to ledger commit",
+  publish: "ledger commit to first status list serving it",
+  effective: "request to effective everywhere",
+};
+
+const reports = new Map(); // credId -> report
+const listServedAt = new Map(); // list -> last time it was served
+const recheckUntil = new Map(); // credId -> latest recommendedRecheckAfter handed out
+const histograms = Object.fromEntries(
+  Object.keys(SPANS).map((s) => [s, { buckets: BUCKETS.map(() => 0), sum: 0, count: 0 }]),
+);
+const slo = { met: 0, missed: 0 };
+
+const observe = (span, seconds) => {
+  const h = histograms[span];
+  BUCKETS.forEach((le, i) => {
+    if (seconds <= le) h.buckets[i]++;
+  });
+  h.sum += seconds;
+  h.count++;
+};
+
+const iso = (ms) => (ms === null ? null : new Date(ms).toISOString());
+const ms = (a, b) => (a === null || b === null ? null : b - a);
+
+// noteRecheck remembers how long a positive verification of credId may be
+// cached.
+export const noteRecheck = (credId, until) => {
+  const t = Date.parse(until);
+  if (!Number.isNaN(t) && t > (recheckUntil.get(credId) || 0)) recheckUntil.set(credId, t);
+};
+
+// revocationCommitted records a revocation once it has committed.
+export const revocationCommitted = (credId, requestedAt, committedAt = Date.now()) => {
+  const pos = statusPosition(credId);
+  const listStaleUntil = pos && listServedAt.has(pos.list)
+    ? listServedAt.get(pos.list) + STATUS_LIST_TTL_SECONDS * 1000
+    : null;
+  const verifyStaleUntil = recheckUntil.get(credId) ?? null;
+  const staleUntil = Math.max(listStaleUntil ?? 0, verifyStaleUntil ?? 0) || null;
+  const effectiveAt = Math.max(committedAt, staleUntil ?? 0);
+
+  reports.set(credId, {
+    credId,
+    statusList: pos?.list ?? null,
+    requestedAt,
+    committedAt,
+    publishedAt: null,
+    listStaleUntil,
+    verifyStaleUntil,
+    effectiveAt,
+  });
+  recheckUntil.delete(credId);
+  observe("commit", (committedAt - requestedAt) / 1000);
+  const total = (effectiveAt - requestedAt) / 1000;
+  observe("effective", total);
+  slo[total <= REVOCATION_SLO_SECONDS ? "met" : "missed"]++;
+};
+
+// noteListServed records that status list list went out to a relying party;
+// revocations in it that had not been published yet are now.
+export const noteListServed = (list) => {
+  const now = Date.now();
+  listServedAt.set(list, now);
+  for (const r of reports.values()) {
+    if (r.statusList !== list || r.publishedAt !== null) continue;
+    r.publishedAt = now;
+    observe("publish", (now - r.committedAt) / 1000);
+  }
+};
+
+// propagationReport is the per-credential report, or null if credId has no
+// recorded revocation.
+export const propagationReport = (credId) => {
+  const r = reports.get(credId);
+  if (!r) return null;
+  const total = ms(r.requestedAt, r.effectiveAt);
+  return {
+    credId,
+    statusList: r.statusList,
+    requestedAt: iso(r.requestedAt),
+    committedAt: iso(r.committedAt),
+    publishedAt: iso(r.publishedAt),
+    listStaleUntil: iso(r.listStaleUntil),
+    verifyStaleUntil: iso(r.verifyStaleUntil),
+    effectiveAt: iso(r.effectiveAt),
+    commitMs: ms(r.requestedAt, r.committedAt),
+    publishMs: ms(r.committedAt, r.publishedAt),
+    effectiveMs: total,
+    sloSeconds: REVOCATION_SLO_SECONDS,
+    withinSlo: total <= REVOCATION_SLO_SECONDS * 1000,
+  };
+};
+
+// revocationMetrics renders the metrics in Prometheus text format 0.0.4.
+export const revocationMetrics = () => {
+  const lines = [];
+  for (const [span, help] of Object.entries(SPANS)) {
+    const name = `audittrail_revocation_${span}_seconds`;
+    const h = histograms[span];
+    lines.push(`# HELP ${name} Revocation latency, ${help}.`, `# TYPE ${name} histogram`);
+    BUCKETS.forEach((le, i) => lines.push(`${name}_bucket{le="${le}"} ${h.buckets[i]}`));
+    lines.push(`${name}_bucket{le="+Inf"} ${h.count}`, `${name}_sum ${h.sum}`, `${name}_count ${h.count}`);
+  }
+  lines.push(
+    "# HELP audittrail_revocation_slo_total Revocations by whether they took effect within the SLO.",
+    "# TYPE audittrail_revocation_slo_total counter",
+    `audittrail_revocation_slo_total{result="met"} ${slo.met}`,
+    `audittrail_revocation_slo_total{result="missed"} ${slo.missed}`,
+    "# HELP audittrail_revocation_slo_seconds Target from revoke request to effect.",
+    "# TYPE audittrail_revocation_slo_seconds gauge",
+    `audittrail_revocation_slo_seconds ${REVOCATION_SLO_SECONDS}`,
+  );
+  return lines.join("\n") + "\n";
+};
@@ -0,0 +1,206 @@
+// Issuance sagas, for issuance flows that span systems besides the ledger:
+// an identity proofing service that must pass the holder first and a
+// document store that keeps the credential document whose hash goes on the
+// ledger. The saga runs the steps in order
+//
+//   reserve   reserve credId on the ledger, as chaincode PrepareIssue does
+//   proof     POST {holderDid, credType, evidence} to IDENTITY_PROOFING_URL;
+//             the service answers {verified, reference}
+//   store     PUT the document to DOCUMENT_STORE_URL/documents/<credId>
+//   commit    issue the reserved credential, as chaincode CommitIssue does
+//
+// and when one fails it undoes the completed ones in reverse: the stored
+// document is DELETEd and the reservation released with AbortIssue, so a
+// failure leaves neither an orphaned document nor a credential ID reserved
+// until its TTL. Proofing has nothing to undo. A step whose system is not
+// configured, or a store step without a document, is skipped.
+//
+// Saga state is written to SAGA_STATE_FILE after every step. A saga the
+// gateway was in the middle of when it stopped is compensated at startup
+// (resumeSagas) rather than carried forward, since the request that started
+// it has gone; its caller retries with a new saga. Compensations are retried
+// SAGA_MAX_ATTEMPTS times with backoff; a saga whose compensation still
+// fails is left Stuck, listed by GET /v1/sagas?status=Stuck, for an operator.
+//
+//   IDENTITY_PROOFING_URL=https://proofing.example.com/v1/checks
+//   DOCUMENT_STORE_URL=https://docs.example.com
+//   SAGA_STATE_FILE=./sagas.json   unset keeps sagas in memory only
+//   SAGA_MAX_ATTEMPTS=3
+
+import crypto from "node:crypto";
+import fs from "node:fs";
+import { setting } from "./config.js";
+
+// Reloadable; see config.js.
+const proofingUrl = () => setting("integrations.identityProofingUrl", "");
+const documentStoreUrl = () => setting("integrations.documentStoreUrl", "").replace(/\/$/, "");
+const SAGA_STATE_FILE = process.env.SAGA_STATE_FILE || "";
+const MAX_ATTEMPTS = Number(process.env.SAGA_MAX_ATTEMPTS || 3);
+const TIMEOUT_MS = 10000;
+
+export const SAGA_STATUSES = ["Running", "Compensating", "Completed", "Aborted", "Stuck"];
+const STEPS = ["reserve", "proof", "store", "commit"];
+
+const sagas = new Map(); // sagaId -> saga
+
+const load = () => {
+  if (!SAGA_STATE_FILE || !fs.existsSync(SAGA_STATE_FILE)) return;
+  for (const saga of JSON.parse(fs.readFileSync(SAGA_STATE_FILE, "utf8"))) sagas.set(saga.sagaId, saga);
+};
+load();
+
+// persist rewrites the state file through a rename, so a crash mid-write
+// leaves the previous state rather than a torn file.
+const persist = (saga) => {
+  saga.updatedAt = new Date().toISOString();
+  if (!SAGA_STATE_FILE) return;
+  const tmp = `${SAGA_STATE_FILE}.tmp`;
+  fs.writeFileSync(tmp, JSON.stringify([...sagas.values()], null, 2));
+  fs.renameSync(tmp, SAGA_STATE_FILE);
+};
+
+const external = async (url, init) => {
+  const res = await fetch(url, { ...init, signal: AbortSignal.timeout(TIMEOUT_MS) });
+  const out = await res.json().catch(() => ({}));
+  return { res, out };
+};
+
+// Forward actions, each returning the step's result for the saga record.
+const forward = {
+  reserve: async (ledger, saga, input) => {
+    await ledger.prepare(input.draft);
+    return {};
+  },
+  proof: async (ledger, saga, input) => {
+    if (!proofingUrl()) return null;
+    const { res, out } = await external(proofingUrl(), {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ holderDid: saga.holderDid, credType: saga.credType, evidence: input.evidence }),
+    });
+    if (!res.ok) throw new Error(`identity proofing: HTTP ${res.status}`);
+    if (out.verified !== true) {
+      throw new Error(`identity proofing rejected the holder: ${out.reason || "not verified"}`);
+    }
+    return { reference: out.reference };
+  },
+  store: async (ledger, saga, input) => {
+    if (!documentStoreUrl() || input.document === undefined) return null;
+    const uri = `${documentStoreUrl()}/documents/${encodeURIComponent(saga.credId)}`;
+    const { res } = await external(uri, {
+      method: "PUT",
+      headers: { "Content-Type": "application/json", "X-AuditTrail-Hashed-Data": input.draft.hashedData },
+      body: JSON.stringify(input.document),
+    });
+    if (!res.ok) throw new Error(`document store: HTTP ${res.status}`);
+    return { uri };
+  },
+  commit: async (ledger, saga) => {
+    const { credential, event } = ledger.commit(saga.credId);
+    return { eventId: event.eventId, status: credential.status };
+  },
+};
+
+// Compensations. Both are idempotent, so a retried or resumed compensation
+// that already took effect succeeds.
+const compensate = {
+  reserve: async (ledger, saga) => ledger.abort(saga.credId),
+  store: async (ledger, saga, result) => {
+    const { res } = await external(result.uri, { method: "DELETE" });
+    if (!res.ok && res.status !== 404) throw new Error(`document store: HTTP ${res.status}`);
+  },
+};
+
+const withRetry = async (fn) => {
+  for (let attempt = 1; ; attempt++) {
+    try {
+      return await fn();
+    } catch (err) {
+      if (attempt >= MAX_ATTEMPTS) throw err;
+      await new Promise((resolve) => setTimeout(resolve, 2 ** attempt * 500));
+    }
+  }
+};
+
+const rollBack = async (ledger, saga) => {
+  saga.status = "Compensating";
+  persist(saga);
+  for (const step of [...saga.steps].reverse()) {
+    if (step.status !== "Done" || !compensate[step.name]) continue;
+    try {
+      await withRetry(() => compensate[step.name](ledger, saga, step.result));
+      step.status = "Compensated";
+    } catch (err) {
+      step.compensationError = err.message;
+      saga.status = "Stuck";
+      persist(saga);
+      return;
+    }
+    persist(saga);
+  }
+  saga.status = "Aborted";
+  persist(saga);
+};
+
+// runIssueSaga issues spec.draft ({credId, holderDid, credType, hashedData,
+// issuerId, ...} as for POST /v1/issue) through the saga, with the optional
+// spec.evidence for proofing and spec.document for the store. ledger is
+// {prepare(draft), commit(credId), abort(credId), issued(credId)}. It
+// resolves to the saga once it has completed or been rolled back.
+export const runIssueSaga = async (ledger, spec, requestedBy) => {
+  const { draft } = spec;
+  const saga = {
+    sagaId: crypto.randomUUID(),
+    type: "issue",
+    credId: draft.credId,
+    holderDid: draft.holderDid,
+    credType: draft.credType,
+    status: "Running",
+    steps: STEPS.map((name) => ({ name, status: "Pending" })),
+    requestedBy,
+    createdAt: new Date().toISOString(),
+  };
+  sagas.set(saga.sagaId, saga);
+  persist(saga);
+
+  for (const step of saga.steps) {
+    try {
+      const result = await forward[step.name](ledger, saga, spec);
+      step.status = result ? "Done" : "Skipped";
+      if (result) step.result = result;
+    } catch (err) {
+      step.status = "Failed";
+      step.error = err.message;
+      saga.error = `${step.name}: ${err.message}`;
+      await rollBack(ledger, saga);
+      return saga;
+    }
+    step.at = new Date().toISOString();
+    persist(saga);
+  }
+  saga.status = "Completed";
+  persist(saga);
+  return saga;
+};
+
+// resumeSagas rolls back the sagas left Running or Compensating by a
+// previous gateway process, and retries the Stuck ones. A Running saga
+// whose credential made it onto the ledger before the process stopped is
+// completed instead.
+export const resumeSagas = async (ledger) => {
+  for (const saga of sagas.values()) {
+    const [reserve, , , commit] = saga.steps;
+    if (saga.status === "Running" && reserve.status === "Done" && ledger.issued(saga.credId)) {
+      commit.status = "Done";
+      saga.status = "Completed";
+      persist(saga);
+    } else if (["Running", "Compensating", "Stuck"].includes(saga.status)) {
+      saga.error ||= "interrupted by a gateway restart";
+      await rollBack(ledger, saga);
+    }
+  }
+};
+
+export const getSaga = (sagaId) => sagas.get(sagaId) || null;
+
+export const listSagas = (status) => [...sagas.values()].filter((s) => !status || s.status === status);
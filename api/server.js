@@ -1,19 +1,72 @@
 import express from "express";
 import crypto from "node:crypto";
+import { Readable, pipeline } from "node:stream";
+import zlib from "node:zlib";
+// config.js goes first: it applies the deployment profile before the other
+// modules read their settings.
+import { configView, watchConfig } from "./config.js";
+import { canReadHolder, requireScope } from "./auth.js";
+import { bundleValidity, offlineBundle } from "./bundle.js";
+import { canaryMetrics, startCanary } from "./canary.js";
+import { validateHolderDid, validateHolderType } from "./did.js";
+import { buildDigest, digestPeriod, digestSchedule, digestText, startDigests } from "./digest.js";
+import {
+  createSubscription,
+  deleteSubscription,
+  dispatchEvent,
+  getSubscription,
+  listSubscriptions,
+} from "./dispatch.js";
+import { openapi } from "./openapi.js";
+import { createHolderSubscription, deleteHolderSubscription, listHolderSubscriptions } from "./notify.js";
+import { holderExport } from "./prov.js";
+import { belongsTo, pseudonymize, resolvePseudonym } from "./pseudonym.js";
+import { deleteDevice, listDevices, registerDevice } from "./push.js";
+import { getJob, jobView, parseExportSpec, startExport } from "./exports.js";
+import { accessHeatmap, heatmapCsv } from "./heatmap.js";
+import { consentReceipt } from "./receipt.js";
+import { buildAccessReview, getReview, signOff } from "./reviews.js";
+import { checkAssertion, relayRevocation } from "./relay.js";
+import { resolveDid, verifySignature } from "./resolver.js";
+import {
+  noteListServed,
+  noteRecheck,
+  propagationReport,
+  revocationCommitted,
+  revocationMetrics,
+} from "./revocation.js";
+import { getSaga, listSagas, resumeSagas, runIssueSaga, SAGA_STATUSES } from "./saga.js";
+import { jwks, signEvent } from "./signing.js";
+import {
+  assignStatus,
+  didDocument,
+  signStatusList,
+  statusListCredential,
+  STATUS_LIST_TTL_SECONDS,
+} from "./status.js";
+import { classifyEvent } from "./taxonomy.js";
+import { addIsoDuration, inRange, resolveRange } from "./timerange.js";
+import { apiVersioning } from "./versioning.js";
+import { attestationFields, checkWalletAttestation, walletDenial } from "./wallet.js";
 
 const app = express();
 app.use(express.json());
+app.use(apiVersioning);
 
 // Simple in-memory mock store; swap with Fabric SDK later.
 const credentials = new Map(); // credId -> credential doc
 const events = [];             // append-only audit log
+const consents = new Map();    // holderDid -> Map(verifierId -> consent)
 
 const required = (body, fields) => {
   const missing = fields.filter((f) => !body[f]);
   if (missing.length) throw new Error(`Missing: ${missing.join(", ")}`);
 };
 
-const recordEvent = (credId, holderDid, action, actorId, outcome, reason = "") => {
+// The mock commits each recorded event in its own block.
+let blockHeight = 0;
+
+const recordEvent = (credId, holderDid, action, actorId, outcome, reason = "", extra = {}) => {
   const evt = {
     eventId: crypto.randomUUID(),
     credId,
@@ -23,39 +76,242 @@ const recordEvent = (credId, holderDid, action, actorId, outcome, reason = "") =
     outcome,
     reason,
     occurredAt: new Date().toISOString(),
+    ...extra,
   };
+  pseudonymize(evt);
+  Object.assign(evt, classifyEvent(evt));
+  evt.signature = signEvent(evt);
   events.push(evt);
+  blockHeight++;
+  dispatchEvent(evt, credentials.get(credId));
   return evt;
 };
 
-app.post("/api/issue", (req, res) => {
+// Credential IDs reserved by issuance sagas (saga.js), as chaincode
+// PrepareIssue reserves them: credId -> issuance inputs.
+const pendingIssues = new Map();
+
+// Mirrors chaincode SetCredTypeValidity: VALIDITY_POLICY='{"KYC": "P2Y"}'
+// gives per credential type the ISO 8601 duration its credentials are valid
+// for, by default and at most, from issuance or renewal.
+const VALIDITY_POLICY = JSON.parse(process.env.VALIDITY_POLICY || "{}");
+for (const d of Object.values(VALIDITY_POLICY)) addIsoDuration(Date.now(), d);
+
+// validityExpiry returns the expiry for a credential of credType given the
+// requested expiresAt (ISO 8601, possibly empty), as chaincode
+// applyValidity does.
+const validityExpiry = (credType, expiresAt) => {
+  const exp = expiresAt ? Date.parse(expiresAt) : null;
+  if (Number.isNaN(exp) || (expiresAt && typeof expiresAt !== "string")) {
+    throw new Error("expiresAt must be an ISO 8601 timestamp");
+  }
+  const validity = VALIDITY_POLICY[credType];
+  if (!validity) return expiresAt;
+  const limit = addIsoDuration(Date.now(), validity);
+  if (exp === null) return new Date(limit).toISOString();
+  if (exp > limit) {
+    throw new Error(`expiry ${expiresAt} exceeds the ${validity} validity of credential type ${credType} ` +
+      `(at most ${new Date(limit).toISOString()})`);
+  }
+  return expiresAt;
+};
+
+// Holder blocklist, mirroring chaincode BlockHolder: holderDid -> entry.
+// Issuance and verification for a blocked holder are denied and recorded.
+const holderBlocks = new Map();
+const blockDenial = (holderDid) => {
+  const b = holderBlocks.get(holderDid);
+  return b ? `holder is blocked (case ${b.caseId})` : "";
+};
+
+const validateIssue = async (body) => {
+  required(body, ["credId", "holderDid", "credType", "hashedData", "issuerId"]);
+  const { credId, holderDid, holderType = "Individual", legalEntityId } = body;
+  if (credentials.has(credId)) throw new Error("Credential already exists");
+  const blocked = blockDenial(holderDid);
+  if (blocked) {
+    const evt = recordEvent(credId, holderDid, "Issue", body.issuerId, "Denied", blocked);
+    throw Object.assign(new Error(blocked), { status: 403, event: evt });
+  }
+  validityExpiry(body.credType, body.expiresAt);
+  if (pendingIssues.has(credId)) throw new Error("Credential ID is reserved by a pending issuance");
+  await validateHolderDid(holderDid);
+  validateHolderType(holderType, holderDid, legalEntityId);
+};
+
+const issueCredential = (body) => {
+  const { credId, holderDid, credType, hashedData, issuerId, holderType = "Individual", legalEntityId } = body;
+  const expiresAt = validityExpiry(credType, body.expiresAt);
+  const cred = {
+    credId,
+    holderDid,
+    credType,
+    hashedData,
+    issuerId,
+    holderType,
+    ...(legalEntityId && { legalEntityId }),
+    ...(expiresAt && { expiresAt }),
+    status: "Active",
+    createdAt: new Date().toISOString(),
+    updatedAt: new Date().toISOString(),
+  };
+  cred.credentialStatus = assignStatus(credId);
+  credentials.set(credId, cred);
+  const evt = recordEvent(credId, holderDid, "Issue", issuerId, "Success");
+  return { credential: cred, event: evt };
+};
+
+app.post("/v1/issue", requireScope("cred:issue"), async (req, res) => {
   try {
-    required(req.body, ["credId", "holderDid", "credType", "hashedData", "issuerId"]);
-    const { credId, holderDid, credType, hashedData, issuerId } = req.body;
-    if (credentials.has(credId)) throw new Error("Credential already exists");
+    await validateIssue(req.body);
+    res.json({ ok: true, ...issueCredential(req.body) });
+  } catch (err) {
+    res.status(err.status || 400).json({ ok: false, error: err.message, ...(err.event && { event: err.event }) });
+  }
+});
 
-    const cred = {
-      credId,
-      holderDid,
-      credType,
-      hashedData,
-      issuerId,
-      status: "Active",
-      createdAt: new Date().toISOString(),
-      updatedAt: new Date().toISOString(),
-    };
-    credentials.set(credId, cred);
-    const evt = recordEvent(credId, holderDid, "Issue", issuerId, "Success");
-    res.json({ ok: true, credential: cred, event: evt });
+// ===== Issuance sagas =====
+// Issuance coordinated with identity proofing and a document store, rolled
+// back across them on failure (see saga.js).
+const sagaLedger = {
+  prepare: async (draft) => {
+    await validateIssue(draft);
+    pendingIssues.set(draft.credId, draft);
+  },
+  commit: (credId) => {
+    const draft = pendingIssues.get(credId);
+    if (!draft) throw new Error(`No pending issuance for ${credId}`);
+    pendingIssues.delete(credId);
+    return issueCredential(draft);
+  },
+  abort: (credId) => pendingIssues.delete(credId),
+  issued: (credId) => credentials.has(credId),
+};
+
+app.post("/v1/sagas/issue", requireScope("cred:issue"), async (req, res) => {
+  try {
+    const { evidence, document, ...draft } = req.body;
+    const saga = await runIssueSaga(sagaLedger, { draft, evidence, document }, req.principal.sub);
+    if (saga.status !== "Completed") return res.status(400).json({ ok: false, error: saga.error, saga });
+    res.json({ ok: true, saga, credential: credentials.get(saga.credId) });
+  } catch (err) {
+    // Step failures are rolled back inside the saga; this is the run itself failing.
+    res.status(500).json({ ok: false, error: err.message });
+  }
+});
+
+app.get("/v1/sagas", requireScope("cred:issue"), (req, res) => {
+  const { status } = req.query;
+  if (status && !SAGA_STATUSES.includes(status)) {
+    return res.status(400).json({ ok: false, error: `status must be one of ${SAGA_STATUSES.join(", ")}` });
+  }
+  res.json({ ok: true, sagas: listSagas(status) });
+});
+
+app.get("/v1/sagas/:id", requireScope("cred:issue"), (req, res) => {
+  const saga = getSaga(req.params.id);
+  if (!saga) return res.status(404).json({ ok: false, error: "Saga not found" });
+  res.json({ ok: true, saga });
+});
+
+// How long relying parties may cache a positive result, as in chaincode
+// SetCredTypeRecheckPolicy: RECHECK_POLICY='{"KYC": 86400}' per credential
+// type, RECHECK_AFTER_SECONDS otherwise. Capped at the credential's expiry.
+const RECHECK_AFTER_SECONDS = Number(process.env.RECHECK_AFTER_SECONDS || 3600);
+const RECHECK_POLICY = JSON.parse(process.env.RECHECK_POLICY || "{}");
+
+// attestation is the outcome of checkWalletAttestation, or null.
+const verifyCredential = (credId, verifierId, reason = "", attestation = null) => {
+  const cred = credentials.get(credId);
+  if (!cred) throw new Error("Credential not found");
+
+  const denial = blockDenial(cred.holderDid) || walletDenial(attestation);
+  if (denial) {
+    const evt = recordEvent(credId, cred.holderDid, "Verify", verifierId, "Denied", denial,
+      attestationFields(attestation));
+    const result = { credId, denied: true, denialReason: denial, checkedAt: evt.occurredAt };
+    result.validAsOfBlock = blockHeight;
+    return { result, event: evt };
+  }
+
+  const checkedAt = new Date();
+  const result = {
+    credId,
+    isActive: cred.status === "Active",
+    hashMatches: true, // placeholder until off-chain hash check
+    checkedAt: checkedAt.toISOString(),
+  };
+  if (result.isActive && result.hashMatches) {
+    let until = checkedAt.getTime() + (RECHECK_POLICY[cred.credType] || RECHECK_AFTER_SECONDS) * 1000;
+    if (cred.expiresAt) until = Math.min(until, Date.parse(cred.expiresAt));
+    result.recommendedRecheckAfter = new Date(until).toISOString();
+    noteRecheck(credId, result.recommendedRecheckAfter);
+  }
+  const evt = recordEvent(credId, cred.holderDid, "Verify", verifierId, "Success", reason,
+    attestationFields(attestation));
+  result.validAsOfBlock = blockHeight;
+  return { result, event: evt };
+};
+
+// cacheHeaders turns the result's recheck time into HTTP caching directives.
+const cacheHeaders = (res, result) => {
+  const maxAge = result.recommendedRecheckAfter
+    ? Math.max(0, Math.floor((Date.parse(result.recommendedRecheckAfter) - Date.now()) / 1000))
+    : 0;
+  res.set("Cache-Control", maxAge ? `private, max-age=${maxAge}` : "no-store");
+};
+
+app.post("/v1/verify", requireScope("cred:verify"), async (req, res) => {
+  try {
+    required(req.body, ["credId", "verifierId"]);
+    const { credId, verifierId, walletAttestation, walletPlatform } = req.body;
+    const attestation = await checkWalletAttestation(walletAttestation, walletPlatform);
+    const out = verifyCredential(credId, verifierId, "", attestation);
+    cacheHeaders(res, out.result);
+    res.json({ ok: true, ...out });
   } catch (err) {
     res.status(400).json({ ok: false, error: err.message });
   }
 });
 
-app.post("/api/verify", (req, res) => {
+// ===== Offline bundles =====
+// A signed snapshot of the credential's state for verifiers that will check
+// it later without connectivity (see bundle.js). Revoking the credential is
+// not in effect for them until the bundle expires.
+app.post("/v1/verify/offline-bundle", requireScope("cred:verify"), (req, res) => {
   try {
     required(req.body, ["credId", "verifierId"]);
-    const { credId, verifierId } = req.body;
+    const { credId, verifierId, validForSeconds } = req.body;
+    const cred = credentials.get(credId);
+    if (!cred) throw new Error("Credential not found");
+    const seconds = bundleValidity(validForSeconds);
+
+    const out = offlineBundle(cred, events, seconds);
+    if (cred.status === "Active") noteRecheck(credId, out.bundle.validUntil);
+    const evt = recordEvent(credId, cred.holderDid, "Verify", verifierId, "Success",
+      `offline bundle ${out.bundle.bundleId} valid until ${out.bundle.validUntil}`);
+    res.set("Cache-Control", "no-store");
+    res.json({ ok: true, ...out, event: evt });
+  } catch (err) {
+    res.status(400).json({ ok: false, error: err.message });
+  }
+});
+
+// ===== Break-glass verification =====
+// Emergency responders may verify without the usual checks by giving a
+// justification code from BREAK_GLASS_CODES (comma-separated, as chaincode
+// breakGlassCodes; empty disables break-glass). Each use is recorded as a
+// Critical event and waits in the review queue until an admin rules on it.
+const BREAK_GLASS_CODES = (process.env.BREAK_GLASS_CODES || "").split(",").filter(Boolean);
+const breakGlassReviews = new Map(); // eventId -> review
+
+app.post("/v1/verify/break-glass", requireScope("cred:break-glass"), async (req, res) => {
+  try {
+    required(req.body, ["credId", "verifierId", "justificationCode"]);
+    const { credId, verifierId, justificationCode, walletAttestation, walletPlatform } = req.body;
+    if (!BREAK_GLASS_CODES.includes(justificationCode)) {
+      throw new Error(`Unknown break-glass justification code ${justificationCode}`);
+    }
     const cred = credentials.get(credId);
     if (!cred) throw new Error("Credential not found");
 
@@ -65,14 +321,120 @@ app.post("/api/verify", (req, res) => {
       hashMatches: true, // placeholder until off-chain hash check
       checkedAt: new Date().toISOString(),
     };
-    const evt = recordEvent(credId, cred.holderDid, "Verify", verifierId, "Success");
-    res.json({ ok: true, result, event: evt });
+    // Recorded, never enforced: break-glass skips the attestation requirement.
+    const attestation = await checkWalletAttestation(walletAttestation, walletPlatform);
+    const evt = recordEvent(credId, cred.holderDid, "BreakGlassVerify", verifierId, "Success",
+      `break-glass:${justificationCode}`, attestationFields(attestation));
+    result.validAsOfBlock = blockHeight;
+    const review = {
+      eventId: evt.eventId,
+      credId,
+      holderDid: cred.holderDid,
+      verifierId,
+      justificationCode,
+      requestedBy: req.principal.sub,
+      requestedAt: evt.occurredAt,
+      status: "Pending",
+    };
+    breakGlassReviews.set(evt.eventId, review);
+    res.set("Cache-Control", "no-store"); // emergency results are not for reuse
+    res.json({ ok: true, result, event: evt, review });
   } catch (err) {
     res.status(400).json({ ok: false, error: err.message });
   }
 });
 
-app.post("/api/revoke", (req, res) => {
+// ===== QR / deep-link verification =====
+// A verifier creates a short-lived request; the holder's wallet scans it and
+// completes the verification. Both halves land in the audit trail.
+const verifyRequests = new Map(); // token -> request
+const VERIFY_REQUEST_TTL_SECONDS = Number(process.env.VERIFY_REQUEST_TTL_SECONDS || 300);
+const PUBLIC_URL = process.env.PUBLIC_URL || `http://localhost:${process.env.PORT || 3000}`;
+// The wallet must sign the challenge with a key from the holder DID's
+// authentication relationship: the token and challenge are readable by
+// anyone holding the link. HOLDER_PROOF_REQUIRED=false accepts unsigned
+// completions.
+const HOLDER_PROOF_REQUIRED = process.env.HOLDER_PROOF_REQUIRED !== "false";
+
+const requestState = (vr) => {
+  if (vr.status === "Pending" && Date.parse(vr.expiresAt) < Date.now()) vr.status = "Expired";
+  return vr;
+};
+
+app.post("/v1/verify/requests", requireScope("cred:verify"), (req, res) => {
+  try {
+    required(req.body, ["credId", "verifierId"]);
+    const { credId, verifierId } = req.body;
+    const cred = credentials.get(credId);
+    if (!cred) throw new Error("Credential not found");
+
+    const token = crypto.randomBytes(18).toString("base64url");
+    const vr = {
+      token,
+      credId,
+      verifierId,
+      challenge: crypto.randomBytes(16).toString("hex"),
+      status: "Pending",
+      createdAt: new Date().toISOString(),
+      expiresAt: new Date(Date.now() + VERIFY_REQUEST_TTL_SECONDS * 1000).toISOString(),
+    };
+    verifyRequests.set(token, vr);
+    const evt = recordEvent(credId, cred.holderDid, "VerifyRequest", verifierId, "Success", `qr:${token}`);
+    res.json({
+      ok: true,
+      request: vr,
+      // Render either value as a QR code; wallets register the audittrail: scheme.
+      deepLink: `audittrail://verify?token=${token}`,
+      url: `${PUBLIC_URL}${req.apiBase}/verify/requests/${token}`,
+      event: evt,
+    });
+  } catch (err) {
+    res.status(400).json({ ok: false, error: err.message });
+  }
+});
+
+app.get("/v1/verify/requests/:token", (req, res) => {
+  const vr = verifyRequests.get(req.params.token);
+  if (!vr) return res.status(404).json({ ok: false, error: "Verification request not found" });
+  res.json({ ok: true, request: requestState(vr) });
+});
+
+app.post("/v1/verify/requests/:token/complete", async (req, res) => {
+  try {
+    required(req.body, ["challenge"]);
+    const vr = verifyRequests.get(req.params.token);
+    if (!vr) throw new Error("Verification request not found");
+    if (requestState(vr).status !== "Pending") throw new Error(`Verification request is ${vr.status}`);
+    if (req.body.challenge !== vr.challenge) throw new Error("Challenge mismatch");
+
+    // The signature is checked here, against the resolved DID document, so the
+    // ledger only ever sees the outcome.
+    const { signature } = req.body;
+    if (HOLDER_PROOF_REQUIRED && !signature) throw new Error("Holder signature over the challenge is required");
+    let holderKey;
+    if (signature) {
+      const holderDid = credentials.get(vr.credId)?.holderDid;
+      holderKey = await verifySignature(holderDid, "authentication", vr.challenge, signature);
+      if (!holderKey) throw new Error(`Signature does not match an authentication key of ${holderDid}`);
+    }
+
+    // The challenge doubles as the attestation nonce, binding the token to
+    // this request.
+    const attestation = await checkWalletAttestation(req.body.walletAttestation, req.body.walletPlatform, vr.challenge);
+    const reason = holderKey ? `qr:${vr.token} key:${holderKey}` : `qr:${vr.token}`;
+    const out = verifyCredential(vr.credId, vr.verifierId, reason, attestation);
+    vr.status = "Completed";
+    vr.completedAt = new Date().toISOString();
+    if (holderKey) vr.holderKey = holderKey;
+    vr.result = out.result;
+    res.json({ ok: true, request: vr, ...out });
+  } catch (err) {
+    res.status(400).json({ ok: false, error: err.message });
+  }
+});
+
+app.post("/v1/revoke", requireScope("cred:revoke"), (req, res) => {
+  const requestedAt = Date.now();
   try {
     required(req.body, ["credId", "reason", "revokerId"]);
     const { credId, reason, revokerId } = req.body;
@@ -85,24 +447,647 @@ app.post("/api/revoke", (req, res) => {
     credentials.set(credId, cred);
 
     const evt = recordEvent(credId, cred.holderDid, "Revoke", revokerId, "Success", reason);
+    revocationCommitted(credId, requestedAt);
+    relayRevocation(cred, evt);
+    res.json({ ok: true, credential: cred, event: evt });
+  } catch (err) {
+    res.status(400).json({ ok: false, error: err.message });
+  }
+});
+
+// Revocations mirrored from sister channels, as chaincode ImportRevocation
+// records them: "<sourceChannel>/<credId>" -> mirror.
+const mirroredRevocations = new Map();
+
+app.post("/v1/revocations/import", requireScope("cred:relay"), (req, res) => {
+  const { assertion } = req.body || {};
+  try {
+    checkAssertion(assertion);
+  } catch (err) {
+    return res.status(err.status || 400).json({ ok: false, error: err.message });
+  }
+  const key = `${assertion.sourceChannel}/${assertion.credId}`;
+  if (mirroredRevocations.has(key)) {
+    const error = `revocation of ${assertion.credId} from ${assertion.sourceChannel} already imported`;
+    return res.status(409).json({ ok: false, error });
+  }
+  const mirror = { assertion, importedAt: new Date().toISOString(), applied: false };
+  const cred = credentials.get(assertion.credId);
+  let evt;
+  if (cred && cred.issuerId === assertion.issuerId && cred.status !== "Revoked") {
+    cred.status = "Revoked";
+    cred.updatedAt = new Date().toISOString();
+    evt = recordEvent(cred.credId, cred.holderDid, "Revoke", `mirror:${assertion.sourceChannel}`, "Success",
+      `mirrored from ${assertion.sourceChannel} tx ${assertion.txId}`);
+    revocationCommitted(cred.credId, Date.now());
+    mirror.applied = true;
+  }
+  mirroredRevocations.set(key, mirror);
+  res.status(201).json({ ok: true, mirror, ...(evt && { event: evt }) });
+});
+
+app.get("/v1/revocations/mirrored/:channel/:credId", requireScope("cred:revoke", "audit:read:any"), (req, res) => {
+  const mirror = mirroredRevocations.get(`${req.params.channel}/${req.params.credId}`);
+  if (!mirror) return res.status(404).json({ ok: false, error: "No revocation imported" });
+  res.json({ ok: true, mirror });
+});
+
+// How long the revocation took to commit and to take effect for relying
+// parties holding cached status lists or verification results.
+app.get("/v1/revocations/:credId/propagation", requireScope("cred:revoke", "audit:read:any"), (req, res) => {
+  const report = propagationReport(req.params.credId);
+  if (!report) return res.status(404).json({ ok: false, error: "No revocation recorded for credential" });
+  res.json({ ok: true, report });
+});
+
+// Mirrors chaincode RenewCreds: extends validity in place instead of a
+// revoke and reissue.
+app.post("/v1/renew", requireScope("cred:issue"), (req, res) => {
+  try {
+    required(req.body, ["credId", "newExpiresAt", "issuerId"]);
+    const { credId, newExpiresAt, newHash, issuerId } = req.body;
+    const cred = credentials.get(credId);
+    if (!cred) throw new Error("Credential not found");
+    if (cred.status === "Revoked") throw new Error("Credential is revoked");
+    if (cred.issuerId !== issuerId) throw new Error(`Credential was issued by ${cred.issuerId}`);
+    const exp = Date.parse(newExpiresAt);
+    if (Number.isNaN(exp)) throw new Error("newExpiresAt must be an ISO 8601 timestamp");
+    if (exp <= Date.now()) throw new Error("newExpiresAt is not in the future");
+    validityExpiry(cred.credType, newExpiresAt);
+    if (cred.expiresAt && exp <= Date.parse(cred.expiresAt)) {
+      throw new Error("newExpiresAt does not extend the current expiry");
+    }
+
+    const reason = cred.expiresAt ? `expiry ${cred.expiresAt} -> ${newExpiresAt}` : `expires ${newExpiresAt}`;
+    cred.expiresAt = newExpiresAt;
+    if (newHash) cred.hashedData = newHash;
+    if (cred.status === "Expired") cred.status = "Active";
+    cred.updatedAt = new Date().toISOString();
+
+    const evt = recordEvent(credId, cred.holderDid, "Renew", issuerId, "Success",
+      newHash ? `${reason}; content hash updated` : reason);
     res.json({ ok: true, credential: cred, event: evt });
   } catch (err) {
     res.status(400).json({ ok: false, error: err.message });
   }
 });
 
-app.get("/api/audit", (req, res) => {
+// ===== Revocation notices =====
+// Mirrors chaincode NotifyRevocation / AcknowledgeNotice: issuers notify
+// relying parties of a revocation and the recipient acknowledges with a
+// signature over "notice:ack:<credId>" by one of its DID authentication
+// keys, so both delivery and receipt are on record.
+const notices = new Map(); // `${credId} ${recipientDid}` -> notice
+
+app.post("/v1/notices", requireScope("cred:revoke"), (req, res) => {
+  try {
+    required(req.body, ["credId", "recipientDid"]);
+    const { credId, recipientDid } = req.body;
+    const cred = credentials.get(credId);
+    if (!cred) throw new Error("Credential not found");
+    if (cred.status !== "Revoked") throw new Error("Credential is not revoked");
+    if (!recipientDid.startsWith("did:")) throw new Error("recipientDid must be a DID");
+    const key = `${credId} ${recipientDid}`;
+    if (notices.has(key)) throw new Error(`${recipientDid} was already notified`);
+
+    const evt = recordEvent(credId, cred.holderDid, "Notify", cred.issuerId, "Success",
+      `revocation notice to ${recipientDid}`);
+    const notice = {
+      credId,
+      recipientDid,
+      issuerId: cred.issuerId,
+      status: "Sent",
+      sentBy: req.principal.sub,
+      sentAt: evt.occurredAt,
+      ackMessage: `notice:ack:${credId}`,
+    };
+    notices.set(key, notice);
+    res.status(201).json({ ok: true, notice, event: evt });
+  } catch (err) {
+    res.status(400).json({ ok: false, error: err.message });
+  }
+});
+
+app.get("/v1/notices", requireScope("cred:revoke", "audit:read:any"), (req, res) => {
+  const { credId } = req.query;
+  if (!credId) return res.status(400).json({ ok: false, error: "credId is required" });
+  res.json({ ok: true, notices: [...notices.values()].filter((n) => n.credId === credId) });
+});
+
+// Recipients acknowledge with a DID signature rather than an API scope.
+app.post("/v1/notices/ack", async (req, res) => {
+  try {
+    required(req.body, ["credId", "recipientDid", "signature"]);
+    const { credId, recipientDid, signature } = req.body;
+    const notice = notices.get(`${credId} ${recipientDid}`);
+    if (!notice) throw new Error("Revocation notice not found");
+    if (notice.status === "Acknowledged") throw new Error("Notice is already acknowledged");
+    const key = await verifySignature(recipientDid, "authentication", notice.ackMessage, signature);
+    if (!key) throw new Error(`Signature does not match an authentication key of ${recipientDid}`);
+
+    const evt = recordEvent(credId, credentials.get(credId).holderDid, "NoticeAck", recipientDid, "Success",
+      `revocation notice acknowledged key:${key}`);
+    Object.assign(notice, { status: "Acknowledged", ackKey: key, ackedAt: evt.occurredAt });
+    res.json({ ok: true, notice, event: evt });
+  } catch (err) {
+    res.status(400).json({ ok: false, error: err.message });
+  }
+});
+
+// ===== Audit export =====
+// Trails can run to megabytes: compress when the client allows it and offer
+// NDJSON (one event per line) so exports can be processed as they stream.
+const COMPRESS_MIN_BYTES = 1024;
+
+const pickEncoding = (req) => {
+  const accepted = (req.get("Accept-Encoding") || "").split(",").map((e) => e.trim().split(";")[0]);
+  // zstd needs Node >= 22.15; older runtimes fall back to gzip.
+  if (accepted.includes("zstd") && zlib.createZstdCompress) return "zstd";
+  if (accepted.includes("gzip")) return "gzip";
+  return null;
+};
+
+// sendEvents answers with list, narrowed to the query's range, from, to and
+// tz (see timerange.js). JSON responses echo the resolved bounds as window.
+const sendEvents = (req, res, all) => {
+  const window = resolveRange(req.query);
+  const list = all.filter((e) => inRange(window, e.occurredAt));
+  const ndjson = req.query.format === "ndjson" || (req.get("Accept") || "").includes("application/x-ndjson");
+  const encoding = pickEncoding(req);
+  res.set("Vary", "Accept, Accept-Encoding");
+
+  if (!ndjson) {
+    const payload = window ? { ok: true, window, events: list } : { ok: true, events: list };
+    const body = JSON.stringify(payload);
+    if (!encoding || Buffer.byteLength(body) < COMPRESS_MIN_BYTES) return res.json(payload);
+    res.type("application/json");
+    res.set("Content-Encoding", encoding);
+    const compress = encoding === "zstd" ? zlib.zstdCompressSync : zlib.gzipSync;
+    return res.end(compress(body));
+  }
+
+  res.type("application/x-ndjson");
+  const lines = Readable.from((function* () {
+    for (const evt of list) yield JSON.stringify(evt) + "\n";
+  })());
+  if (!encoding) return lines.pipe(res);
+  res.set("Content-Encoding", encoding);
+  const compressor = encoding === "zstd" ? zlib.createZstdCompress() : zlib.createGzip();
+  pipeline(lines, compressor, res, (err) => {
+    if (err) res.destroy(err);
+  });
+};
+
+app.get("/v1/audit", requireScope("audit:read:any", "audit:read:own"), (req, res) => {
   try {
     const holderDid = req.query.holderDid;
     if (!holderDid) throw new Error("holderDid is required");
-    const holderEvents = events.filter((e) => e.holderDid === holderDid);
-    res.json({ ok: true, events: holderEvents });
+    if (!canReadHolder(req.principal, holderDid)) {
+      return res.status(403).json({ ok: false, error: "audit:read:own only covers your own trail" });
+    }
+    const holderEvents = events.filter((e) => belongsTo(e, holderDid));
+    sendEvents(req, res, holderEvents);
   } catch (err) {
     res.status(400).json({ ok: false, error: err.message });
   }
 });
 
+// ===== Access heatmaps =====
+// Verification activity by hour of day and day of week, per verifier and
+// credential type (heatmap.js). format=csv, or Accept: text/csv, downloads
+// the cells as CSV.
+app.get("/v1/analytics/heatmap", requireScope("audit:read:any"), (req, res) => {
+  try {
+    const { verifierId, credType, tz = "UTC" } = req.query;
+    const window = resolveRange(req.query);
+    const list = events.filter((e) => inRange(window, e.occurredAt));
+    const cells = accessHeatmap(list, credentials, { verifierId, credType, tz });
+    res.set("Vary", "Accept");
+    if (req.query.format === "csv" || (req.get("Accept") || "").includes("text/csv")) {
+      res.attachment("heatmap.csv");
+      return res.type("text/csv").send(heatmapCsv(cells));
+    }
+    res.json({ ok: true, ...(window && { window }), timeZone: tz, cells });
+  } catch (err) {
+    res.status(400).json({ ok: false, error: err.message });
+  }
+});
+
+// ===== Issuer digests =====
+// Daily or weekly summaries emailed to issuer orgs (digest.js). The report
+// behind a digest is the drill-down its email links to, over the period
+// sent or any window given with range, from, to and tz. format=text shows
+// the email.
+app.get("/v1/digests", requireScope("audit:read:any"), (req, res) => {
+  res.json({ ok: true, schedule: digestSchedule() });
+});
+
+app.get("/v1/digests/:issuerId", requireScope("audit:read:any"), (req, res) => {
+  try {
+    const window = resolveRange(req.query) || digestPeriod(req.params.issuerId);
+    if (!window.from || !window.to) throw new Error("a digest needs both from and to");
+    const digest = buildDigest({ events, credentials }, req.params.issuerId, window);
+    if (req.query.format === "text") return res.type("text/plain").send(digestText(digest).text);
+    res.json({ ok: true, digest });
+  } catch (err) {
+    res.status(400).json({ ok: false, error: err.message });
+  }
+});
+
+// ===== Event subscriptions =====
+// Consumers (SIEMs, issuer back offices) register filtered webhook or Kafka
+// deliveries of newly recorded events; see dispatch.js. Each consumer sees
+// and manages only its own subscriptions.
+app.get("/v1/subscriptions", requireScope("events:subscribe"), (req, res) => {
+  res.json({ ok: true, subscriptions: listSubscriptions(req.principal.sub) });
+});
+
+app.post("/v1/subscriptions", requireScope("events:subscribe"), (req, res) => {
+  try {
+    required(req.body, ["channel", "target"]);
+    res.status(201).json({ ok: true, subscription: createSubscription(req.principal.sub, req.body) });
+  } catch (err) {
+    res.status(400).json({ ok: false, error: err.message });
+  }
+});
+
+app.get("/v1/subscriptions/:id", requireScope("events:subscribe"), (req, res) => {
+  const sub = getSubscription(req.principal.sub, req.params.id);
+  if (!sub) return res.status(404).json({ ok: false, error: "Subscription not found" });
+  res.json({ ok: true, subscription: sub });
+});
+
+app.delete("/v1/subscriptions/:id", requireScope("events:subscribe"), (req, res) => {
+  if (!deleteSubscription(req.principal.sub, req.params.id)) {
+    return res.status(404).json({ ok: false, error: "Subscription not found" });
+  }
+  res.json({ ok: true });
+});
+
+// Auditors resolve a pseudonymous trail entry to its holder; the resolution
+// is itself recorded.
+app.get("/v1/pseudonyms/:pseudonym", requireScope("audit:link"), (req, res) => {
+  const linkage = resolvePseudonym(req.params.pseudonym);
+  if (!linkage) return res.status(404).json({ ok: false, error: "Pseudonym not found" });
+  const evt = recordEvent("", linkage.holderDid, "PseudonymResolve", req.principal.sub, "Success",
+    `pseudonym ${linkage.pseudonym}`);
+  res.json({ ok: true, linkage, event: evt });
+});
+
+// ===== Regulator bulk exports =====
+// Asynchronous: POST returns 202 with the job, which the client polls until
+// it is Completed and then downloads. The export itself is audited.
+app.post("/v1/exports", requireScope("audit:read:any"), (req, res) => {
+  try {
+    // Authenticated callers are recorded as themselves; requestedBy only
+    // names the regulator when the gateway runs open.
+    const requestedBy = (req.principal.via === "open" && req.body.requestedBy) || req.principal.sub;
+    const spec = parseExportSpec(req.body);
+    const job = startExport(events, spec, requestedBy);
+    const reason = `export:${job.jobId}`;
+    if (spec.holders.length) {
+      for (const holderDid of spec.holders) recordEvent("", holderDid, "Export", requestedBy, "Success", reason);
+    } else {
+      recordEvent("", "", "Export", requestedBy, "Success", `${reason} (all holders)`);
+    }
+    res.status(202).location(`${req.apiBase}/exports/${job.jobId}`).json({ ok: true, job: jobView(job) });
+  } catch (err) {
+    res.status(400).json({ ok: false, error: err.message });
+  }
+});
+
+app.get("/v1/exports/:jobId", requireScope("audit:read:any"), (req, res) => {
+  const job = getJob(req.params.jobId);
+  if (!job) return res.status(404).json({ ok: false, error: "Export job not found" });
+  res.json({ ok: true, job: jobView(job) });
+});
+
+app.get("/v1/exports/:jobId/download", requireScope("audit:read:any"), (req, res) => {
+  const job = getJob(req.params.jobId);
+  if (!job) return res.status(404).json({ ok: false, error: "Export job not found" });
+  if (job.status !== "Completed") {
+    return res.status(409).json({ ok: false, error: `Export job is ${job.status}` });
+  }
+  res.type("application/gzip");
+  res.attachment(`audit-export-${job.jobId}.tar.gz`);
+  res.set("Digest", `sha-256=${Buffer.from(job.archiveSha256, "hex").toString("base64")}`);
+  res.end(job.archive);
+});
+
+// ===== Quarterly access reviews =====
+// Compliance builds a review per issuer and quarter; a reviewer then signs
+// it off. Both steps are audited.
+app.post("/v1/reviews/access", requireScope("audit:read:any"), (req, res) => {
+  try {
+    required(req.body, ["issuerId", "quarter"]);
+    const { issuerId, quarter } = req.body;
+    const review = buildAccessReview({ events, credentials, consents }, issuerId, quarter, req.principal.sub);
+    const { reviewId } = review.package;
+    recordEvent("", "", "AccessReview", req.principal.sub, "Success", `review:${reviewId} ${issuerId} ${quarter}`);
+    res.status(201).location(`${req.apiBase}/reviews/access/${reviewId}`).json({ ok: true, review });
+  } catch (err) {
+    res.status(400).json({ ok: false, error: err.message });
+  }
+});
+
+app.get("/v1/reviews/access/:reviewId", requireScope("audit:read:any"), (req, res) => {
+  const review = getReview(req.params.reviewId);
+  if (!review) return res.status(404).json({ ok: false, error: "Access review not found" });
+  res.json({ ok: true, review });
+});
+
+app.post("/v1/reviews/access/:reviewId/signoff", requireScope("audit:read:any"), (req, res) => {
+  const review = getReview(req.params.reviewId);
+  if (!review) return res.status(404).json({ ok: false, error: "Access review not found" });
+  try {
+    required(req.body, ["decision"]);
+    signOff(review, req.principal.sub, req.body.decision, req.body.comments);
+    const evt = recordEvent("", "", "ReviewSignOff", req.principal.sub, "Success",
+      `review:${req.params.reviewId} ${req.body.decision}`);
+    res.json({ ok: true, review, event: evt });
+  } catch (err) {
+    res.status(400).json({ ok: false, error: err.message });
+  }
+});
+
+// Post-hoc review queue for break-glass verifications.
+app.get("/v1/reviews/break-glass", requireScope("audit:read:any"), (req, res) => {
+  const status = req.query.status || "Pending";
+  res.json({ ok: true, reviews: [...breakGlassReviews.values()].filter((r) => r.status === status) });
+});
+
+app.post("/v1/reviews/break-glass/:eventId", requireScope("registry:admin"), (req, res) => {
+  const review = breakGlassReviews.get(req.params.eventId);
+  if (!review) return res.status(404).json({ ok: false, error: "Break-glass review not found" });
+  try {
+    required(req.body, ["decision"]);
+    const { decision, notes = "" } = req.body;
+    if (!["Justified", "Unjustified"].includes(decision)) throw new Error("decision must be Justified or Unjustified");
+    if (review.status !== "Pending") throw new Error(`Review is already ${review.status}`);
+    Object.assign(review, {
+      status: decision,
+      reviewedBy: req.principal.sub,
+      reviewNotes: notes,
+      reviewedAt: new Date().toISOString(),
+    });
+    const evt = recordEvent(review.credId, review.holderDid, "BreakGlassReview", req.principal.sub, "Success",
+      `event ${review.eventId}: ${decision}`);
+    res.json({ ok: true, review, event: evt });
+  } catch (err) {
+    res.status(400).json({ ok: false, error: err.message });
+  }
+});
+
+// ===== Holder (wallet) endpoints =====
+// The holder is the principal's DID: the holder_did claim (or a DID sub) of
+// a token, an API key's holderDid, or X-Holder-DID when the gateway runs open.
+const requireHolder = (req, res, next) => {
+  const holderDid = req.principal.holderDid;
+  if (!holderDid) {
+    return res.status(401).json({ ok: false, error: "Caller is not bound to a holder DID" });
+  }
+  req.holderDid = holderDid;
+  next();
+};
+
+const me = express.Router();
+me.use(requireScope("audit:read:own"), requireHolder);
+
+me.get("/credentials", (req, res) => {
+  const mine = [...credentials.values()].filter((c) => c.holderDid === req.holderDid);
+  res.json({ ok: true, credentials: mine });
+});
+
+// Mirrors the chaincode's GetHolderSummary: one call for the wallet home screen.
+me.get("/summary", (req, res) => {
+  const mine = [...credentials.values()].filter((c) => c.holderDid === req.holderDid);
+  const count = (key) => mine.reduce((acc, c) => ({ ...acc, [key(c)]: (acc[key(c)] || 0) + 1 }), {});
+  const expired = (c) => c.status === "Active" && c.expiresAt && Date.parse(c.expiresAt) < Date.now();
+  const latest = events.filter((e) => belongsTo(e, req.holderDid)).at(-1);
+  res.json({
+    ok: true,
+    summary: {
+      holderDid: req.holderDid,
+      credentials: mine.length,
+      byStatus: count((c) => (expired(c) ? "Expired" : c.status)),
+      byCredType: count((c) => c.credType),
+      latestActivityAt: latest?.occurredAt,
+      latestEventId: latest?.eventId,
+      consents: [...(consents.get(req.holderDid) || new Map()).values()],
+    },
+  });
+});
+
+me.get("/audit", (req, res) => {
+  try {
+    const mine = events.filter((e) => belongsTo(e, req.holderDid));
+    sendEvents(req, res, mine);
+  } catch (err) {
+    res.status(400).json({ ok: false, error: err.message });
+  }
+});
+
+// Data portability: the holder's credentials and trail as one JSON-LD
+// document in the VC and PROV-O vocabularies (prov.js), recorded as an
+// Export by the holder.
+me.get("/export", (req, res) => {
+  const mine = [...credentials.values()].filter((c) => c.holderDid === req.holderDid);
+  const trail = events.filter((e) => belongsTo(e, req.holderDid));
+  const exportId = crypto.randomUUID();
+  const doc = holderExport(req.holderDid, mine, trail, exportId, new Date().toISOString());
+  recordEvent("", req.holderDid, "Export", req.holderDid, "Success", `portability:${exportId}`);
+  res.attachment(`audittrail-export-${exportId}.jsonld`);
+  res.type("application/ld+json").send(JSON.stringify(doc, null, 2) + "\n");
+});
+
+// Every consent change issues a Kantara consent receipt; the consent keeps
+// the latest one so the wallet can fetch it again.
+const issueReceipt = (holderDid, consent, evt) => {
+  const out = consentReceipt(holderDid, consent, evt);
+  consent.receiptId = out.receipt.consentReceiptID;
+  consent.receipt = out.jws;
+  return out;
+};
+
+me.get("/consents", (req, res) => {
+  const mine = consents.get(req.holderDid) || new Map();
+  res.json({ ok: true, consents: [...mine.values()] });
+});
+
+me.post("/consents", (req, res) => {
+  try {
+    required(req.body, ["verifierId", "purpose"]);
+    const { verifierId, purpose } = req.body;
+    if (!consents.has(req.holderDid)) consents.set(req.holderDid, new Map());
+    const consent = {
+      verifierId,
+      purpose,
+      status: "Granted",
+      grantedAt: new Date().toISOString(),
+    };
+    const previous = consents.get(req.holderDid).get(verifierId);
+    if (previous) consent.receiptId = previous.receiptId;
+    consents.get(req.holderDid).set(verifierId, consent);
+    const evt = recordEvent("", req.holderDid, "ConsentGrant", req.holderDid, "Success", purpose);
+    const receipt = issueReceipt(req.holderDid, consent, evt);
+    res.json({ ok: true, consent, event: evt, receipt });
+  } catch (err) {
+    res.status(400).json({ ok: false, error: err.message });
+  }
+});
+
+me.delete("/consents/:verifierId", (req, res) => {
+  try {
+    const consent = consents.get(req.holderDid)?.get(req.params.verifierId);
+    if (!consent || consent.status !== "Granted") throw new Error("No active consent for verifier");
+    consent.status = "Revoked";
+    consent.revokedAt = new Date().toISOString();
+    const evt = recordEvent("", req.holderDid, "ConsentRevoke", req.holderDid, "Success");
+    const receipt = issueReceipt(req.holderDid, consent, evt);
+    res.json({ ok: true, consent, event: evt, receipt });
+  } catch (err) {
+    res.status(400).json({ ok: false, error: err.message });
+  }
+});
+
+me.get("/consents/:verifierId/receipt", (req, res) => {
+  const consent = consents.get(req.holderDid)?.get(req.params.verifierId);
+  if (!consent?.receipt) return res.status(404).json({ ok: false, error: "No consent receipt for verifier" });
+  res.type("application/jwt").send(consent.receipt);
+});
+
+// Email and SMS alerts; see notify.js.
+me.get("/subscriptions", (req, res) => {
+  res.json({ ok: true, subscriptions: listHolderSubscriptions(req.holderDid) });
+});
+
+me.post("/subscriptions", (req, res) => {
+  try {
+    required(req.body, ["channel", "target"]);
+    res.json({ ok: true, subscription: createHolderSubscription(req.holderDid, req.body) });
+  } catch (err) {
+    res.status(400).json({ ok: false, error: err.message });
+  }
+});
+
+me.delete("/subscriptions/:id", (req, res) => {
+  if (!deleteHolderSubscription(req.holderDid, req.params.id)) {
+    return res.status(404).json({ ok: false, error: "Subscription not found" });
+  }
+  res.json({ ok: true });
+});
+
+// Wallet devices for push alerts; see push.js. Tokens are never returned.
+me.get("/devices", (req, res) => {
+  res.json({ ok: true, devices: listDevices(req.holderDid) });
+});
+
+me.post("/devices", (req, res) => {
+  try {
+    required(req.body, ["platform", "token"]);
+    res.status(201).json({ ok: true, device: registerDevice(req.holderDid, req.body) });
+  } catch (err) {
+    res.status(400).json({ ok: false, error: err.message });
+  }
+});
+
+me.delete("/devices/:id", (req, res) => {
+  if (!deleteDevice(req.holderDid, req.params.id)) {
+    return res.status(404).json({ ok: false, error: "Device not found" });
+  }
+  res.json({ ok: true });
+});
+
+app.use("/v1/me", me);
+
+// ===== DID resolution =====
+// Same path and result shape as the universal resolver, so clients can point
+// at the gateway instead; results come from the gateway's cache.
+app.get("/1.0/identifiers/:did", async (req, res) => {
+  try {
+    res.type("application/did-resolution+json").json(await resolveDid(req.params.did));
+  } catch (err) {
+    const status = { invalidDid: 400, notFound: 404, methodNotSupported: 501 }[err.error] || 500;
+    res.status(status).json({
+      didDocument: null,
+      didResolutionMetadata: { error: err.error || "internalError", errorMessage: err.message },
+      didDocumentMetadata: {},
+    });
+  }
+});
+
+app.get("/.well-known/jwks.json", (req, res) => {
+  res.json(jwks());
+});
+
+// ===== Credential status =====
+// Bitstring status lists for VC verifier libraries (see status.js). Lists are
+// public and cacheable; send Accept: application/vc+jwt for the signed form.
+app.get("/.well-known/credential-status/:listId", (req, res) => {
+  const vc = statusListCredential(req.params.listId, credentials.values());
+  if (!vc) return res.status(404).json({ ok: false, error: "Status list not found" });
+  noteListServed(Number(req.params.listId));
+  res.set({ "Cache-Control": `public, max-age=${STATUS_LIST_TTL_SECONDS}`, Vary: "Accept" });
+  if ((req.get("Accept") || "").includes("application/vc+jwt")) {
+    return res.type("application/vc+jwt").send(signStatusList(vc));
+  }
+  res.type("application/vc").send(JSON.stringify(vc));
+});
+
+app.get("/.well-known/did.json", (req, res) => {
+  res.type("application/did+json").json(didDocument());
+});
+
+// The active deployment profile (config.js): each setting's value and
+// whether it came from the profile or the environment.
+app.get("/v1/admin/config", requireScope("registry:admin"), (req, res) => {
+  res.json({ ok: true, config: configView() });
+});
+
+// Mirrors chaincode BlockHolder, UnblockHolder and GetBlockedHolders. Events
+// name the case; the reason stays in the entry.
+app.get("/v1/admin/holder-blocks", requireScope("registry:admin"), (req, res) => {
+  res.json({ ok: true, blocks: [...holderBlocks.values()] });
+});
+
+app.post("/v1/admin/holder-blocks", requireScope("registry:admin"), (req, res) => {
+  const { holderDid, caseId, reason = "" } = req.body || {};
+  if (!holderDid || !caseId) return res.status(400).json({ ok: false, error: "holderDid and caseId are required" });
+  const existing = holderBlocks.get(holderDid);
+  if (existing) {
+    const error = `holder ${holderDid} is already blocked (case ${existing.caseId})`;
+    return res.status(409).json({ ok: false, error });
+  }
+  const block = { holderDid, caseId, reason, blockedBy: req.principal.sub, blockedAt: new Date().toISOString() };
+  holderBlocks.set(holderDid, block);
+  const evt = recordEvent("", holderDid, "HolderBlock", req.principal.sub, "Success", `case ${caseId}`);
+  res.status(201).json({ ok: true, block, event: evt });
+});
+
+app.delete("/v1/admin/holder-blocks/:holderDid", requireScope("registry:admin"), (req, res) => {
+  const block = holderBlocks.get(req.params.holderDid);
+  if (!block) return res.status(404).json({ ok: false, error: `holder ${req.params.holderDid} is not blocked` });
+  holderBlocks.delete(block.holderDid);
+  const evt = recordEvent("", block.holderDid, "HolderUnblock", req.principal.sub, "Success", `case ${block.caseId}`);
+  res.json({ ok: true, event: evt });
+});
+
+// Machine-readable API description; the client generators consume this.
+app.get("/openapi.json", (req, res) => {
+  res.json(openapi);
+});
+
+// Synthetic monitoring (canary.js) and revocation latency (revocation.js)
+// metrics, for Prometheus to scrape.
+app.get("/metrics", (req, res) => {
+  res.type("text/plain; version=0.0.4").send(canaryMetrics() + revocationMetrics());
+});
+
 const PORT = process.env.PORT || 3000;
 app.listen(PORT, () => {
   console.log(`API listening on http://localhost:${PORT}`);
+  startCanary();
+  startDigests({ events, credentials });
+  resumeSagas(sagaLedger);
+  watchConfig();
 });
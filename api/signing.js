@@ -0,0 +1,73 @@
+// The gateway's signing key, shared by everything it hands out for offline
+// checking (consent receipts, export archives, audit events). It is the key
+// of the org running the gateway. Signatures are compact JWS (EdDSA); the
+// public half is served at /.well-known/jwks.json.
+//
+//   GATEWAY_SIGNING_KEY=...   PEM Ed25519 private key (RECEIPT_SIGNING_KEY is
+//                             still read); generated per process when unset,
+//                             so signatures stop verifying after a restart
+//   ORG_MSP_ID=Org1MSP        org named in event signatures
+
+import crypto from "node:crypto";
+
+const pem = process.env.GATEWAY_SIGNING_KEY || process.env.RECEIPT_SIGNING_KEY;
+const signingKey = pem ? crypto.createPrivateKey(pem) : crypto.generateKeyPairSync("ed25519").privateKey;
+if (!pem) console.warn("GATEWAY_SIGNING_KEY unset; receipts, exports and events are signed with a throwaway key");
+
+export const publicKeyPem = crypto.createPublicKey(signingKey).export({ type: "spki", format: "pem" });
+export const keyId = crypto.createHash("sha256").update(publicKeyPem).digest("base64url").slice(0, 16);
+
+// signJws signs claims as a compact JWS; extra header fields override the
+// defaults.
+export const signJws = (claims, extra = {}) => {
+  const header = { alg: "EdDSA", typ: "JWT", kid: keyId, ...extra };
+  const input = [header, claims].map((p) => Buffer.from(JSON.stringify(p)).toString("base64url")).join(".");
+  return `${input}.${crypto.sign(null, Buffer.from(input), signingKey).toString("base64url")}`;
+};
+
+const ORG_MSP_ID = process.env.ORG_MSP_ID || "Org1MSP";
+
+// canonicalJson matches contracts/canonical.go: object keys sorted, no
+// whitespace, so any JCS (RFC 8785) implementation reproduces the bytes.
+export const canonicalJson = (v) => {
+  if (Array.isArray(v)) return `[${v.map(canonicalJson).join(",")}]`;
+  if (v && typeof v === "object") {
+    const keys = Object.keys(v).filter((k) => v[k] !== undefined).sort();
+    return `{${keys.map((k) => `${JSON.stringify(k)}:${canonicalJson(v[k])}`).join(",")}}`;
+  }
+  return JSON.stringify(v);
+};
+
+// signDetached returns a JWS with detached payload (RFC 7515 appendix F),
+// "<header>..<signature>": the signed bytes travel separately, here as the
+// record the signature sits in.
+export const signDetached = (payload) => {
+  const header = Buffer.from(JSON.stringify({ alg: "EdDSA", kid: keyId, org: ORG_MSP_ID })).toString("base64url");
+  const input = `${header}.${Buffer.from(payload).toString("base64url")}`;
+  return `${header}..${crypto.sign(null, Buffer.from(input), signingKey).toString("base64url")}`;
+};
+
+// verifyDetached checks a detached JWS over payload against a public JWK,
+// e.g. one from /.well-known/jwks.json.
+export const verifyDetached = (jws, payload, jwk) => {
+  const [header, , sig] = jws.split(".");
+  const input = `${header}.${Buffer.from(payload).toString("base64url")}`;
+  const key = crypto.createPublicKey({ key: jwk, format: "jwk" });
+  return crypto.verify(null, Buffer.from(input), key, Buffer.from(sig, "base64url"));
+};
+
+// signEvent signs an audit event's canonical JSON, without its own
+// signature field, so the event stays attributable to this org once
+// exported off the ledger.
+export const signEvent = (evt) => {
+  const { signature, ...unsigned } = evt;
+  return signDetached(canonicalJson(unsigned));
+};
+
+// signBytes returns the base64url Ed25519 signature over data, for
+// verifiers that check the key directly rather than a JWS (see relay.js).
+export const signBytes = (data) => crypto.sign(null, Buffer.from(data), signingKey).toString("base64url");
+
+export const jwks = () => ({
+  keys: [{ ...crypto.createPublicKey(signingKey).export({ format: "jwk" }), kid: keyId, alg: "EdDSA", use: "sig" }],
+});
@@ -0,0 +1,109 @@
+// Credential status for off-the-shelf verifiers, after W3C Bitstring Status
+// List v1.0. Each credential gets an index in a status list when it is
+// issued and the issue response carries the credentialStatus entry to embed
+// in the VC. The list itself is a credential served at
+// /.well-known/credential-status/<list>, and the bit at a credential's index
+// is set once it is revoked. Verifier libraries that understand
+// BitstringStatusListEntry resolve it with no AuditTrail-specific code.
+//
+// Indexes are drawn at random, and lists are filled only halfway, so a
+// list's bit order says nothing about issue order. Fetching a whole list
+// tells the gateway nothing about which credential a verifier is checking.
+//
+//   STATUS_LIST_ISSUER=did:web:...  issuer of the list credentials; defaults
+//                                   to did:web of PUBLIC_URL, whose DID
+//                                   document is served at /.well-known/did.json
+//   STATUS_LIST_TTL_SECONDS=300     how long verifiers may cache a list, so
+//                                   also how stale a revocation may be seen
+
+import crypto from "node:crypto";
+import zlib from "node:zlib";
+import { jwks, keyId, signJws } from "./signing.js";
+
+export const PUBLIC_URL = process.env.PUBLIC_URL || `http://localhost:${process.env.PORT || 3000}`;
+export const STATUS_LIST_ISSUER =
+  process.env.STATUS_LIST_ISSUER || `did:web:${encodeURIComponent(new URL(PUBLIC_URL).host)}`;
+export const STATUS_LIST_TTL_SECONDS = Number(process.env.STATUS_LIST_TTL_SECONDS || 300);
+
+// 131072 bits (16 KiB) is the spec's minimum list size.
+const LIST_SIZE = 131072;
+const LIST_FILL = LIST_SIZE / 2;
+
+const lists = []; // { createdAt, used: Set<index> }
+const positions = new Map(); // credId -> { list, index }
+
+const listUrl = (list) => `${PUBLIC_URL}/.well-known/credential-status/${list}`;
+
+// assignStatus gives credId an index in the current list and returns the
+// credentialStatus entry for its VC.
+export const assignStatus = (credId) => {
+  let list = lists.length - 1;
+  if (list < 0 || lists[list].used.size >= LIST_FILL) {
+    lists.push({ createdAt: new Date().toISOString(), used: new Set() });
+    list++;
+  }
+  let index;
+  do index = crypto.randomInt(LIST_SIZE);
+  while (lists[list].used.has(index));
+  lists[list].used.add(index);
+  positions.set(credId, { list, index });
+
+  return {
+    id: `${listUrl(list)}#${index}`,
+    type: "BitstringStatusListEntry",
+    statusPurpose: "revocation",
+    statusListIndex: String(index),
+    statusListCredential: listUrl(list),
+  };
+};
+
+// statusPosition is credId's list and index, or undefined.
+export const statusPosition = (credId) => positions.get(credId);
+
+// statusListCredential builds list listId over creds, or returns null if
+// there is no such list. validFrom is the list's last change, so the
+// document, and with it its ETag, only changes when a bit does.
+export const statusListCredential = (listId, creds) => {
+  const list = /^\d+$/.test(listId) ? Number(listId) : -1;
+  if (!lists[list]) return null;
+
+  const bits = Buffer.alloc(LIST_SIZE / 8);
+  let validFrom = lists[list].createdAt;
+  for (const cred of creds) {
+    const pos = positions.get(cred.credId);
+    if (pos?.list !== list || cred.status !== "Revoked") continue;
+    bits[pos.index >> 3] |= 0x80 >> (pos.index & 7); // index 0 is the leftmost bit
+    if (cred.updatedAt > validFrom) validFrom = cred.updatedAt;
+  }
+  return {
+    "@context": ["https://www.w3.org/ns/credentials/v2"],
+    id: listUrl(list),
+    type: ["VerifiableCredential", "BitstringStatusListCredential"],
+    issuer: STATUS_LIST_ISSUER,
+    validFrom,
+    credentialSubject: {
+      id: `${listUrl(list)}#list`,
+      type: "BitstringStatusList",
+      statusPurpose: "revocation",
+      encodedList: `u${zlib.gzipSync(bits).toString("base64url")}`,
+    },
+  };
+};
+
+// signStatusList secures a list credential as a VC-JOSE-COSE vc+jwt,
+// signed with the gateway key named as a key of the issuer DID.
+export const signStatusList = (vc) =>
+  signJws(vc, { typ: "vc+jwt", cty: "vc", kid: `${STATUS_LIST_ISSUER}#${keyId}` });
+
+// didDocument is the issuer's did:web document, naming the gateway key.
+export const didDocument = () => ({
+  "@context": ["https://www.w3.org/ns/did/v1", "https://w3id.org/security/suites/jws-2020/v1"],
+  id: STATUS_LIST_ISSUER,
+  verificationMethod: jwks().keys.map(({ kid, ...publicKeyJwk }) => ({
+    id: `${STATUS_LIST_ISSUER}#${kid}`,
+    type: "JsonWebKey2020",
+    controller: STATUS_LIST_ISSUER,
+    publicKeyJwk,
+  })),
+  assertionMethod: jwks().keys.map(({ kid }) => `${STATUS_LIST_ISSUER}#${kid}`),
+});
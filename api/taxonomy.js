@@ -0,0 +1,117 @@
+// Event taxonomy, mirroring contracts/taxonomy.go. Every recorded event gets
+// eventCategory, severity and sourceComponent so exports map onto enterprise
+// audit schemas (ECS, CEF) without guessing from the action. Actions only the
+// gateway records (VerifyRequest, Export, AccessReview, ReviewSignOff,
+// PseudonymResolve) carry sourceComponent "gateway".
+
+const CREDENTIAL = "audittrail.credential";
+const AUDIT = "audittrail.audit";
+
+const TAXONOMY = {
+  Issue: ["CredentialLifecycle", CREDENTIAL],
+  Accept: ["CredentialLifecycle", CREDENTIAL],
+  Renew: ["CredentialLifecycle", CREDENTIAL],
+  Revoke: ["CredentialLifecycle", CREDENTIAL],
+  Expire: ["CredentialLifecycle", CREDENTIAL],
+  Transfer: ["CredentialLifecycle", CREDENTIAL],
+  CustodyTransfer: ["CredentialLifecycle", CREDENTIAL],
+  Notify: ["CredentialLifecycle", CREDENTIAL],
+  NoticeAck: ["CredentialLifecycle", CREDENTIAL],
+  Verify: ["Access", CREDENTIAL],
+  VerifyAttribute: ["Access", CREDENTIAL],
+  VerifySummary: ["Access", CREDENTIAL],
+  BreakGlassVerify: ["Access", CREDENTIAL],
+  VerifyRequest: ["Access", "gateway"],
+  Export: ["Access", "gateway"],
+  ConsentGrant: ["Consent", CREDENTIAL],
+  ConsentRevoke: ["Consent", CREDENTIAL],
+  Justify: ["Review", AUDIT],
+  Dispute: ["Review", AUDIT],
+  BreakGlassReview: ["Review", AUDIT],
+  AccessReview: ["Review", "gateway"],
+  ReviewSignOff: ["Review", "gateway"],
+  PseudonymResolve: ["Review", "gateway"],
+  HolderBlock: ["Review", "audittrail.admin"],
+  HolderUnblock: ["Review", "audittrail.admin"],
+};
+
+// RFC 5424 severity names, with their numeric levels for the mappers.
+export const SEVERITY_LEVELS = { Informational: 6, Notice: 5, Warning: 4, Critical: 2 };
+
+// classifyEvent returns the taxonomy fields for evt. Unknown actions get no
+// category rather than a guess.
+export const classifyEvent = (evt) => {
+  const [eventCategory, sourceComponent] = TAXONOMY[evt.action] || [];
+  let severity = "Informational";
+  if (evt.action === "BreakGlassVerify") severity = "Critical";
+  else if (["Denied", "Failure"].includes(evt.outcome) || evt.action === "Dispute") severity = "Warning";
+  else if (["Revoke", "Transfer", "ConsentRevoke", "HolderBlock"].includes(evt.action)) severity = "Notice";
+  return { ...(eventCategory ? { eventCategory, sourceComponent } : {}), severity };
+};
+
+const ECS_CATEGORY = {
+  CredentialLifecycle: ["iam", "change"],
+  Access: ["iam", "access"],
+  Consent: ["configuration", "change"],
+  Review: ["iam", "info"],
+};
+
+// toEcs maps an event to Elastic Common Schema fields.
+export const toEcs = (e) => {
+  const [category, type] = ECS_CATEGORY[e.eventCategory] || ["iam", "info"];
+  return {
+    "@timestamp": e.occurredAt,
+    event: {
+      id: e.eventId,
+      kind: "event",
+      category: [category],
+      type: [type],
+      action: e.action,
+      outcome: e.outcome === "Success" ? "success" : "failure",
+      reason: e.reason || undefined,
+      severity: SEVERITY_LEVELS[e.severity],
+      provider: e.sourceComponent,
+    },
+    log: { level: (e.severity || "informational").toLowerCase() },
+    user: { id: e.holderDid || undefined },
+    related: { user: [e.holderDid, e.actorId].filter(Boolean) },
+    source: { user: { id: e.actorId } },
+    audittrail: {
+      credId: e.credId || undefined,
+      eventCategory: e.eventCategory,
+      outcome: e.outcome,
+      walletAttestation: e.walletAttestation,
+      walletPlatform: e.walletPlatform,
+      signature: e.signature,
+    },
+  };
+};
+
+// CEF severity is 0-10; RFC 5424 levels 6/5/4/2 map to 3/5/7/10.
+const CEF_SEVERITY = { Informational: 3, Notice: 5, Warning: 7, Critical: 10 };
+const cefHeader = (v) => String(v ?? "").replace(/\\/g, "\\\\").replace(/\|/g, "\\|");
+const cefValue = (v) => String(v ?? "").replace(/\\/g, "\\\\").replace(/=/g, "\\=").replace(/\r?\n/g, "\\n");
+
+// toCef maps an event to one ArcSight Common Event Format line.
+export const toCef = (e) => {
+  const ext = {
+    rt: Date.parse(e.occurredAt),
+    externalId: e.eventId,
+    suser: e.actorId,
+    duser: e.holderDid,
+    outcome: e.outcome,
+    reason: e.reason,
+    cat: e.eventCategory,
+    ...(e.credId ? { cs1Label: "credId", cs1: e.credId } : {}),
+    ...(e.sourceComponent ? { cs2Label: "sourceComponent", cs2: e.sourceComponent } : {}),
+    ...(e.signature ? { cs3Label: "orgSignature", cs3: e.signature } : {}),
+    ...(e.walletAttestation ? { cs4Label: "walletAttestation", cs4: e.walletAttestation } : {}),
+  };
+  const extension = Object.entries(ext)
+    .filter(([, v]) => v !== undefined && v !== "")
+    .map(([k, v]) => `${k}=${cefValue(v)}`)
+    .join(" ");
+  const header = ["CEF:0", "AuditTrail", "audittrail", "1.0", e.action, `${e.action} ${e.outcome}`,
+    CEF_SEVERITY[e.severity] ?? 3].map(cefHeader).join("|");
+  return `${header}|${extension}`;
+};
@@ -0,0 +1,201 @@
+// Time windows for audit queries. Callers may name a window the way people
+// do instead of computing RFC3339 bounds themselves:
+//
+//   range=2024            a calendar year
+//   range=2024-Q3         a quarter
+//   range=2024-07         a month
+//   range=2024-W05        an ISO 8601 week (Monday to Monday)
+//   range=2024-07-15      a day
+//   range=today           also yesterday, this-week, this-month,
+//                         this-quarter, this-year, previous-week,
+//                         previous-month, previous-quarter, previous-year
+//   range=last-90d        the 90 days up to now; also h(ours) and w(eeks)
+//
+// or give from and/or to, either as timestamps with an offset or as plain
+// dates (a to date includes that whole day). Calendar boundaries fall at
+// local midnight in tz, an IANA zone name such as Europe/Berlin, which
+// defaults to UTC, so "2024-Q3" in America/New_York starts at
+// 2024-07-01T04:00:00Z. Whatever was given resolves to half-open UTC bounds
+// [from, to), the form QueryEventsByTime takes.
+
+const UNIT_MS = { h: 3600e3, d: 86400e3, w: 7 * 86400e3 };
+
+// tzOffset is tz's offset from UTC at instant ms, in ms.
+const tzOffset = (ms, tz) => {
+  const parts = Object.fromEntries(
+    new Intl.DateTimeFormat("en-US", {
+      timeZone: tz,
+      hourCycle: "h23",
+      year: "numeric",
+      month: "numeric",
+      day: "numeric",
+      hour: "numeric",
+      minute: "numeric",
+      second: "numeric",
+    })
+      .formatToParts(new Date(ms))
+      .map((p) => [p.type, Number(p.value)]),
+  );
+  const local = Date.UTC(parts.year, parts.month - 1, parts.day, parts.hour, parts.minute, parts.second);
+  return local - Math.floor(ms / 1000) * 1000;
+};
+
+// midnight is the instant local midnight starts y-m-d in tz. Date.UTC
+// normalizes overflowing months and days, so callers may pass m = 13.
+const midnight = (y, m, d, tz) => {
+  const wall = Date.UTC(y, m - 1, d);
+  let at = wall - tzOffset(wall, tz);
+  at = wall - tzOffset(at, tz); // again in case a DST change lies in between
+  return at;
+};
+
+// localDate is the calendar date in tz at instant ms.
+const localDate = (ms, tz) => {
+  const d = new Date(ms + tzOffset(ms, tz));
+  return { y: d.getUTCFullYear(), m: d.getUTCMonth() + 1, d: d.getUTCDate(), dow: d.getUTCDay() || 7 };
+};
+
+const isoWeekStart = (year, week) => {
+  const jan4 = new Date(Date.UTC(year, 0, 4));
+  const monday = 4 - (jan4.getUTCDay() || 7) + 1; // day of January the ISO week 1 Monday falls on
+  return { y: year, m: 1, d: monday + (week - 1) * 7 };
+};
+
+const span = (start, end, tz) => [midnight(start.y, start.m, start.d, tz), midnight(end.y, end.m, end.d, tz)];
+
+const calendar = (unit, offset, now, tz) => {
+  const t = localDate(now, tz);
+  switch (unit) {
+    case "day":
+      return span({ ...t, d: t.d + offset }, { ...t, d: t.d + offset + 1 }, tz);
+    case "week": {
+      const d = t.d - t.dow + 1 + offset * 7;
+      return span({ ...t, d }, { ...t, d: d + 7 }, tz);
+    }
+    case "month":
+      return span({ y: t.y, m: t.m + offset, d: 1 }, { y: t.y, m: t.m + offset + 1, d: 1 }, tz);
+    case "quarter": {
+      const m = t.m - ((t.m - 1) % 3) + offset * 3;
+      return span({ y: t.y, m, d: 1 }, { y: t.y, m: m + 3, d: 1 }, tz);
+    }
+    case "year":
+      return span({ y: t.y + offset, m: 1, d: 1 }, { y: t.y + offset + 1, m: 1, d: 1 }, tz);
+  }
+};
+
+const parseRange = (range, now, tz) => {
+  let m;
+  if ((m = /^last-(\d+)([hdw])$/.exec(range))) {
+    if (Number(m[1]) < 1) throw new Error("range last-<n> needs n of at least 1");
+    return [now - Number(m[1]) * UNIT_MS[m[2]], now];
+  }
+  if (range === "today") return calendar("day", 0, now, tz);
+  if (range === "yesterday") return calendar("day", -1, now, tz);
+  if ((m = /^(this|previous)-(week|month|quarter|year)$/.exec(range))) {
+    return calendar(m[2], m[1] === "this" ? 0 : -1, now, tz);
+  }
+  if ((m = /^(\d{4})$/.exec(range))) {
+    const y = Number(m[1]);
+    return span({ y, m: 1, d: 1 }, { y: y + 1, m: 1, d: 1 }, tz);
+  }
+  if ((m = /^(\d{4})-Q([1-4])$/.exec(range))) {
+    const y = Number(m[1]);
+    const start = (Number(m[2]) - 1) * 3 + 1;
+    return span({ y, m: start, d: 1 }, { y, m: start + 3, d: 1 }, tz);
+  }
+  if ((m = /^(\d{4})-W(\d{2})$/.exec(range))) {
+    const week = Number(m[2]);
+    const start = isoWeekStart(Number(m[1]), week);
+    if (week < 1 || week > 53 || localDate(Date.UTC(start.y, 0, start.d + 3), "UTC").y !== Number(m[1])) {
+      throw new Error(`range ${range} is not an ISO week`);
+    }
+    return span(start, { ...start, d: start.d + 7 }, tz);
+  }
+  if ((m = /^(\d{4})-(\d{2})$/.exec(range))) {
+    const [y, mo] = [Number(m[1]), Number(m[2])];
+    if (mo < 1 || mo > 12) throw new Error(`range ${range} is not a month`);
+    return span({ y, m: mo, d: 1 }, { y, m: mo + 1, d: 1 }, tz);
+  }
+  if ((m = /^(\d{4})-(\d{2})-(\d{2})$/.exec(range))) {
+    const [y, mo, d] = m.slice(1).map(Number);
+    if (new Date(Date.UTC(y, mo - 1, d)).getUTCDate() !== d) throw new Error(`range ${range} is not a date`);
+    return span({ y, m: mo, d }, { y, m: mo, d: d + 1 }, tz);
+  }
+  throw new Error(`unknown range ${range}`);
+};
+
+// parseBound reads a from or to value: a timestamp with an offset as given,
+// a plain date at local midnight in tz (the following midnight for to).
+const parseBound = (name, value, tz) => {
+  const date = /^(\d{4})-(\d{2})-(\d{2})$/.exec(value);
+  if (date) {
+    const [y, m, d] = date.slice(1).map(Number);
+    return midnight(y, m, name === "to" ? d + 1 : d, tz);
+  }
+  if (!/(Z|[+-]\d{2}:?\d{2})$/i.test(value) || Number.isNaN(Date.parse(value))) {
+    throw new Error(`${name} must be a date or an RFC 3339 timestamp with an offset`);
+  }
+  return Date.parse(value);
+};
+
+export const validateTimeZone = (tz) => {
+  try {
+    new Intl.DateTimeFormat("en-US", { timeZone: tz });
+  } catch {
+    throw new Error(`unknown time zone ${tz}`);
+  }
+};
+
+// resolveRange turns query or body parameters into RFC3339 UTC bounds
+// { from, to, timeZone }, either bound null when open, or returns null when
+// none were given. range and from/to are mutually exclusive.
+export const resolveRange = ({ range, from, to, tz = "UTC" } = {}, now = Date.now()) => {
+  for (const [name, v] of [["range", range], ["from", from], ["to", to], ["tz", tz]]) {
+    if (v !== undefined && typeof v !== "string") throw new Error(`${name} must be a string`);
+  }
+  validateTimeZone(tz);
+  if (range && (from || to)) throw new Error("give either range or from/to, not both");
+
+  let bounds;
+  if (range) bounds = parseRange(range, now, tz);
+  else if (from || to) bounds = [from ? parseBound("from", from, tz) : null, to ? parseBound("to", to, tz) : null];
+  else return null;
+
+  const [start, end] = bounds;
+  if (start !== null && end !== null && start >= end) throw new Error("from must be before to");
+  const iso = (ms) => (ms === null ? null : new Date(ms).toISOString().replace(/\.000Z$/, "Z"));
+  return { from: iso(start), to: iso(end), timeZone: tz };
+};
+
+const ISO_DURATION = /^P(?:(\d+)Y)?(?:(\d+)M)?(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$/;
+
+// addIsoDuration returns instant ms plus the ISO 8601 duration d, such as
+// P2Y or PT12H, as chaincode addISODuration does: calendar units in UTC
+// first, so P1M from January 31 ends in early March, then the time part.
+export const addIsoDuration = (ms, d) => {
+  const m = ISO_DURATION.exec(d);
+  if (!m || d === "P" || d.endsWith("T")) throw new Error(`invalid ISO 8601 duration ${d}`);
+  const [y, mo, w, day, h, min, sec] = m.slice(1).map((v) => Number(v || 0));
+  if ([y, mo, w, day, h, min, sec].some((v) => v > 1e6)) throw new Error(`ISO 8601 duration ${d} is out of range`);
+  const t = new Date(ms);
+  const out =
+    Date.UTC(
+      t.getUTCFullYear() + y,
+      t.getUTCMonth() + mo,
+      t.getUTCDate() + 7 * w + day,
+      t.getUTCHours(),
+      t.getUTCMinutes(),
+      t.getUTCSeconds(),
+      t.getUTCMilliseconds(),
+    ) +
+    ((h * 60 + min) * 60 + sec) * 1000;
+  if (out <= ms) throw new Error(`ISO 8601 duration ${d} is zero`);
+  return out;
+};
+
+// inRange reports whether an RFC3339 timestamp lies in resolved bounds.
+export const inRange = (window, at) => {
+  if (!window) return true;
+  const ms = Date.parse(at);
+  return (!window.from || ms >= Date.parse(window.from)) && (!window.to || ms < Date.parse(window.to));
+};
@@ -0,0 +1,51 @@
+// API versioning. Routes are served under /v1. The unversioned /api prefix
+// the first wallet and portal integrations were built against stays as an
+// alias that answers with Deprecation (RFC 9745), Sunset (RFC 8594) and a
+// successor-version Link to the /v1 route. With LEGACY_API_ENFORCE_SUNSET=true
+// it answers 410 Gone once the sunset date passes.
+//
+// Handlers always build the current version's response; responseAdapters
+// rewrite it for older prefixes, so an envelope change ships as a new
+// version plus an adapter rather than breaking existing clients overnight.
+// /api has v1's shape today.
+//
+//   LEGACY_API_DEPRECATED_AT=2026-10-16T00:00:00Z
+//   LEGACY_API_SUNSET=2027-04-30T00:00:00Z
+//   LEGACY_API_ENFORCE_SUNSET=false
+
+export const CURRENT_PREFIX = "/v1";
+const LEGACY_PREFIX = "/api";
+
+const DEPRECATED_AT = Date.parse(process.env.LEGACY_API_DEPRECATED_AT || "2026-10-16T00:00:00Z");
+const SUNSET = Date.parse(process.env.LEGACY_API_SUNSET || "2027-04-30T00:00:00Z");
+const ENFORCE_SUNSET = process.env.LEGACY_API_ENFORCE_SUNSET === "true";
+
+const responseAdapters = {
+  [LEGACY_PREFIX]: (body) => body,
+};
+
+// apiVersioning maps legacy requests onto the current routes and sets
+// req.apiBase to the prefix the client used, for links in responses.
+export const apiVersioning = (req, res, next) => {
+  if (req.url.startsWith(CURRENT_PREFIX + "/")) {
+    req.apiBase = CURRENT_PREFIX;
+    return next();
+  }
+  if (!req.url.startsWith(LEGACY_PREFIX + "/")) return next();
+
+  const rest = req.url.slice(LEGACY_PREFIX.length);
+  res.set({
+    Deprecation: `@${Math.floor(DEPRECATED_AT / 1000)}`,
+    Sunset: new Date(SUNSET).toUTCString(),
+    Link: `<${CURRENT_PREFIX}${rest.split("?")[0]}>; rel="successor-version"`,
+  });
+  if (ENFORCE_SUNSET && Date.now() >= SUNSET) {
+    return res.status(410).json({ ok: false, error: `${LEGACY_PREFIX} was retired; use ${CURRENT_PREFIX}` });
+  }
+
+  const json = res.json.bind(res);
+  res.json = (body) => json(responseAdapters[LEGACY_PREFIX](body));
+  req.apiBase = LEGACY_PREFIX;
+  req.url = CURRENT_PREFIX + rest;
+  next();
+};
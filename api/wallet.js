@@ -0,0 +1,52 @@
+// Wallet device attestation. Wallets may send a platform attestation token
+// (Play Integrity, App Attest, ...) with a presentation; the gateway has it
+// checked by WALLET_ATTESTATION_VERIFIER_URL and records only the outcome on
+// the verification event, as chaincode VerifyCreds does with the transient
+// walletAttestation field (contracts/wallet.go).
+//
+//   WALLET_ATTESTATION_VERIFIER_URL=...  POST {token, platform, nonce} -> {valid, platform?, reason?}
+//   WALLET_ATTESTATION_TIMEOUT_MS=3000
+//   WALLET_ATTESTATION_REQUIRED=true     deny verifications without a Valid attestation
+//
+// Without a verifier URL every token is recorded as Unverified.
+
+const VERIFIER_URL = process.env.WALLET_ATTESTATION_VERIFIER_URL || "";
+const TIMEOUT_MS = Number(process.env.WALLET_ATTESTATION_TIMEOUT_MS || 3000);
+export const WALLET_ATTESTATION_REQUIRED = process.env.WALLET_ATTESTATION_REQUIRED === "true";
+
+// checkWalletAttestation resolves to { status, platform, reason } with
+// status Valid | Invalid | Unverified, or null when no token was sent.
+// nonce binds the token to this presentation where the platform supports it.
+export const checkWalletAttestation = async (token, platform = "", nonce = "") => {
+  if (!token) return null;
+  if (!VERIFIER_URL) return { status: "Unverified", platform, reason: "no attestation verifier configured" };
+  try {
+    const res = await fetch(VERIFIER_URL, {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ token, platform, nonce }),
+      signal: AbortSignal.timeout(TIMEOUT_MS),
+    });
+    if (!res.ok) throw new Error(`attestation verifier returned ${res.status}`);
+    const out = await res.json();
+    return {
+      status: out.valid === true ? "Valid" : "Invalid",
+      platform: out.platform || platform,
+      reason: out.reason || "",
+    };
+  } catch (err) {
+    return { status: "Unverified", platform, reason: err.message };
+  }
+};
+
+// walletDenial returns why a verification must be denied under
+// WALLET_ATTESTATION_REQUIRED, or "" when it may proceed.
+export const walletDenial = (attestation) => {
+  if (!WALLET_ATTESTATION_REQUIRED) return "";
+  if (!attestation) return "wallet attestation required";
+  return attestation.status === "Valid" ? "" : `wallet attestation ${attestation.status}`;
+};
+
+// attestationFields are the event fields for an attestation outcome.
+export const attestationFields = (attestation) =>
+  attestation ? { walletAttestation: attestation.status, walletPlatform: attestation.platform } : {};
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// roleAttr is the Fabric CA certificate attribute carrying a client's role.
+const roleAttr = "audittrail.role"
+
+// requireAdmin rejects callers whose certificate lacks audittrail.role=admin.
+func requireAdmin(ctx contractapi.TransactionContextInterface) error {
+	if err := ctx.GetClientIdentity().AssertAttributeValue(roleAttr, "admin"); err != nil {
+		return fmt.Errorf("admin role required: %v", err)
+	}
+	return nil
+}
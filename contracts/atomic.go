@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Atomic transactions. Some workflows change several credentials at once
+// ("revoke the old one, issue its replacement, link the two") and must not
+// stop halfway, which separate transactions can: the revoke commits and the
+// issue is rejected, or the gateway dies in between. Atomic takes the steps
+// as one ordered list and applies them in a single transaction; if any step
+// fails, the transaction fails and none of them commit.
+//
+// Each step runs the same checks as the transaction it stands for
+// (IssueCreds or IssueOrgCreds, RevokeCreds, LinkCredentials), the access
+// policy included, and sees the state earlier steps left behind.
+
+// AtomicOp is one step of an Atomic transaction. Op selects the fields used:
+//
+//	Issue   credId, holderDid, credType, hashedData or merkleRoot, issuerId,
+//	        optional jurisdiction, expiresAt, holderType, legalEntityId
+//	Revoke  credId, reason, revokerId
+//	Link    credId (the link's source), targetCredId, relation, actorId
+type AtomicOp struct {
+	Op            string `json:"op"` // Issue | Revoke | Link
+	CredID        string `json:"credId"`
+	HolderDID     string `json:"holderDid,omitempty"`
+	CredType      string `json:"credType,omitempty"`
+	HashedData    string `json:"hashedData,omitempty"`
+	IssuerID      string `json:"issuerId,omitempty"`
+	Jurisdiction  string `json:"jurisdiction,omitempty"`
+	ExpiresAt     string `json:"expiresAt,omitempty"`
+	MerkleRoot    string `json:"merkleRoot,omitempty"`
+	HolderType    string `json:"holderType,omitempty"`
+	LegalEntityID string `json:"legalEntityId,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+	RevokerID     string `json:"revokerId,omitempty"`
+	TargetCredID  string `json:"targetCredId,omitempty"`
+	Relation      string `json:"relation,omitempty"`
+	ActorID       string `json:"actorId,omitempty"`
+}
+
+// maxAtomicOps bounds the read-write set of one Atomic transaction.
+const maxAtomicOps = 50
+
+// Atomic validates every step, then applies them in order; any failure
+// rejects the whole transaction.
+func (s *CredentialContract) Atomic(ctx contractapi.TransactionContextInterface,
+	ops []AtomicOp) error {
+
+	if len(ops) == 0 {
+		return fmt.Errorf("ops is empty")
+	}
+	if len(ops) > maxAtomicOps {
+		return fmt.Errorf("at most %d ops per transaction, got %d", maxAtomicOps, len(ops))
+	}
+	for i, op := range ops {
+		if err := op.validate(); err != nil {
+			return fmt.Errorf("op %d (%s %s): %v", i, op.Op, op.CredID, err)
+		}
+		if err := authorizeCall(ctx, contractNames["CredentialContract"]+":"+op.transaction()); err != nil {
+			return fmt.Errorf("op %d (%s %s): %v", i, op.Op, op.CredID, err)
+		}
+	}
+	for i, op := range ops {
+		if err := s.apply(ctx, op); err != nil {
+			return fmt.Errorf("op %d (%s %s): %v", i, op.Op, op.CredID, err)
+		}
+	}
+	return nil
+}
+
+// ===== Helpers =====
+
+// transaction names the transaction an op stands for, as access policy
+// rules name it.
+func (op *AtomicOp) transaction() string {
+	switch op.Op {
+	case "Issue":
+		if op.HolderType == HolderOrganization {
+			return "IssueOrgCreds"
+		}
+		return "IssueCreds"
+	case "Revoke":
+		return "RevokeCreds"
+	default:
+		return "LinkCredentials"
+	}
+}
+
+// validate checks an op's shape before anything is applied; the checks
+// that depend on state run when it is.
+func (op *AtomicOp) validate() error {
+	var required [][2]string
+	switch op.Op {
+	case "Issue":
+		required = [][2]string{{"credId", op.CredID}, {"holderDid", op.HolderDID}, {"credType", op.CredType},
+			{"issuerId", op.IssuerID}}
+		if op.HolderType != "" && op.HolderType != HolderIndividual && op.HolderType != HolderOrganization {
+			return fmt.Errorf("holderType must be %s or %s", HolderIndividual, HolderOrganization)
+		}
+	case "Revoke":
+		required = [][2]string{{"credId", op.CredID}, {"revokerId", op.RevokerID}}
+	case "Link":
+		required = [][2]string{{"credId", op.CredID}, {"targetCredId", op.TargetCredID}, {"relation", op.Relation},
+			{"actorId", op.ActorID}}
+	default:
+		return fmt.Errorf("op must be Issue, Revoke or Link")
+	}
+	for _, f := range required {
+		if f[1] == "" {
+			return fmt.Errorf("%s is required", f[0])
+		}
+	}
+	return nil
+}
+
+func (s *CredentialContract) apply(ctx contractapi.TransactionContextInterface, op AtomicOp) error {
+	switch op.Op {
+	case "Issue":
+		// A blocked holder's issuance would commit as a denial; here it
+		// must fail the transaction instead.
+		if denial, err := holderBlockDenial(ctx, op.HolderDID); err != nil || denial != "" {
+			if err == nil {
+				err = fmt.Errorf("%s", denial)
+			}
+			return err
+		}
+		if op.HolderType == HolderOrganization {
+			return s.IssueOrgCreds(ctx, op.CredID, op.HolderDID, op.LegalEntityID, op.CredType, op.HashedData,
+				op.IssuerID, op.Jurisdiction, op.ExpiresAt, op.MerkleRoot)
+		}
+		return s.IssueCreds(ctx, op.CredID, op.HolderDID, op.CredType, op.HashedData, op.IssuerID,
+			op.Jurisdiction, op.ExpiresAt, op.MerkleRoot)
+	case "Revoke":
+		return s.RevokeCreds(ctx, op.CredID, op.Reason, op.RevokerID)
+	default:
+		return s.LinkCredentials(ctx, op.CredID, op.TargetCredID, op.Relation, op.ActorID)
+	}
+}
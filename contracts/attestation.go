@@ -0,0 +1,181 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// PeriodAttestation is an issuer's sign-off that the on-chain event set for
+// its credentials in [PeriodStart, PeriodEnd) is complete.
+type PeriodAttestation struct {
+	IssuerID    string `json:"issuerId"`
+	PeriodStart string `json:"periodStart"` // RFC3339
+	PeriodEnd   string `json:"periodEnd"`   // RFC3339
+	EventCount  int    `json:"eventCount"`
+	Digest      string `json:"digest"`     // hex sha256 of sorted event IDs, newline-joined
+	AttestedBy  string `json:"attestedBy"` // submitting MSP ID
+	TxID        string `json:"txId"`
+	AttestedAt  string `json:"attestedAt"` // RFC3339
+}
+
+// PeriodDigest is the on-chain view of an issuer's events in a period.
+type PeriodDigest struct {
+	EventCount int    `json:"eventCount"`
+	Digest     string `json:"digest"`
+}
+
+// GetPeriodDigest computes the event count and digest an issuer must attest to.
+func (s *AuditContract) GetPeriodDigest(ctx contractapi.TransactionContextInterface,
+	issuerID, periodStart, periodEnd string) (*PeriodDigest, error) {
+
+	start, end, err := parsePeriod(periodStart, periodEnd)
+	if err != nil {
+		return nil, err
+	}
+	return s.periodDigest(ctx, issuerID, start, end)
+}
+
+// AttestPeriod records the issuer's completeness claim. Only the issuer's
+// registered org may attest. The supplied count and digest must match what
+// the ledger holds, so the attestation is verifiable.
+func (s *AuditContract) AttestPeriod(ctx contractapi.TransactionContextInterface,
+	issuerID, periodStart, periodEnd string, eventCount int, digest string) error {
+
+	mspID, err := s.requireRegisteredIssuerMSP(ctx, issuerID)
+	if err != nil {
+		return err
+	}
+	start, end, err := parsePeriod(periodStart, periodEnd)
+	if err != nil {
+		return err
+	}
+	ck, err := compositeKey(ctx, "attest~issuer", []string{issuerID, periodStart})
+	if err != nil {
+		return err
+	}
+	existing, err := ctx.GetStub().GetState(ck)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("period starting %s already attested for issuer %s", periodStart, issuerID)
+	}
+
+	onChain, err := s.periodDigest(ctx, issuerID, start, end)
+	if err != nil {
+		return err
+	}
+	if onChain.EventCount != eventCount || onChain.Digest != digest {
+		return fmt.Errorf("attestation mismatch: ledger has %d events with digest %s",
+			onChain.EventCount, onChain.Digest)
+	}
+
+	att := &PeriodAttestation{
+		IssuerID:    issuerID,
+		PeriodStart: periodStart,
+		PeriodEnd:   periodEnd,
+		EventCount:  eventCount,
+		Digest:      digest,
+		AttestedBy:  mspID,
+		TxID:        ctx.GetStub().GetTxID(),
+		AttestedAt:  nowRFC3339(),
+	}
+	bz, _ := json.Marshal(att)
+	if err := ctx.GetStub().PutState(ck, bz); err != nil {
+		return err
+	}
+	return emitEvent(ctx, "PeriodAttested", att)
+}
+
+// GetAttestations returns all period attestations for an issuer.
+func (s *AuditContract) GetAttestations(ctx contractapi.TransactionContextInterface,
+	issuerID string) ([]PeriodAttestation, error) {
+
+	iter, err := ctx.GetStub().GetStateByPartialCompositeKey("attest~issuer", []string{issuerID})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var atts []PeriodAttestation
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		var att PeriodAttestation
+		if err := json.Unmarshal(kv.Value, &att); err != nil {
+			return nil, err
+		}
+		atts = append(atts, att)
+	}
+	return atts, nil
+}
+
+// ===== Helpers =====
+
+// periodDigest walks the issuer's credentials and their events. Credentials
+// issued before the cred~issuer index existed are not covered.
+func (s *ledger) periodDigest(ctx contractapi.TransactionContextInterface,
+	issuerID string, start, end time.Time) (*PeriodDigest, error) {
+
+	credIter, err := ctx.GetStub().GetStateByPartialCompositeKey("cred~issuer", []string{issuerID})
+	if err != nil {
+		return nil, err
+	}
+	defer credIter.Close()
+
+	var ids []string
+	for credIter.HasNext() {
+		kv, err := credIter.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, attrs, err := ctx.GetStub().SplitCompositeKey(kv.Key)
+		if err != nil {
+			return nil, err
+		}
+		cred, err := s.getCred(ctx, attrs[1])
+		if err != nil {
+			return nil, err
+		}
+
+		events, err := credEvents(ctx, cred)
+		if err != nil {
+			return nil, err
+		}
+		for _, evt := range events {
+			at, err := time.Parse(time.RFC3339, evt.OccurredAt)
+			if err != nil || at.Before(start) || !at.Before(end) {
+				continue
+			}
+			ids = append(ids, evt.EventID)
+		}
+	}
+
+	sort.Strings(ids)
+	sum := sha256.Sum256([]byte(strings.Join(ids, "\n")))
+	return &PeriodDigest{EventCount: len(ids), Digest: hex.EncodeToString(sum[:])}, nil
+}
+
+func parsePeriod(periodStart, periodEnd string) (time.Time, time.Time, error) {
+	start, err := time.Parse(time.RFC3339, periodStart)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid periodStart %q: %v", periodStart, err)
+	}
+	end, err := time.Parse(time.RFC3339, periodEnd)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid periodEnd %q: %v", periodEnd, err)
+	}
+	if !end.After(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("periodEnd must be after periodStart")
+	}
+	return start, end, nil
+}
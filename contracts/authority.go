@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Issuer authority sweeps. The checks made when an event is recorded only
+// hold if every endorsing peer ran them; an issuer that got a credential
+// endorsed outside its authority, through a compromised peer or a chaincode
+// version without the check, leaves an event that looks like any other.
+// RunAuthoritySweep replays an issuer's credential events against the
+// registry and the delegation records:
+//
+//   IssuedBeforeRegistration  Issue, Renew or Transfer-in by an issuer
+//                             before its RegisteredAt
+//   IssuedAfterDeactivation   the same after its DeactivatedAt
+//   RevokedOutsideDelegation  a delegated revocation while the delegate
+//                             held no grant covering the credential
+//
+// An issuer is registered once, so its authority is the window between its
+// registry entry's two timestamps. A delegation record is overwritten by
+// every grant and withdrawal, so delegation windows are rebuilt from the
+// record's history. Timestamps have second resolution, so an event in the
+// same second as a window's end counts as inside it. Issuers never
+// registered predate the registry and are left to RunComplianceSweep's
+// IssuerUnregistered.
+
+// RunAuthoritySweep checks the events on one page of issuerID's credentials,
+// persists findings as compliance findings (GetComplianceFindings) and emits
+// them as a single ComplianceFinding event. A finding's ID is derived from
+// its rule and event, so a rerun replaces rather than repeats it. Call
+// repeatedly with the returned bookmark.
+func (s *AuditContract) RunAuthoritySweep(ctx contractapi.TransactionContextInterface,
+	issuerID string, pageSize int32, bookmark string) (*SweepResult, error) {
+
+	raw, err := decodeBookmark(ctx, "cred~issuer", bookmark)
+	if err != nil {
+		return nil, err
+	}
+	iter, meta, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(
+		"cred~issuer", []string{issuerID}, pageSize, raw)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+	next, err := encodeBookmark(ctx, "cred~issuer", meta.Bookmark)
+	if err != nil {
+		return nil, err
+	}
+
+	issuers := map[string]*Issuer{}
+	windows := map[string][]delegationWindow{}
+	res := &SweepResult{Findings: []ComplianceFinding{}, Bookmark: next}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, attrs, err := ctx.GetStub().SplitCompositeKey(kv.Key)
+		if err != nil {
+			return nil, err
+		}
+		cred, err := s.getCred(ctx, attrs[1])
+		if err != nil {
+			return nil, err
+		}
+		events, err := credEvents(ctx, cred)
+		if err != nil {
+			return nil, err
+		}
+		res.Scanned++
+
+		for _, evt := range events {
+			if evt.Outcome != "Success" {
+				continue
+			}
+			var rule, detail string
+			switch evt.Action {
+			case "Issue", "Renew", "Transfer":
+				issuer, ok := issuers[evt.ActorID]
+				if !ok {
+					if issuer, err = s.getIssuer(ctx, evt.ActorID); err != nil {
+						return nil, err
+					}
+					issuers[evt.ActorID] = issuer
+				}
+				rule, detail = issuer.authorityAt(evt.OccurredAt)
+			case "Revoke":
+				delegate := revocationDelegate(evt.Reason)
+				if delegate == "" {
+					continue
+				}
+				wk := cred.IssuerID + "/" + delegate
+				if _, ok := windows[wk]; !ok {
+					if windows[wk], err = delegationWindows(ctx, cred.IssuerID, delegate); err != nil {
+						return nil, err
+					}
+				}
+				if !delegatedAt(windows[wk], cred, evt.OccurredAt) {
+					rule = "RevokedOutsideDelegation"
+					detail = delegate + " held no grant from " + cred.IssuerID + " covering it at " + evt.OccurredAt
+				}
+			}
+			if rule == "" {
+				continue
+			}
+			finding := ComplianceFinding{
+				FindingID:  rule + ":" + evt.EventID,
+				CredID:     cred.CredID,
+				CredType:   cred.CredType,
+				IssuerID:   cred.IssuerID,
+				Rule:       rule,
+				Detail:     evt.Action + " event " + evt.EventID + ": " + detail,
+				DetectedAt: nowRFC3339(),
+			}
+			res.Findings = append(res.Findings, finding)
+			if err := s.putFinding(ctx, &finding); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(res.Findings) > 0 {
+		if err := emitEvent(ctx, "ComplianceFinding", complianceEvent{Findings: res.Findings}); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// ===== Helpers =====
+
+// delegationWindow is a span in which one grant was in force; To is empty
+// while it still is.
+type delegationWindow struct {
+	From, To string // RFC3339
+	Grant    RevocationDelegation
+}
+
+// authorityAt returns the rule an issuance act by i at the RFC3339 time at
+// breaks, if any. Unregistered issuers break none here.
+func (i *Issuer) authorityAt(at string) (string, string) {
+	switch {
+	case i == nil:
+		return "", ""
+	case at < i.RegisteredAt:
+		return "IssuedBeforeRegistration", i.IssuerID + " registered at " + i.RegisteredAt
+	case i.DeactivatedAt != "" && at > i.DeactivatedAt:
+		return "IssuedAfterDeactivation", i.IssuerID + " deactivated at " + i.DeactivatedAt
+	}
+	return "", ""
+}
+
+// revocationDelegate extracts the delegate MSP RevokeCreds appends to the
+// reason of a delegated revocation.
+func revocationDelegate(reason string) string {
+	const marker = " [delegated to "
+	i := strings.LastIndex(reason, marker)
+	if i < 0 || !strings.HasSuffix(reason, "]") {
+		return ""
+	}
+	return reason[i+len(marker) : len(reason)-1]
+}
+
+// delegationWindows rebuilds the grants issuerID has made to delegateMSP
+// from the history of the delegation record. A grant lasts until it is
+// withdrawn or replaced by the next one.
+func delegationWindows(ctx contractapi.TransactionContextInterface,
+	issuerID, delegateMSP string) ([]delegationWindow, error) {
+
+	ck, err := compositeKey(ctx, "delegation~issuer", []string{issuerID, delegateMSP})
+	if err != nil {
+		return nil, err
+	}
+	iter, err := ctx.GetStub().GetHistoryForKey(ck)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var versions []RevocationDelegation
+	for iter.HasNext() {
+		mod, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		if mod.IsDelete {
+			continue
+		}
+		var d RevocationDelegation
+		if err := json.Unmarshal(mod.Value, &d); err != nil {
+			return nil, err
+		}
+		versions = append(versions, d)
+	}
+	// Every write sets UpdatedAt, whatever order the peer returns history in.
+	sort.SliceStable(versions, func(i, j int) bool { return versions[i].UpdatedAt < versions[j].UpdatedAt })
+
+	var windows []delegationWindow
+	for _, d := range versions {
+		if n := len(windows); n > 0 && windows[n-1].To == "" {
+			windows[n-1].To = d.UpdatedAt
+		}
+		if d.Status == "Active" {
+			windows = append(windows, delegationWindow{From: d.GrantedAt, Grant: d})
+		}
+	}
+	return windows, nil
+}
+
+// delegatedAt reports whether a window covering cred was open at the
+// RFC3339 time at.
+func delegatedAt(windows []delegationWindow, cred *Credential, at string) bool {
+	for _, w := range windows {
+		if at >= w.From && (w.To == "" || at <= w.To) && w.Grant.covers(cred) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Consortium benchmarking. Once a month is over, each org that wants to
+// compare itself with its peers runs ContributeBenchmark for it, typically
+// from a scheduled job. The chaincode computes the org's figures from the
+// credentials of the issuers registered to its MSP, so they are neither
+// self-reported nor exchanged bilaterally, and files them in the benchmark
+// namespace under a random token rather than the MSP ID. GetBenchmarkReport
+// hands out distributions over the contributions only, never one org's
+// figures, only once minBenchmarkContributors orgs have contributed, and
+// only to orgs that contributed for that month themselves.
+//
+// The namespace keeps figures out of every query; it does not hide them
+// from channel members who read blocks and match a contribution's write
+// set with its creator.
+
+// minBenchmarkContributors is the fewest contributions a report, or a
+// credential type within one, is published for.
+const minBenchmarkContributors = 3
+
+// BenchmarkMetrics is one org's contribution for a month.
+type BenchmarkMetrics struct {
+	Issued         int                             `json:"issued"`         // credentials issued in the month
+	Revoked        int                             `json:"revoked"`        // credentials revoked in the month
+	Portfolio      int                             `json:"portfolio"`      // credentials issued by the end of the month
+	RevocationRate float64                         `json:"revocationRate"` // Revoked / Portfolio
+	ByCredType     map[string]BenchmarkTypeMetrics `json:"byCredType"`
+}
+
+// BenchmarkTypeMetrics is the part of a contribution for one credential type.
+type BenchmarkTypeMetrics struct {
+	Issued  int `json:"issued"`
+	Revoked int `json:"revoked"`
+}
+
+// BenchmarkDistribution summarizes one metric across contributions.
+type BenchmarkDistribution struct {
+	Min    float64 `json:"min"`
+	P25    float64 `json:"p25"`
+	Median float64 `json:"median"`
+	P75    float64 `json:"p75"`
+	Max    float64 `json:"max"`
+}
+
+// BenchmarkReport is the consortium view of a month.
+type BenchmarkReport struct {
+	Period         string                         `json:"period"` // YYYY-MM
+	Contributors   int                            `json:"contributors"`
+	Issued         BenchmarkDistribution          `json:"issued"`
+	Revoked        BenchmarkDistribution          `json:"revoked"`
+	RevocationRate BenchmarkDistribution          `json:"revocationRate"`
+	ByCredType     map[string]BenchmarkTypeReport `json:"byCredType"` // types with enough contributors only
+}
+
+// BenchmarkTypeReport is the consortium view of one credential type.
+type BenchmarkTypeReport struct {
+	Contributors int                   `json:"contributors"`
+	Issued       BenchmarkDistribution `json:"issued"`
+	Revoked      BenchmarkDistribution `json:"revoked"`
+}
+
+// ContributeBenchmark computes the caller's org's figures for period
+// (YYYY-MM), which must be over, and adds them to the benchmark namespace.
+// An org contributes once per month.
+func (s *AuditContract) ContributeBenchmark(ctx contractapi.TransactionContextInterface,
+	period string) (*BenchmarkMetrics, error) {
+
+	start, err := benchmarkMonth(period)
+	if err != nil {
+		return nil, err
+	}
+	end := start.AddDate(0, 1, 0)
+	if time.Now().UTC().Before(end) {
+		return nil, fmt.Errorf("period %s has not ended yet", period)
+	}
+	mspID, contributed, err := benchmarkMember(ctx, period)
+	if err != nil {
+		return nil, err
+	}
+	if contributed {
+		return nil, fmt.Errorf("%s has already contributed for %s", mspID, period)
+	}
+
+	issuers, err := s.orgIssuers(ctx, mspID)
+	if err != nil {
+		return nil, err
+	}
+	if len(issuers) == 0 {
+		return nil, fmt.Errorf("no issuers are registered to %s", mspID)
+	}
+	m, err := s.benchmarkMetrics(ctx, issuers, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	// The token ties the contribution to nothing but its transaction.
+	sum := sha256.Sum256([]byte(ctx.GetStub().GetTxID()))
+	contribKey, err := compositeKey(ctx, "benchmark~contrib", []string{period, hex.EncodeToString(sum[:])})
+	if err != nil {
+		return nil, err
+	}
+	bz, _ := json.Marshal(m)
+	if err := ctx.GetStub().PutState(contribKey, bz); err != nil {
+		return nil, err
+	}
+	if err := putIndexKey(ctx, "benchmark~member", period, mspID); err != nil {
+		return nil, err
+	}
+	contribs, err := benchmarkContributions(ctx, period)
+	if err != nil {
+		return nil, err
+	}
+	if err := emitEvent(ctx, "BenchmarkContribution", map[string]interface{}{
+		"period":       period,
+		"contributors": len(contribs) + 1,
+	}); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// GetBenchmarkReport returns the distributions over period's contributions.
+// The caller's org must have contributed for period.
+func (s *AuditContract) GetBenchmarkReport(ctx contractapi.TransactionContextInterface,
+	period string) (*BenchmarkReport, error) {
+
+	if _, err := benchmarkMonth(period); err != nil {
+		return nil, err
+	}
+	mspID, contributed, err := benchmarkMember(ctx, period)
+	if err != nil {
+		return nil, err
+	}
+	if !contributed {
+		return nil, fmt.Errorf("%s has not contributed for %s", mspID, period)
+	}
+
+	contribs, err := benchmarkContributions(ctx, period)
+	if err != nil {
+		return nil, err
+	}
+	if len(contribs) < minBenchmarkContributors {
+		return nil, fmt.Errorf("%s has %d contributions; reports need %d", period, len(contribs), minBenchmarkContributors)
+	}
+
+	var issued, revoked, rate []float64
+	byType := map[string][]BenchmarkTypeMetrics{}
+	for _, m := range contribs {
+		issued = append(issued, float64(m.Issued))
+		revoked = append(revoked, float64(m.Revoked))
+		rate = append(rate, m.RevocationRate)
+		for credType, tm := range m.ByCredType {
+			byType[credType] = append(byType[credType], tm)
+		}
+	}
+	report := &BenchmarkReport{
+		Period:         period,
+		Contributors:   len(contribs),
+		Issued:         distribution(issued),
+		Revoked:        distribution(revoked),
+		RevocationRate: distribution(rate),
+		ByCredType:     map[string]BenchmarkTypeReport{},
+	}
+	for credType, tms := range byType {
+		if len(tms) < minBenchmarkContributors {
+			continue
+		}
+		var ti, tr []float64
+		for _, tm := range tms {
+			ti = append(ti, float64(tm.Issued))
+			tr = append(tr, float64(tm.Revoked))
+		}
+		report.ByCredType[credType] = BenchmarkTypeReport{
+			Contributors: len(tms),
+			Issued:       distribution(ti),
+			Revoked:      distribution(tr),
+		}
+	}
+	return report, nil
+}
+
+// ===== Helpers =====
+
+func benchmarkMonth(period string) (time.Time, error) {
+	start, err := time.Parse("2006-01", period)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid period %q, want YYYY-MM", period)
+	}
+	return start, nil
+}
+
+// benchmarkMember reports whether the caller's org contributed for period.
+func benchmarkMember(ctx contractapi.TransactionContextInterface, period string) (string, bool, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", false, err
+	}
+	key, err := compositeKey(ctx, "benchmark~member", []string{period, mspID})
+	if err != nil {
+		return "", false, err
+	}
+	bz, err := ctx.GetStub().GetState(key)
+	return mspID, bz != nil, err
+}
+
+// orgIssuers returns the IDs of the issuers registered to mspID.
+func (s *ledger) orgIssuers(ctx contractapi.TransactionContextInterface, mspID string) ([]string, error) {
+	iter, err := ctx.GetStub().GetStateByRange(issuerKey(""), issuerKey("")+"\xff")
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var ids []string
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		var issuer Issuer
+		if err := json.Unmarshal(kv.Value, &issuer); err != nil {
+			return nil, err
+		}
+		if issuer.MSPID == mspID {
+			ids = append(ids, issuer.IssuerID)
+		}
+	}
+	return ids, nil
+}
+
+// benchmarkMetrics walks the issuers' credentials. A revoked credential
+// counts as revoked on the day of its last update, as in cred~revoked.
+func (s *ledger) benchmarkMetrics(ctx contractapi.TransactionContextInterface,
+	issuers []string, start, end time.Time) (*BenchmarkMetrics, error) {
+
+	m := &BenchmarkMetrics{ByCredType: map[string]BenchmarkTypeMetrics{}}
+	from, until := start.Format(time.DateOnly), end.Format(time.DateOnly)
+	for _, issuerID := range issuers {
+		iter, err := ctx.GetStub().GetStateByPartialCompositeKey("cred~issuer", []string{issuerID})
+		if err != nil {
+			return nil, err
+		}
+		for iter.HasNext() {
+			kv, err := iter.Next()
+			if err != nil {
+				iter.Close()
+				return nil, err
+			}
+			_, attrs, err := ctx.GetStub().SplitCompositeKey(kv.Key)
+			if err != nil {
+				iter.Close()
+				return nil, err
+			}
+			cred, err := s.getCred(ctx, attrs[1])
+			if err != nil {
+				iter.Close()
+				return nil, err
+			}
+			created, err := time.Parse(time.RFC3339, cred.CreatedAt)
+			if err != nil || !created.Before(end) {
+				continue
+			}
+			m.Portfolio++
+			tm := m.ByCredType[cred.CredType]
+			if !created.Before(start) {
+				m.Issued++
+				tm.Issued++
+			}
+			if day := revokedDay(cred); cred.Status == "Revoked" && day >= from && day < until {
+				m.Revoked++
+				tm.Revoked++
+			}
+			m.ByCredType[cred.CredType] = tm
+		}
+		iter.Close()
+	}
+	for credType, tm := range m.ByCredType {
+		if tm == (BenchmarkTypeMetrics{}) {
+			delete(m.ByCredType, credType)
+		}
+	}
+	if m.Portfolio > 0 {
+		m.RevocationRate = float64(m.Revoked) / float64(m.Portfolio)
+	}
+	return m, nil
+}
+
+func benchmarkContributions(ctx contractapi.TransactionContextInterface, period string) ([]BenchmarkMetrics, error) {
+	iter, err := ctx.GetStub().GetStateByPartialCompositeKey("benchmark~contrib", []string{period})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var out []BenchmarkMetrics
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		var m BenchmarkMetrics
+		if err := json.Unmarshal(kv.Value, &m); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+// distribution summarizes vs by nearest-rank percentiles.
+func distribution(vs []float64) BenchmarkDistribution {
+	sort.Float64s(vs)
+	at := func(p float64) float64 {
+		i := int(math.Ceil(p*float64(len(vs)))) - 1
+		return vs[max(i, 0)]
+	}
+	return BenchmarkDistribution{Min: vs[0], P25: at(0.25), Median: at(0.5), P75: at(0.75), Max: vs[len(vs)-1]}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Holder blocklist. When a fraud case implicates a holder DID, an admin of
+// any consortium org blocks it on the channel, and every org's issuance and
+// verification of that holder's credentials is refused until an admin
+// unblocks it:
+//
+//   - issuance (IssueCreds, IssueOrgCreds, PrepareIssue, CommitIssue) stores
+//     no credential and reserves nothing, and commits a Denied Issue event
+//     instead. The transaction itself succeeds, since a failed one would
+//     take the event with it; callers tell the denial from the event. An
+//     Issue step of an Atomic transaction fails the transaction, which
+//     must not commit in part.
+//   - verification is denied like a lapsed DPA (see checkVerifier), and
+//     BreakGlassVerify records the block as the check it bypassed.
+//
+// Blocking and unblocking are recorded in the holder's trail. Events name
+// the case, never the reason, which stays in the blocklist entry for admins.
+
+// HolderBlock is a blocklist entry.
+type HolderBlock struct {
+	HolderDID string `json:"holderDid"`
+	CaseID    string `json:"caseId"`    // fraud case reference
+	Reason    string `json:"reason"`    // optional
+	BlockedBy string `json:"blockedBy"` // MSP ID of the admin
+	BlockedAt string `json:"blockedAt"` // RFC3339
+}
+
+// BlockHolder adds holderDID to the blocklist under caseID.
+func (s *AdminContract) BlockHolder(ctx contractapi.TransactionContextInterface,
+	holderDID, caseID, reason string) error {
+
+	if err := requireAdmin(ctx); err != nil {
+		return err
+	}
+	if holderDID == "" || caseID == "" {
+		return fmt.Errorf("holderDID and caseID are required")
+	}
+	existing, err := getHolderBlock(ctx, holderDID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("holder %s is already blocked (case %s)", holderDID, existing.CaseID)
+	}
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return err
+	}
+
+	b := HolderBlock{
+		HolderDID: holderDID,
+		CaseID:    caseID,
+		Reason:    reason,
+		BlockedBy: mspID,
+		BlockedAt: nowRFC3339(),
+	}
+	bz, _ := json.Marshal(b)
+	if err := ctx.GetStub().PutState(holderBlockKey(holderDID), bz); err != nil {
+		return err
+	}
+	return s.recordEvent(ctx, "", holderDID, "HolderBlock", mspID, "Success", "case "+caseID)
+}
+
+// UnblockHolder removes holderDID from the blocklist.
+func (s *AdminContract) UnblockHolder(ctx contractapi.TransactionContextInterface,
+	holderDID, reason string) error {
+
+	if err := requireAdmin(ctx); err != nil {
+		return err
+	}
+	b, err := getHolderBlock(ctx, holderDID)
+	if err != nil {
+		return err
+	}
+	if b == nil {
+		return fmt.Errorf("holder %s is not blocked", holderDID)
+	}
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.GetStub().DelState(holderBlockKey(holderDID)); err != nil {
+		return err
+	}
+	return s.recordEvent(ctx, "", holderDID, "HolderUnblock", mspID, "Success", "case "+b.CaseID)
+}
+
+// GetHolderBlock returns holderDID's blocklist entry, or nil when it is not
+// blocked.
+func (s *AdminContract) GetHolderBlock(ctx contractapi.TransactionContextInterface,
+	holderDID string) (*HolderBlock, error) {
+
+	return getHolderBlock(ctx, holderDID)
+}
+
+// GetBlockedHolders lists the blocklist.
+func (s *AdminContract) GetBlockedHolders(ctx contractapi.TransactionContextInterface) ([]HolderBlock, error) {
+	iter, err := ctx.GetStub().GetStateByRange(holderBlockKey(""), holderBlockKey("")+"\xff")
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	blocks := []HolderBlock{}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		var b HolderBlock
+		if err := json.Unmarshal(kv.Value, &b); err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, b)
+	}
+	return blocks, nil
+}
+
+// ===== Helpers =====
+
+func getHolderBlock(ctx contractapi.TransactionContextInterface, holderDID string) (*HolderBlock, error) {
+	bz, err := ctx.GetStub().GetState(holderBlockKey(holderDID))
+	if err != nil || bz == nil {
+		return nil, err
+	}
+	var b HolderBlock
+	if err := json.Unmarshal(bz, &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// holderBlockDenial returns a denial reason when holderDID is blocked.
+func holderBlockDenial(ctx contractapi.TransactionContextInterface, holderDID string) (string, error) {
+	b, err := getHolderBlock(ctx, holderDID)
+	if err != nil || b == nil {
+		return "", err
+	}
+	return "holder is blocked (case " + b.CaseID + ")", nil
+}
+
+// denyBlockedIssue commits a Denied Issue event in place of issuing cred
+// when its holder is blocked, and reports whether it did.
+func (s *ledger) denyBlockedIssue(ctx contractapi.TransactionContextInterface, cred *Credential) (bool, error) {
+	denial, err := holderBlockDenial(ctx, cred.HolderDID)
+	if err != nil || denial == "" {
+		return false, err
+	}
+	return true, s.recordEvent(ctx, cred.CredID, cred.HolderDID, "Issue", cred.IssuerID, "Denied", denial)
+}
+
+func holderBlockKey(holderDID string) string { return "holderblock:" + holderDID }
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Paginated composite-key scans hand out bookmarks that record the key
+// layout they were issued under, not Fabric's raw bookmark (which is the
+// next key itself). An upgrade that changes an index's key attributes bumps
+// its version in keyLayouts and registers a translator from the previous
+// version, so a long export walking the index across the upgrade resumes
+// where it stopped instead of restarting or skipping entries. Translators
+// must preserve key order for the walk to stay gap-free.
+
+// bookmarkPrefix marks a versioned bookmark; the digit is the bookmark
+// format version. Bookmarks without it are raw Fabric keys from before
+// versioning and are read as layout version 1.
+const bookmarkPrefix = "bm1."
+
+// keyLayouts is the current key layout version of each paginated index.
+var keyLayouts = map[string]int{
+	"event~holder":    1,
+	"event~actor":     1,
+	"event~since":     1,
+	"cred~type":       1,
+	"cred~issuer":     1,
+	"cred~holdertype": 1,
+	"cred~expiry":     1,
+	"finding~cred":    1,
+}
+
+// bookmarkTranslators rewrite a key's attributes from a layout version (the
+// inner map key) to the next one, per index.
+var bookmarkTranslators = map[string]map[int]func(attrs []string) ([]string, error){}
+
+type pageBookmark struct {
+	Version int      `json:"v"`
+	Index   string   `json:"i"`
+	Attrs   []string `json:"k"`
+}
+
+// encodeBookmark wraps the raw bookmark Fabric returned for a scan of index.
+func encodeBookmark(ctx contractapi.TransactionContextInterface, index, raw string) (string, error) {
+	if raw == "" {
+		return "", nil
+	}
+	objectType, attrs, err := ctx.GetStub().SplitCompositeKey(raw)
+	if err != nil || objectType != index {
+		return "", fmt.Errorf("unexpected bookmark for index %s", index)
+	}
+	bz, _ := json.Marshal(pageBookmark{Version: layoutVersion(index), Index: index, Attrs: attrs})
+	return bookmarkPrefix + base64.RawURLEncoding.EncodeToString(bz), nil
+}
+
+// decodeBookmark returns the raw Fabric bookmark for resuming a scan of
+// index, translating bookmarks issued under older key layouts.
+func decodeBookmark(ctx contractapi.TransactionContextInterface, index, bookmark string) (string, error) {
+	if bookmark == "" {
+		return "", nil
+	}
+	b := pageBookmark{Version: 1, Index: index}
+	if strings.HasPrefix(bookmark, bookmarkPrefix) {
+		raw, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(bookmark, bookmarkPrefix))
+		if err != nil {
+			return "", fmt.Errorf("malformed bookmark")
+		}
+		if err := json.Unmarshal(raw, &b); err != nil {
+			return "", fmt.Errorf("malformed bookmark")
+		}
+	} else {
+		objectType, attrs, err := ctx.GetStub().SplitCompositeKey(bookmark)
+		if err != nil || objectType != index {
+			return "", fmt.Errorf("malformed bookmark")
+		}
+		b.Attrs = attrs
+	}
+	if b.Index != index {
+		return "", fmt.Errorf("bookmark is for index %s, not %s", b.Index, index)
+	}
+
+	current := layoutVersion(index)
+	if b.Version > current {
+		return "", fmt.Errorf("bookmark uses %s key layout %d, newer than this chaincode's %d", index, b.Version, current)
+	}
+	attrs := b.Attrs
+	for v := b.Version; v < current; v++ {
+		translate, ok := bookmarkTranslators[index][v]
+		if !ok {
+			return "", fmt.Errorf("no bookmark translation for %s key layout %d", index, v)
+		}
+		var err error
+		if attrs, err = translate(attrs); err != nil {
+			return "", err
+		}
+	}
+	return ctx.GetStub().CreateCompositeKey(index, attrs)
+}
+
+func layoutVersion(index string) int {
+	if v, ok := keyLayouts[index]; ok {
+		return v
+	}
+	return 1
+}
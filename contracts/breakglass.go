@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// BreakGlassReview is the post-hoc review of one break-glass verification.
+// Every BreakGlassVerify lands in the queue as Pending; an admin then rules
+// it Justified or Unjustified.
+type BreakGlassReview struct {
+	EventID           string `json:"eventId"` // the BreakGlassVerify event
+	CredID            string `json:"credId"`
+	HolderDID         string `json:"holderDid"`
+	VerifierID        string `json:"verifierId"`
+	JustificationCode string `json:"justificationCode"`
+	Bypassed          string `json:"bypassed,omitempty"` // the denial a normal VerifyCreds would have returned
+	RequestedBy       string `json:"requestedBy"`        // submitting MSP ID
+	RequestedAt       string `json:"requestedAt"`        // RFC3339
+	Status            string `json:"status"`             // Pending | Justified | Unjustified
+	ReviewedBy        string `json:"reviewedBy,omitempty"`
+	ReviewNotes       string `json:"reviewNotes,omitempty"`
+	ReviewedAt        string `json:"reviewedAt,omitempty"`
+}
+
+// BreakGlassVerify is VerifyCreds for emergencies: registry, DPA, wallet
+// attestation and jurisdiction denials are bypassed, so responders can check a credential whatever the
+// holder's or verifier's standing. The caller needs audittrail.role=responder
+// and a justification code from the channel config's breakGlassCodes.
+// The check is recorded as a Critical BreakGlassVerify event and queued for
+// mandatory review.
+func (s *CredentialContract) BreakGlassVerify(ctx contractapi.TransactionContextInterface,
+	credID, verifierID, justificationCode string) (*VerificationResult, error) {
+
+	if err := ctx.GetClientIdentity().AssertAttributeValue(roleAttr, "responder"); err != nil {
+		return nil, fmt.Errorf("responder role required: %v", err)
+	}
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.isBreakGlassCode(justificationCode) {
+		return nil, fmt.Errorf("unknown break-glass justification code %q", justificationCode)
+	}
+	cred, err := s.getCred(ctx, credID)
+	if err != nil {
+		return nil, err
+	}
+	bypassed, err := s.checkVerifier(ctx, cred, verifierID)
+	if err != nil {
+		return nil, err
+	}
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, err
+	}
+
+	res := &VerificationResult{
+		CredID:      credID,
+		IsActive:    cred.Status == "Active" && !cred.expired(),
+		HashMatches: true,
+		EndorsedBy:  cred.EndorsedBy,
+		StateTxID:   cred.StateTxID,
+		CheckedAt:   nowRFC3339(),
+	}
+	evt, err := s.writeEvent(ctx, credID, cred.HolderDID, "BreakGlassVerify", verifierID, "Success",
+		"break-glass:"+justificationCode)
+	if err != nil {
+		return nil, err
+	}
+
+	review := &BreakGlassReview{
+		EventID:           evt.EventID,
+		CredID:            credID,
+		HolderDID:         cred.HolderDID,
+		VerifierID:        verifierID,
+		JustificationCode: justificationCode,
+		Bypassed:          bypassed,
+		RequestedBy:       mspID,
+		RequestedAt:       res.CheckedAt,
+		Status:            "Pending",
+	}
+	if err := putBreakGlassReview(ctx, review); err != nil {
+		return nil, err
+	}
+	if err := emitEvent(ctx, "BreakGlassAccess", review); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetBreakGlassQueue lists break-glass reviews with the given status,
+// oldest first; "Pending" is the open review queue.
+func (s *AuditContract) GetBreakGlassQueue(ctx contractapi.TransactionContextInterface,
+	status string) ([]BreakGlassReview, error) {
+
+	iter, err := ctx.GetStub().GetStateByPartialCompositeKey("breakglass~status", []string{status})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	reviews := []BreakGlassReview{}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		var r BreakGlassReview
+		if err := json.Unmarshal(kv.Value, &r); err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, r)
+	}
+	return reviews, nil
+}
+
+// ReviewBreakGlass closes a pending review as Justified or Unjustified and
+// records the ruling in the holder's trail.
+func (s *AuditContract) ReviewBreakGlass(ctx contractapi.TransactionContextInterface,
+	eventID, decision, notes string) error {
+
+	if err := requireAdmin(ctx); err != nil {
+		return err
+	}
+	if decision != "Justified" && decision != "Unjustified" {
+		return fmt.Errorf("decision must be Justified or Unjustified")
+	}
+	pending, err := breakGlassKey(ctx, "Pending", eventID)
+	if err != nil {
+		return err
+	}
+	bz, err := ctx.GetStub().GetState(pending)
+	if err != nil {
+		return err
+	}
+	if bz == nil {
+		return fmt.Errorf("no pending break-glass review for event %s", eventID)
+	}
+	var review BreakGlassReview
+	if err := json.Unmarshal(bz, &review); err != nil {
+		return err
+	}
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return err
+	}
+
+	review.Status = decision
+	review.ReviewedBy = mspID
+	review.ReviewNotes = notes
+	review.ReviewedAt = nowRFC3339()
+	if err := ctx.GetStub().DelState(pending); err != nil {
+		return err
+	}
+	if err := putBreakGlassReview(ctx, &review); err != nil {
+		return err
+	}
+	return s.recordEvent(ctx, review.CredID, review.HolderDID, "BreakGlassReview", mspID, "Success",
+		fmt.Sprintf("event %s: %s", eventID, decision))
+}
+
+// ===== Helpers =====
+
+func breakGlassKey(ctx contractapi.TransactionContextInterface, status, eventID string) (string, error) {
+	return compositeKey(ctx, "breakglass~status", []string{status, eventID})
+}
+
+func putBreakGlassReview(ctx contractapi.TransactionContextInterface, r *BreakGlassReview) error {
+	ck, err := breakGlassKey(ctx, r.Status, r.EventID)
+	if err != nil {
+		return err
+	}
+	bz, _ := json.Marshal(r)
+	return ctx.GetStub().PutState(ck, bz)
+}
+
+func (c *ContractConfig) isBreakGlassCode(code string) bool {
+	for _, allowed := range c.BreakGlassCodes {
+		if allowed == code {
+			return true
+		}
+	}
+	return false
+}
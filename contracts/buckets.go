@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Hot holders. All of a holder's events share the event~holder prefix of
+// their DID (or epoch pseudonym), so an institutional holder with a million
+// credentials turns that prefix into one hot key range: every issuance and
+// verification for it lands there, and its audit trail is one very long
+// scan. ContractConfig.HolderIndexBuckets spreads such a holder's events
+// over several prefixes, "<key>" for bucket 0 and "<key>#b<n>" for the
+// others, by a hash of the credential ID, so one credential's events stay
+// in one bucket while the count is unchanged. A DID never contains "#", so bucket prefixes cannot
+// collide with another holder's.
+//
+// Reads fan in over the buckets in order (see holderPrefixes), the way they
+// already run across pseudonym epochs, and QueryAuditTrail bookmarks name
+// the bucket to resume in. Bucket 0 is the unbucketed prefix, so a holder
+// can be bucketed after the fact without moving its existing events, and
+// counts may grow but never shrink, or events in the dropped buckets would
+// no longer be read. Since earlier events stay where they were written,
+// reads of one credential's events scan every bucket as well.
+
+// maxHolderBuckets bounds how many prefixes one holder's reads fan in over.
+const maxHolderBuckets = 256
+
+// holderPrefixes returns every event~holder prefix holderDID's events may
+// be under: each key from holderKeys followed by its buckets.
+func holderPrefixes(ctx contractapi.TransactionContextInterface, holderDID string) ([]string, error) {
+	keys, err := holderKeys(ctx, holderDID)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	n := holderBuckets(cfg, holderDID)
+	prefixes := make([]string, 0, len(keys)*n)
+	for _, key := range keys {
+		prefixes = append(prefixes, key)
+		for b := 1; b < n; b++ {
+			prefixes = append(prefixes, fmt.Sprintf("%s#b%d", key, b))
+		}
+	}
+	return prefixes, nil
+}
+
+// holderBuckets is how many event~holder prefixes holderDID's events are
+// spread over.
+func holderBuckets(cfg *ContractConfig, holderDID string) int {
+	if n := cfg.HolderIndexBuckets[holderDID]; n > 1 {
+		return n
+	}
+	return 1
+}
+
+// holderBucketKey is the event~holder holder attribute for an event on
+// credID recorded under key, the holder's DID or pseudonym. Events without
+// a credential go to bucket 0.
+func holderBucketKey(key, credID string, buckets int) string {
+	if buckets <= 1 || credID == "" {
+		return key
+	}
+	h := fnv.New32a()
+	h.Write([]byte(credID))
+	if b := h.Sum32() % uint32(buckets); b > 0 {
+		return fmt.Sprintf("%s#b%d", key, b)
+	}
+	return key
+}
+
+// validateHolderBuckets checks a new HolderIndexBuckets against the current
+// one: counts between 1 and maxHolderBuckets, none lowered or removed.
+func validateHolderBuckets(current, next map[string]int) error {
+	for holderDID, n := range next {
+		if n < 1 || n > maxHolderBuckets {
+			return fmt.Errorf("holderIndexBuckets for %s must be between 1 and %d", holderDID, maxHolderBuckets)
+		}
+	}
+	for holderDID, n := range current {
+		if next[holderDID] < n {
+			return fmt.Errorf("holderIndexBuckets for %s cannot drop below %d", holderDID, n)
+		}
+	}
+	return nil
+}
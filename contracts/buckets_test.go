@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+)
+
+// memStub is a world state in a map, enough for the event~holder writes and
+// scans. Calls it does not implement panic on the nil embedded interface.
+type memStub struct {
+	shim.ChaincodeStubInterface
+	state map[string][]byte
+}
+
+func (s *memStub) GetTxID() string                            { return "tx1" }
+func (s *memStub) SetEvent(name string, payload []byte) error { return nil }
+func (s *memStub) GetState(key string) ([]byte, error)        { return s.state[key], nil }
+func (s *memStub) DelState(key string) error                  { delete(s.state, key); return nil }
+
+func (s *memStub) PutState(key string, value []byte) error {
+	s.state[key] = value
+	return nil
+}
+
+func (s *memStub) CreateCompositeKey(objectType string, attributes []string) (string, error) {
+	return "\x00" + objectType + "\x00" + strings.Join(attributes, "\x00") + "\x00", nil
+}
+
+func (s *memStub) GetStateByPartialCompositeKey(objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	prefix, _ := s.CreateCompositeKey(objectType, keys)
+	if len(keys) == 0 {
+		prefix = "\x00" + objectType + "\x00"
+	}
+	it := &memIter{}
+	for k, v := range s.state {
+		if strings.HasPrefix(k, prefix) {
+			it.kvs = append(it.kvs, &queryresult.KV{Key: k, Value: v})
+		}
+	}
+	sort.Slice(it.kvs, func(i, j int) bool { return it.kvs[i].Key < it.kvs[j].Key })
+	return it, nil
+}
+
+type memIter struct{ kvs []*queryresult.KV }
+
+func (it *memIter) HasNext() bool { return len(it.kvs) > 0 }
+func (it *memIter) Close() error  { return nil }
+
+func (it *memIter) Next() (*queryresult.KV, error) {
+	kv := it.kvs[0]
+	it.kvs = it.kvs[1:]
+	return kv, nil
+}
+
+// A credential whose events straddle a HolderIndexBuckets increase keeps
+// all of them: the first stays in bucket 0, the second lands in the
+// credential's new bucket.
+func TestHolderEventsAcrossBucketIncrease(t *testing.T) {
+	ctx := new(contractapi.TransactionContext)
+	stub := &memStub{state: map[string][]byte{}}
+	ctx.SetStub(stub)
+
+	holderDID := "did:example:institution"
+	credID := ""
+	for i := 0; credID == ""; i++ {
+		if id := fmt.Sprintf("cred-%d", i); holderBucketKey(holderDID, id, 4) != holderDID {
+			credID = id
+		}
+	}
+	s := &ledger{}
+
+	if err := s.recordEvent(ctx, credID, holderDID, "Issue", "issuer-1", "Success", ""); err != nil {
+		t.Fatal(err)
+	}
+	bz, _ := json.Marshal(ContractConfig{HolderIndexBuckets: map[string]int{holderDID: 4}})
+	stub.state[configKey] = bz
+	if err := s.recordEvent(ctx, credID, holderDID, "Renew", "issuer-1", "Success", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := holderEvents(ctx, holderDID, credID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events for %s, want 2", len(events), credID)
+	}
+}
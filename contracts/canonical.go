@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// canonicalJSON encodes v so that equal records always produce equal bytes,
+// whatever Go version or language wrote them: object keys sorted bytewise,
+// no insignificant whitespace, no HTML escaping, and numbers kept exactly as
+// encoded. For the integer and string fields our records hold this matches
+// RFC 8785 (JCS), so external verifiers can rehash state with an off-the-shelf
+// JCS library.
+func canonicalJSON(v interface{}) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+
+	// encoding/json writes map keys in sorted order.
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(generic); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
@@ -2,9 +2,12 @@ package main
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/binary"
-	"encoding/json"
+	"encoding/hex"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
@@ -12,74 +15,164 @@ import (
 
 // Minimal on-chain metadata; keep PII off-ledger.
 type Credential struct {
-	CredID     string `json:"credId"`
-	HolderDID  string `json:"holderDid"`
-	CredType   string `json:"credType"`
-	HashedData string `json:"hashedData"`
-	IssuerID   string `json:"issuerId"`
-	Status     string `json:"status"`     // Active | Revoked
-	CreatedAt  string `json:"createdAt"`  // RFC3339
-	UpdatedAt  string `json:"updatedAt"`  // RFC3339
+	CredID          string `json:"credId"`
+	HolderDID       string `json:"holderDid"`
+	CredType        string `json:"credType"`
+	HashedData      string `json:"hashedData"`
+	MerkleRoot      string `json:"merkleRoot,omitempty"`      // hex root over per-attribute hashes
+	HolderProofHash string `json:"holderProofHash,omitempty"` // sha256 of the acceptance proof
+	IssuerID        string `json:"issuerId"`
+	HolderType      string `json:"holderType,omitempty"`    // Individual | Organization; empty is Individual (see holdertype.go)
+	LegalEntityID   string `json:"legalEntityId,omitempty"` // ISO 17442 LEI of an Organization holder
+	Jurisdiction    string `json:"jurisdiction,omitempty"`  // e.g. EU; empty means unrestricted
+	Status          string `json:"status"`                  // PendingAcceptance | Active | Revoked | Expired
+	ExpiresAt       string `json:"expiresAt,omitempty"`     // RFC3339; empty means no expiry
+	CreatedAt       string `json:"createdAt"`               // RFC3339
+	UpdatedAt       string `json:"updatedAt"`               // RFC3339
+	// EndorsedBy and StateTxID identify the org and transaction that wrote
+	// the current state; block height is resolved from the tx ID off-chain.
+	EndorsedBy string `json:"endorsedBy,omitempty"` // submitting MSP ID
+	StateTxID  string `json:"stateTxId,omitempty"`
 }
 
 // AccessEvent captures audit trail entries.
 type AccessEvent struct {
 	EventID    string `json:"eventId"`
 	CredID     string `json:"credId"`
-	HolderDID  string `json:"holderDid"`
-	Action     string `json:"action"`     // Issue | Verify | Revoke
+	HolderDID  string `json:"holderDid"`  // or its epoch pseudonym; see pseudonym.go
+	Action     string `json:"action"`     // Issue | Accept | Renew | Verify | VerifySummary | VerifyAttribute | BreakGlassVerify | BreakGlassReview | Justify | Dispute | Revoke | Link | Expire | Transfer | CustodyTransfer | Notify | NoticeAck | ConsentGrant | ConsentRevoke | HolderBlock | HolderUnblock
 	ActorID    string `json:"actorId"`    // issuer | verifier | revoker
-	Outcome    string `json:"outcome"`    // Success | Failure
+	Outcome    string `json:"outcome"`    // Success | Failure | Denied
 	Reason     string `json:"reason"`     // optional
 	OccurredAt string `json:"occurredAt"` // RFC3339
+	// ReasonCategory replaces Reason in query results the caller may only
+	// read redacted; it is never stored. See redaction.go.
+	ReasonCategory string `json:"reasonCategory,omitempty"`
+	// Standardized classification, filled in by classifyEvent; see taxonomy.go.
+	EventCategory   string `json:"eventCategory,omitempty"`
+	Severity        string `json:"severity,omitempty"`
+	SourceComponent string `json:"sourceComponent,omitempty"`
+	// Outcome of the presenting wallet's device attestation, on verification
+	// events only; see wallet.go.
+	WalletAttestation string `json:"walletAttestation,omitempty"` // Valid | Invalid | Unverified
+	WalletPlatform    string `json:"walletPlatform,omitempty"`
 }
 
 type VerificationResult struct {
-	CredID      string `json:"credId"`
-	IsActive    bool   `json:"isActive"`
-	HashMatches bool   `json:"hashMatches"`
-	CheckedAt   string `json:"checkedAt"`
+	CredID       string `json:"credId"`
+	AttrPath     string `json:"attrPath,omitempty"` // set by VerifyAttribute
+	IsActive     bool   `json:"isActive"`
+	HashMatches  bool   `json:"hashMatches"`
+	Denied       bool   `json:"denied"`
+	DenialReason string `json:"denialReason,omitempty"`
+	EndorsedBy   string `json:"endorsedBy,omitempty"` // org that wrote the verified state
+	StateTxID    string `json:"stateTxId,omitempty"`  // tx that wrote the verified state
+	CheckedAt    string `json:"checkedAt"`
+	// RecommendedRecheckAfter (RFC3339) is how long a positive result may be
+	// cached; see freshness.go. ValidAsOfBlock is the block that committed
+	// the check, filled in by the gateway since chaincode cannot see it.
+	RecommendedRecheckAfter string `json:"recommendedRecheckAfter,omitempty"`
+	ValidAsOfBlock          uint64 `json:"validAsOfBlock,omitempty"`
+}
+
+// The chaincode is split into contracts with their own namespaces so each
+// can carry its own access policy and evolve independently. Clients call
+// "<namespace>:<Transaction>"; credential transactions are also reachable
+// unprefixed as the default contract.
+var contractNames = map[string]string{
+	"CredentialContract": "audittrail.credential",
+	"AuditContract":      "audittrail.audit",
+	"RegistryContract":   "audittrail.registry",
+	"AdminContract":      "audittrail.admin",
 }
 
-type SmartContract struct {
+const defaultContract = "CredentialContract"
+
+// ledger holds the state helpers every contract shares. Contracts embed it;
+// its methods are unexported, so the contract API never exposes them.
+type ledger struct{}
+
+// CredentialContract issues, verifies, revokes and transfers credentials.
+type CredentialContract struct {
 	contractapi.Contract
+	ledger
+}
+
+// AuditContract reads, anchors and attests the audit trail.
+type AuditContract struct {
+	contractapi.Contract
+	ledger
+}
+
+// RegistryContract manages issuers, credential types and jurisdiction policy.
+type RegistryContract struct {
+	contractapi.Contract
+	ledger
+}
+
+// AdminContract holds configuration, feature flags, index maintenance and
+// governance.
+type AdminContract struct {
+	contractapi.Contract
+	ledger
 }
 
 // IssueCreds creates a credential and records an Issue event.
-func (s *SmartContract) IssueCreds(ctx contractapi.TransactionContextInterface,
-	credID, holderDID, credType, hashedData, issuerID string) error {
+// jurisdiction may be empty for credentials without data-sovereignty limits;
+// expiresAt is RFC3339 or empty for credentials that do not expire.
+// merkleRoot optionally commits to individual attributes (see VerifyAttribute)
+// and may replace hashedData entirely.
+func (s *CredentialContract) IssueCreds(ctx contractapi.TransactionContextInterface,
+	credID, holderDID, credType, hashedData, issuerID, jurisdiction, expiresAt, merkleRoot string) error {
+
+	if err := s.checkNotReserved(ctx, credID); err != nil {
+		return err
+	}
+	cred, warning, err := s.newCred(ctx, credID, holderDID, credType, hashedData, issuerID, jurisdiction, expiresAt, merkleRoot)
+	if err != nil {
+		return err
+	}
+	return s.storeIssued(ctx, cred, warning)
+}
 
-	exists, err := s.credExists(ctx, credID)
+// AcceptCredential activates a PendingAcceptance credential once the holder
+// shows control of the bound DID. For did:key holders, holderProof must be a
+// base64 Ed25519 signature over "accept:<credID>"; other methods are resolved
+// and checked by the gateway, and only it may submit their acceptance (see
+// checkHolderProof).
+func (s *CredentialContract) AcceptCredential(ctx contractapi.TransactionContextInterface,
+	credID, holderProof string) error {
+
+	cred, err := s.getCred(ctx, credID)
 	if err != nil {
 		return err
 	}
-	if exists {
-		return fmt.Errorf("credential %s already exists", credID)
+	if cred.Status != "PendingAcceptance" {
+		return fmt.Errorf("credential %s is %s, not pending acceptance", credID, cred.Status)
 	}
-
-	now := nowRFC3339()
-	cred := &Credential{
-		CredID:     credID,
-		HolderDID:  holderDID,
-		CredType:   credType,
-		HashedData: hashedData,
-		IssuerID:   issuerID,
-		Status:     "Active",
-		CreatedAt:  now,
-		UpdatedAt:  now,
+	if err := checkHolderProof(ctx, cred.HolderDID, holderProof, "accept:"+credID); err != nil {
+		return err
 	}
 
-	bz, _ := json.Marshal(cred)
-	if err := ctx.GetStub().PutState(credKey(credID), bz); err != nil {
+	cred.HolderProofHash = proofHash(holderProof)
+	cred.Status = "Active"
+	cred.UpdatedAt = nowRFC3339()
+
+	if err := s.putCred(ctx, cred); err != nil {
+		return err
+	}
+	if err := countTransition(ctx, "PendingAcceptance", "Active"); err != nil {
 		return err
 	}
 
-	return s.recordEvent(ctx, credID, holderDID, "Issue", issuerID, "Success", "")
+	return s.recordEvent(ctx, credID, cred.HolderDID, "Accept", cred.HolderDID, "Success", "")
 }
 
 // VerifyCreds records a verify event and returns a verification result.
 // HashMatches is a placeholder until off-chain hash checks are wired.
-func (s *SmartContract) VerifyCreds(ctx contractapi.TransactionContextInterface,
+// Cross-jurisdiction checks, verifiers with a lapsed DPA and blocked holders
+// are denied but still committed so the attempt is audited.
+func (s *CredentialContract) VerifyCreds(ctx contractapi.TransactionContextInterface,
 	credID, verifierID string) (*VerificationResult, error) {
 
 	cred, err := s.getCred(ctx, credID)
@@ -87,21 +180,115 @@ func (s *SmartContract) VerifyCreds(ctx contractapi.TransactionContextInterface,
 		return nil, err
 	}
 
+	denial, err := s.checkVerifier(ctx, cred, verifierID)
+	if err != nil {
+		return nil, err
+	}
+	if denial != "" {
+		if err := s.recordEvent(ctx, credID, cred.HolderDID, "Verify", verifierID, "Denied", denial); err != nil {
+			return nil, err
+		}
+		return &VerificationResult{
+			CredID:       credID,
+			Denied:       true,
+			DenialReason: denial,
+			CheckedAt:    nowRFC3339(),
+		}, nil
+	}
+
 	res := &VerificationResult{
 		CredID:      credID,
-		IsActive:    cred.Status == "Active",
+		IsActive:    cred.Status == "Active" && !cred.expired(),
 		HashMatches: true,
+		EndorsedBy:  cred.EndorsedBy,
+		StateTxID:   cred.StateTxID,
 		CheckedAt:   nowRFC3339(),
 	}
+	if err := s.setFreshness(ctx, res, cred); err != nil {
+		return nil, err
+	}
 
+	// Under sampling, routine checks of active credentials are aggregated;
+	// anything a reviewer would want to see individually is still recorded.
+	if res.IsActive {
+		sampled, err := s.sampleVerify(ctx, cred, verifierID)
+		if err != nil || sampled {
+			return res, err
+		}
+	}
 	if err := s.recordEvent(ctx, credID, cred.HolderDID, "Verify", verifierID, "Success", ""); err != nil {
 		return nil, err
 	}
 	return res, nil
 }
 
-// RevokeCreds marks the credential revoked and records the event.
-func (s *SmartContract) RevokeCreds(ctx contractapi.TransactionContextInterface,
+// VerifyAttribute checks a single attribute against the credential's Merkle
+// root without the verifier holding the rest of the document, and records
+// the check like VerifyCreds.
+func (s *CredentialContract) VerifyAttribute(ctx contractapi.TransactionContextInterface,
+	credID, attrPath, attrHash string, proof []MerkleProofStep, verifierID string) (*VerificationResult, error) {
+
+	cred, err := s.getCred(ctx, credID)
+	if err != nil {
+		return nil, err
+	}
+	if cred.MerkleRoot == "" {
+		return nil, fmt.Errorf("credential %s has no attribute commitment", credID)
+	}
+
+	denial, err := s.checkVerifier(ctx, cred, verifierID)
+	if err != nil {
+		return nil, err
+	}
+	if denial != "" {
+		if err := s.recordEvent(ctx, credID, cred.HolderDID, "VerifyAttribute", verifierID, "Denied", denial); err != nil {
+			return nil, err
+		}
+		return &VerificationResult{
+			CredID:       credID,
+			AttrPath:     attrPath,
+			Denied:       true,
+			DenialReason: denial,
+			CheckedAt:    nowRFC3339(),
+		}, nil
+	}
+
+	leaf, err := attributeLeaf(attrPath, attrHash)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := verifyMerkleProof(leaf, proof, cred.MerkleRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &VerificationResult{
+		CredID:      credID,
+		AttrPath:    attrPath,
+		IsActive:    cred.Status == "Active" && !cred.expired(),
+		HashMatches: matches,
+		EndorsedBy:  cred.EndorsedBy,
+		StateTxID:   cred.StateTxID,
+		CheckedAt:   nowRFC3339(),
+	}
+	if err := s.setFreshness(ctx, res, cred); err != nil {
+		return nil, err
+	}
+	outcome := "Success"
+	if !matches {
+		outcome = "Failure"
+	}
+	if err := s.recordEvent(ctx, credID, cred.HolderDID, "VerifyAttribute", verifierID, outcome, attrPath); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// RevokeCreds marks the credential revoked and records the event. Callers
+// must belong to the issuer's MSP or hold a covering revocation delegation.
+// With the revocation-broadcast feature on, it also emits RevocationBroadcast
+// for sister channels to import.
+func (s *CredentialContract) RevokeCreds(ctx contractapi.TransactionContextInterface,
 	credID, reason, revokerID string) error {
 
 	cred, err := s.getCred(ctx, credID)
@@ -111,72 +298,382 @@ func (s *SmartContract) RevokeCreds(ctx contractapi.TransactionContextInterface,
 	if cred.Status == "Revoked" {
 		return fmt.Errorf("credential %s is already revoked", credID)
 	}
+	delegate, err := s.revocationAuthority(ctx, cred)
+	if err != nil {
+		return err
+	}
+	if delegate != "" {
+		reason += " [delegated to " + delegate + "]"
+	}
 
+	prev := cred.Status
 	cred.Status = "Revoked"
 	cred.UpdatedAt = nowRFC3339()
 
-	bz, _ := json.Marshal(cred)
-	if err := ctx.GetStub().PutState(credKey(credID), bz); err != nil {
+	if err := s.putCred(ctx, cred); err != nil {
+		return err
+	}
+	if err := countTransition(ctx, prev, "Revoked"); err != nil {
+		return err
+	}
+	if err := putRevokedIndex(ctx, cred); err != nil {
 		return err
 	}
 
-	return s.recordEvent(ctx, credID, cred.HolderDID, "Revoke", revokerID, "Success", reason)
+	evt, err := s.writeEvent(ctx, credID, cred.HolderDID, "Revoke", revokerID, "Success", reason)
+	if err != nil {
+		return err
+	}
+	// Broadcasting replaces the AuditTrail event, so consumers opt in per org.
+	broadcast, err := featureEnabled(ctx, "revocation-broadcast")
+	if err != nil || !broadcast {
+		return err
+	}
+	return s.broadcastRevocation(ctx, cred, *evt)
 }
 
-// QueryAuditTrail returns paginated events for a holder DID.
-func (s *SmartContract) QueryAuditTrail(ctx contractapi.TransactionContextInterface,
-	holderDID string, pageSize int32, bookmark string) ([]AccessEvent, string, error) {
+// ExpireCreds moves an Active credential past its ExpiresAt to Expired so
+// counters and status queries reflect it. Anyone may trigger it.
+func (s *CredentialContract) ExpireCreds(ctx contractapi.TransactionContextInterface,
+	credID string) error {
 
-	iter, nextBookmark, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(
-		"event~holder", []string{holderDID}, pageSize, bookmark)
+	cred, err := s.getCred(ctx, credID)
 	if err != nil {
-		return nil, "", err
+		return err
+	}
+	if cred.Status != "Active" {
+		return fmt.Errorf("credential %s is %s, not active", credID, cred.Status)
+	}
+	if !cred.expired() {
+		return fmt.Errorf("credential %s has not expired", credID)
+	}
+
+	cred.Status = "Expired"
+	cred.UpdatedAt = nowRFC3339()
+
+	if err := s.putCred(ctx, cred); err != nil {
+		return err
+	}
+	if err := countTransition(ctx, "Active", "Expired"); err != nil {
+		return err
+	}
+
+	return s.recordEvent(ctx, credID, cred.HolderDID, "Expire", "system", "Success", "expired at "+cred.ExpiresAt)
+}
+
+// QueryAuditTrail returns one page of a holder's events. The bookmark stays
+// valid across upgrades that change the event key layout (see bookmark.go).
+// With holder pseudonyms or index buckets on, a page runs on across the
+// holder's prefixes (see holderPrefixes), and the bookmark names the prefix
+// to resume in.
+func (s *AuditContract) QueryAuditTrail(ctx contractapi.TransactionContextInterface,
+	holderDID string, pageSize int32, bookmark string) (*EventPage, error) {
+
+	keys, err := holderPrefixes(ctx, holderDID)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := decodeBookmark(ctx, "event~holder", bookmark)
+	if err != nil {
+		return nil, err
+	}
+	start := 0
+	if raw != "" {
+		_, attrs, err := ctx.GetStub().SplitCompositeKey(raw)
+		if err != nil {
+			return nil, err
+		}
+		for start < len(keys) && keys[start] != attrs[0] {
+			start++
+		}
+		if start == len(keys) {
+			return nil, fmt.Errorf("bookmark is not for holder %s", holderDID)
+		}
 	}
-	defer iter.Close()
 
-	var events []AccessEvent
-	for iter.HasNext() {
-		kv, err := iter.Next()
+	page := &EventPage{Events: []AccessEvent{}}
+	for i := start; i < len(keys) && int32(len(page.Events)) < pageSize; i++ {
+		iter, meta, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(
+			"event~holder", []string{keys[i]}, pageSize-int32(len(page.Events)), raw)
 		if err != nil {
-			return nil, "", err
+			return nil, err
+		}
+		for iter.HasNext() {
+			kv, err := iter.Next()
+			if err != nil {
+				iter.Close()
+				return nil, err
+			}
+			evt, err := decodeEvent(ctx, kv.Value)
+			if err != nil {
+				iter.Close()
+				return nil, err
+			}
+			page.Events = append(page.Events, *evt)
 		}
-		var evt AccessEvent
-		if err := json.Unmarshal(kv.Value, &evt); err != nil {
-			return nil, "", err
+		iter.Close()
+
+		raw = "" // later prefixes start from their first key
+		next := meta.Bookmark
+		if next == "" && i+1 < len(keys) {
+			if next, err = ctx.GetStub().CreateCompositeKey("event~holder", []string{keys[i+1]}); err != nil {
+				return nil, err
+			}
 		}
-		events = append(events, evt)
+		if page.Bookmark, err = encodeBookmark(ctx, "event~holder", next); err != nil {
+			return nil, err
+		}
+	}
+	if err := redactEvents(ctx, page.Events); err != nil {
+		return nil, err
 	}
-	return events, nextBookmark, nil
+	return page, nil
+}
+
+// maxSincePage caps the events one GetEventsSince call returns.
+const maxSincePage = 500
+
+// GetEventsSince returns up to pageSize of a holder's events recorded after
+// cursor, oldest first, so wallets can sync incrementally. It pages over the
+// event~since index, in event ID order within each of the holder's keys
+// (see holderKeys). The returned bookmark is the cursor for the next call;
+// it stays valid once the trail is exhausted, so a wallet keeps it and
+// polls with it. An empty cursor starts from the first event. pageSize is
+// capped at 500. Events recorded before the index existed appear once
+// ReindexEvents has backfilled them.
+func (s *AuditContract) GetEventsSince(ctx contractapi.TransactionContextInterface,
+	holderDID, cursor string, pageSize int32) (*EventPage, error) {
+
+	if pageSize <= 0 || pageSize > maxSincePage {
+		pageSize = maxSincePage
+	}
+	keys, err := holderKeys(ctx, holderDID)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := decodeBookmark(ctx, "event~since", cursor)
+	if err != nil {
+		return nil, err
+	}
+	start := 0
+	if raw != "" {
+		_, attrs, err := ctx.GetStub().SplitCompositeKey(raw)
+		if err != nil {
+			return nil, err
+		}
+		for start < len(keys) && keys[start] != attrs[0] {
+			start++
+		}
+		if start == len(keys) {
+			return nil, fmt.Errorf("cursor is not for holder %s", holderDID)
+		}
+	}
+
+	page := &EventPage{Events: []AccessEvent{}, Bookmark: cursor}
+	last := raw
+	for i := start; i < len(keys) && int32(len(page.Events)) < pageSize; i++ {
+		// Fabric bookmarks are inclusive start keys, so the cursor's own
+		// entry comes back first and is skipped.
+		from := ""
+		if i == start {
+			from = raw
+		}
+		iter, _, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(
+			"event~since", []string{keys[i]}, pageSize-int32(len(page.Events))+1, from)
+		if err != nil {
+			return nil, err
+		}
+		for iter.HasNext() && int32(len(page.Events)) < pageSize {
+			kv, err := iter.Next()
+			if err != nil {
+				iter.Close()
+				return nil, err
+			}
+			if kv.Key == raw {
+				continue
+			}
+			last = kv.Key
+			evt, err := eventByIndex(ctx, kv.Key, kv.Value)
+			if err != nil {
+				iter.Close()
+				return nil, err
+			}
+			if evt == nil {
+				continue
+			}
+			page.Events = append(page.Events, *evt)
+		}
+		iter.Close()
+	}
+	if last != raw {
+		if page.Bookmark, err = encodeBookmark(ctx, "event~since", last); err != nil {
+			return nil, err
+		}
+	}
+	if err := redactEvents(ctx, page.Events); err != nil {
+		return nil, err
+	}
+	return page, nil
 }
 
 // ===== Helpers =====
 
-func (s *SmartContract) credExists(ctx contractapi.TransactionContextInterface, credID string) (bool, error) {
-	val, err := ctx.GetStub().GetState(credKey(credID))
+func (c *Credential) expired() bool {
+	if c.ExpiresAt == "" {
+		return false
+	}
+	exp, err := time.Parse(time.RFC3339, c.ExpiresAt)
+	return err == nil && time.Now().UTC().After(exp)
+}
+
+func (s *ledger) credExists(ctx contractapi.TransactionContextInterface, credID string) (bool, error) {
+	val, err := batchGet(ctx, credKey(credID))
 	if err != nil {
 		return false, err
 	}
 	return val != nil, nil
 }
 
-func (s *SmartContract) getCred(ctx contractapi.TransactionContextInterface, credID string) (*Credential, error) {
-	bz, err := ctx.GetStub().GetState(credKey(credID))
+func (s *ledger) getCred(ctx contractapi.TransactionContextInterface, credID string) (*Credential, error) {
+	bz, err := batchGet(ctx, credKey(credID))
 	if err != nil {
 		return nil, err
 	}
 	if bz == nil {
 		return nil, fmt.Errorf("credential %s not found", credID)
 	}
-	var cred Credential
-	if err := json.Unmarshal(bz, &cred); err != nil {
+	cred, err := decodeCred(ctx, bz)
+	if err != nil {
+		return nil, err
+	}
+	if err := shadowRead(ctx, credKey(credID), bz, cred, decodeCredRecord); err != nil {
 		return nil, err
 	}
-	return &cred, nil
+	return cred, nil
+}
+
+// newCred validates issuance inputs against the credential type lifecycle and
+// builds the credential without storing it. warning is non-empty for
+// deprecated types.
+func (s *ledger) newCred(ctx contractapi.TransactionContextInterface,
+	credID, holderDID, credType, hashedData, issuerID, jurisdiction, expiresAt, merkleRoot string) (*Credential, string, error) {
+
+	exists, err := s.credExists(ctx, credID)
+	if err != nil {
+		return nil, "", err
+	}
+	if exists {
+		return nil, "", fmt.Errorf("credential %s already exists", credID)
+	}
+	if hashedData == "" && merkleRoot == "" {
+		return nil, "", fmt.Errorf("hashedData or merkleRoot is required")
+	}
+	if merkleRoot != "" {
+		if raw, err := hex.DecodeString(merkleRoot); err != nil || len(raw) != sha256.Size {
+			return nil, "", fmt.Errorf("merkleRoot must be a hex sha256 digest")
+		}
+	}
+	if expiresAt != "" {
+		if _, err := time.Parse(time.RFC3339, expiresAt); err != nil {
+			return nil, "", fmt.Errorf("invalid expiresAt %q: %v", expiresAt, err)
+		}
+	}
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := validateHolderDID(cfg, holderDID); err != nil {
+		return nil, "", err
+	}
+
+	// Unregistered types stay issuable; registered ones follow their lifecycle.
+	ct, err := s.getCredType(ctx, credType)
+	if err != nil {
+		return nil, "", err
+	}
+	status, warning := "Active", ""
+	if ct != nil {
+		if ct.RequiresAcceptance {
+			status = "PendingAcceptance"
+		}
+		switch ct.Status {
+		case "Sunset":
+			return nil, "", fmt.Errorf("credential type %s is sunset", credType)
+		case "Deprecated":
+			warning = fmt.Sprintf("warning: credential type %s is deprecated", credType)
+		}
+	}
+	if expiresAt, err = applyValidity(ct, time.Now().UTC(), expiresAt); err != nil {
+		return nil, "", err
+	}
+
+	now := nowRFC3339()
+	cred := &Credential{
+		CredID:       credID,
+		HolderDID:    holderDID,
+		CredType:     credType,
+		HashedData:   hashedData,
+		MerkleRoot:   merkleRoot,
+		IssuerID:     issuerID,
+		Jurisdiction: jurisdiction,
+		Status:       status,
+		ExpiresAt:    expiresAt,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	return cred, warning, nil
+}
+
+// storeIssued writes a credential built by newCred with its indexes,
+// counters and Issue event, or only a Denied Issue event when the holder is
+// blocked (see blocklist.go).
+func (s *ledger) storeIssued(ctx contractapi.TransactionContextInterface,
+	cred *Credential, warning string) error {
+
+	if denied, err := s.denyBlockedIssue(ctx, cred); err != nil || denied {
+		return err
+	}
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := checkHolderType(cfg, cred); err != nil {
+		return err
+	}
+	if err := s.putCred(ctx, cred); err != nil {
+		return err
+	}
+	if err := countIssued(ctx, cred); err != nil {
+		return err
+	}
+	if err := putIndexKey(ctx, "cred~type", cred.CredType, cred.CredID); err != nil {
+		return err
+	}
+	if err := putIndexKey(ctx, "cred~issuer", cred.IssuerID, cred.CredID); err != nil {
+		return err
+	}
+	if err := putIndexKey(ctx, "cred~holdertype", cred.holderType(), cred.CredID); err != nil {
+		return err
+	}
+	if err := putExpiryIndex(ctx, cred); err != nil {
+		return err
+	}
+
+	return s.recordEvent(ctx, cred.CredID, cred.HolderDID, "Issue", cred.IssuerID, "Success", warning)
 }
 
-func (s *SmartContract) recordEvent(ctx contractapi.TransactionContextInterface,
+func (s *ledger) recordEvent(ctx contractapi.TransactionContextInterface,
 	credID, holderDID, action, actorID, outcome, reason string) error {
 
+	_, err := s.writeEvent(ctx, credID, holderDID, action, actorID, outcome, reason)
+	return err
+}
+
+// writeEvent is recordEvent for callers that need the stored event back.
+func (s *ledger) writeEvent(ctx contractapi.TransactionContextInterface,
+	credID, holderDID, action, actorID, outcome, reason string) (*AccessEvent, error) {
+
 	evt := AccessEvent{
 		EventID:    newEventID(),
 		CredID:     credID,
@@ -187,23 +684,105 @@ func (s *SmartContract) recordEvent(ctx contractapi.TransactionContextInterface,
 		Reason:     reason,
 		OccurredAt: nowRFC3339(),
 	}
-	bz, _ := json.Marshal(evt)
+	if err := stampWalletAttestation(ctx, &evt); err != nil {
+		return nil, err
+	}
+	classifyEvent(&evt) // so the returned event matches what was stored
+	if err := storeEvent(ctx, evt); err != nil {
+		return nil, err
+	}
+	if err := scoreEvent(ctx, &evt); err != nil {
+		return nil, err
+	}
+	return &evt, nil
+}
+
+// storeEvent writes an event under its holder, with its lookup entries, and
+// emits it. Writing an existing event ID replaces that event. The writes
+// are batched; see writes.go.
+func storeEvent(ctx contractapi.TransactionContextInterface, evt AccessEvent) error {
+	classifyEvent(&evt)
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		return err
+	}
+	buckets := holderBuckets(cfg, evt.HolderDID)
+	if err := pseudonymizeEvent(ctx, cfg, &evt); err != nil {
+		return err
+	}
+	bz, err := encodeEvent(cfg, &evt)
+	if err != nil {
+		return err
+	}
+	if err := checkSize("event for "+evt.CredID, bz, cfg.MaxEventBytes); err != nil {
+		return err
+	}
+
+	ck, err := compositeKey(ctx, "event~holder", []string{holderBucketKey(evt.HolderDID, evt.CredID, buckets), evt.CredID, evt.EventID})
+	if err != nil {
+		return err
+	}
+	if err := batchPut(ctx, ck, bz); err != nil {
+		return err
+	}
+	keys, err := eventIndexKeys(ctx, &evt)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := batchPut(ctx, key, indexValue(key, ck)); err != nil {
+			return err
+		}
+	}
+	return emitEvent(ctx, "AuditTrail", evt)
+}
+
+// putIndexKey writes a value-less composite key used purely for lookups.
+func putIndexKey(ctx contractapi.TransactionContextInterface, objectType string, attrs ...string) error {
+	ck, err := compositeKey(ctx, objectType, attrs)
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().PutState(ck, []byte{0})
+}
+
+// putCred stamps the writing org and transaction onto the credential and stores it.
+func (s *ledger) putCred(ctx contractapi.TransactionContextInterface, cred *Credential) error {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return err
+	}
+	cred.EndorsedBy = mspID
+	cred.StateTxID = ctx.GetStub().GetTxID()
 
-	ck, err := ctx.GetStub().CreateCompositeKey("event~holder", []string{holderDID, credID, evt.EventID})
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		return err
+	}
+	bz, err := encodeCred(cfg, cred)
 	if err != nil {
 		return err
 	}
-	if err := ctx.GetStub().PutState(ck, bz); err != nil {
+	if err := checkSize("credential "+cred.CredID, bz, cfg.MaxCredentialBytes); err != nil {
 		return err
 	}
-	ctx.GetStub().SetEvent("AuditTrail", bz)
-	return nil
+	return batchPut(ctx, credKey(cred.CredID), bz)
 }
 
 func credKey(credID string) string { return "cred:" + credID }
 
 func nowRFC3339() string { return time.Now().UTC().Format(time.RFC3339) }
 
+// eventNanos extracts the recording time embedded in an event ID.
+func eventNanos(eventID string) (int64, error) {
+	prefix, _, _ := strings.Cut(eventID, "-")
+	n, err := strconv.ParseInt(prefix, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("malformed event ID %q", eventID)
+	}
+	return n, nil
+}
+
 func newEventID() string {
 	now := time.Now().UTC().UnixNano()
 	var n uint64
@@ -212,10 +791,24 @@ func newEventID() string {
 }
 
 func main() {
-	cc, err := contractapi.NewChaincode(new(SmartContract))
+	named := func(typ string) contractapi.Contract {
+		return contractapi.Contract{
+			Name:                      contractNames[typ],
+			TransactionContextHandler: new(TxContext),
+			BeforeTransaction:         checkAccessPolicy,
+			AfterTransaction:          flushWrites,
+		}
+	}
+	cc, err := contractapi.NewChaincode(
+		&CredentialContract{Contract: named("CredentialContract")},
+		&AuditContract{Contract: named("AuditContract")},
+		&RegistryContract{Contract: named("RegistryContract")},
+		&AdminContract{Contract: named("AdminContract")},
+	)
 	if err != nil {
 		panic(err)
 	}
+	cc.DefaultContract = contractNames[defaultContract]
 	if err := cc.Start(); err != nil {
 		panic(err)
 	}
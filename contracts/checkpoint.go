@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Checkpoint anchors a Merkle root over a holder's audit trail. It covers,
+// in ledger key order per holderPrefixes prefix, every event of the holder
+// recorded up to CutoffNanos.
+type Checkpoint struct {
+	CheckpointID string `json:"checkpointId"`
+	HolderDID    string `json:"holderDid"`
+	Root         string `json:"root"` // hex Merkle root of eventLeaf(eventJSON(stored event))
+	EventCount   int    `json:"eventCount"`
+	CutoffNanos  int64  `json:"cutoffNanos"`
+	TxID         string `json:"txId"`
+	CreatedAt    string `json:"createdAt"` // RFC3339
+}
+
+// IntegrityProof lets a holder show a verifier that one event is part of the
+// anchored history without handing over the rest of the trail.
+type IntegrityProof struct {
+	Event      AccessEvent       `json:"event"`
+	EventJSON  string            `json:"eventJson"` // exact JSON the leaf is computed over (see eventJSON)
+	Leaf       string            `json:"leaf"`      // hex eventLeaf(EventJSON)
+	Proof      []MerkleProofStep `json:"proof"`
+	Checkpoint Checkpoint        `json:"checkpoint"`
+}
+
+// CreateCheckpoint anchors the holder's current audit trail.
+func (s *AuditContract) CreateCheckpoint(ctx contractapi.TransactionContextInterface,
+	holderDID string) (*Checkpoint, error) {
+
+	id := newEventID()
+	cutoff, _ := eventNanos(id)
+	leaves, _, err := s.checkpointLeaves(ctx, holderDID, cutoff, "")
+	if err != nil {
+		return nil, err
+	}
+
+	cp := &Checkpoint{
+		CheckpointID: id,
+		HolderDID:    holderDID,
+		Root:         hex.EncodeToString(merkleRoot(leaves)),
+		EventCount:   len(leaves),
+		CutoffNanos:  cutoff,
+		TxID:         ctx.GetStub().GetTxID(),
+		CreatedAt:    nowRFC3339(),
+	}
+	ck, err := compositeKey(ctx, "checkpoint~holder", []string{holderDID, id})
+	if err != nil {
+		return nil, err
+	}
+	bz, _ := json.Marshal(cp)
+	if err := ctx.GetStub().PutState(ck, bz); err != nil {
+		return nil, err
+	}
+	if err := emitEvent(ctx, "CheckpointCreated", cp); err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
+// GetAuditTrailIntegrityProof returns the Merkle path for one event under the
+// earliest checkpoint that covers it.
+func (s *AuditContract) GetAuditTrailIntegrityProof(ctx contractapi.TransactionContextInterface,
+	eventID string) (*IntegrityProof, error) {
+
+	evt, evtJSON, err := getEvent(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	at, err := eventNanos(eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	holderDID, err := s.eventHolder(ctx, evt)
+	if err != nil {
+		return nil, err
+	}
+	cp, err := s.coveringCheckpoint(ctx, holderDID, at)
+	if err != nil {
+		return nil, err
+	}
+	leaves, idx, err := s.checkpointLeaves(ctx, holderDID, cp.CutoffNanos, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if len(leaves) != cp.EventCount || hex.EncodeToString(merkleRoot(leaves)) != cp.Root {
+		return nil, fmt.Errorf("audit trail no longer matches checkpoint %s", cp.CheckpointID)
+	}
+
+	return &IntegrityProof{
+		Event:      *evt,
+		EventJSON:  string(evtJSON),
+		Leaf:       hex.EncodeToString(leaves[idx]),
+		Proof:      merkleProof(leaves, idx),
+		Checkpoint: *cp,
+	}, nil
+}
+
+// ===== Helpers =====
+
+// checkpointLeaves hashes the holder's events up to cutoff in key order
+// (per holderPrefixes prefix, oldest prefix first) and
+// reports the index of eventID among them (-1 if absent or empty).
+func (s *ledger) checkpointLeaves(ctx contractapi.TransactionContextInterface,
+	holderDID string, cutoff int64, eventID string) ([][]byte, int, error) {
+
+	stored, err := holderEvents(ctx, holderDID, "")
+	if err != nil {
+		return nil, -1, err
+	}
+
+	var leaves [][]byte
+	idx := -1
+	for _, kv := range stored {
+		_, attrs, err := ctx.GetStub().SplitCompositeKey(kv.Key)
+		if err != nil {
+			return nil, -1, err
+		}
+		id := attrs[len(attrs)-1]
+		at, err := eventNanos(id)
+		if err != nil {
+			return nil, -1, err
+		}
+		if at > cutoff {
+			continue
+		}
+		if id == eventID {
+			idx = len(leaves)
+		}
+		bz, err := eventJSON(kv.Value)
+		if err != nil {
+			return nil, -1, err
+		}
+		leaves = append(leaves, eventLeaf(bz))
+	}
+	if eventID != "" && idx < 0 {
+		return nil, -1, fmt.Errorf("event %s not covered by checkpoint", eventID)
+	}
+	return leaves, idx, nil
+}
+
+func (s *ledger) coveringCheckpoint(ctx contractapi.TransactionContextInterface,
+	holderDID string, at int64) (*Checkpoint, error) {
+
+	iter, err := ctx.GetStub().GetStateByPartialCompositeKey("checkpoint~holder", []string{holderDID})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	// Checkpoint IDs lead with their creation time, so keys iterate oldest first.
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		var cp Checkpoint
+		if err := json.Unmarshal(kv.Value, &cp); err != nil {
+			return nil, err
+		}
+		if cp.CutoffNanos >= at {
+			return &cp, nil
+		}
+	}
+	return nil, fmt.Errorf("no checkpoint covers the event yet; call CreateCheckpoint")
+}
+
+// getEvent resolves an event by ID through its pointer entry.
+func getEvent(ctx contractapi.TransactionContextInterface, eventID string) (*AccessEvent, []byte, error) {
+	ptr, err := ctx.GetStub().GetState(eventPointerKey(eventID))
+	if err != nil {
+		return nil, nil, err
+	}
+	if ptr == nil {
+		return nil, nil, fmt.Errorf("event %s not found", eventID)
+	}
+	stored, err := ctx.GetStub().GetState(string(ptr))
+	if err != nil {
+		return nil, nil, err
+	}
+	evt, err := decodeEvent(ctx, stored)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := shadowRead(ctx, string(ptr), stored, evt, decodeEventRecord); err != nil {
+		return nil, nil, err
+	}
+	bz, err := eventJSON(stored)
+	if err != nil {
+		return nil, nil, err
+	}
+	return evt, bz, nil
+}
+
+func eventPointerKey(eventID string) string { return "eventid:" + eventID }
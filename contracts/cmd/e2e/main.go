@@ -0,0 +1,475 @@
+//go:build integration
+
+// Command e2e runs lifecycle scenarios against the chaincode on a real
+// Fabric network: fabric-samples' test-network with CouchDB, driven through
+// the peer CLI (see internal/fabnet). It covers what a mock stub cannot —
+// composite-key range scans, bookmark pagination, rich queries and
+// upgrades with live state.
+//
+//	go run -tags integration ./cmd/e2e -samples ~/fabric-samples
+//	go run -tags integration ./cmd/e2e -up=false -run pagination   # reuse a running network
+//
+// IDs are derived from -seed, so a run is repeatable on a fresh ledger.
+// -record writes every call and its outcome as JSON lines; -replay submits a
+// recorded transcript again and reports calls whose outcome changed, which
+// turns a bug report into a regression check.
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+
+	"audittrail/chaincode/internal/fabnet"
+)
+
+// call is one transcript line.
+type call struct {
+	Scenario string   `json:"scenario"`
+	Kind     string   `json:"kind"` // submit | evaluate
+	Fn       string   `json:"fn"`
+	Args     []string `json:"args"`
+	OK       bool     `json:"ok"`
+	Error    string   `json:"error,omitempty"`
+}
+
+type run struct {
+	net      *fabnet.Network
+	prefix   string
+	scenario string
+	calls    []call
+
+	ccPath   string
+	sequence int // deployed chaincode sequence; 0 when the network is not ours
+	events   int // events per holder in the pagination scenarios
+	pageSize int
+}
+
+type scenario struct {
+	name string
+	run  func(r *run) error
+}
+
+// errSkip is returned by scenarios that do not apply to this run.
+var errSkip = errors.New("skipped")
+
+var scenarios = []scenario{
+	{"lifecycle", lifecycle},
+	{"pagination", pagination},
+	{"rich-query", richQuery},
+	{"upgrade", upgrade},
+}
+
+func main() {
+	samples := flag.String("samples", envOr("FABRIC_SAMPLES", "../fabric-samples"), "fabric-samples checkout")
+	ccPath := flag.String("chaincode", ".", "chaincode module to deploy")
+	up := flag.Bool("up", true, "start the network and deploy the chaincode first")
+	down := flag.Bool("down", false, "tear the network down afterwards")
+	only := flag.String("run", "", "run only scenarios matching this regexp")
+	seed := flag.Int64("seed", 1, "seed for credential and holder IDs")
+	events := flag.Int("events", 25, "events per holder in the pagination scenarios")
+	pageSize := flag.Int("page-size", 7, "page size in the pagination scenarios")
+	record := flag.String("record", "", "write the call transcript to this file")
+	replay := flag.String("replay", "", "replay a recorded transcript instead of the scenarios")
+	verbose := flag.Bool("v", false, "show network.sh output")
+	flag.Parse()
+
+	net := &fabnet.Network{SamplesDir: *samples, Verbose: *verbose}
+	r := &run{
+		net:      net,
+		prefix:   fmt.Sprintf("e2e-%d", *seed),
+		ccPath:   *ccPath,
+		events:   *events,
+		pageSize: *pageSize,
+	}
+
+	if *up {
+		r.sequence = 1
+		log.Printf("starting test-network in %s", filepath.Join(*samples, "test-network"))
+		if err := net.Up(); err != nil {
+			log.Fatal(err)
+		}
+		if err := net.Deploy(*ccPath, "1.0", r.sequence); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *down {
+		defer func() {
+			if err := net.Down(); err != nil {
+				log.Print(err)
+			}
+		}()
+	}
+
+	var failed bool
+	if *replay != "" {
+		failed = replayTranscript(r, *replay)
+	} else {
+		match := regexp.MustCompile(*only)
+		for _, sc := range scenarios {
+			if !match.MatchString(sc.name) {
+				continue
+			}
+			r.scenario = sc.name
+			err := sc.run(r)
+			if err == errSkip {
+				log.Printf("skip %s", sc.name)
+				continue
+			}
+			if err != nil {
+				log.Printf("FAIL %s: %v", sc.name, err)
+				failed = true
+				continue
+			}
+			log.Printf("ok   %s", sc.name)
+		}
+	}
+
+	if *record != "" {
+		if err := writeTranscript(*record, r.calls); err != nil {
+			log.Print(err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// ===== Scenarios =====
+
+// lifecycle registers a verifier, then issues, verifies and revokes a
+// credential and checks the holder's trail has every step in order.
+// Registering needs the submitting identity to carry audittrail.role=admin.
+func lifecycle(r *run) error {
+	holder := r.holder("lifecycle")
+	credID := r.id("lifecycle-cred")
+	verifierID := r.id("lifecycle-verifier")
+
+	if _, err := r.submit("audittrail.registry:RegisterVerifier", verifierID, "Org1MSP", hash(verifierID),
+		time.Now().AddDate(1, 0, 0).UTC().Format(time.RFC3339)); err != nil {
+		return err
+	}
+	if _, err := r.submit("IssueCreds", credID, holder, "KYC", hash(credID), "issuer-1", "", "", ""); err != nil {
+		return err
+	}
+	var res struct {
+		IsActive bool `json:"isActive"`
+	}
+	if err := r.submitJSON(&res, "VerifyCreds", credID, verifierID); err != nil {
+		return err
+	}
+	if !res.IsActive {
+		return fmt.Errorf("fresh credential verified inactive")
+	}
+	if _, err := r.submit("RevokeCreds", credID, "e2e", "issuer-1"); err != nil {
+		return err
+	}
+	if err := r.submitJSON(&res, "VerifyCreds", credID, verifierID); err != nil {
+		return err
+	}
+	if res.IsActive {
+		return fmt.Errorf("revoked credential verified active")
+	}
+	if _, err := r.submit("RevokeCreds", credID, "again", "issuer-1"); err == nil {
+		return fmt.Errorf("second revocation was accepted")
+	}
+
+	var trail struct {
+		Events []struct {
+			Action string `json:"action"`
+		} `json:"events"`
+	}
+	if err := r.evaluateJSON(&trail, "audittrail.audit:GetEventsSince", holder, "", "100"); err != nil {
+		return err
+	}
+	want := []string{"Issue", "Verify", "Revoke", "Verify"}
+	var got []string
+	for _, e := range trail.Events {
+		got = append(got, e.Action)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		return fmt.Errorf("trail actions %v, want %v", got, want)
+	}
+	return nil
+}
+
+// pagination pages through a holder's trail, checking every event comes
+// back exactly once, no page exceeds the page size, and a second pass
+// returns the same order.
+func pagination(r *run) error {
+	holder := r.holder("pagination")
+	if err := r.issueMany("pagination", holder); err != nil {
+		return err
+	}
+	first, err := r.pageAll(holder, "")
+	if err != nil {
+		return err
+	}
+	if len(first) != r.events {
+		return fmt.Errorf("paged %d events, want %d", len(first), r.events)
+	}
+	second, err := r.pageAll(holder, "")
+	if err != nil {
+		return err
+	}
+	if fmt.Sprint(first) != fmt.Sprint(second) {
+		return fmt.Errorf("pagination order changed between passes")
+	}
+	return nil
+}
+
+// richQuery pages QueryCredentials by issuer, which runs as a CouchDB
+// query and so only works against a real state database.
+func richQuery(r *run) error {
+	issuer := r.id("rich-issuer")
+	holder := r.holder("rich-query")
+	for i := 0; i < r.events; i++ {
+		credID := r.id("rich-cred-" + strconv.Itoa(i))
+		if _, err := r.submit("IssueCreds", credID, holder, "KYC", hash(credID), issuer, "", "", ""); err != nil {
+			return err
+		}
+	}
+	seen := map[string]bool{}
+	bookmark := ""
+	for {
+		var page struct {
+			Credentials []struct {
+				CredID string `json:"credId"`
+			} `json:"credentials"`
+			Bookmark string `json:"bookmark"`
+		}
+		if err := r.evaluateJSON(&page, "QueryCredentials", "", "", issuer, strconv.Itoa(r.pageSize), bookmark); err != nil {
+			return err
+		}
+		if len(page.Credentials) > r.pageSize {
+			return fmt.Errorf("page of %d exceeds page size %d", len(page.Credentials), r.pageSize)
+		}
+		for _, c := range page.Credentials {
+			if seen[c.CredID] {
+				return fmt.Errorf("credential %s returned twice", c.CredID)
+			}
+			seen[c.CredID] = true
+		}
+		if len(page.Credentials) == 0 || page.Bookmark == "" {
+			break
+		}
+		bookmark = page.Bookmark
+	}
+	if len(seen) != r.events {
+		return fmt.Errorf("queried %d credentials, want %d", len(seen), r.events)
+	}
+	return nil
+}
+
+// upgrade takes a bookmark, redeploys the chaincode with the next sequence,
+// and finishes paging with the old bookmark. It needs the harness to own
+// the deployment, so it is skipped with -up=false.
+func upgrade(r *run) error {
+	if r.sequence == 0 {
+		return errSkip
+	}
+	holder := r.holder("upgrade")
+	if err := r.issueMany("upgrade", holder); err != nil {
+		return err
+	}
+	want, err := r.pageAll(holder, "")
+	if err != nil {
+		return err
+	}
+
+	page, err := r.page(holder, "")
+	if err != nil {
+		return err
+	}
+	r.sequence++
+	if err := r.net.Deploy(r.ccPath, fmt.Sprintf("1.%d", r.sequence-1), r.sequence); err != nil {
+		return err
+	}
+	rest, err := r.pageAll(holder, page.Bookmark)
+	if err != nil {
+		return fmt.Errorf("continuing after upgrade: %v", err)
+	}
+	got := append(page.ids(), rest...)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		return fmt.Errorf("events across the upgrade %v, want %v", got, want)
+	}
+	return nil
+}
+
+// ===== Helpers =====
+
+func (r *run) id(name string) string { return r.prefix + "-" + name }
+
+func (r *run) holder(name string) string { return "did:web:" + r.prefix + "-" + name + ".example.org" }
+
+func (r *run) issueMany(name, holder string) error {
+	for i := 0; i < r.events; i++ {
+		credID := r.id(name + "-cred-" + strconv.Itoa(i))
+		if _, err := r.submit("IssueCreds", credID, holder, "KYC", hash(credID), "issuer-1", "", "", ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type eventPage struct {
+	Events []struct {
+		EventID string `json:"eventId"`
+	} `json:"events"`
+	Bookmark string `json:"bookmark"`
+}
+
+func (p *eventPage) ids() []string {
+	ids := []string{}
+	for _, e := range p.Events {
+		ids = append(ids, e.EventID)
+	}
+	return ids
+}
+
+func (r *run) page(holder, bookmark string) (*eventPage, error) {
+	var p eventPage
+	err := r.evaluateJSON(&p, "audittrail.audit:QueryAuditTrail", holder, strconv.Itoa(r.pageSize), bookmark)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.Events) > r.pageSize {
+		return nil, fmt.Errorf("page of %d exceeds page size %d", len(p.Events), r.pageSize)
+	}
+	return &p, nil
+}
+
+// pageAll follows bookmarks from bookmark to the end and returns the event
+// IDs in order, failing on duplicates.
+func (r *run) pageAll(holder, bookmark string) ([]string, error) {
+	var ids []string
+	seen := map[string]bool{}
+	for {
+		p, err := r.page(holder, bookmark)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range p.ids() {
+			if seen[id] {
+				return nil, fmt.Errorf("event %s returned twice", id)
+			}
+			seen[id] = true
+			ids = append(ids, id)
+		}
+		if len(p.Events) == 0 || p.Bookmark == "" {
+			return ids, nil
+		}
+		bookmark = p.Bookmark
+	}
+}
+
+func (r *run) submit(fn string, args ...string) ([]byte, error) {
+	out, err := r.net.Submit(fn, args...)
+	r.record("submit", fn, args, err)
+	return out, err
+}
+
+func (r *run) submitJSON(v interface{}, fn string, args ...string) error {
+	out, err := r.submit(fn, args...)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(out, v)
+}
+
+func (r *run) evaluateJSON(v interface{}, fn string, args ...string) error {
+	out, err := r.net.Evaluate(fn, args...)
+	r.record("evaluate", fn, args, err)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(out, v); err != nil {
+		return fmt.Errorf("%s: decoding %q: %v", fn, out, err)
+	}
+	return nil
+}
+
+func (r *run) record(kind, fn string, args []string, err error) {
+	c := call{Scenario: r.scenario, Kind: kind, Fn: fn, Args: args, OK: err == nil}
+	if err != nil {
+		c.Error = err.Error()
+	}
+	r.calls = append(r.calls, c)
+}
+
+func writeTranscript(path string, calls []call) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, c := range calls {
+		if err := enc.Encode(c); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// replayTranscript resubmits every recorded call in order and reports the
+// ones whose outcome differs. Chaincode errors are compared verbatim; other
+// failures (network, CLI) only by whether the call succeeded.
+func replayTranscript(r *run, path string) (failed bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 1<<20), 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		var want call
+		if err := json.Unmarshal(sc.Bytes(), &want); err != nil {
+			log.Fatalf("%s:%d: %v", path, line, err)
+		}
+		r.scenario = want.Scenario
+		var err error
+		if want.Kind == "submit" {
+			_, err = r.submit(want.Fn, want.Args...)
+		} else {
+			_, err = r.net.Evaluate(want.Fn, want.Args...)
+			r.record("evaluate", want.Fn, want.Args, err)
+		}
+		got := r.calls[len(r.calls)-1]
+		var txErr *fabnet.TxError
+		if got.OK != want.OK || (errors.As(err, &txErr) && got.Error != want.Error) {
+			log.Printf("DIFF %s:%d %s %s: got ok=%v %q, recorded ok=%v %q",
+				path, line, want.Kind, want.Fn, got.OK, got.Error, want.OK, want.Error)
+			failed = true
+		}
+	}
+	if err := sc.Err(); err != nil {
+		log.Fatal(err)
+	}
+	return failed
+}
+
+func hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func envOr(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,283 @@
+// Command genmeta writes the contract metadata file the contract API loads
+// at startup (META-INF/metadata.json), deriving transaction descriptions,
+// parameter names and schemas from the chaincode source so they never drift
+// from the code. Run it through go generate from the contracts directory.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type schema map[string]interface{}
+
+type parameter struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Schema      schema `json:"schema"`
+}
+
+type transaction struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Tag         []string    `json:"tag"`
+	Parameters  []parameter `json:"parameters,omitempty"`
+	Returns     *struct {
+		Schema schema `json:"schema"`
+	} `json:"returns,omitempty"`
+}
+
+type object struct {
+	ID                   string            `json:"$id"`
+	Properties           map[string]schema `json:"properties"`
+	Required             []string          `json:"required,omitempty"`
+	AdditionalProperties bool              `json:"additionalProperties"`
+}
+
+type gen struct {
+	structs map[string]*ast.StructType
+	objects map[string]object
+}
+
+func main() {
+	dir := flag.String("dir", ".", "chaincode package directory")
+	out := flag.String("out", "META-INF/metadata.json", "output path, relative to -dir")
+	title := flag.String("title", "AuditTrail", "info.title")
+	version := flag.String("version", "0.1.0", "info.version")
+	flag.Parse()
+
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, *dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, parser.ParseComments)
+	if err != nil {
+		log.Fatal(err)
+	}
+	pkg, ok := pkgs["main"]
+	if !ok {
+		log.Fatalf("no package main in %s", *dir)
+	}
+
+	g := &gen{structs: map[string]*ast.StructType{}, objects: map[string]object{}}
+	evaluate := map[string]bool{}
+	names := map[string]string{} // contract type -> namespace, from contractNames
+	defaultType := ""
+	var methods []*ast.FuncDecl
+	for _, f := range pkg.Files {
+		for _, decl := range f.Decls {
+			switch d := decl.(type) {
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					switch sp := spec.(type) {
+					case *ast.TypeSpec:
+						if st, ok := sp.Type.(*ast.StructType); ok {
+							g.structs[sp.Name.Name] = st
+						}
+					case *ast.ValueSpec:
+						if len(sp.Names) != 1 || len(sp.Values) != 1 {
+							continue
+						}
+						switch sp.Names[0].Name {
+						case "evaluateTransactions":
+							for _, elt := range sp.Values[0].(*ast.CompositeLit).Elts {
+								evaluate[stringLit(elt)] = true
+							}
+						case "contractNames":
+							for _, elt := range sp.Values[0].(*ast.CompositeLit).Elts {
+								kv := elt.(*ast.KeyValueExpr)
+								names[stringLit(kv.Key)] = stringLit(kv.Value)
+							}
+						case "defaultContract":
+							defaultType = stringLit(sp.Values[0])
+						}
+					}
+				}
+			case *ast.FuncDecl:
+				if d.Recv != nil && d.Name.IsExported() {
+					methods = append(methods, d)
+				}
+			}
+		}
+	}
+	sort.Slice(methods, func(i, j int) bool { return methods[i].Name.Name < methods[j].Name.Name })
+
+	for name := range evaluate {
+		found := false
+		for _, m := range methods {
+			found = found || m.Name.Name == name
+		}
+		if !found {
+			log.Fatalf("evaluateTransactions lists unknown transaction %s", name)
+		}
+	}
+
+	if len(names) == 0 {
+		log.Fatal("no contractNames found")
+	}
+	txs := map[string][]transaction{}
+	total := 0
+	for _, m := range methods {
+		typ := receiverName(m)
+		if _, ok := names[typ]; !ok {
+			continue // not a contract, or a shared hook such as GetEvaluateTransactions
+		}
+		tx := transaction{Name: m.Name.Name, Description: docText(m.Doc), Tag: []string{"submit"}}
+		if evaluate[tx.Name] {
+			tx.Tag = []string{"evaluate"}
+		}
+		for i, field := range m.Type.Params.List {
+			if i == 0 {
+				continue // transaction context
+			}
+			for _, name := range field.Names {
+				tx.Parameters = append(tx.Parameters, parameter{Name: name.Name, Schema: g.schemaOf(field.Type)})
+			}
+		}
+		if res := m.Type.Results; res != nil {
+			for _, field := range res.List {
+				if id, ok := field.Type.(*ast.Ident); ok && id.Name == "error" {
+					continue
+				}
+				tx.Returns = &struct {
+					Schema schema `json:"schema"`
+				}{g.schemaOf(field.Type)}
+			}
+		}
+		txs[typ] = append(txs[typ], tx)
+		total++
+	}
+
+	info := map[string]string{"title": *title, "version": *version}
+	contracts := map[string]interface{}{}
+	for typ, name := range names {
+		contracts[name] = map[string]interface{}{
+			"name":         name,
+			"info":         info,
+			"transactions": txs[typ],
+			"default":      typ == defaultType,
+		}
+	}
+	doc := map[string]interface{}{
+		"$schema":    "https://hyperledger.github.io/fabric-chaincode-node/main/api/contract-schema.json",
+		"info":       info,
+		"contracts":  contracts,
+		"components": map[string]interface{}{"schemas": g.objects},
+	}
+	bz, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	path := filepath.Join(*dir, *out)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(path, append(bz, '\n'), 0o644); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("wrote %d contracts, %d transactions and %d schemas to %s", len(contracts), total, len(g.objects), path)
+}
+
+func stringLit(expr ast.Expr) string {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		log.Fatalf("expected a string literal, got %T", expr)
+	}
+	s, _ := strconv.Unquote(lit.Value)
+	return s
+}
+
+func receiverName(d *ast.FuncDecl) string {
+	t := d.Recv.List[0].Type
+	if star, ok := t.(*ast.StarExpr); ok {
+		t = star.X
+	}
+	if id, ok := t.(*ast.Ident); ok {
+		return id.Name
+	}
+	return ""
+}
+
+// docText flattens a doc comment into one paragraph.
+func docText(cg *ast.CommentGroup) string {
+	if cg == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(cg.Text()), " ")
+}
+
+func (g *gen) schemaOf(expr ast.Expr) schema {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return g.schemaOf(t.X)
+	case *ast.ArrayType:
+		return schema{"type": "array", "items": g.schemaOf(t.Elt)}
+	case *ast.MapType:
+		return schema{"type": "object", "additionalProperties": g.schemaOf(t.Value)}
+	case *ast.Ident:
+		switch t.Name {
+		case "string":
+			return schema{"type": "string"}
+		case "bool":
+			return schema{"type": "boolean"}
+		case "int", "int64":
+			return schema{"type": "integer", "format": "int64"}
+		case "int32":
+			return schema{"type": "integer", "format": "int32"}
+		case "uint64":
+			return schema{"type": "integer", "format": "int64", "minimum": 0}
+		case "float64":
+			return schema{"type": "number", "format": "double"}
+		}
+		if st, ok := g.structs[t.Name]; ok {
+			g.object(t.Name, st)
+			return schema{"$ref": "#/components/schemas/" + t.Name}
+		}
+	}
+	log.Fatalf("unsupported type %T %v", expr, expr)
+	return nil
+}
+
+func (g *gen) object(name string, st *ast.StructType) {
+	if _, done := g.objects[name]; done {
+		return
+	}
+	obj := object{ID: name, Properties: map[string]schema{}}
+	g.objects[name] = obj // reserve before recursing
+	for _, field := range st.Fields.List {
+		tag := ""
+		if field.Tag != nil {
+			raw, _ := strconv.Unquote(field.Tag.Value)
+			tag = reflect.StructTag(raw).Get("json")
+		}
+		jsonName, opts, _ := strings.Cut(tag, ",")
+		for _, n := range field.Names {
+			if !n.IsExported() || jsonName == "-" {
+				continue
+			}
+			prop := jsonName
+			if prop == "" {
+				prop = n.Name
+			}
+			obj.Properties[prop] = g.schemaOf(field.Type)
+			if !strings.Contains(opts, "omitempty") {
+				obj.Required = append(obj.Required, prop)
+			}
+		}
+	}
+	sort.Strings(obj.Required)
+	g.objects[name] = obj
+	if len(obj.Properties) == 0 {
+		fmt.Fprintf(os.Stderr, "warning: %s has no exported fields\n", name)
+	}
+}
@@ -0,0 +1,384 @@
+// Command policy-sim replays historical audit events through a proposed
+// access policy and consent rule, and reports which past verifications the
+// proposal would have denied, so governance can weigh a change before
+// enacting it with SetAccessPolicy.
+//
+//	go run ./cmd/policy-sim -events export.ndjson -policy proposed.json -actors actors.json
+//	go run ./cmd/policy-sim -events export.ndjson -require-consent -json > impact.json
+//
+// -events takes a JSON array or NDJSON of AccessEvents: a gateway export, or
+// QueryAuditTrail pages concatenated. -policy is an AccessPolicy as passed to
+// SetAccessPolicy. Events name the verifier, not its MSP or role, so rules
+// that match on those need -actors: {"<actorId>": {"msp": "Org2MSP",
+// "role": "verifier"}}. Unmapped actors match only rules without msps or
+// role.
+//
+// With -require-consent a verification is also denied unless the holder's
+// consent to the verifier was in force at the time. Consent history comes
+// from the ConsentGrant / ConsentRevoke events in -events. Gateway events
+// do not name the verifier; pass the holders' consent records as -consents
+// ([]Consent, as from GetConsents) to cover those.
+//
+// Rule matching mirrors checkAccessPolicy in policy.go; keep the two in step.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// The chaincode is package main and cannot be imported; these mirror the
+// JSON of its AccessEvent, AccessPolicy, AccessRule and Consent.
+type accessEvent struct {
+	EventID    string `json:"eventId"`
+	CredID     string `json:"credId"`
+	HolderDID  string `json:"holderDid"`
+	Action     string `json:"action"`
+	ActorID    string `json:"actorId"`
+	Outcome    string `json:"outcome"`
+	Reason     string `json:"reason"`
+	OccurredAt string `json:"occurredAt"`
+}
+
+type accessPolicy struct {
+	Default string       `json:"default"`
+	Rules   []accessRule `json:"rules"`
+}
+
+type accessRule struct {
+	ID           string   `json:"id"`
+	Effect       string   `json:"effect"`
+	Transactions []string `json:"transactions"`
+	MSPs         []string `json:"msps"`
+	Role         string   `json:"role"`
+}
+
+type consent struct {
+	HolderDID  string `json:"holderDid"`
+	VerifierID string `json:"verifierId"`
+	Status     string `json:"status"`
+	GrantedAt  string `json:"grantedAt"`
+	RevokedAt  string `json:"revokedAt"`
+}
+
+type actor struct {
+	MSP  string `json:"msp"`
+	Role string `json:"role"`
+}
+
+// verifyTransactions maps verification actions to the transaction that
+// recorded them.
+var verifyTransactions = map[string]string{
+	"Verify":           "audittrail.credential:VerifyCreds",
+	"VerifyAttribute":  "audittrail.credential:VerifyAttribute",
+	"BreakGlassVerify": "audittrail.credential:BreakGlassVerify",
+}
+
+// denial is a past verification the proposal would have denied.
+type denial struct {
+	EventID    string `json:"eventId"`
+	OccurredAt string `json:"occurredAt"`
+	CredID     string `json:"credId"`
+	HolderDID  string `json:"holderDid"`
+	VerifierID string `json:"verifierId"`
+	Rule       string `json:"rule"` // rule ID, "default" or "consent"
+	Reason     string `json:"reason"`
+}
+
+type report struct {
+	Events         int            `json:"events"`
+	Verifications  int            `json:"verifications"`  // allowed at the time, so replayed
+	WouldDeny      int            `json:"wouldDeny"`      // of which the proposal denies
+	ByRule         map[string]int `json:"byRule"`         // denials per rule
+	ByVerifier     map[string]int `json:"byVerifier"`     // denials per verifier
+	UnmappedActors []string       `json:"unmappedActors"` // verifiers missing from -actors
+	Denials        []denial       `json:"denials"`
+}
+
+func main() {
+	eventsPath := flag.String("events", "", "events to replay: JSON array or NDJSON (required)")
+	policyPath := flag.String("policy", "", "proposed AccessPolicy JSON; empty allows everything")
+	actorsPath := flag.String("actors", "", "JSON map of actorId to {msp, role}")
+	consentsPath := flag.String("consents", "", "JSON array of consent records, adding to the event history")
+	requireConsent := flag.Bool("require-consent", false, "deny verifications without a consent in force")
+	asJSON := flag.Bool("json", false, "print the report as JSON")
+	limit := flag.Int("limit", 50, "denials listed in the text report; 0 lists all")
+	flag.Parse()
+	if *eventsPath == "" {
+		log.Fatal("-events is required")
+	}
+
+	events, err := readEvents(*eventsPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	policy := &accessPolicy{}
+	actors := map[string]actor{}
+	var consents []consent
+	for _, in := range []struct {
+		path string
+		v    interface{}
+	}{{*policyPath, policy}, {*actorsPath, &actors}, {*consentsPath, &consents}} {
+		if in.path == "" {
+			continue
+		}
+		if err := readJSON(in.path, in.v); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	rep := simulate(events, policy, actors, consents, *requireConsent)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(rep); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	printReport(os.Stdout, rep, *limit)
+}
+
+// simulate replays events in time order. Consent history is built as it
+// goes, so a verification is judged by the consents granted before it.
+func simulate(events []accessEvent, policy *accessPolicy, actors map[string]actor,
+	snapshot []consent, requireConsent bool) *report {
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].OccurredAt < events[j].OccurredAt })
+	rep := &report{
+		Events:         len(events),
+		ByRule:         map[string]int{},
+		ByVerifier:     map[string]int{},
+		UnmappedActors: []string{},
+		Denials:        []denial{},
+	}
+	history := newConsentHistory(snapshot)
+	unmapped := map[string]bool{}
+
+	for _, evt := range events {
+		switch evt.Action {
+		case "ConsentGrant", "ConsentRevoke":
+			history.apply(evt)
+			continue
+		}
+		fn, ok := verifyTransactions[evt.Action]
+		if !ok || evt.Outcome == "Denied" {
+			continue
+		}
+		rep.Verifications++
+
+		a, mapped := actors[evt.ActorID]
+		if !mapped && !unmapped[evt.ActorID] {
+			unmapped[evt.ActorID] = true
+			rep.UnmappedActors = append(rep.UnmappedActors, evt.ActorID)
+		}
+		rule, reason := policy.decide(fn, a)
+		if rule == "" && requireConsent && evt.Action != "BreakGlassVerify" &&
+			!history.coveredAt(evt.HolderDID, evt.ActorID, evt.OccurredAt) {
+			rule, reason = "consent", fmt.Sprintf("no consent from %s to %s in force", evt.HolderDID, evt.ActorID)
+		}
+		if rule == "" {
+			continue
+		}
+		rep.WouldDeny++
+		rep.ByRule[rule]++
+		rep.ByVerifier[evt.ActorID]++
+		rep.Denials = append(rep.Denials, denial{
+			EventID:    evt.EventID,
+			OccurredAt: evt.OccurredAt,
+			CredID:     evt.CredID,
+			HolderDID:  evt.HolderDID,
+			VerifierID: evt.ActorID,
+			Rule:       rule,
+			Reason:     reason,
+		})
+	}
+	sort.Strings(rep.UnmappedActors)
+	return rep
+}
+
+// decide returns the denying rule ID ("default" for the default) and a
+// reason, or "" when fn is allowed.
+func (p *accessPolicy) decide(fn string, a actor) (string, string) {
+	for _, r := range p.Rules {
+		if !r.matches(fn, a) {
+			continue
+		}
+		if r.Effect == "deny" {
+			return r.ID, fmt.Sprintf("access policy rule %s denies %s to %s", r.ID, fn, a.MSP)
+		}
+		return "", ""
+	}
+	if p.Default == "deny" {
+		return "default", fmt.Sprintf("access policy denies %s to %s", fn, a.MSP)
+	}
+	return "", ""
+}
+
+func (r *accessRule) matches(fn string, a actor) bool {
+	namespace, name, _ := strings.Cut(fn, ":")
+	txMatch := false
+	for _, t := range r.Transactions {
+		if t == "*" || t == fn || t == name || t == namespace+":*" {
+			txMatch = true
+			break
+		}
+	}
+	if !txMatch {
+		return false
+	}
+	if len(r.MSPs) > 0 {
+		found := false
+		for _, m := range r.MSPs {
+			if m == a.MSP {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return r.Role == "" || r.Role == a.Role
+}
+
+// consentHistory holds, per holder and verifier, the periods a consent was
+// in force as [grantedAt, revokedAt) pairs; an open period has no end.
+type consentHistory map[string][][2]string
+
+func consentKey(holderDID, verifierID string) string { return holderDID + "\x00" + verifierID }
+
+func newConsentHistory(snapshot []consent) consentHistory {
+	h := consentHistory{}
+	for _, c := range snapshot {
+		if c.GrantedAt == "" {
+			continue
+		}
+		end := ""
+		if c.Status != "Granted" {
+			end = c.RevokedAt
+		}
+		k := consentKey(c.HolderDID, c.VerifierID)
+		h[k] = append(h[k], [2]string{c.GrantedAt, end})
+	}
+	return h
+}
+
+// apply folds a chaincode consent event ("verifier <id>: <purpose>" or
+// "verifier <id>") into the history. Events without a verifier are skipped.
+func (h consentHistory) apply(evt accessEvent) {
+	rest, ok := strings.CutPrefix(evt.Reason, "verifier ")
+	if !ok {
+		return
+	}
+	verifierID, _, _ := strings.Cut(rest, ":")
+	k := consentKey(evt.HolderDID, verifierID)
+	periods := h[k]
+	open := len(periods) > 0 && periods[len(periods)-1][1] == ""
+	switch {
+	case evt.Action == "ConsentGrant" && !open:
+		h[k] = append(periods, [2]string{evt.OccurredAt, ""})
+	case evt.Action == "ConsentRevoke" && open:
+		periods[len(periods)-1][1] = evt.OccurredAt
+	}
+}
+
+func (h consentHistory) coveredAt(holderDID, verifierID, at string) bool {
+	for _, p := range h[consentKey(holderDID, verifierID)] {
+		if p[0] <= at && (p[1] == "" || at < p[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+func readEvents(path string) ([]accessEvent, error) {
+	bz, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if trimmed := bytes.TrimSpace(bz); len(trimmed) > 0 && trimmed[0] == '[' {
+		var events []accessEvent
+		return events, json.Unmarshal(trimmed, &events)
+	}
+
+	var events []accessEvent
+	sc := bufio.NewScanner(bytes.NewReader(bz))
+	sc.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		var evt accessEvent
+		if err := json.Unmarshal(sc.Bytes(), &evt); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		events = append(events, evt)
+	}
+	return events, sc.Err()
+}
+
+func readJSON(path string, v interface{}) error {
+	bz, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(bz, v); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+func printReport(w io.Writer, rep *report, limit int) {
+	fmt.Fprintf(w, "replayed %d verifications from %d events; the proposal denies %d\n",
+		rep.Verifications, rep.Events, rep.WouldDeny)
+	if len(rep.UnmappedActors) > 0 {
+		fmt.Fprintf(w, "actors without an -actors entry (MSP and role unknown): %s\n",
+			strings.Join(rep.UnmappedActors, ", "))
+	}
+	if rep.WouldDeny == 0 {
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "\nRULE\tDENIALS")
+	for _, k := range sortedByCount(rep.ByRule) {
+		fmt.Fprintf(tw, "%s\t%d\n", k, rep.ByRule[k])
+	}
+	fmt.Fprintln(tw, "\nVERIFIER\tDENIALS")
+	for _, k := range sortedByCount(rep.ByVerifier) {
+		fmt.Fprintf(tw, "%s\t%d\n", k, rep.ByVerifier[k])
+	}
+	fmt.Fprintln(tw, "\nOCCURRED\tEVENT\tVERIFIER\tHOLDER\tRULE")
+	for i, d := range rep.Denials {
+		if limit > 0 && i == limit {
+			fmt.Fprintf(tw, "... %d more; use -limit 0 or -json\n", len(rep.Denials)-limit)
+			break
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", d.OccurredAt, d.EventID, d.VerifierID, d.HolderDID, d.Rule)
+	}
+	tw.Flush()
+}
+
+func sortedByCount(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if m[keys[i]] != m[keys[j]] {
+			return m[keys[i]] > m[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
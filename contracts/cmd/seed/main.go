@@ -0,0 +1,241 @@
+// Command seed populates a dev network with a synthetic but realistically
+// shaped ledger: a few large issuers and a long tail, holders with skewed
+// credential counts, issuance concentrated in business hours, Poisson
+// verification traffic and a small revocation rate.
+//
+// It drives the gateway's REST API, so timestamps are assigned by the network
+// at submission time. Actions are generated on a timeline spanning -days and
+// replayed in order; -speedup paces the replay (e.g. 86400 plays one
+// simulated day per second) and 0 submits as fast as possible.
+//
+//	go run ./cmd/seed -api http://localhost:3000 -creds 5000 -days 90
+//
+// Gateways with authorization enabled need -api-key, holding the cred:issue,
+// cred:verify and cred:revoke scopes.
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"time"
+)
+
+type action struct {
+	at   time.Time
+	path string
+	body map[string]string
+}
+
+var credTypes = []struct {
+	name   string
+	weight float64
+}{
+	{"KYC", 0.40},
+	{"EmploymentProof", 0.20},
+	{"DegreeCertificate", 0.15},
+	{"DriverLicense", 0.15},
+	{"HealthInsurance", 0.10},
+}
+
+// hourWeights shapes issuance and verification toward working hours (UTC).
+var hourWeights = [24]float64{
+	1, 1, 1, 1, 1, 2, 3, 6, 10, 12, 12, 11,
+	9, 11, 12, 12, 10, 8, 5, 4, 3, 2, 2, 1,
+}
+
+func main() {
+	apiURL := flag.String("api", "http://localhost:3000", "gateway base URL")
+	apiKey := flag.String("api-key", "", "gateway API key (X-API-Key), if authorization is enabled")
+	issuers := flag.Int("issuers", 5, "number of issuers")
+	holders := flag.Int("holders", 200, "number of holders")
+	creds := flag.Int("creds", 1000, "number of credentials")
+	days := flag.Int("days", 90, "length of the simulated timeline in days")
+	verifiers := flag.Int("verifiers", 20, "number of verifiers")
+	verifyMean := flag.Float64("verify-mean", 6, "mean verifications per credential")
+	revokeFrac := flag.Float64("revoke-frac", 0.05, "fraction of credentials revoked")
+	speedup := flag.Float64("speedup", 0, "replay speed relative to the timeline; 0 disables pacing")
+	seed := flag.Int64("seed", 1, "random seed, for reproducible datasets")
+	dryRun := flag.Bool("dry-run", false, "print the action counts without submitting")
+	flag.Parse()
+
+	rng := rand.New(rand.NewSource(*seed))
+	end := time.Now().UTC()
+	start := end.AddDate(0, 0, -*days)
+
+	// Zipf gives a few heavy issuers/holders/verifiers and a long tail.
+	pickIssuer := zipf(rng, *issuers)
+	pickHolder := zipf(rng, *holders)
+	pickVerifier := zipf(rng, *verifiers)
+
+	var actions []action
+	for i := 0; i < *creds; i++ {
+		credID := fmt.Sprintf("seed-cred-%06d", i)
+		issuedAt := businessTime(rng, start, end)
+		holderDID := fmt.Sprintf("did:example:holder-%05d", pickHolder())
+		actions = append(actions, action{issuedAt, "/v1/issue", map[string]string{
+			"credId":     credID,
+			"holderDid":  holderDID,
+			"credType":   pickCredType(rng),
+			"hashedData": randomHash(rng),
+			"issuerId":   fmt.Sprintf("issuer-%02d", pickIssuer()),
+		}})
+
+		for n := poisson(rng, *verifyMean); n > 0; n-- {
+			at := between(rng, issuedAt, end)
+			actions = append(actions, action{at, "/v1/verify", map[string]string{
+				"credId":     credID,
+				"verifierId": fmt.Sprintf("verifier-%03d", pickVerifier()),
+			}})
+		}
+		if rng.Float64() < *revokeFrac {
+			actions = append(actions, action{between(rng, issuedAt, end), "/v1/revoke", map[string]string{
+				"credId":    credID,
+				"reason":    "seeded revocation",
+				"revokerId": "seed",
+			}})
+		}
+	}
+	sort.SliceStable(actions, func(i, j int) bool { return actions[i].at.Before(actions[j].at) })
+
+	counts := map[string]int{}
+	for _, a := range actions {
+		counts[a.path]++
+	}
+	log.Printf("generated %d actions over %d days: %v", len(actions), *days, counts)
+	if *dryRun {
+		return
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	failed := 0
+	prev := start
+	for i, a := range actions {
+		if *speedup > 0 {
+			time.Sleep(time.Duration(float64(a.at.Sub(prev)) / *speedup))
+			prev = a.at
+		}
+		if err := post(client, *apiURL+a.path, *apiKey, a.body); err != nil {
+			failed++
+			log.Printf("%s %s: %v", a.path, a.body["credId"], err)
+		}
+		if (i+1)%1000 == 0 {
+			log.Printf("%d/%d submitted", i+1, len(actions))
+		}
+	}
+	log.Printf("done: %d submitted, %d failed", len(actions)-failed, failed)
+}
+
+func post(client *http.Client, url, apiKey string, body map[string]string) error {
+	bz, _ := json.Marshal(body)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(bz))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var out struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return fmt.Errorf("status %d: %v", resp.StatusCode, err)
+	}
+	if !out.OK {
+		return fmt.Errorf("%s", out.Error)
+	}
+	return nil
+}
+
+// zipf returns a picker over [0, n) where low indexes dominate.
+func zipf(rng *rand.Rand, n int) func() int {
+	if n <= 1 {
+		return func() int { return 0 }
+	}
+	z := rand.NewZipf(rng, 1.2, 1, uint64(n-1))
+	return func() int { return int(z.Uint64()) }
+}
+
+// businessTime picks a day uniformly in [start, end) and an hour weighted
+// toward working hours.
+func businessTime(rng *rand.Rand, start, end time.Time) time.Time {
+	span := end.Sub(start)
+	day := start.Add(time.Duration(rng.Int63n(int64(span)))).Truncate(24 * time.Hour)
+	total := 0.0
+	for _, w := range hourWeights {
+		total += w
+	}
+	r := rng.Float64() * total
+	hour := 0
+	for ; hour < 23; hour++ {
+		if r -= hourWeights[hour]; r < 0 {
+			break
+		}
+	}
+	t := day.Add(time.Duration(hour)*time.Hour + time.Duration(rng.Int63n(int64(time.Hour))))
+	if t.Before(start) {
+		return start
+	}
+	if t.After(end) {
+		return end
+	}
+	return t
+}
+
+// between picks a time in [from, to], biased toward from: verifications
+// cluster soon after issuance and taper off.
+func between(rng *rand.Rand, from, to time.Time) time.Time {
+	span := to.Sub(from)
+	if span <= 0 {
+		return from
+	}
+	offset := time.Duration(rng.ExpFloat64() * float64(span) / 4)
+	if offset > span {
+		offset = time.Duration(rng.Int63n(int64(span)))
+	}
+	return from.Add(offset)
+}
+
+// poisson samples with Knuth's method; fine for the small means used here.
+func poisson(rng *rand.Rand, mean float64) int {
+	l, k, p := math.Exp(-mean), 0, 1.0
+	for {
+		p *= rng.Float64()
+		if p <= l {
+			return k
+		}
+		k++
+	}
+}
+
+func pickCredType(rng *rand.Rand) string {
+	r := rng.Float64()
+	for _, ct := range credTypes {
+		if r -= ct.weight; r < 0 {
+			return ct.name
+		}
+	}
+	return credTypes[len(credTypes)-1].name
+}
+
+func randomHash(rng *rand.Rand) string {
+	buf := make([]byte, 32)
+	rng.Read(buf)
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Credentials and audit events can be stored as canonical JSON (the default)
+// or, with ContractConfig.StateCodec "proto", in protobuf wire format per
+// proto/state.proto. Protobuf values carry protoStateMagic as a version
+// prefix; JSON never starts with a NUL byte, so readers detect the encoding
+// per record and a channel can switch codecs without migrating old state or
+// changing any key layout. Chaincode events are always emitted as JSON.
+const protoStateMagic = "\x00pb1"
+
+// encodeCred encodes a credential with the configured codec.
+func encodeCred(cfg *ContractConfig, cred *Credential) ([]byte, error) {
+	if cfg.StateCodec == "proto" {
+		return encodeProtoState(cred.protoFields()), nil
+	}
+	return canonicalJSON(cred)
+}
+
+// decodeCred decodes a stored credential, strictly unless the channel
+// config allows lenient decoding (see strict.go).
+func decodeCred(ctx contractapi.TransactionContextInterface, bz []byte) (*Credential, error) {
+	strict, err := strictDecoding(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return decodeCredWith(bz, strict)
+}
+
+func decodeCredWith(bz []byte, strict bool) (*Credential, error) {
+	var cred Credential
+	if err := decodeState(bz, &cred, strict); err != nil {
+		return nil, err
+	}
+	return &cred, nil
+}
+
+// encodeEvent encodes an event with the configured codec.
+func encodeEvent(cfg *ContractConfig, evt *AccessEvent) ([]byte, error) {
+	if cfg.StateCodec == "proto" {
+		return encodeProtoState(evt.protoFields()), nil
+	}
+	return canonicalJSON(evt)
+}
+
+// decodeEvent decodes a stored event, strictly unless the channel config
+// allows lenient decoding (see strict.go).
+func decodeEvent(ctx contractapi.TransactionContextInterface, bz []byte) (*AccessEvent, error) {
+	strict, err := strictDecoding(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return decodeEventWith(bz, strict)
+}
+
+func decodeEventWith(bz []byte, strict bool) (*AccessEvent, error) {
+	var evt AccessEvent
+	if err := decodeState(bz, &evt, strict); err != nil {
+		return nil, err
+	}
+	return &evt, nil
+}
+
+// decodeState decodes a record in either codec. Strict decoding rejects
+// fields the record type does not have.
+func decodeState(bz []byte, v protoRecord, strict bool) error {
+	if bytes.HasPrefix(bz, []byte(protoStateMagic)) {
+		return decodeProtoState(bz, v.protoFields(), strict)
+	}
+	if !strict {
+		return json.Unmarshal(bz, v)
+	}
+	dec := json.NewDecoder(bytes.NewReader(bz))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("strict decoding: %v", err)
+	}
+	return nil
+}
+
+// eventJSON returns the JSON form of a stored event: the stored bytes
+// themselves for JSON state, canonical JSON for protobuf state. Checkpoint
+// leaves are computed over this so they are independent of the codec.
+func eventJSON(bz []byte) ([]byte, error) {
+	if !bytes.HasPrefix(bz, []byte(protoStateMagic)) {
+		return bz, nil
+	}
+	evt, err := decodeEventWith(bz, false)
+	if err != nil {
+		return nil, err
+	}
+	return canonicalJSON(evt)
+}
+
+// protoFields lists the fields in proto/state.proto order: element i is
+// field number i+1. Only append; never reorder or remove.
+func (c *Credential) protoFields() []*string {
+	return []*string{
+		&c.CredID, &c.HolderDID, &c.CredType, &c.HashedData, &c.MerkleRoot,
+		&c.HolderProofHash, &c.IssuerID, &c.Jurisdiction, &c.Status, &c.ExpiresAt,
+		&c.CreatedAt, &c.UpdatedAt, &c.EndorsedBy, &c.StateTxID, &c.HolderType,
+		&c.LegalEntityID,
+	}
+}
+
+// protoFields: see Credential.protoFields.
+func (e *AccessEvent) protoFields() []*string {
+	return []*string{
+		&e.EventID, &e.CredID, &e.HolderDID, &e.Action, &e.ActorID,
+		&e.Outcome, &e.Reason, &e.OccurredAt, &e.EventCategory, &e.Severity,
+		&e.SourceComponent, &e.WalletAttestation, &e.WalletPlatform,
+	}
+}
+
+// ===== Helpers =====
+
+const protoWireBytes = 2 // length-delimited
+
+// encodeProtoState writes string fields as protobuf length-delimited
+// fields, skipping empty ones as proto3 does.
+func encodeProtoState(fields []*string) []byte {
+	buf := []byte(protoStateMagic)
+	for i, f := range fields {
+		if *f == "" {
+			continue
+		}
+		buf = binary.AppendUvarint(buf, uint64(i+1)<<3|protoWireBytes)
+		buf = binary.AppendUvarint(buf, uint64(len(*f)))
+		buf = append(buf, *f...)
+	}
+	return buf
+}
+
+// decodeProtoState fills fields from protobuf wire data. Unknown fields,
+// e.g. from records written by newer chaincode, are skipped, or rejected
+// when strict.
+func decodeProtoState(bz []byte, fields []*string, strict bool) error {
+	r := bytes.NewReader(bz[len(protoStateMagic):])
+	for r.Len() > 0 {
+		tag, err := binary.ReadUvarint(r)
+		if err != nil {
+			return fmt.Errorf("protobuf state: %v", err)
+		}
+		num, wire := int(tag>>3), tag&7
+		var skip uint64
+		switch wire {
+		case 0: // varint
+			if _, err := binary.ReadUvarint(r); err != nil {
+				return fmt.Errorf("protobuf state: %v", err)
+			}
+			if strict {
+				return fmt.Errorf("strict decoding: protobuf state: unknown field %d", num)
+			}
+			continue
+		case 1: // fixed64
+			skip = 8
+		case 5: // fixed32
+			skip = 4
+		case protoWireBytes:
+			if skip, err = binary.ReadUvarint(r); err != nil {
+				return fmt.Errorf("protobuf state: %v", err)
+			}
+		default:
+			return fmt.Errorf("protobuf state: unsupported wire type %d", wire)
+		}
+		if skip > uint64(r.Len()) {
+			return fmt.Errorf("protobuf state: truncated field %d", num)
+		}
+		val := make([]byte, skip)
+		r.Read(val)
+		if wire == protoWireBytes && num >= 1 && num <= len(fields) {
+			*fields[num-1] = string(val)
+		} else if strict {
+			return fmt.Errorf("strict decoding: protobuf state: unknown field %d", num)
+		}
+	}
+	return nil
+}
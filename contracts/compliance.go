@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ComplianceFinding is a policy violation detected by a compliance sweep.
+type ComplianceFinding struct {
+	FindingID  string `json:"findingId"`
+	CredID     string `json:"credId"`
+	CredType   string `json:"credType"`
+	IssuerID   string `json:"issuerId"`
+	Rule       string `json:"rule"` // Expired | IssuerUnregistered | IssuerDeactivated | CredTypeUnregistered | RetentionExceeded | OrphanedIndex; see also authority.go
+	Detail     string `json:"detail"`
+	DetectedAt string `json:"detectedAt"` // RFC3339
+}
+
+// complianceEvent is the ComplianceFinding event payload.
+type complianceEvent struct {
+	Findings []ComplianceFinding `json:"findings"`
+}
+
+// SweepResult summarizes one page of a compliance sweep.
+type SweepResult struct {
+	Scanned  int32               `json:"scanned"`
+	Findings []ComplianceFinding `json:"findings"`
+	Bookmark string              `json:"bookmark"`
+}
+
+// DPACoverage reports whether a registered verifier's data processing
+// agreement is in force.
+type DPACoverage struct {
+	VerifierID    string `json:"verifierId"`
+	MSPID         string `json:"mspId"`
+	DPAHash       string `json:"dpaHash"`
+	DPAExpiresAt  string `json:"dpaExpiresAt"`
+	Status        string `json:"status"`        // Covered | Expiring | Expired
+	DaysRemaining int    `json:"daysRemaining"` // negative once expired
+}
+
+const dpaExpiringDays = 30
+
+// RunComplianceSweep re-evaluates a page of credentials of credType against
+// current policy, persists any findings and emits them as a single
+// ComplianceFinding event. Revoked credentials are only checked against
+// their holder type's retention period. Index entries whose credential is
+// gone or has another type are flagged as OrphanedIndex rather than
+// stopping the sweep. A finding's ID is derived from its rule and
+// credential, so a rerun replaces rather than repeats it. Admins and
+// auditors only. Call repeatedly with the returned bookmark.
+func (s *AuditContract) RunComplianceSweep(ctx contractapi.TransactionContextInterface,
+	credType string, pageSize int32, bookmark string) (*SweepResult, error) {
+
+	role, _, err := ctx.GetClientIdentity().GetAttributeValue(roleAttr)
+	if err != nil {
+		return nil, err
+	}
+	if role != "admin" && role != "auditor" {
+		return nil, fmt.Errorf("admin or auditor role required")
+	}
+	raw, err := decodeBookmark(ctx, "cred~type", bookmark)
+	if err != nil {
+		return nil, err
+	}
+	iter, meta, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(
+		"cred~type", []string{credType}, pageSize, raw)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+	next, err := encodeBookmark(ctx, "cred~type", meta.Bookmark)
+	if err != nil {
+		return nil, err
+	}
+
+	ct, err := s.getCredType(ctx, credType)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &SweepResult{Findings: []ComplianceFinding{}, Bookmark: next}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, attrs, err := ctx.GetStub().SplitCompositeKey(kv.Key)
+		if err != nil {
+			return nil, err
+		}
+		credID := attrs[1]
+		res.Scanned++
+
+		exists, err := s.credExists(ctx, credID)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			finding := sweepFinding(credID, credType, "", "OrphanedIndex", "cred~type entry for a missing credential")
+			if err := s.recordFinding(ctx, res, finding); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		cred, err := s.getCred(ctx, credID)
+		if err != nil {
+			return nil, err
+		}
+		if cred.CredType != credType {
+			finding := sweepFinding(credID, credType, cred.IssuerID, "OrphanedIndex",
+				"cred~type entry for a credential of type "+cred.CredType)
+			if err := s.recordFinding(ctx, res, finding); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		flag := func(rule, detail string) error {
+			return s.recordFinding(ctx, res, sweepFinding(credID, credType, cred.IssuerID, rule, detail))
+		}
+
+		if detail := retentionExceeded(cfg, cred); detail != "" {
+			if err := flag("RetentionExceeded", detail); err != nil {
+				return nil, err
+			}
+		}
+		if cred.Status == "Revoked" {
+			continue
+		}
+
+		if cred.expired() {
+			if err := flag("Expired", "expired at "+cred.ExpiresAt); err != nil {
+				return nil, err
+			}
+		}
+		issuer, err := s.getIssuer(ctx, cred.IssuerID)
+		if err != nil {
+			return nil, err
+		}
+		if issuer == nil {
+			err = flag("IssuerUnregistered", "issuer "+cred.IssuerID+" not in registry")
+		} else if issuer.Status == "Deactivated" {
+			err = flag("IssuerDeactivated", "issuer deactivated at "+issuer.DeactivatedAt)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if ct == nil {
+			if err := flag("CredTypeUnregistered", "credential type "+credType+" not in registry"); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(res.Findings) > 0 {
+		if err := emitEvent(ctx, "ComplianceFinding", complianceEvent{Findings: res.Findings}); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// GetComplianceFindings returns all findings recorded for a credential.
+func (s *AuditContract) GetComplianceFindings(ctx contractapi.TransactionContextInterface,
+	credID string) ([]ComplianceFinding, error) {
+
+	iter, err := ctx.GetStub().GetStateByPartialCompositeKey("finding~cred", []string{credID})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var findings []ComplianceFinding
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		var f ComplianceFinding
+		if err := json.Unmarshal(kv.Value, &f); err != nil {
+			return nil, err
+		}
+		findings = append(findings, f)
+	}
+	return findings, nil
+}
+
+// GetDPACoverage lists every registered verifier with the state of its DPA.
+// Agreements ending within 30 days are reported as Expiring.
+func (s *AuditContract) GetDPACoverage(ctx contractapi.TransactionContextInterface) ([]DPACoverage, error) {
+	iter, err := ctx.GetStub().GetStateByRange(verifierKey(""), verifierKey("")+"\xff")
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	coverage := []DPACoverage{}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		var v Verifier
+		if err := json.Unmarshal(kv.Value, &v); err != nil {
+			return nil, err
+		}
+		c := DPACoverage{
+			VerifierID:   v.VerifierID,
+			MSPID:        v.MSPID,
+			DPAHash:      v.DPAHash,
+			DPAExpiresAt: v.DPAExpiresAt,
+		}
+		c.Status, c.DaysRemaining = v.dpaStatus()
+		coverage = append(coverage, c)
+	}
+	return coverage, nil
+}
+
+// ===== Helpers =====
+
+// dpaStatus classifies the verifier's DPA as Covered, Expiring (ending
+// within 30 days) or Expired, with whole days remaining.
+func (v *Verifier) dpaStatus() (string, int) {
+	exp, err := time.Parse(time.RFC3339, v.DPAExpiresAt)
+	if err != nil {
+		return "Expired", 0
+	}
+	left := time.Until(exp)
+	days := int(left.Hours() / 24)
+	switch {
+	case v.dpaExpired():
+		return "Expired", days
+	case left < dpaExpiringDays*24*time.Hour:
+		return "Expiring", days
+	}
+	return "Covered", days
+}
+
+// sweepFinding builds a RunComplianceSweep finding, one per rule and
+// credential.
+func sweepFinding(credID, credType, issuerID, rule, detail string) ComplianceFinding {
+	return ComplianceFinding{
+		FindingID:  rule + ":" + credID,
+		CredID:     credID,
+		CredType:   credType,
+		IssuerID:   issuerID,
+		Rule:       rule,
+		Detail:     detail,
+		DetectedAt: nowRFC3339(),
+	}
+}
+
+// recordFinding stores a finding and adds it to the sweep's page.
+func (s *ledger) recordFinding(ctx contractapi.TransactionContextInterface, res *SweepResult, f ComplianceFinding) error {
+	res.Findings = append(res.Findings, f)
+	return s.putFinding(ctx, &f)
+}
+
+func (s *ledger) putFinding(ctx contractapi.TransactionContextInterface, f *ComplianceFinding) error {
+	ck, err := compositeKey(ctx, "finding~cred", []string{f.CredID, f.FindingID})
+	if err != nil {
+		return err
+	}
+	bz, _ := json.Marshal(f)
+	return ctx.GetStub().PutState(ck, bz)
+}
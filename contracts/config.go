@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Defaults applied when a ContractConfig field is left zero.
+const (
+	defaultMaxCredentialBytes = 8 * 1024
+	defaultMaxEventBytes      = 4 * 1024
+)
+
+// ContractConfig holds channel-wide tunables managed by admins.
+type ContractConfig struct {
+	MaxCredentialBytes int `json:"maxCredentialBytes"` // serialized Credential size limit
+	MaxEventBytes      int `json:"maxEventBytes"`      // serialized AccessEvent size limit
+	// VerifySummarySeconds turns on verify sampling: successful checks of
+	// active credentials are folded into one VerifySummary event per
+	// credential per bucket of this length. 0 records every Verify.
+	VerifySummarySeconds int `json:"verifySummarySeconds"`
+	// StateCodec selects how new credentials and events are stored: "json"
+	// (default) or "proto". Existing records are read in either encoding.
+	StateCodec string `json:"stateCodec"`
+	// DIDMethods restricts holder DIDs to these methods, e.g. ["key", "web"].
+	// Empty allows any syntactically valid DID.
+	DIDMethods []string `json:"didMethods"`
+	// GovernanceOrgs are the MSPs that vote on proposals; once set, they can
+	// only be changed by an executed proposal.
+	GovernanceOrgs   []string `json:"governanceOrgs"`
+	GovernanceQuorum int      `json:"governanceQuorum"` // approvals needed; 0 means a simple majority
+	// RequireWalletAttestation denies verifications whose wallet did not
+	// present a Valid device attestation (see wallet.go).
+	RequireWalletAttestation bool `json:"requireWalletAttestation"`
+	// AllowUnregisteredVerifiers lets verifiers missing from the registry
+	// check credentials without a jurisdiction. Off by default:
+	// unregistered verifiers are denied (see verifier.go).
+	AllowUnregisteredVerifiers bool `json:"allowUnregisteredVerifiers,omitempty"`
+	// BreakGlassCodes are the justification codes BreakGlassVerify accepts,
+	// e.g. ["MEDICAL_EMERGENCY"]. Empty disables break-glass access.
+	BreakGlassCodes []string `json:"breakGlassCodes"`
+	// PseudonymEpochDays turns on holder pseudonyms (see pseudonym.go):
+	// events name the holder by a pseudonym that rotates this often. It
+	// cannot be changed once set, since holder reads re-derive every past
+	// epoch's pseudonym; PseudonymsSince records when it was set.
+	PseudonymEpochDays int    `json:"pseudonymEpochDays"`
+	PseudonymsSince    string `json:"pseudonymsSince,omitempty"` // RFC3339, set by the chaincode
+	// HolderTypes sets validation, consent and retention rules per holder
+	// type (Individual | Organization); see holdertype.go.
+	HolderTypes map[string]HolderTypeRules `json:"holderTypes,omitempty"`
+	// HolderIndexBuckets spreads the events of hot holders, by DID, over
+	// this many event~holder prefixes; counts may grow but not shrink. See
+	// buckets.go.
+	HolderIndexBuckets map[string]int `json:"holderIndexBuckets,omitempty"`
+	// RevocationSources are the channels ImportRevocation accepts
+	// revocations from, each with the base64url Ed25519 public key its
+	// relay signs assertions with (the "x" of the relay's JWK). See
+	// revocation.go.
+	RevocationSources map[string]string `json:"revocationSources,omitempty"`
+	// LenientDecoding reads credentials and events with fields this
+	// chaincode version does not know instead of rejecting them, e.g. after
+	// rolling back an upgrade that added one. See strict.go.
+	LenientDecoding bool   `json:"lenientDecoding,omitempty"`
+	UpdatedBy       string `json:"updatedBy"` // MSP ID of the admin
+	UpdatedAt       string `json:"updatedAt"` // RFC3339
+}
+
+// SizeLimitError is returned when a record would exceed its configured size.
+type SizeLimitError struct {
+	Record string
+	Size   int
+	Limit  int
+}
+
+func (e *SizeLimitError) Error() string {
+	return fmt.Sprintf("%s is %d bytes, exceeding the %d byte limit", e.Record, e.Size, e.Limit)
+}
+
+// SetConfig replaces the contract configuration.
+func (s *AdminContract) SetConfig(ctx contractapi.TransactionContextInterface,
+	cfg ContractConfig) error {
+
+	if err := requireAdmin(ctx); err != nil {
+		return err
+	}
+	current, err := loadConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if len(current.GovernanceOrgs) > 0 && !sameGovernance(current, &cfg) {
+		return fmt.Errorf("governance membership changes require an executed proposal")
+	}
+	return putConfig(ctx, cfg)
+}
+
+// GetConfig returns the effective configuration, defaults included.
+func (s *AdminContract) GetConfig(ctx contractapi.TransactionContextInterface) (*ContractConfig, error) {
+	return loadConfig(ctx)
+}
+
+// ===== Helpers =====
+
+const configKey = "config"
+
+func putConfig(ctx contractapi.TransactionContextInterface, cfg ContractConfig) error {
+	if cfg.MaxCredentialBytes < 0 || cfg.MaxEventBytes < 0 {
+		return fmt.Errorf("size limits must not be negative")
+	}
+	if cfg.VerifySummarySeconds < 0 {
+		return fmt.Errorf("verifySummarySeconds must not be negative")
+	}
+	if cfg.StateCodec != "" && cfg.StateCodec != "json" && cfg.StateCodec != "proto" {
+		return fmt.Errorf("stateCodec must be json or proto")
+	}
+	for _, m := range cfg.DIDMethods {
+		if !didMethodName.MatchString(m) {
+			return fmt.Errorf("invalid DID method %q", m)
+		}
+	}
+	if err := validateHolderTypes(cfg.HolderTypes); err != nil {
+		return err
+	}
+	for channel, key := range cfg.RevocationSources {
+		if _, err := relayPublicKey(key); err != nil {
+			return fmt.Errorf("revocationSources %s: %v", channel, err)
+		}
+	}
+	if cfg.GovernanceQuorum < 0 || cfg.GovernanceQuorum > len(cfg.GovernanceOrgs) {
+		return fmt.Errorf("governance quorum must be between 0 and the number of governance orgs")
+	}
+	current, err := loadConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if current.PseudonymEpochDays != 0 && cfg.PseudonymEpochDays != current.PseudonymEpochDays {
+		return fmt.Errorf("pseudonymEpochDays cannot be changed once set")
+	}
+	if cfg.PseudonymEpochDays < 0 {
+		return fmt.Errorf("pseudonymEpochDays must not be negative")
+	}
+	if err := validateHolderBuckets(current.HolderIndexBuckets, cfg.HolderIndexBuckets); err != nil {
+		return err
+	}
+	cfg.PseudonymsSince = current.PseudonymsSince
+	if cfg.PseudonymEpochDays > 0 && cfg.PseudonymsSince == "" {
+		cfg.PseudonymsSince = nowRFC3339()
+	}
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return err
+	}
+	cfg.UpdatedBy = mspID
+	cfg.UpdatedAt = nowRFC3339()
+
+	bz, _ := json.Marshal(cfg)
+	return ctx.GetStub().PutState(configKey, bz)
+}
+
+func loadConfig(ctx contractapi.TransactionContextInterface) (*ContractConfig, error) {
+	cfg := &ContractConfig{}
+	bz, err := ctx.GetStub().GetState(configKey)
+	if err != nil {
+		return nil, err
+	}
+	if bz != nil {
+		if err := json.Unmarshal(bz, cfg); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.MaxCredentialBytes == 0 {
+		cfg.MaxCredentialBytes = defaultMaxCredentialBytes
+	}
+	if cfg.MaxEventBytes == 0 {
+		cfg.MaxEventBytes = defaultMaxEventBytes
+	}
+	return cfg, nil
+}
+
+// quorum returns the number of approvals a governance proposal needs.
+func (c *ContractConfig) quorum() int {
+	if c.GovernanceQuorum > 0 {
+		return c.GovernanceQuorum
+	}
+	return len(c.GovernanceOrgs)/2 + 1
+}
+
+func (c *ContractConfig) isGovernanceOrg(mspID string) bool {
+	for _, org := range c.GovernanceOrgs {
+		if org == mspID {
+			return true
+		}
+	}
+	return false
+}
+
+func sameGovernance(a, b *ContractConfig) bool {
+	if a.GovernanceQuorum != b.GovernanceQuorum || len(a.GovernanceOrgs) != len(b.GovernanceOrgs) {
+		return false
+	}
+	for i := range a.GovernanceOrgs {
+		if a.GovernanceOrgs[i] != b.GovernanceOrgs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// checkSize enforces a configured limit on a serialized record.
+func checkSize(record string, bz []byte, limit int) error {
+	if len(bz) > limit {
+		return &SizeLimitError{Record: record, Size: len(bz), Limit: limit}
+	}
+	return nil
+}
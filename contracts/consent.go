@@ -0,0 +1,212 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Consent records a holder's permission for a verifier to check their
+// credentials for a stated purpose. The latest grant or revocation per
+// verifier is kept; the trail of changes is in the holder's audit events.
+type Consent struct {
+	HolderDID  string `json:"holderDid"`
+	VerifierID string `json:"verifierId"`
+	Purpose    string `json:"purpose"`
+	Status     string `json:"status"`    // Granted | Revoked
+	ProofHash  string `json:"proofHash"` // sha256 of the holder proof for the latest change
+	GrantedAt  string `json:"grantedAt"` // RFC3339
+	RevokedAt  string `json:"revokedAt,omitempty"`
+	UpdatedAt  string `json:"updatedAt"` // RFC3339
+}
+
+// GrantConsent records consent for verifierID, replacing any earlier grant.
+// For did:key holders, holderProof must be a base64 Ed25519 signature over
+// the JSON consentMessage for the grant; for other methods the gateway
+// checks it and submits the grant (see checkHolderProof). nonce is the
+// holder's choice, fresh for every change.
+func (s *CredentialContract) GrantConsent(ctx contractapi.TransactionContextInterface,
+	holderDID, verifierID, purpose, nonce, holderProof string) error {
+
+	if verifierID == "" || purpose == "" || nonce == "" {
+		return fmt.Errorf("verifierId, purpose and nonce are required")
+	}
+	msg := consentMessage{
+		Action:     "grant",
+		HolderDID:  holderDID,
+		VerifierID: verifierID,
+		Purpose:    purpose,
+		Nonce:      nonce,
+	}
+	if err := checkHolderProof(ctx, holderDID, holderProof, string(msg.bytes())); err != nil {
+		return err
+	}
+	if err := useHolderProof(ctx, holderProof); err != nil {
+		return err
+	}
+
+	now := nowRFC3339()
+	c := &Consent{
+		HolderDID:  holderDID,
+		VerifierID: verifierID,
+		Purpose:    purpose,
+		Status:     "Granted",
+		ProofHash:  proofHash(holderProof),
+		GrantedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := putConsent(ctx, c); err != nil {
+		return err
+	}
+	return s.recordEvent(ctx, "", holderDID, "ConsentGrant", holderDID, "Success",
+		fmt.Sprintf("verifier %s: %s", verifierID, purpose))
+}
+
+// RevokeConsent withdraws a granted consent. For did:key holders the proof
+// signs the consentMessage for the revocation.
+func (s *CredentialContract) RevokeConsent(ctx contractapi.TransactionContextInterface,
+	holderDID, verifierID, nonce, holderProof string) error {
+
+	c, err := getConsent(ctx, holderDID, verifierID)
+	if err != nil {
+		return err
+	}
+	if c == nil || c.Status != "Granted" {
+		return fmt.Errorf("no granted consent from %s to %s", holderDID, verifierID)
+	}
+	if nonce == "" {
+		return fmt.Errorf("nonce is required")
+	}
+	msg := consentMessage{Action: "revoke", HolderDID: holderDID, VerifierID: verifierID, Nonce: nonce}
+	if err := checkHolderProof(ctx, holderDID, holderProof, string(msg.bytes())); err != nil {
+		return err
+	}
+	if err := useHolderProof(ctx, holderProof); err != nil {
+		return err
+	}
+
+	c.Status = "Revoked"
+	c.ProofHash = proofHash(holderProof)
+	c.RevokedAt = nowRFC3339()
+	c.UpdatedAt = c.RevokedAt
+	if err := putConsent(ctx, c); err != nil {
+		return err
+	}
+	return s.recordEvent(ctx, "", holderDID, "ConsentRevoke", holderDID, "Success", "verifier "+verifierID)
+}
+
+// GetConsents lists a holder's consents, granted and revoked.
+func (s *CredentialContract) GetConsents(ctx contractapi.TransactionContextInterface,
+	holderDID string) ([]Consent, error) {
+
+	return listConsents(ctx, holderDID)
+}
+
+// ===== Helpers =====
+
+// consentMessage is what a holder signs to grant or revoke consent, as
+// JSON in field order, e.g. {"action":"grant","holderDid":"did:key:...",
+// "verifierId":"v1","purpose":"KYC refresh","nonce":"..."}. Binding the
+// holder, purpose and a nonce means a proof cannot be moved to another
+// holder or purpose, and since each proof is accepted once (see
+// useHolderProof), an old grant cannot be replayed after a revocation.
+type consentMessage struct {
+	Action     string `json:"action"` // grant | revoke
+	HolderDID  string `json:"holderDid"`
+	VerifierID string `json:"verifierId"`
+	Purpose    string `json:"purpose,omitempty"` // grants only
+	Nonce      string `json:"nonce"`
+}
+
+func (m consentMessage) bytes() []byte {
+	bz, _ := json.Marshal(m)
+	return bz
+}
+
+// useHolderProof records holderProof as spent, rejecting one seen before.
+func useHolderProof(ctx contractapi.TransactionContextInterface, holderProof string) error {
+	key := "proofused:" + proofHash(holderProof)
+	seen, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return err
+	}
+	if seen != nil {
+		return fmt.Errorf("holder proof was already used")
+	}
+	return ctx.GetStub().PutState(key, []byte{0})
+}
+
+// checkHolderProof checks that holderProof shows control of holderDID by
+// signing msg. The chaincode verifies did:key proofs itself. Other methods
+// need DID resolution, which it cannot do, so their proofs are only
+// accepted from a caller with audittrail.role=gateway, whose certificate
+// vouches that the gateway resolved the DID and checked the proof
+// off-chain.
+func checkHolderProof(ctx contractapi.TransactionContextInterface, holderDID, holderProof, msg string) error {
+	if holderProof == "" {
+		return fmt.Errorf("holder proof is required")
+	}
+	if strings.HasPrefix(holderDID, "did:key:") {
+		return verifyDIDKeyProof(holderDID, holderProof, []byte(msg))
+	}
+	if err := ctx.GetClientIdentity().AssertAttributeValue(roleAttr, "gateway"); err != nil {
+		return fmt.Errorf("holder proofs for %s can only be checked by the gateway: %v", holderDID, err)
+	}
+	return nil
+}
+
+func proofHash(holderProof string) string {
+	sum := sha256.Sum256([]byte(holderProof))
+	return hex.EncodeToString(sum[:])
+}
+
+func listConsents(ctx contractapi.TransactionContextInterface, holderDID string) ([]Consent, error) {
+	iter, err := ctx.GetStub().GetStateByPartialCompositeKey("consent~holder", []string{holderDID})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	consents := []Consent{}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		var c Consent
+		if err := json.Unmarshal(kv.Value, &c); err != nil {
+			return nil, err
+		}
+		consents = append(consents, c)
+	}
+	return consents, nil
+}
+
+func getConsent(ctx contractapi.TransactionContextInterface, holderDID, verifierID string) (*Consent, error) {
+	ck, err := compositeKey(ctx, "consent~holder", []string{holderDID, verifierID})
+	if err != nil {
+		return nil, err
+	}
+	bz, err := ctx.GetStub().GetState(ck)
+	if err != nil || bz == nil {
+		return nil, err
+	}
+	var c Consent
+	if err := json.Unmarshal(bz, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func putConsent(ctx contractapi.TransactionContextInterface, c *Consent) error {
+	ck, err := compositeKey(ctx, "consent~holder", []string{c.HolderDID, c.VerifierID})
+	if err != nil {
+		return err
+	}
+	bz, _ := json.Marshal(c)
+	return ctx.GetStub().PutState(ck, bz)
+}
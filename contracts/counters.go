@@ -0,0 +1,100 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Counters are maintained incrementally on every credential write so status
+// pages never need to scan the ledger.
+type Counters struct {
+	Total      int64            `json:"total"`
+	ByStatus   map[string]int64 `json:"byStatus"`
+	ByCredType map[string]int64 `json:"byCredType"`
+	ByIssuer   map[string]int64 `json:"byIssuer"`
+}
+
+// GetCounters returns the current credential counters.
+func (s *AuditContract) GetCounters(ctx contractapi.TransactionContextInterface) (*Counters, error) {
+	iter, err := ctx.GetStub().GetStateByPartialCompositeKey("counter", []string{})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	c := &Counters{
+		ByStatus:   map[string]int64{},
+		ByCredType: map[string]int64{},
+		ByIssuer:   map[string]int64{},
+	}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, attrs, err := ctx.GetStub().SplitCompositeKey(kv.Key)
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.ParseInt(string(kv.Value), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		switch attrs[0] {
+		case "total":
+			c.Total = n
+		case "status":
+			c.ByStatus[attrs[1]] = n
+		case "type":
+			c.ByCredType[attrs[1]] = n
+		case "issuer":
+			c.ByIssuer[attrs[1]] = n
+		}
+	}
+	return c, nil
+}
+
+// ===== Helpers =====
+
+// countIssued bumps every counter a newly issued credential contributes to.
+func countIssued(ctx contractapi.TransactionContextInterface, cred *Credential) error {
+	for _, k := range [][2]string{
+		{"total", "all"},
+		{"status", cred.Status},
+		{"type", cred.CredType},
+		{"issuer", cred.IssuerID},
+	} {
+		if err := incrCounter(ctx, k[0], k[1], 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// countTransition moves a credential between status counters.
+func countTransition(ctx contractapi.TransactionContextInterface, from, to string) error {
+	if err := incrCounter(ctx, "status", from, -1); err != nil {
+		return err
+	}
+	return incrCounter(ctx, "status", to, 1)
+}
+
+// Each counter is its own key so unrelated counters do not contend.
+func incrCounter(ctx contractapi.TransactionContextInterface, scope, name string, delta int64) error {
+	ck, err := compositeKey(ctx, "counter", []string{scope, name})
+	if err != nil {
+		return err
+	}
+	bz, err := batchGet(ctx, ck)
+	if err != nil {
+		return err
+	}
+	var n int64
+	if bz != nil {
+		if n, err = strconv.ParseInt(string(bz), 10, 64); err != nil {
+			return err
+		}
+	}
+	return batchPut(ctx, ck, []byte(strconv.FormatInt(n+delta, 10)))
+}
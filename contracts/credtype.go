@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// CredentialType is a registry entry governing issuance of a credType.
+// Types that were never registered stay issuable so existing flows keep working.
+type CredentialType struct {
+	CredType    string `json:"credType"`
+	Description string `json:"description"`
+	// RequiresAcceptance issues credentials as PendingAcceptance until the
+	// holder proves control of the DID via AcceptCredential.
+	RequiresAcceptance bool `json:"requiresAcceptance"`
+	// RecheckAfterSeconds is how long verifiers may cache a positive result;
+	// 0 means the default (see freshness.go).
+	RecheckAfterSeconds int `json:"recheckAfterSeconds,omitempty"`
+	// Validity is the ISO 8601 duration credentials of the type are valid
+	// for, by default and at most; empty means no policy (see validity.go).
+	Validity  string `json:"validity,omitempty"`
+	Status    string `json:"status"`    // Active | Deprecated | Sunset
+	CreatedAt string `json:"createdAt"` // RFC3339
+	UpdatedAt string `json:"updatedAt"` // RFC3339
+}
+
+// CredTypeEvent records a registry lifecycle transition for governance audits.
+type CredTypeEvent struct {
+	EventID    string `json:"eventId"`
+	CredType   string `json:"credType"`
+	Action     string `json:"action"` // Register | Deprecate | Sunset | RecheckPolicy | ValidityPolicy
+	ActorID    string `json:"actorId"`
+	Reason     string `json:"reason"`     // optional
+	OccurredAt string `json:"occurredAt"` // RFC3339
+}
+
+// RegisterCredType adds a credType to the registry in Active state. Registry
+// writes are admin only.
+func (s *RegistryContract) RegisterCredType(ctx contractapi.TransactionContextInterface,
+	credType, description string, requiresAcceptance bool, actorID string) error {
+
+	if err := requireAdmin(ctx); err != nil {
+		return err
+	}
+	existing, err := s.getCredType(ctx, credType)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("credential type %s already registered", credType)
+	}
+
+	now := nowRFC3339()
+	ct := &CredentialType{
+		CredType:           credType,
+		Description:        description,
+		RequiresAcceptance: requiresAcceptance,
+		Status:             "Active",
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+	if err := s.putCredType(ctx, ct); err != nil {
+		return err
+	}
+	return s.recordCredTypeEvent(ctx, credType, "Register", actorID, "")
+}
+
+// DeprecateCredType keeps a type issuable but flags new issuances with a warning.
+func (s *RegistryContract) DeprecateCredType(ctx contractapi.TransactionContextInterface,
+	credType, reason, actorID string) error {
+
+	if err := requireAdmin(ctx); err != nil {
+		return err
+	}
+	ct, err := s.mustGetCredType(ctx, credType)
+	if err != nil {
+		return err
+	}
+	if ct.Status != "Active" {
+		return fmt.Errorf("credential type %s is %s, cannot deprecate", credType, ct.Status)
+	}
+
+	ct.Status = "Deprecated"
+	ct.UpdatedAt = nowRFC3339()
+	if err := s.putCredType(ctx, ct); err != nil {
+		return err
+	}
+	return s.recordCredTypeEvent(ctx, credType, "Deprecate", actorID, reason)
+}
+
+// SunsetCredType rejects new issuance; existing credentials remain verifiable.
+func (s *RegistryContract) SunsetCredType(ctx contractapi.TransactionContextInterface,
+	credType, reason, actorID string) error {
+
+	if err := requireAdmin(ctx); err != nil {
+		return err
+	}
+	ct, err := s.mustGetCredType(ctx, credType)
+	if err != nil {
+		return err
+	}
+	if ct.Status == "Sunset" {
+		return fmt.Errorf("credential type %s is already sunset", credType)
+	}
+
+	ct.Status = "Sunset"
+	ct.UpdatedAt = nowRFC3339()
+	if err := s.putCredType(ctx, ct); err != nil {
+		return err
+	}
+	return s.recordCredTypeEvent(ctx, credType, "Sunset", actorID, reason)
+}
+
+// GetCredType returns the registry entry for a credType.
+func (s *RegistryContract) GetCredType(ctx contractapi.TransactionContextInterface,
+	credType string) (*CredentialType, error) {
+
+	return s.mustGetCredType(ctx, credType)
+}
+
+// GetCredTypeHistory returns the lifecycle events recorded for a credType.
+func (s *RegistryContract) GetCredTypeHistory(ctx contractapi.TransactionContextInterface,
+	credType string) ([]CredTypeEvent, error) {
+
+	iter, err := ctx.GetStub().GetStateByPartialCompositeKey("credtype~event", []string{credType})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var events []CredTypeEvent
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		var evt CredTypeEvent
+		if err := json.Unmarshal(kv.Value, &evt); err != nil {
+			return nil, err
+		}
+		events = append(events, evt)
+	}
+	return events, nil
+}
+
+// ===== Helpers =====
+
+// getCredType returns nil without error when the type was never registered.
+func (s *ledger) getCredType(ctx contractapi.TransactionContextInterface, credType string) (*CredentialType, error) {
+	bz, err := ctx.GetStub().GetState(credTypeKey(credType))
+	if err != nil {
+		return nil, err
+	}
+	if bz == nil {
+		return nil, nil
+	}
+	var ct CredentialType
+	if err := json.Unmarshal(bz, &ct); err != nil {
+		return nil, err
+	}
+	return &ct, nil
+}
+
+func (s *ledger) mustGetCredType(ctx contractapi.TransactionContextInterface, credType string) (*CredentialType, error) {
+	ct, err := s.getCredType(ctx, credType)
+	if err != nil {
+		return nil, err
+	}
+	if ct == nil {
+		return nil, fmt.Errorf("credential type %s not registered", credType)
+	}
+	return ct, nil
+}
+
+func (s *ledger) putCredType(ctx contractapi.TransactionContextInterface, ct *CredentialType) error {
+	bz, _ := json.Marshal(ct)
+	return ctx.GetStub().PutState(credTypeKey(ct.CredType), bz)
+}
+
+func (s *ledger) recordCredTypeEvent(ctx contractapi.TransactionContextInterface,
+	credType, action, actorID, reason string) error {
+
+	evt := CredTypeEvent{
+		EventID:    newEventID(),
+		CredType:   credType,
+		Action:     action,
+		ActorID:    actorID,
+		Reason:     reason,
+		OccurredAt: nowRFC3339(),
+	}
+	bz, _ := json.Marshal(evt)
+
+	ck, err := compositeKey(ctx, "credtype~event", []string{credType, evt.EventID})
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutState(ck, bz); err != nil {
+		return err
+	}
+	return emitEvent(ctx, "CredTypeLifecycle", evt)
+}
+
+func credTypeKey(credType string) string { return "credtype:" + credType }
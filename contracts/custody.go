@@ -0,0 +1,148 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// CustodyRecord is one hand-over of the physical artifact behind a
+// credential (e.g. a notarized original). Records for a credential form a
+// hash chain: PrevHash is the sha256 of the previous record's canonical
+// JSON, so a removed or altered hand-over is detectable off-chain.
+type CustodyRecord struct {
+	CredID       string `json:"credId"`
+	Seq          int    `json:"seq"` // 1 for the first hand-over
+	FromParty    string `json:"fromParty"`
+	ToParty      string `json:"toParty"`
+	LocationHash string `json:"locationHash"` // hex sha256; the location itself stays off-chain
+	PrevHash     string `json:"prevHash"`     // empty for Seq 1
+	RecordedBy   string `json:"recordedBy"`   // submitting MSP ID
+	TxID         string `json:"txId"`
+	RecordedAt   string `json:"recordedAt"` // RFC3339
+}
+
+// RecordCustodyTransfer appends a hand-over from fromParty to toParty.
+// fromParty must be the current custodian once the chain has started.
+func (s *CredentialContract) RecordCustodyTransfer(ctx contractapi.TransactionContextInterface,
+	credID, fromParty, toParty, locationHash string) (*CustodyRecord, error) {
+
+	if fromParty == "" || toParty == "" {
+		return nil, fmt.Errorf("fromParty and toParty are required")
+	}
+	if fromParty == toParty {
+		return nil, fmt.Errorf("fromParty and toParty must differ")
+	}
+	if raw, err := hex.DecodeString(locationHash); err != nil || len(raw) != sha256.Size {
+		return nil, fmt.Errorf("locationHash must be a hex sha256 digest")
+	}
+	cred, err := s.getCred(ctx, credID)
+	if err != nil {
+		return nil, err
+	}
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, err
+	}
+
+	chain, err := listCustody(ctx, credID)
+	if err != nil {
+		return nil, err
+	}
+	rec := &CustodyRecord{
+		CredID:       credID,
+		Seq:          len(chain) + 1,
+		FromParty:    fromParty,
+		ToParty:      toParty,
+		LocationHash: locationHash,
+		RecordedBy:   mspID,
+		TxID:         ctx.GetStub().GetTxID(),
+		RecordedAt:   nowRFC3339(),
+	}
+	if len(chain) > 0 {
+		last := chain[len(chain)-1]
+		if last.ToParty != fromParty {
+			return nil, fmt.Errorf("credential %s artifact is held by %s, not %s", credID, last.ToParty, fromParty)
+		}
+		if rec.PrevHash, err = custodyHash(&last); err != nil {
+			return nil, err
+		}
+	}
+
+	ck, err := custodyKey(ctx, credID, rec.Seq)
+	if err != nil {
+		return nil, err
+	}
+	bz, _ := json.Marshal(rec)
+	if err := ctx.GetStub().PutState(ck, bz); err != nil {
+		return nil, err
+	}
+	reason := fmt.Sprintf("custody #%d %s -> %s", rec.Seq, fromParty, toParty)
+	if err := s.recordEvent(ctx, credID, cred.HolderDID, "CustodyTransfer", mspID, "Success", reason); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// GetCustodyChain returns every hand-over of a credential's artifact, oldest first.
+func (s *CredentialContract) GetCustodyChain(ctx contractapi.TransactionContextInterface,
+	credID string) ([]CustodyRecord, error) {
+
+	return listCustody(ctx, credID)
+}
+
+// GetCurrentCustodian returns the latest hand-over; its ToParty holds the
+// artifact.
+func (s *CredentialContract) GetCurrentCustodian(ctx contractapi.TransactionContextInterface,
+	credID string) (*CustodyRecord, error) {
+
+	chain, err := listCustody(ctx, credID)
+	if err != nil {
+		return nil, err
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("no custody recorded for credential %s", credID)
+	}
+	return &chain[len(chain)-1], nil
+}
+
+// ===== Helpers =====
+
+// custodyKey zero-pads Seq so key order is chain order.
+func custodyKey(ctx contractapi.TransactionContextInterface, credID string, seq int) (string, error) {
+	return compositeKey(ctx, "custody~cred", []string{credID, fmt.Sprintf("%08d", seq)})
+}
+
+func custodyHash(rec *CustodyRecord) (string, error) {
+	bz, err := canonicalJSON(rec)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(bz)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func listCustody(ctx contractapi.TransactionContextInterface, credID string) ([]CustodyRecord, error) {
+	iter, err := ctx.GetStub().GetStateByPartialCompositeKey("custody~cred", []string{credID})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	chain := []CustodyRecord{}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		var rec CustodyRecord
+		if err := json.Unmarshal(kv.Value, &rec); err != nil {
+			return nil, err
+		}
+		chain = append(chain, rec)
+	}
+	return chain, nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// RevocationDelegation lets another org (typically a regulator) revoke a
+// scoped subset of an issuer's credentials.
+type RevocationDelegation struct {
+	IssuerID    string   `json:"issuerId"`
+	DelegateMSP string   `json:"delegateMsp"`
+	CredTypes   []string `json:"credTypes"`  // empty means any type
+	CredIDFrom  string   `json:"credIdFrom"` // inclusive, lexicographic; empty means unbounded
+	CredIDTo    string   `json:"credIdTo"`   // inclusive, lexicographic; empty means unbounded
+	Status      string   `json:"status"`     // Active | Withdrawn
+	GrantedAt   string   `json:"grantedAt"`  // RFC3339
+	UpdatedAt   string   `json:"updatedAt"`  // RFC3339
+}
+
+// DelegateRevocation grants delegateMSP revocation rights over the issuer's
+// credentials matching the scope, replacing any earlier grant to that org.
+// At least one of credTypes or the credID range must be given.
+func (s *CredentialContract) DelegateRevocation(ctx contractapi.TransactionContextInterface,
+	issuerID, delegateMSP string, credTypes []string, credIDFrom, credIDTo string) error {
+
+	if _, err := s.requireRegisteredIssuerMSP(ctx, issuerID); err != nil {
+		return err
+	}
+	if delegateMSP == "" {
+		return fmt.Errorf("delegateMSP is required")
+	}
+	if len(credTypes) == 0 && credIDFrom == "" && credIDTo == "" {
+		return fmt.Errorf("delegation scope must name credTypes or a credID range")
+	}
+	if credIDFrom != "" && credIDTo != "" && credIDFrom > credIDTo {
+		return fmt.Errorf("credIdFrom must not sort after credIdTo")
+	}
+
+	d := &RevocationDelegation{
+		IssuerID:    issuerID,
+		DelegateMSP: delegateMSP,
+		CredTypes:   credTypes,
+		CredIDFrom:  credIDFrom,
+		CredIDTo:    credIDTo,
+		Status:      "Active",
+		GrantedAt:   nowRFC3339(),
+		UpdatedAt:   nowRFC3339(),
+	}
+	return putDelegation(ctx, d)
+}
+
+// WithdrawDelegation ends a delegate's revocation rights.
+func (s *CredentialContract) WithdrawDelegation(ctx contractapi.TransactionContextInterface,
+	issuerID, delegateMSP string) error {
+
+	if _, err := s.requireRegisteredIssuerMSP(ctx, issuerID); err != nil {
+		return err
+	}
+	d, err := getDelegation(ctx, issuerID, delegateMSP)
+	if err != nil {
+		return err
+	}
+	if d == nil || d.Status != "Active" {
+		return fmt.Errorf("no active delegation from %s to %s", issuerID, delegateMSP)
+	}
+	d.Status = "Withdrawn"
+	d.UpdatedAt = nowRFC3339()
+	return putDelegation(ctx, d)
+}
+
+// GetDelegations lists every revocation delegation an issuer has granted.
+func (s *CredentialContract) GetDelegations(ctx contractapi.TransactionContextInterface,
+	issuerID string) ([]RevocationDelegation, error) {
+
+	iter, err := ctx.GetStub().GetStateByPartialCompositeKey("delegation~issuer", []string{issuerID})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	delegations := []RevocationDelegation{}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		var d RevocationDelegation
+		if err := json.Unmarshal(kv.Value, &d); err != nil {
+			return nil, err
+		}
+		delegations = append(delegations, d)
+	}
+	return delegations, nil
+}
+
+// ===== Helpers =====
+
+// revocationAuthority checks the caller may revoke cred. It returns the
+// delegate MSP when the right comes from a delegation, "" otherwise.
+// Credentials of unregistered issuers predate the registry and stay open.
+func (s *ledger) revocationAuthority(ctx contractapi.TransactionContextInterface,
+	cred *Credential) (string, error) {
+
+	issuer, err := s.getIssuer(ctx, cred.IssuerID)
+	if err != nil || issuer == nil {
+		return "", err
+	}
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", err
+	}
+	if mspID == issuer.MSPID {
+		return "", nil
+	}
+	d, err := getDelegation(ctx, cred.IssuerID, mspID)
+	if err != nil {
+		return "", err
+	}
+	if d == nil || d.Status != "Active" || !d.covers(cred) {
+		return "", fmt.Errorf("%s may not revoke credential %s of issuer %s", mspID, cred.CredID, cred.IssuerID)
+	}
+	return mspID, nil
+}
+
+func (d *RevocationDelegation) covers(cred *Credential) bool {
+	if len(d.CredTypes) > 0 {
+		found := false
+		for _, t := range d.CredTypes {
+			if t == cred.CredType {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if d.CredIDFrom != "" && cred.CredID < d.CredIDFrom {
+		return false
+	}
+	if d.CredIDTo != "" && cred.CredID > d.CredIDTo {
+		return false
+	}
+	return true
+}
+
+// requireRegisteredIssuerMSP is requireIssuerMSP for operations that only
+// make sense once the issuer is in the registry.
+func (s *ledger) requireRegisteredIssuerMSP(ctx contractapi.TransactionContextInterface,
+	issuerID string) (string, error) {
+
+	issuer, err := s.getIssuer(ctx, issuerID)
+	if err != nil {
+		return "", err
+	}
+	if issuer == nil {
+		return "", fmt.Errorf("issuer %s not registered", issuerID)
+	}
+	return s.requireIssuerMSP(ctx, issuerID)
+}
+
+func getDelegation(ctx contractapi.TransactionContextInterface,
+	issuerID, delegateMSP string) (*RevocationDelegation, error) {
+
+	ck, err := compositeKey(ctx, "delegation~issuer", []string{issuerID, delegateMSP})
+	if err != nil {
+		return nil, err
+	}
+	bz, err := ctx.GetStub().GetState(ck)
+	if err != nil || bz == nil {
+		return nil, err
+	}
+	var d RevocationDelegation
+	if err := json.Unmarshal(bz, &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func putDelegation(ctx contractapi.TransactionContextInterface, d *RevocationDelegation) error {
+	ck, err := compositeKey(ctx, "delegation~issuer", []string{d.IssuerID, d.DelegateMSP})
+	if err != nil {
+		return err
+	}
+	bz, _ := json.Marshal(d)
+	return ctx.GetStub().PutState(ck, bz)
+}
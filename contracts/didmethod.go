@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// didMethodValidators check the method-specific part of a holder DID. They
+// are syntactic only: resolving a DID needs network access, which happens in
+// the gateway before submission. Add a method by registering it here.
+var didMethodValidators = map[string]func(id string) error{
+	"key":  validateDIDKeyID,
+	"web":  validateDIDWebID,
+	"ebsi": validateDIDEBSIID,
+	"indy": validateDIDIndyID,
+}
+
+// Generic DID syntax (W3C DID Core §3.1).
+var (
+	didMethodName = regexp.MustCompile(`^[a-z0-9]+$`)
+	didSyntax     = regexp.MustCompile(`^did:([a-z0-9]+):((?:[A-Za-z0-9._-]|%[0-9A-Fa-f]{2}|:)*(?:[A-Za-z0-9._-]|%[0-9A-Fa-f]{2}))$`)
+	webSegment    = regexp.MustCompile(`^(?:[A-Za-z0-9._-]|%[0-9A-Fa-f]{2})+$`)
+	indyNamespace = regexp.MustCompile(`^[a-z0-9_-]+$`)
+)
+
+// validateHolderDID checks generic DID syntax, the method allow-list from
+// config (empty allows any method) and, for registered methods, their
+// method-specific syntax.
+func validateHolderDID(cfg *ContractConfig, did string) error {
+	m := didSyntax.FindStringSubmatch(did)
+	if m == nil {
+		return fmt.Errorf("holder DID %q is not a valid DID", did)
+	}
+	method, id := m[1], m[2]
+	if len(cfg.DIDMethods) > 0 {
+		allowed := false
+		for _, am := range cfg.DIDMethods {
+			allowed = allowed || am == method
+		}
+		if !allowed {
+			return fmt.Errorf("DID method %s is not allowed on this channel", method)
+		}
+	}
+	if validate, ok := didMethodValidators[method]; ok {
+		if err := validate(id); err != nil {
+			return fmt.Errorf("holder DID %q: %v", did, err)
+		}
+	}
+	return nil
+}
+
+// did:key:z<base58btc multicodec key>
+func validateDIDKeyID(id string) error {
+	if !strings.HasPrefix(id, "z") {
+		return fmt.Errorf("did:key must use base58btc multibase")
+	}
+	raw, err := base58Decode(id[1:])
+	if err != nil {
+		return err
+	}
+	if len(raw) < 3 {
+		return fmt.Errorf("did:key is too short")
+	}
+	return nil
+}
+
+// did:web:<host>[%3A<port>][:<path>...]
+func validateDIDWebID(id string) error {
+	for i, seg := range strings.Split(id, ":") {
+		if !webSegment.MatchString(seg) {
+			return fmt.Errorf("did:web segment %d is empty or malformed", i)
+		}
+	}
+	host := strings.SplitN(strings.Split(id, ":")[0], "%3A", 2)[0]
+	if !strings.Contains(host, ".") && host != "localhost" {
+		return fmt.Errorf("did:web host %q is not a domain name", host)
+	}
+	return nil
+}
+
+// did:ebsi:z<base58btc of version 0x01 || 16 random bytes> (legal entities;
+// natural persons use did:key).
+func validateDIDEBSIID(id string) error {
+	if !strings.HasPrefix(id, "z") {
+		return fmt.Errorf("did:ebsi must use base58btc multibase")
+	}
+	raw, err := base58Decode(id[1:])
+	if err != nil {
+		return err
+	}
+	if len(raw) != 17 || raw[0] != 0x01 {
+		return fmt.Errorf("did:ebsi must encode version 1 and 16 bytes")
+	}
+	return nil
+}
+
+// did:indy:<namespace>[:<sub-namespace>]:<base58 of 16 bytes>
+func validateDIDIndyID(id string) error {
+	parts := strings.Split(id, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return fmt.Errorf("did:indy must be <namespace>[:<sub-namespace>]:<identifier>")
+	}
+	for _, ns := range parts[:len(parts)-1] {
+		if !indyNamespace.MatchString(ns) {
+			return fmt.Errorf("did:indy namespace %q is malformed", ns)
+		}
+	}
+	raw, err := base58Decode(parts[len(parts)-1])
+	if err != nil {
+		return err
+	}
+	if len(raw) != 16 {
+		return fmt.Errorf("did:indy identifier must encode 16 bytes")
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Credentials with an expiry are indexed under cred~expiry [issuerID, day,
+// credID], day being the UTC date of ExpiresAt, so an issuer's upcoming
+// expiries are a handful of prefix scans rather than a walk of everything
+// it ever issued. Entries are not removed on revocation or expiry; queries
+// check the credential itself.
+
+const maxExpiryWindowDays = 366
+
+// GetCredentialsExpiringSoon returns issuerID's active credentials expiring
+// within the next days days, soonest first, so issuers can prompt holders
+// to renew.
+func (s *CredentialContract) GetCredentialsExpiringSoon(ctx contractapi.TransactionContextInterface,
+	issuerID string, days int) ([]Credential, error) {
+
+	if issuerID == "" {
+		return nil, fmt.Errorf("issuerId is required")
+	}
+	if days < 1 || days > maxExpiryWindowDays {
+		return nil, fmt.Errorf("days must be between 1 and %d", maxExpiryWindowDays)
+	}
+
+	now := time.Now().UTC()
+	until := now.AddDate(0, 0, days)
+	creds := []Credential{}
+	for day := now; !day.After(until); day = day.AddDate(0, 0, 1) {
+		iter, err := ctx.GetStub().GetStateByPartialCompositeKey("cred~expiry",
+			[]string{issuerID, day.Format(time.DateOnly)})
+		if err != nil {
+			return nil, err
+		}
+		for iter.HasNext() {
+			kv, err := iter.Next()
+			if err != nil {
+				iter.Close()
+				return nil, err
+			}
+			_, attrs, err := ctx.GetStub().SplitCompositeKey(kv.Key)
+			if err != nil {
+				iter.Close()
+				return nil, err
+			}
+			cred, err := s.getCred(ctx, attrs[2])
+			if err != nil {
+				iter.Close()
+				return nil, err
+			}
+			exp, err := time.Parse(time.RFC3339, cred.ExpiresAt)
+			if err != nil || cred.Status != "Active" || cred.IssuerID != issuerID {
+				continue
+			}
+			if exp.After(now) && !exp.After(until) {
+				creds = append(creds, *cred)
+			}
+		}
+		iter.Close()
+	}
+	sort.SliceStable(creds, func(i, j int) bool { return creds[i].ExpiresAt < creds[j].ExpiresAt })
+	return creds, nil
+}
+
+// ===== Helpers =====
+
+// expiryBucket is the cred~expiry day for an RFC3339 expiry, or "" when the
+// credential does not expire.
+func expiryBucket(expiresAt string) string {
+	exp, err := time.Parse(time.RFC3339, expiresAt)
+	if err != nil {
+		return ""
+	}
+	return exp.UTC().Format(time.DateOnly)
+}
+
+func putExpiryIndex(ctx contractapi.TransactionContextInterface, cred *Credential) error {
+	day := expiryBucket(cred.ExpiresAt)
+	if day == "" {
+		return nil
+	}
+	return putIndexKey(ctx, "cred~expiry", cred.IssuerID, day, cred.CredID)
+}
+
+// moveExpiryIndex re-indexes cred after its issuer or expiry changed from
+// oldIssuerID / oldExpiresAt.
+func moveExpiryIndex(ctx contractapi.TransactionContextInterface, cred *Credential, oldIssuerID, oldExpiresAt string) error {
+	if day := expiryBucket(oldExpiresAt); day != "" {
+		ck, err := ctx.GetStub().CreateCompositeKey("cred~expiry", []string{oldIssuerID, day, cred.CredID})
+		if err != nil {
+			return err
+		}
+		if err := ctx.GetStub().DelState(ck); err != nil {
+			return err
+		}
+	}
+	return putExpiryIndex(ctx, cred)
+}
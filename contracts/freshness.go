@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Positive verification results tell relying parties how long they may be
+// cached: until RecommendedRecheckAfter, which is the credential type's
+// recheck interval after the check, capped at the credential's expiry.
+// Negative results carry no recommendation; they should not be cached.
+
+const defaultRecheckSeconds = 3600
+
+// SetCredTypeRecheckPolicy sets how long verifiers may cache a positive
+// result for credType. 0 restores the default of one hour. Admin only.
+func (s *RegistryContract) SetCredTypeRecheckPolicy(ctx contractapi.TransactionContextInterface,
+	credType string, recheckAfterSeconds int, actorID string) error {
+
+	if err := requireAdmin(ctx); err != nil {
+		return err
+	}
+	if recheckAfterSeconds < 0 {
+		return fmt.Errorf("recheckAfterSeconds must not be negative")
+	}
+	ct, err := s.mustGetCredType(ctx, credType)
+	if err != nil {
+		return err
+	}
+
+	ct.RecheckAfterSeconds = recheckAfterSeconds
+	ct.UpdatedAt = nowRFC3339()
+	if err := s.putCredType(ctx, ct); err != nil {
+		return err
+	}
+	return s.recordCredTypeEvent(ctx, credType, "RecheckPolicy", actorID,
+		"recheckAfterSeconds="+strconv.Itoa(recheckAfterSeconds))
+}
+
+// ===== Helpers =====
+
+// setFreshness fills RecommendedRecheckAfter on a positive result.
+func (s *ledger) setFreshness(ctx contractapi.TransactionContextInterface,
+	res *VerificationResult, cred *Credential) error {
+
+	if !res.IsActive || !res.HashMatches || res.Denied {
+		return nil
+	}
+	recheck := defaultRecheckSeconds
+	ct, err := s.getCredType(ctx, cred.CredType)
+	if err != nil {
+		return err
+	}
+	if ct != nil && ct.RecheckAfterSeconds > 0 {
+		recheck = ct.RecheckAfterSeconds
+	}
+
+	checked, err := time.Parse(time.RFC3339, res.CheckedAt)
+	if err != nil {
+		return err
+	}
+	until := checked.Add(time.Duration(recheck) * time.Second)
+	if exp, err := time.Parse(time.RFC3339, cred.ExpiresAt); err == nil && exp.Before(until) {
+		until = exp
+	}
+	res.RecommendedRecheckAfter = until.UTC().Format(time.RFC3339)
+	return nil
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Proposal is a consortium decision put to the governance orgs. The proposal
+// record itself, votes included, is the audit trail of the decision.
+type Proposal struct {
+	ProposalID string           `json:"proposalId"`
+	Kind       string           `json:"kind"`    // RegisterIssuer | DeactivateIssuer | SetConfig
+	Payload    string           `json:"payload"` // kind-specific JSON, see applyProposal
+	ProposedBy string           `json:"proposedBy"`
+	Status     string           `json:"status"` // Open | Approved | Rejected | Executed
+	Votes      []GovernanceVote `json:"votes"`
+	CreatedAt  string           `json:"createdAt"` // RFC3339
+	ExecutedAt string           `json:"executedAt,omitempty"`
+	ExecTxID   string           `json:"execTxId,omitempty"`
+}
+
+// GovernanceVote is one org's ballot on a proposal.
+type GovernanceVote struct {
+	Org     string `json:"org"` // MSP ID
+	Approve bool   `json:"approve"`
+	TxID    string `json:"txId"`
+	VotedAt string `json:"votedAt"` // RFC3339
+}
+
+// ProposeConfigChange opens a proposal. Only governance orgs may propose.
+func (s *AdminContract) ProposeConfigChange(ctx contractapi.TransactionContextInterface,
+	proposalID, kind, payload string) error {
+
+	mspID, _, err := governanceCaller(ctx)
+	if err != nil {
+		return err
+	}
+	if err := validateProposal(kind, payload); err != nil {
+		return err
+	}
+	existing, err := ctx.GetStub().GetState(proposalKey(proposalID))
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("proposal %s already exists", proposalID)
+	}
+
+	p := &Proposal{
+		ProposalID: proposalID,
+		Kind:       kind,
+		Payload:    payload,
+		ProposedBy: mspID,
+		Status:     "Open",
+		Votes:      []GovernanceVote{},
+		CreatedAt:  nowRFC3339(),
+	}
+	return putProposal(ctx, p)
+}
+
+// Vote casts the caller org's ballot. Each governance org votes once; the
+// proposal closes as soon as the outcome can no longer change.
+func (s *AdminContract) Vote(ctx contractapi.TransactionContextInterface,
+	proposalID string, approve bool) error {
+
+	mspID, cfg, err := governanceCaller(ctx)
+	if err != nil {
+		return err
+	}
+	p, err := getProposal(ctx, proposalID)
+	if err != nil {
+		return err
+	}
+	if p.Status != "Open" {
+		return fmt.Errorf("proposal %s is %s", proposalID, p.Status)
+	}
+	for _, v := range p.Votes {
+		if v.Org == mspID {
+			return fmt.Errorf("%s has already voted on proposal %s", mspID, proposalID)
+		}
+	}
+
+	p.Votes = append(p.Votes, GovernanceVote{
+		Org:     mspID,
+		Approve: approve,
+		TxID:    ctx.GetStub().GetTxID(),
+		VotedAt: nowRFC3339(),
+	})
+
+	approvals, rejections := 0, 0
+	for _, v := range p.Votes {
+		if !cfg.isGovernanceOrg(v.Org) {
+			continue // org left governance since voting
+		}
+		if v.Approve {
+			approvals++
+		} else {
+			rejections++
+		}
+	}
+	switch {
+	case approvals >= cfg.quorum():
+		p.Status = "Approved"
+	case rejections > len(cfg.GovernanceOrgs)-cfg.quorum():
+		p.Status = "Rejected"
+	}
+	return putProposal(ctx, p)
+}
+
+// ExecuteProposal applies an approved proposal. Any governance org may execute.
+func (s *AdminContract) ExecuteProposal(ctx contractapi.TransactionContextInterface,
+	proposalID string) error {
+
+	if _, _, err := governanceCaller(ctx); err != nil {
+		return err
+	}
+	p, err := getProposal(ctx, proposalID)
+	if err != nil {
+		return err
+	}
+	if p.Status != "Approved" {
+		return fmt.Errorf("proposal %s is %s, not Approved", proposalID, p.Status)
+	}
+	if err := s.applyProposal(ctx, p); err != nil {
+		return fmt.Errorf("execute proposal %s: %v", proposalID, err)
+	}
+
+	p.Status = "Executed"
+	p.ExecutedAt = nowRFC3339()
+	p.ExecTxID = ctx.GetStub().GetTxID()
+	return putProposal(ctx, p)
+}
+
+// GetProposal returns a proposal and its votes.
+func (s *AdminContract) GetProposal(ctx contractapi.TransactionContextInterface,
+	proposalID string) (*Proposal, error) {
+
+	return getProposal(ctx, proposalID)
+}
+
+// ===== Helpers =====
+
+// Payloads by kind.
+type issuerProposal struct {
+	IssuerID string `json:"issuerId"`
+	MSPID    string `json:"mspId"`
+}
+
+func validateProposal(kind, payload string) error {
+	switch kind {
+	case "RegisterIssuer", "DeactivateIssuer":
+		var ip issuerProposal
+		if err := json.Unmarshal([]byte(payload), &ip); err != nil {
+			return fmt.Errorf("invalid %s payload: %v", kind, err)
+		}
+		if ip.IssuerID == "" || (kind == "RegisterIssuer" && ip.MSPID == "") {
+			return fmt.Errorf("%s payload is missing fields", kind)
+		}
+	case "SetConfig":
+		var cfg ContractConfig
+		if err := json.Unmarshal([]byte(payload), &cfg); err != nil {
+			return fmt.Errorf("invalid SetConfig payload: %v", err)
+		}
+	default:
+		return fmt.Errorf("unknown proposal kind %s", kind)
+	}
+	return nil
+}
+
+// applyProposal performs the change. State may have moved on since the vote,
+// so failures here leave the proposal Approved for a later retry.
+func (s *ledger) applyProposal(ctx contractapi.TransactionContextInterface, p *Proposal) error {
+	switch p.Kind {
+	case "RegisterIssuer", "DeactivateIssuer":
+		var ip issuerProposal
+		if err := json.Unmarshal([]byte(p.Payload), &ip); err != nil {
+			return err
+		}
+		if p.Kind == "RegisterIssuer" {
+			return s.registerIssuer(ctx, ip.IssuerID, ip.MSPID)
+		}
+		return s.deactivateIssuer(ctx, ip.IssuerID)
+	case "SetConfig":
+		var cfg ContractConfig
+		if err := json.Unmarshal([]byte(p.Payload), &cfg); err != nil {
+			return err
+		}
+		return putConfig(ctx, cfg)
+	}
+	return fmt.Errorf("unknown proposal kind %s", p.Kind)
+}
+
+// requireUngoverned rejects a direct registry change that, once governance
+// orgs are configured, only an executed proposal of that kind may make.
+func requireUngoverned(ctx contractapi.TransactionContextInterface, kind string) error {
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if len(cfg.GovernanceOrgs) > 0 {
+		return fmt.Errorf("%s requires an executed %s proposal once governance orgs are configured", kind, kind)
+	}
+	return nil
+}
+
+// governanceCaller returns the caller's MSP if it is a governance org.
+func governanceCaller(ctx contractapi.TransactionContextInterface) (string, *ContractConfig, error) {
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(cfg.GovernanceOrgs) == 0 {
+		return "", nil, fmt.Errorf("no governance orgs configured")
+	}
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", nil, err
+	}
+	if !cfg.isGovernanceOrg(mspID) {
+		return "", nil, fmt.Errorf("%s is not a governance org", mspID)
+	}
+	return mspID, cfg, nil
+}
+
+func getProposal(ctx contractapi.TransactionContextInterface, proposalID string) (*Proposal, error) {
+	bz, err := ctx.GetStub().GetState(proposalKey(proposalID))
+	if err != nil {
+		return nil, err
+	}
+	if bz == nil {
+		return nil, fmt.Errorf("proposal %s not found", proposalID)
+	}
+	var p Proposal
+	if err := json.Unmarshal(bz, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// putProposal stores the proposal and emits it as a GovernanceProposal event.
+func putProposal(ctx contractapi.TransactionContextInterface, p *Proposal) error {
+	bz, _ := json.Marshal(p)
+	if err := ctx.GetStub().PutState(proposalKey(p.ProposalID), bz); err != nil {
+		return err
+	}
+	return emitEvent(ctx, "GovernanceProposal", p)
+}
+
+func proposalKey(proposalID string) string { return "proposal:" + proposalID }
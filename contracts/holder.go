@@ -0,0 +1,69 @@
+package main
+
+import (
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// HolderSummary is everything a wallet home screen shows, in one read.
+type HolderSummary struct {
+	HolderDID        string           `json:"holderDid"`
+	Credentials      int64            `json:"credentials"`
+	ByStatus         map[string]int64 `json:"byStatus"` // Active credentials past expiresAt count as Expired
+	ByCredType       map[string]int64 `json:"byCredType"`
+	LatestActivityAt string           `json:"latestActivityAt,omitempty"` // RFC3339 time of the newest event
+	LatestEventID    string           `json:"latestEventId,omitempty"`
+	Consents         []Consent        `json:"consents"`
+}
+
+// GetHolderSummary returns a holder's credential counts by status and type,
+// their latest audit activity and their consents. Credentials are found
+// through the holder's events, since each one has at least its Issue event.
+func (s *AuditContract) GetHolderSummary(ctx contractapi.TransactionContextInterface,
+	holderDID string) (*HolderSummary, error) {
+
+	stored, err := holderEvents(ctx, holderDID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	sum := &HolderSummary{
+		HolderDID:  holderDID,
+		ByStatus:   map[string]int64{},
+		ByCredType: map[string]int64{},
+	}
+	seen := map[string]bool{}
+	for _, kv := range stored {
+		evt, err := decodeEvent(ctx, kv.Value)
+		if err != nil {
+			return nil, err
+		}
+		if evt.OccurredAt > sum.LatestActivityAt {
+			sum.LatestActivityAt = evt.OccurredAt
+			sum.LatestEventID = evt.EventID
+		}
+		if evt.CredID == "" || seen[evt.CredID] {
+			continue
+		}
+		seen[evt.CredID] = true
+
+		cred, err := s.getCred(ctx, evt.CredID)
+		if err != nil {
+			return nil, err
+		}
+		if cred.HolderDID != holderDID {
+			continue // event predates a holder change
+		}
+		status := cred.Status
+		if status == "Active" && cred.expired() {
+			status = "Expired"
+		}
+		sum.Credentials++
+		sum.ByStatus[status]++
+		sum.ByCredType[cred.CredType]++
+	}
+
+	if sum.Consents, err = listConsents(ctx, holderDID); err != nil {
+		return nil, err
+	}
+	return sum, nil
+}
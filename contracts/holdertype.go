@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Holder types. Credentials were issued to people; supply-chain deployments
+// also credential companies, which are not data subjects and are identified
+// differently. A credential's HolderType is Individual (the default, and
+// what credentials from before holder types read as) or Organization.
+// Organization holders carry their ISO 17442 Legal Entity Identifier and
+// need a DID method that resolves to something the organization controls:
+// did:web, did:ebsi or did:indy unless the config says otherwise, so not
+// did:key, which anyone can generate. The config's holderTypes sets
+// per-type rules:
+//
+//	didMethods      DID methods allowed for the type, within the channel's
+//	                didMethods
+//	requireConsent  verifications without the holder's granted consent to the
+//	                verifier are denied (still recorded)
+//	retentionDays   revoked or expired credentials kept longer than this are
+//	                reported by compliance sweeps as RetentionExceeded, so
+//	                the issuer erases the off-chain data behind them
+
+const (
+	HolderIndividual   = "Individual"
+	HolderOrganization = "Organization"
+)
+
+// HolderTypeRules are the config's rules for one holder type.
+type HolderTypeRules struct {
+	DIDMethods     []string `json:"didMethods,omitempty"`
+	RequireConsent bool     `json:"requireConsent,omitempty"`
+	RetentionDays  int      `json:"retentionDays,omitempty"` // 0 means no limit
+}
+
+// orgDIDMethods are the methods an Organization holder may use when the
+// config names none.
+var orgDIDMethods = []string{"web", "ebsi", "indy"}
+
+var leiSyntax = regexp.MustCompile(`^[A-Z0-9]{18}[0-9]{2}$`)
+
+// IssueOrgCreds is IssueCreds for an Organization holder, identified by
+// legalEntityID, its LEI.
+func (s *CredentialContract) IssueOrgCreds(ctx contractapi.TransactionContextInterface,
+	credID, holderDID, legalEntityID, credType, hashedData, issuerID, jurisdiction, expiresAt, merkleRoot string) error {
+
+	if err := s.checkNotReserved(ctx, credID); err != nil {
+		return err
+	}
+	cred, warning, err := s.newCred(ctx, credID, holderDID, credType, hashedData, issuerID, jurisdiction, expiresAt, merkleRoot)
+	if err != nil {
+		return err
+	}
+	cred.HolderType = HolderOrganization
+	cred.LegalEntityID = legalEntityID
+	return s.storeIssued(ctx, cred, warning)
+}
+
+// GetCredentialsByHolderType returns one page of the credentials issued to
+// holders of holderType, from the cred~holdertype index. Credentials issued
+// before the index existed appear once RepairIndexes("cred") has run.
+func (s *CredentialContract) GetCredentialsByHolderType(ctx contractapi.TransactionContextInterface,
+	holderType string, pageSize int32, bookmark string) (*CredentialPage, error) {
+
+	if holderType != HolderIndividual && holderType != HolderOrganization {
+		return nil, fmt.Errorf("holderType must be %s or %s", HolderIndividual, HolderOrganization)
+	}
+	raw, err := decodeBookmark(ctx, "cred~holdertype", bookmark)
+	if err != nil {
+		return nil, err
+	}
+	iter, meta, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(
+		"cred~holdertype", []string{holderType}, pageSize, raw)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	page := &CredentialPage{Credentials: []Credential{}}
+	if page.Bookmark, err = encodeBookmark(ctx, "cred~holdertype", meta.Bookmark); err != nil {
+		return nil, err
+	}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, attrs, err := ctx.GetStub().SplitCompositeKey(kv.Key)
+		if err != nil {
+			return nil, err
+		}
+		cred, err := s.getCred(ctx, attrs[1])
+		if err != nil {
+			return nil, err
+		}
+		page.Credentials = append(page.Credentials, *cred)
+	}
+	return page, nil
+}
+
+// ===== Helpers =====
+
+// holderType returns the credential's holder type, Individual if unset.
+func (c *Credential) holderType() string {
+	if c.HolderType == "" {
+		return HolderIndividual
+	}
+	return c.HolderType
+}
+
+// checkHolderType applies the holder type's issuance rules to a credential
+// about to be stored.
+func checkHolderType(cfg *ContractConfig, cred *Credential) error {
+	method := strings.SplitN(cred.HolderDID, ":", 3)[1]
+	switch cred.holderType() {
+	case HolderIndividual:
+		if cred.LegalEntityID != "" {
+			return fmt.Errorf("legalEntityId is only for %s holders", HolderOrganization)
+		}
+	case HolderOrganization:
+		if err := validateLEI(cred.LegalEntityID); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("holderType must be %s or %s", HolderIndividual, HolderOrganization)
+	}
+	methods := cfg.HolderTypes[cred.holderType()].DIDMethods
+	if len(methods) == 0 && cred.holderType() == HolderOrganization {
+		methods = orgDIDMethods
+	}
+	if len(methods) > 0 && !slices.Contains(methods, method) {
+		return fmt.Errorf("DID method %s is not allowed for %s holders", method, cred.holderType())
+	}
+	return nil
+}
+
+// validateLEI checks an ISO 17442 Legal Entity Identifier: 18 alphanumerics
+// and two ISO 7064 MOD 97-10 check digits.
+func validateLEI(lei string) error {
+	if !leiSyntax.MatchString(lei) {
+		return fmt.Errorf("legalEntityId %q is not a 20-character LEI", lei)
+	}
+	var digits strings.Builder
+	for _, r := range lei {
+		if r >= 'A' {
+			fmt.Fprintf(&digits, "%d", r-'A'+10)
+		} else {
+			digits.WriteRune(r)
+		}
+	}
+	n, _ := new(big.Int).SetString(digits.String(), 10)
+	if new(big.Int).Mod(n, big.NewInt(97)).Int64() != 1 {
+		return fmt.Errorf("legalEntityId %q fails its check digits", lei)
+	}
+	return nil
+}
+
+func validateHolderTypes(types map[string]HolderTypeRules) error {
+	for t, rules := range types {
+		if t != HolderIndividual && t != HolderOrganization {
+			return fmt.Errorf("holderTypes: unknown holder type %q", t)
+		}
+		for _, m := range rules.DIDMethods {
+			if !didMethodName.MatchString(m) {
+				return fmt.Errorf("holderTypes.%s: invalid DID method %q", t, m)
+			}
+		}
+		if rules.RetentionDays < 0 {
+			return fmt.Errorf("holderTypes.%s: retentionDays must not be negative", t)
+		}
+	}
+	return nil
+}
+
+// consentDenial returns a denial reason when the holder type requires
+// consent and the holder has not granted it to verifierID.
+func consentDenial(ctx contractapi.TransactionContextInterface, cred *Credential, verifierID string) (string, error) {
+	cfg, err := loadConfig(ctx)
+	if err != nil || !cfg.HolderTypes[cred.holderType()].RequireConsent {
+		return "", err
+	}
+	c, err := getConsent(ctx, cred.HolderDID, verifierID)
+	if err != nil {
+		return "", err
+	}
+	if c == nil || c.Status != "Granted" {
+		return fmt.Sprintf("holder has not consented to verifier %s", verifierID), nil
+	}
+	return "", nil
+}
+
+// retentionExceeded reports how long past its holder type's retention
+// period a revoked or expired credential is, or "" if it is not.
+func retentionExceeded(cfg *ContractConfig, cred *Credential) string {
+	days := cfg.HolderTypes[cred.holderType()].RetentionDays
+	if days == 0 {
+		return ""
+	}
+	retired, how := "", ""
+	switch {
+	case cred.Status == "Revoked":
+		retired, how = cred.UpdatedAt, "revocation"
+	case cred.Status == "Expired" || cred.expired():
+		retired, how = cred.ExpiresAt, "expiry"
+	}
+	at, err := time.Parse(time.RFC3339, retired)
+	if err != nil {
+		return ""
+	}
+	if until := at.AddDate(0, 0, days); time.Now().After(until) {
+		return fmt.Sprintf("%s holder record kept past %s, %d days after %s",
+			cred.holderType(), until.UTC().Format(time.RFC3339), days, how)
+	}
+	return ""
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// IndexReport describes one page of an index consistency scan.
+type IndexReport struct {
+	Index    string   `json:"index"`
+	Scanned  int32    `json:"scanned"`
+	Orphaned []string `json:"orphaned"` // index entries whose target is gone or changed
+	Missing  []string `json:"missing"`  // pointer entries absent for live credentials
+	Bookmark string   `json:"bookmark"`
+}
+
+// credIndexes maps each credential pointer index to the credential fields
+// its leading attributes must match, "/"-joined. Audit events (event~holder)
+// and compliance findings (finding~cred) are history, not pointers, and are
+// never garbage-collected, even when their credential is gone.
+var credIndexes = map[string]func(*Credential) string{
+	"cred~type":       func(c *Credential) string { return c.CredType },
+	"cred~issuer":     func(c *Credential) string { return c.IssuerID },
+	"cred~holdertype": func(c *Credential) string { return c.holderType() },
+	"cred~expiry":     func(c *Credential) string { return c.IssuerID + "/" + expiryBucket(c.ExpiresAt) },
+}
+
+// maxReindexWrites bounds the index entries one ReindexEvents call writes,
+// keeping its write set well inside the orderer's block size limit.
+const maxReindexWrites = 500
+
+// eventIndexKeys returns the lookup entries derived from an event: the ID
+// pointer, holding its event~holder key so single events can be found by ID
+// alone, and event~actor and event~since, value-less and resolved through
+// the ID pointer (see indexValue). event~since orders a holder's events by
+// ID under the key they were recorded with, for GetEventsSince. An index added here is written for new events by
+// storeEvent and backfilled for old ones by ReindexEvents; check what it
+// adds to each event with GetEventWriteCost.
+func eventIndexKeys(ctx contractapi.TransactionContextInterface, evt *AccessEvent) ([]string, error) {
+	keys := []string{eventPointerKey(evt.EventID)}
+	if evt.ActorID != "" {
+		ck, err := compositeKey(ctx, "event~actor", []string{evt.ActorID, evt.EventID})
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, ck)
+	}
+	if evt.HolderDID != "" {
+		ck, err := compositeKey(ctx, "event~since", []string{evt.HolderDID, evt.EventID})
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, ck)
+	}
+	return keys, nil
+}
+
+// VerifyIndexes reports index entries on one page that no longer point at a
+// matching credential. Pass index "cred" to instead report credentials that
+// are missing their pointer entries.
+func (s *AdminContract) VerifyIndexes(ctx contractapi.TransactionContextInterface,
+	index string, pageSize int32, bookmark string) (*IndexReport, error) {
+
+	return s.scanIndex(ctx, index, pageSize, bookmark, false)
+}
+
+// RepairIndexes is VerifyIndexes that also deletes orphaned entries, or for
+// index "cred" writes the missing pointer entries.
+func (s *AdminContract) RepairIndexes(ctx contractapi.TransactionContextInterface,
+	index string, pageSize int32, bookmark string) (*IndexReport, error) {
+
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	return s.scanIndex(ctx, index, pageSize, bookmark, true)
+}
+
+// ReindexEvents writes the lookup entries missing for one page of stored
+// events, so an index introduced by an upgrade also covers events recorded
+// before it. A call stops early rather than write more than
+// maxReindexWrites entries; resume from the returned bookmark until it is
+// empty. Missing lists the entries written.
+func (s *AdminContract) ReindexEvents(ctx contractapi.TransactionContextInterface,
+	pageSize int32, bookmark string) (*IndexReport, error) {
+
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	raw, err := decodeBookmark(ctx, "event~holder", bookmark)
+	if err != nil {
+		return nil, err
+	}
+	iter, meta, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination("event~holder", []string{}, pageSize, raw)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	report := &IndexReport{Index: "event~holder", Orphaned: []string{}, Missing: []string{}}
+	if report.Bookmark, err = encodeBookmark(ctx, "event~holder", meta.Bookmark); err != nil {
+		return nil, err
+	}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		evt, err := decodeEvent(ctx, kv.Value)
+		if err != nil {
+			return nil, err
+		}
+		keys, err := eventIndexKeys(ctx, evt)
+		if err != nil {
+			return nil, err
+		}
+		var missing []string
+		for _, key := range keys {
+			val, err := ctx.GetStub().GetState(key)
+			if err != nil {
+				return nil, err
+			}
+			if val == nil {
+				missing = append(missing, key)
+			}
+		}
+		if len(report.Missing) > 0 && len(report.Missing)+len(missing) > maxReindexWrites {
+			// Resume at this event; Fabric bookmarks are inclusive start keys.
+			if report.Bookmark, err = encodeBookmark(ctx, "event~holder", kv.Key); err != nil {
+				return nil, err
+			}
+			return report, nil
+		}
+		report.Scanned++
+		for _, key := range missing {
+			if err := ctx.GetStub().PutState(key, indexValue(key, kv.Key)); err != nil {
+				return nil, err
+			}
+			report.Missing = append(report.Missing, printableKey(key))
+		}
+	}
+	return report, nil
+}
+
+// ===== Helpers =====
+
+// indexValue is what an event lookup entry holds: the event~holder key for
+// the ID pointer, nothing for the others.
+func indexValue(key, eventKey string) []byte {
+	if strings.HasPrefix(key, eventPointerKey("")) {
+		return []byte(eventKey)
+	}
+	return indexMarker
+}
+
+// eventByIndex loads the event a lookup entry points at, resolving
+// value-less entries through the event's ID pointer. Entries written before
+// they were value-less still hold the event~holder key. It returns nil when
+// the event is gone.
+func eventByIndex(ctx contractapi.TransactionContextInterface, key string, value []byte) (*AccessEvent, error) {
+	target := string(value)
+	if bytes.Equal(value, indexMarker) {
+		_, attrs, err := ctx.GetStub().SplitCompositeKey(key)
+		if err != nil {
+			return nil, err
+		}
+		ptr, err := ctx.GetStub().GetState(eventPointerKey(attrs[len(attrs)-1]))
+		if err != nil || ptr == nil {
+			return nil, err
+		}
+		target = string(ptr)
+	}
+	stored, err := ctx.GetStub().GetState(target)
+	if err != nil || stored == nil {
+		return nil, err
+	}
+	evt, err := decodeEvent(ctx, stored)
+	if err != nil {
+		return nil, err
+	}
+	if err := shadowRead(ctx, target, stored, evt, decodeEventRecord); err != nil {
+		return nil, err
+	}
+	return evt, nil
+}
+
+// printableKey renders a composite key as "/"-joined parts for reports.
+func printableKey(key string) string {
+	return strings.Trim(strings.ReplaceAll(key, "\x00", "/"), "/")
+}
+
+func (s *ledger) scanIndex(ctx contractapi.TransactionContextInterface,
+	index string, pageSize int32, bookmark string, repair bool) (*IndexReport, error) {
+
+	if index == "cred" {
+		return s.scanCredPointers(ctx, pageSize, bookmark, repair)
+	}
+	field, ok := credIndexes[index]
+	if !ok {
+		return nil, fmt.Errorf("unknown index %s", index)
+	}
+
+	raw, err := decodeBookmark(ctx, index, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	iter, meta, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(index, []string{}, pageSize, raw)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+	next, err := encodeBookmark(ctx, index, meta.Bookmark)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &IndexReport{Index: index, Orphaned: []string{}, Missing: []string{}, Bookmark: next}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		report.Scanned++
+		_, attrs, err := ctx.GetStub().SplitCompositeKey(kv.Key)
+		if err != nil {
+			return nil, err
+		}
+		credID, indexed := attrs[len(attrs)-1], strings.Join(attrs[:len(attrs)-1], "/")
+		exists, err := s.credExists(ctx, credID)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			cred, err := s.getCred(ctx, credID)
+			if err != nil {
+				return nil, err
+			}
+			if field(cred) == indexed {
+				continue
+			}
+		}
+		report.Orphaned = append(report.Orphaned, index+"/"+strings.Join(attrs, "/"))
+		if repair {
+			if err := ctx.GetStub().DelState(kv.Key); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return report, nil
+}
+
+// scanCredPointers finds credentials issued before their pointer indexes existed.
+func (s *ledger) scanCredPointers(ctx contractapi.TransactionContextInterface,
+	pageSize int32, bookmark string, repair bool) (*IndexReport, error) {
+
+	iter, meta, err := ctx.GetStub().GetStateByRangeWithPagination("cred:", "cred;", pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	report := &IndexReport{Index: "cred", Orphaned: []string{}, Missing: []string{}, Bookmark: meta.Bookmark}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		report.Scanned++
+		cred, err := s.getCred(ctx, strings.TrimPrefix(kv.Key, "cred:"))
+		if err != nil {
+			return nil, err
+		}
+		pointers := [][]string{
+			{"cred~type", cred.CredType, cred.CredID},
+			{"cred~issuer", cred.IssuerID, cred.CredID},
+			{"cred~holdertype", cred.holderType(), cred.CredID},
+		}
+		if day := expiryBucket(cred.ExpiresAt); day != "" {
+			pointers = append(pointers, []string{"cred~expiry", cred.IssuerID, day, cred.CredID})
+		}
+		if cred.Status == "Revoked" {
+			pointers = append(pointers, []string{"cred~revoked", revokedDay(cred), cred.CredID})
+		}
+		for _, pointer := range pointers {
+			ck, err := compositeKey(ctx, pointer[0], pointer[1:])
+			if err != nil {
+				return nil, err
+			}
+			val, err := ctx.GetStub().GetState(ck)
+			if err != nil {
+				return nil, err
+			}
+			if val != nil {
+				continue
+			}
+			report.Missing = append(report.Missing, strings.Join(pointer, "/"))
+			if repair {
+				if err := ctx.GetStub().PutState(ck, []byte{0}); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,186 @@
+//go:build integration
+
+// Package fabnet drives a fabric-samples test-network for end-to-end runs:
+// it brings the network up, deploys this chaincode, and submits or
+// evaluates transactions through the peer CLI as Org1's admin. It shells
+// out rather than embedding a Fabric SDK so the harness exercises exactly
+// what an operator would.
+package fabnet
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Network is a test-network under fabric-samples. SamplesDir must contain
+// test-network/, bin/ (peer, configtxgen, ...) and config/.
+type Network struct {
+	SamplesDir string
+	Channel    string // default "mychannel"
+	Chaincode  string // default "audittrail"
+	Verbose    bool   // stream network.sh output
+}
+
+func (n *Network) channel() string {
+	if n.Channel == "" {
+		return "mychannel"
+	}
+	return n.Channel
+}
+
+func (n *Network) chaincode() string {
+	if n.Chaincode == "" {
+		return "audittrail"
+	}
+	return n.Chaincode
+}
+
+func (n *Network) testNetwork() string { return filepath.Join(n.SamplesDir, "test-network") }
+
+// Up starts the network with CouchDB state databases (rich queries need
+// them) and creates the channel. Any previous network is torn down first.
+func (n *Network) Up() error {
+	if err := n.Down(); err != nil {
+		return err
+	}
+	return n.script("up", "createChannel", "-c", n.channel(), "-s", "couchdb")
+}
+
+// Down stops the network and removes its volumes.
+func (n *Network) Down() error {
+	return n.script("down")
+}
+
+// Deploy installs the chaincode at path and commits it on the channel.
+// Redeploying with a higher sequence upgrades it in place, keeping state.
+func (n *Network) Deploy(path, version string, sequence int) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	return n.script("deployCC", "-c", n.channel(), "-ccn", n.chaincode(), "-ccp", abs, "-ccl", "go",
+		"-ccv", version, "-ccs", strconv.Itoa(sequence), "-cccg", filepath.Join(abs, "collections_config.json"))
+}
+
+func (n *Network) script(args ...string) error {
+	cmd := exec.Command("./network.sh", args...)
+	cmd.Dir = n.testNetwork()
+	cmd.Env = n.env()
+	var out bytes.Buffer
+	if n.Verbose {
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	} else {
+		cmd.Stdout, cmd.Stderr = &out, &out
+	}
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("network.sh %s: %v\n%s", strings.Join(args, " "), err, out.String())
+	}
+	return nil
+}
+
+// env is test-network's setGlobals for Org1.
+func (n *Network) env() []string {
+	org := filepath.Join(n.testNetwork(), "organizations", "peerOrganizations", "org1.example.com")
+	return append(os.Environ(),
+		"PATH="+filepath.Join(n.SamplesDir, "bin")+string(os.PathListSeparator)+os.Getenv("PATH"),
+		"FABRIC_CFG_PATH="+filepath.Join(n.SamplesDir, "config"),
+		"CORE_PEER_TLS_ENABLED=true",
+		"CORE_PEER_LOCALMSPID=Org1MSP",
+		"CORE_PEER_TLS_ROOTCERT_FILE="+filepath.Join(org, "tlsca", "tlsca.org1.example.com-cert.pem"),
+		"CORE_PEER_MSPCONFIGPATH="+filepath.Join(org, "users", "Admin@org1.example.com", "msp"),
+		"CORE_PEER_ADDRESS=localhost:7051",
+	)
+}
+
+func (n *Network) tlsRoot(org string) string {
+	return filepath.Join(n.testNetwork(), "organizations", "peerOrganizations", org+".example.com",
+		"tlsca", "tlsca."+org+".example.com-cert.pem")
+}
+
+func (n *Network) ctorJSON(fn string, args []string) string {
+	bz, _ := json.Marshal(map[string]interface{}{"function": fn, "Args": args})
+	return string(bz)
+}
+
+// Submit endorses fn on both orgs' peers, waits for the commit and returns
+// the transaction's payload.
+func (n *Network) Submit(fn string, args ...string) ([]byte, error) {
+	orderCA := filepath.Join(n.testNetwork(), "organizations", "ordererOrganizations", "example.com",
+		"tlsca", "tlsca.example.com-cert.pem")
+	out, err := n.peer("chaincode", "invoke",
+		"-o", "localhost:7050", "--ordererTLSHostnameOverride", "orderer.example.com",
+		"--tls", "--cafile", orderCA,
+		"-C", n.channel(), "-n", n.chaincode(),
+		"--peerAddresses", "localhost:7051", "--tlsRootCertFiles", n.tlsRoot("org1"),
+		"--peerAddresses", "localhost:9051", "--tlsRootCertFiles", n.tlsRoot("org2"),
+		"--waitForEvent",
+		"-c", n.ctorJSON(fn, args))
+	if err != nil {
+		return nil, err
+	}
+	return invokePayload(out)
+}
+
+// Evaluate queries fn on Org1's peer without submitting it.
+func (n *Network) Evaluate(fn string, args ...string) ([]byte, error) {
+	out, err := n.peer("chaincode", "query", "-C", n.channel(), "-n", n.chaincode(), "-c", n.ctorJSON(fn, args))
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimSpace(out), nil
+}
+
+func (n *Network) peer(args ...string) ([]byte, error) {
+	cmd := exec.Command("peer", args...)
+	cmd.Env = n.env()
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, &TxError{Message: chaincodeMessage(stderr.String())}
+	}
+	// invoke reports its result on stderr, query on stdout.
+	return append(stdout.Bytes(), stderr.Bytes()...), nil
+}
+
+// TxError is a transaction the peer rejected, carrying the chaincode's
+// error message when there is one.
+type TxError struct{ Message string }
+
+func (e *TxError) Error() string { return e.Message }
+
+var (
+	chaincodeMsgRe = regexp.MustCompile(`message:"((?:[^"\\]|\\.)*)"`)
+	payloadRe      = regexp.MustCompile(`payload:"((?:[^"\\]|\\.)*)"`)
+)
+
+func chaincodeMessage(stderr string) string {
+	if m := chaincodeMsgRe.FindStringSubmatch(stderr); m != nil {
+		if s, err := strconv.Unquote(`"` + m[1] + `"`); err == nil {
+			return s
+		}
+		return m[1]
+	}
+	return strings.TrimSpace(stderr)
+}
+
+// invokePayload extracts the payload from "Chaincode invoke successful.
+// result: status:200 payload:"..."", which the peer prints in protobuf text
+// format (Go-compatible escapes).
+func invokePayload(out []byte) ([]byte, error) {
+	m := payloadRe.FindSubmatch(out)
+	if m == nil {
+		return nil, nil // transactions without a return value
+	}
+	s, err := strconv.Unquote(`"` + string(m[1]) + `"`)
+	if err != nil {
+		return nil, fmt.Errorf("decoding invoke payload: %v", err)
+	}
+	return []byte(s), nil
+}
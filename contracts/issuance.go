@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const defaultPrepareTTLSeconds = 15 * 60
+
+// PendingIssue reserves a credential ID while an integration coordinates with
+// an external system (payment, identity proofing) before committing.
+type PendingIssue struct {
+	Draft      Credential `json:"draft"`      // issuance inputs as prepared
+	PreparedBy string     `json:"preparedBy"` // MSP ID; only it may commit or abort
+	PreparedAt string     `json:"preparedAt"` // RFC3339
+	ExpiresAt  string     `json:"expiresAt"`  // RFC3339; reservation lapses after this
+}
+
+// PrepareIssue validates an issuance and reserves credID for ttlSeconds
+// (default 15 minutes). Nothing is issued until CommitIssue. A blocked
+// holder gets a Denied Issue event and no reservation.
+func (s *CredentialContract) PrepareIssue(ctx contractapi.TransactionContextInterface,
+	credID, holderDID, credType, hashedData, issuerID, jurisdiction, expiresAt, merkleRoot string,
+	ttlSeconds int) error {
+
+	if ttlSeconds < 0 {
+		return fmt.Errorf("ttlSeconds must not be negative")
+	}
+	if ttlSeconds == 0 {
+		ttlSeconds = defaultPrepareTTLSeconds
+	}
+	if err := s.checkNotReserved(ctx, credID); err != nil {
+		return err
+	}
+	cred, _, err := s.newCred(ctx, credID, holderDID, credType, hashedData, issuerID, jurisdiction, expiresAt, merkleRoot)
+	if err != nil {
+		return err
+	}
+	if denied, err := s.denyBlockedIssue(ctx, cred); err != nil || denied {
+		return err
+	}
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return err
+	}
+
+	pending := &PendingIssue{
+		Draft:      *cred,
+		PreparedBy: mspID,
+		PreparedAt: nowRFC3339(),
+		ExpiresAt:  time.Now().UTC().Add(time.Duration(ttlSeconds) * time.Second).Format(time.RFC3339),
+	}
+	bz, _ := json.Marshal(pending)
+	return ctx.GetStub().PutState(pendingIssueKey(credID), bz)
+}
+
+// CommitIssue issues a prepared credential. Validation is repeated, so a
+// credential type sunset in the meantime still blocks issuance.
+func (s *CredentialContract) CommitIssue(ctx contractapi.TransactionContextInterface,
+	credID string) error {
+
+	pending, err := s.ownPendingIssue(ctx, credID)
+	if err != nil {
+		return err
+	}
+	if pending.lapsed() {
+		return fmt.Errorf("reservation for %s expired at %s", credID, pending.ExpiresAt)
+	}
+
+	d := pending.Draft
+	cred, warning, err := s.newCred(ctx, d.CredID, d.HolderDID, d.CredType, d.HashedData,
+		d.IssuerID, d.Jurisdiction, d.ExpiresAt, d.MerkleRoot)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().DelState(pendingIssueKey(credID)); err != nil {
+		return err
+	}
+	return s.storeIssued(ctx, cred, warning)
+}
+
+// AbortIssue releases a reservation without issuing.
+func (s *CredentialContract) AbortIssue(ctx contractapi.TransactionContextInterface,
+	credID string) error {
+
+	if _, err := s.ownPendingIssue(ctx, credID); err != nil {
+		return err
+	}
+	return ctx.GetStub().DelState(pendingIssueKey(credID))
+}
+
+// GetPendingIssue returns the reservation for credID.
+func (s *CredentialContract) GetPendingIssue(ctx contractapi.TransactionContextInterface,
+	credID string) (*PendingIssue, error) {
+
+	pending, err := getPendingIssue(ctx, credID)
+	if err != nil {
+		return nil, err
+	}
+	if pending == nil {
+		return nil, fmt.Errorf("no pending issuance for %s", credID)
+	}
+	return pending, nil
+}
+
+// ===== Helpers =====
+
+func (p *PendingIssue) lapsed() bool {
+	exp, err := time.Parse(time.RFC3339, p.ExpiresAt)
+	return err != nil || time.Now().UTC().After(exp)
+}
+
+// checkNotReserved fails while another integration holds credID. Lapsed
+// reservations are simply overwritten.
+func (s *ledger) checkNotReserved(ctx contractapi.TransactionContextInterface, credID string) error {
+	pending, err := getPendingIssue(ctx, credID)
+	if err != nil {
+		return err
+	}
+	if pending != nil && !pending.lapsed() {
+		return fmt.Errorf("credential %s is reserved until %s", credID, pending.ExpiresAt)
+	}
+	return nil
+}
+
+func (s *ledger) ownPendingIssue(ctx contractapi.TransactionContextInterface, credID string) (*PendingIssue, error) {
+	pending, err := getPendingIssue(ctx, credID)
+	if err != nil {
+		return nil, err
+	}
+	if pending == nil {
+		return nil, fmt.Errorf("no pending issuance for %s", credID)
+	}
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, err
+	}
+	if mspID != pending.PreparedBy {
+		return nil, fmt.Errorf("pending issuance for %s belongs to %s", credID, pending.PreparedBy)
+	}
+	return pending, nil
+}
+
+func getPendingIssue(ctx contractapi.TransactionContextInterface, credID string) (*PendingIssue, error) {
+	bz, err := ctx.GetStub().GetState(pendingIssueKey(credID))
+	if err != nil || bz == nil {
+		return nil, err
+	}
+	var pending PendingIssue
+	if err := json.Unmarshal(bz, &pending); err != nil {
+		return nil, err
+	}
+	return &pending, nil
+}
+
+func pendingIssueKey(credID string) string { return "pending:" + credID }
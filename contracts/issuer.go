@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Issuer is a registry entry for an organization allowed to issue credentials.
+type Issuer struct {
+	IssuerID      string `json:"issuerId"`
+	MSPID         string `json:"mspId"`
+	Status        string `json:"status"`                  // Active | Deactivated
+	RegisteredAt  string `json:"registeredAt"`            // RFC3339
+	DeactivatedAt string `json:"deactivatedAt,omitempty"` // RFC3339
+}
+
+// RegisterIssuer adds an issuer to the registry in Active state. Admin
+// only, and only while no governance orgs are configured; from then on
+// issuers are admitted by a RegisterIssuer proposal (see governance.go).
+func (s *RegistryContract) RegisterIssuer(ctx contractapi.TransactionContextInterface,
+	issuerID, mspID string) error {
+
+	if err := requireAdmin(ctx); err != nil {
+		return err
+	}
+	if err := requireUngoverned(ctx, "RegisterIssuer"); err != nil {
+		return err
+	}
+	return s.registerIssuer(ctx, issuerID, mspID)
+}
+
+// DeactivateIssuer marks an issuer as no longer authorized. Credentials it
+// already issued are surfaced by compliance sweeps rather than revoked.
+// Admin only, and only while no governance orgs are configured, as for
+// RegisterIssuer.
+func (s *RegistryContract) DeactivateIssuer(ctx contractapi.TransactionContextInterface,
+	issuerID string) error {
+
+	if err := requireAdmin(ctx); err != nil {
+		return err
+	}
+	if err := requireUngoverned(ctx, "DeactivateIssuer"); err != nil {
+		return err
+	}
+	return s.deactivateIssuer(ctx, issuerID)
+}
+
+// GetIssuer returns the registry entry for an issuer.
+func (s *RegistryContract) GetIssuer(ctx contractapi.TransactionContextInterface,
+	issuerID string) (*Issuer, error) {
+
+	issuer, err := s.getIssuer(ctx, issuerID)
+	if err != nil {
+		return nil, err
+	}
+	if issuer == nil {
+		return nil, fmt.Errorf("issuer %s not registered", issuerID)
+	}
+	return issuer, nil
+}
+
+// ===== Helpers =====
+
+// registerIssuer also serves governance proposals.
+func (s *ledger) registerIssuer(ctx contractapi.TransactionContextInterface,
+	issuerID, mspID string) error {
+
+	existing, err := s.getIssuer(ctx, issuerID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("issuer %s already registered", issuerID)
+	}
+
+	issuer := &Issuer{
+		IssuerID:     issuerID,
+		MSPID:        mspID,
+		Status:       "Active",
+		RegisteredAt: nowRFC3339(),
+	}
+	bz, _ := json.Marshal(issuer)
+	return ctx.GetStub().PutState(issuerKey(issuerID), bz)
+}
+
+func (s *ledger) deactivateIssuer(ctx contractapi.TransactionContextInterface,
+	issuerID string) error {
+
+	issuer, err := s.getIssuer(ctx, issuerID)
+	if err != nil {
+		return err
+	}
+	if issuer == nil {
+		return fmt.Errorf("issuer %s not registered", issuerID)
+	}
+	if issuer.Status == "Deactivated" {
+		return fmt.Errorf("issuer %s is already deactivated", issuerID)
+	}
+
+	issuer.Status = "Deactivated"
+	issuer.DeactivatedAt = nowRFC3339()
+	bz, _ := json.Marshal(issuer)
+	return ctx.GetStub().PutState(issuerKey(issuerID), bz)
+}
+
+func (s *ledger) getIssuer(ctx contractapi.TransactionContextInterface, issuerID string) (*Issuer, error) {
+	bz, err := ctx.GetStub().GetState(issuerKey(issuerID))
+	if err != nil {
+		return nil, err
+	}
+	if bz == nil {
+		return nil, nil
+	}
+	var issuer Issuer
+	if err := json.Unmarshal(bz, &issuer); err != nil {
+		return nil, err
+	}
+	return &issuer, nil
+}
+
+func issuerKey(issuerID string) string { return "issuer:" + issuerID }
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// JurisdictionPolicy lists the jurisdictions a verifier org may check
+// credentials from. Credentials without a jurisdiction are not restricted.
+type JurisdictionPolicy struct {
+	VerifierID    string   `json:"verifierId"`
+	Jurisdictions []string `json:"jurisdictions"` // e.g. EU, US-CA
+	UpdatedBy     string   `json:"updatedBy"`
+	UpdatedAt     string   `json:"updatedAt"` // RFC3339
+}
+
+// SetJurisdictionPolicy replaces the allowed jurisdictions for a verifier.
+// Admin only.
+func (s *RegistryContract) SetJurisdictionPolicy(ctx contractapi.TransactionContextInterface,
+	verifierID string, jurisdictions []string, actorID string) error {
+
+	if err := requireAdmin(ctx); err != nil {
+		return err
+	}
+	policy := &JurisdictionPolicy{
+		VerifierID:    verifierID,
+		Jurisdictions: jurisdictions,
+		UpdatedBy:     actorID,
+		UpdatedAt:     nowRFC3339(),
+	}
+	bz, _ := json.Marshal(policy)
+	return ctx.GetStub().PutState(jurisdictionKey(verifierID), bz)
+}
+
+// GetJurisdictionPolicy returns the policy recorded for a verifier.
+func (s *RegistryContract) GetJurisdictionPolicy(ctx contractapi.TransactionContextInterface,
+	verifierID string) (*JurisdictionPolicy, error) {
+
+	policy, err := s.getJurisdictionPolicy(ctx, verifierID)
+	if err != nil {
+		return nil, err
+	}
+	if policy == nil {
+		return nil, fmt.Errorf("no jurisdiction policy for verifier %s", verifierID)
+	}
+	return policy, nil
+}
+
+// ===== Helpers =====
+
+func (s *ledger) getJurisdictionPolicy(ctx contractapi.TransactionContextInterface, verifierID string) (*JurisdictionPolicy, error) {
+	bz, err := ctx.GetStub().GetState(jurisdictionKey(verifierID))
+	if err != nil {
+		return nil, err
+	}
+	if bz == nil {
+		return nil, nil
+	}
+	var policy JurisdictionPolicy
+	if err := json.Unmarshal(bz, &policy); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// checkJurisdiction returns a denial reason when the verifier may not check
+// the credential. Verifiers without a policy may only check untagged credentials.
+// The policy is only applied to the verifier's own MSP, resolved through the
+// registry, so unregistered verifiers may only check untagged credentials too.
+func (s *ledger) checkJurisdiction(ctx contractapi.TransactionContextInterface,
+	cred *Credential, verifierID string) (string, error) {
+
+	if cred.Jurisdiction == "" {
+		return "", nil
+	}
+	v, err := s.getVerifier(ctx, verifierID)
+	if err != nil {
+		return "", err
+	}
+	if v == nil {
+		return fmt.Sprintf("unregistered verifier %s not permitted for jurisdiction %s", verifierID, cred.Jurisdiction), nil
+	}
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", err
+	}
+	if mspID != v.MSPID {
+		return fmt.Sprintf("caller MSP %s does not match verifier %s MSP %s", mspID, verifierID, v.MSPID), nil
+	}
+	policy, err := s.getJurisdictionPolicy(ctx, verifierID)
+	if err != nil {
+		return "", err
+	}
+	if policy != nil {
+		for _, j := range policy.Jurisdictions {
+			if j == cred.Jurisdiction {
+				return "", nil
+			}
+		}
+	}
+	return fmt.Sprintf("verifier %s not permitted for jurisdiction %s", verifierID, cred.Jurisdiction), nil
+}
+
+func jurisdictionKey(verifierID string) string { return "jurisdiction:" + verifierID }
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Justification binds the hash of a verifier's legal-basis document to a
+// verification event. The document itself stays off-chain.
+type Justification struct {
+	EventID    string `json:"eventId"`
+	DocHash    string `json:"docHash"`    // hex sha256 of the document
+	AttachedBy string `json:"attachedBy"` // submitting MSP ID
+	TxID       string `json:"txId"`
+	AttachedAt string `json:"attachedAt"` // RFC3339
+}
+
+// AttachJustification records docHash against a Verify or VerifyAttribute
+// event. Only the verifier's registered MSP may attach it. Each event takes
+// one justification; the holder's trail gets a Justify event pointing at it.
+func (s *AuditContract) AttachJustification(ctx contractapi.TransactionContextInterface,
+	eventID, docHash string) error {
+
+	if raw, err := hex.DecodeString(docHash); err != nil || len(raw) != sha256.Size {
+		return fmt.Errorf("docHash must be a hex sha256 digest")
+	}
+	evt, _, err := getEvent(ctx, eventID)
+	if err != nil {
+		return err
+	}
+	if evt.Action != "Verify" && evt.Action != "VerifyAttribute" {
+		return fmt.Errorf("event %s is a %s event, not a verification", eventID, evt.Action)
+	}
+	v, err := s.getVerifier(ctx, evt.ActorID)
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		return fmt.Errorf("verifier %s not registered", evt.ActorID)
+	}
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return err
+	}
+	if mspID != v.MSPID {
+		return fmt.Errorf("caller MSP %s does not match verifier MSP %s", mspID, v.MSPID)
+	}
+	existing, err := ctx.GetStub().GetState(justificationKey(eventID))
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("event %s already has a justification", eventID)
+	}
+
+	j := &Justification{
+		EventID:    eventID,
+		DocHash:    docHash,
+		AttachedBy: mspID,
+		TxID:       ctx.GetStub().GetTxID(),
+		AttachedAt: nowRFC3339(),
+	}
+	bz, _ := json.Marshal(j)
+	if err := ctx.GetStub().PutState(justificationKey(eventID), bz); err != nil {
+		return err
+	}
+	return s.recordEvent(ctx, evt.CredID, evt.HolderDID, "Justify", evt.ActorID, "Success", "event "+eventID)
+}
+
+// GetJustification returns the justification attached to an event.
+func (s *AuditContract) GetJustification(ctx contractapi.TransactionContextInterface,
+	eventID string) (*Justification, error) {
+
+	bz, err := ctx.GetStub().GetState(justificationKey(eventID))
+	if err != nil {
+		return nil, err
+	}
+	if bz == nil {
+		return nil, fmt.Errorf("event %s has no justification", eventID)
+	}
+	var j Justification
+	if err := json.Unmarshal(bz, &j); err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+// ===== Helpers =====
+
+func justificationKey(eventID string) string { return "justification:" + eventID }
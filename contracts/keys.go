@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Composite key attributes are joined with U+0000, and partial-key scans
+// match on attribute prefixes, so an empty attribute or one carrying a
+// separator or control character lands a record under the wrong prefix and
+// corrupts every range query over the index. compositeKey validates each
+// attribute before a key is built; keys written before the check can be
+// found with VerifyKeyAttributes and moved aside with RepairKeyAttributes.
+
+// KeyAttributeError is returned when a composite key attribute is unsafe.
+type KeyAttributeError struct {
+	Index    string
+	Position int // attribute index within the key
+	Reason   string
+}
+
+func (e *KeyAttributeError) Error() string {
+	return fmt.Sprintf("%s key attribute %d %s", e.Index, e.Position, e.Reason)
+}
+
+// QuarantinedKey is a composite key RepairKeyAttributes moved out of its
+// index, kept so the record it held is not lost.
+type QuarantinedKey struct {
+	Index   string   `json:"index"`
+	Attrs   []string `json:"attrs"`
+	Value   []byte   `json:"value"`
+	Reason  string   `json:"reason"`
+	MovedBy string   `json:"movedBy"` // tx that moved it
+}
+
+// VerifyKeyAttributes reports the keys on one page of index whose
+// attributes compositeKey would now reject.
+func (s *AdminContract) VerifyKeyAttributes(ctx contractapi.TransactionContextInterface,
+	index string, pageSize int32, bookmark string) (*IndexReport, error) {
+
+	return scanKeyAttributes(ctx, index, pageSize, bookmark, false)
+}
+
+// RepairKeyAttributes is VerifyKeyAttributes that also moves each bad key
+// to a "badkey:" entry holding its attributes and value, since the record
+// cannot be rewritten under a key it never had.
+func (s *AdminContract) RepairKeyAttributes(ctx contractapi.TransactionContextInterface,
+	index string, pageSize int32, bookmark string) (*IndexReport, error) {
+
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	return scanKeyAttributes(ctx, index, pageSize, bookmark, true)
+}
+
+// optionalKeyAttrs are the attribute positions that may be empty, per index.
+// Holder-level events such as consent changes have no credential.
+var optionalKeyAttrs = map[string]map[int]bool{
+	"event~holder": {1: true},
+}
+
+// ===== Helpers =====
+
+// compositeKey is CreateCompositeKey with every attribute validated first.
+func compositeKey(ctx contractapi.TransactionContextInterface, objectType string, attrs []string) (string, error) {
+	if err := checkKeyAttributes(objectType, attrs); err != nil {
+		return "", err
+	}
+	return ctx.GetStub().CreateCompositeKey(objectType, attrs)
+}
+
+func checkKeyAttributes(objectType string, attrs []string) error {
+	for i, attr := range attrs {
+		if attr == "" && optionalKeyAttrs[objectType][i] {
+			continue
+		}
+		if reason := badKeyAttribute(attr); reason != "" {
+			return &KeyAttributeError{Index: objectType, Position: i, Reason: reason}
+		}
+	}
+	return nil
+}
+
+// badKeyAttribute returns why attr cannot be a key attribute, or "".
+func badKeyAttribute(attr string) string {
+	if attr == "" {
+		return "is empty"
+	}
+	if !utf8.ValidString(attr) {
+		return "is not valid UTF-8"
+	}
+	for _, r := range attr {
+		if r == utf8.MaxRune {
+			return "contains U+10FFFF"
+		}
+		if unicode.IsControl(r) {
+			return fmt.Sprintf("contains control character %U", r)
+		}
+	}
+	return ""
+}
+
+func scanKeyAttributes(ctx contractapi.TransactionContextInterface,
+	index string, pageSize int32, bookmark string, repair bool) (*IndexReport, error) {
+
+	raw, err := decodeBookmark(ctx, index, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	iter, meta, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(index, []string{}, pageSize, raw)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	report := &IndexReport{Index: index, Orphaned: []string{}, Missing: []string{}}
+	if report.Bookmark, err = encodeBookmark(ctx, index, meta.Bookmark); err != nil {
+		return nil, err
+	}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		report.Scanned++
+		_, attrs, err := ctx.GetStub().SplitCompositeKey(kv.Key)
+		if err != nil {
+			return nil, err
+		}
+		bad := checkKeyAttributes(index, attrs)
+		if bad == nil {
+			continue
+		}
+		report.Orphaned = append(report.Orphaned, fmt.Sprintf("%s%q", index, attrs))
+		if !repair {
+			continue
+		}
+		bz, _ := json.Marshal(QuarantinedKey{
+			Index:   index,
+			Attrs:   attrs,
+			Value:   kv.Value,
+			Reason:  bad.Error(),
+			MovedBy: ctx.GetStub().GetTxID(),
+		})
+		if err := ctx.GetStub().PutState(quarantineKey(kv.Key), bz); err != nil {
+			return nil, err
+		}
+		if err := ctx.GetStub().DelState(kv.Key); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+func quarantineKey(key string) string {
+	return "badkey:" + base64.RawURLEncoding.EncodeToString([]byte(strings.TrimPrefix(key, "\x00")))
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// CredentialLink records how one credential relates to another, e.g. that a
+// reissued credential replaces a revoked one, so verifiers who hold the old
+// ID can find the current one. Links are stored under cred~link
+// (fromCredId, toCredId) with a value-less cred~backlink entry for lookups
+// from the other end.
+type CredentialLink struct {
+	FromCredID string `json:"fromCredId"`
+	ToCredID   string `json:"toCredId"`
+	Relation   string `json:"relation"` // Replaces | RelatedTo
+	ActorID    string `json:"actorId"`
+	LinkedBy   string `json:"linkedBy"`  // submitting MSP ID
+	CreatedAt  string `json:"createdAt"` // RFC3339
+}
+
+// Link relations. Replaces needs the replaced credential to be revoked or
+// expired already, in this transaction at the latest (see Atomic).
+var linkRelations = map[string]bool{"Replaces": true, "RelatedTo": true}
+
+// LinkCredentials links fromCredID to toCredID and records a Link event on
+// fromCredID. Callers must belong to the MSP of fromCredID's issuer.
+func (s *CredentialContract) LinkCredentials(ctx contractapi.TransactionContextInterface,
+	fromCredID, toCredID, relation, actorID string) error {
+
+	if !linkRelations[relation] {
+		return fmt.Errorf("relation must be Replaces or RelatedTo")
+	}
+	if fromCredID == toCredID {
+		return fmt.Errorf("a credential cannot be linked to itself")
+	}
+	from, err := s.getCred(ctx, fromCredID)
+	if err != nil {
+		return err
+	}
+	to, err := s.getCred(ctx, toCredID)
+	if err != nil {
+		return err
+	}
+	mspID, err := s.requireIssuerMSP(ctx, from.IssuerID)
+	if err != nil {
+		return err
+	}
+	if relation == "Replaces" && to.Status != "Revoked" && to.Status != "Expired" && !to.expired() {
+		return fmt.Errorf("credential %s is %s; revoke it before linking a replacement", toCredID, to.Status)
+	}
+
+	ck, err := compositeKey(ctx, "cred~link", []string{fromCredID, toCredID})
+	if err != nil {
+		return err
+	}
+	existing, err := batchGet(ctx, ck)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("credentials %s and %s are already linked", fromCredID, toCredID)
+	}
+	link := CredentialLink{
+		FromCredID: fromCredID,
+		ToCredID:   toCredID,
+		Relation:   relation,
+		ActorID:    actorID,
+		LinkedBy:   mspID,
+		CreatedAt:  nowRFC3339(),
+	}
+	bz, _ := json.Marshal(link)
+	if err := batchPut(ctx, ck, bz); err != nil {
+		return err
+	}
+	back, err := compositeKey(ctx, "cred~backlink", []string{toCredID, fromCredID})
+	if err != nil {
+		return err
+	}
+	if err := batchPut(ctx, back, indexMarker); err != nil {
+		return err
+	}
+	return s.recordEvent(ctx, fromCredID, from.HolderDID, "Link", actorID, "Success", relation+" "+toCredID)
+}
+
+// GetCredentialLinks returns the links from and to a credential.
+func (s *CredentialContract) GetCredentialLinks(ctx contractapi.TransactionContextInterface,
+	credID string) ([]CredentialLink, error) {
+
+	links := []CredentialLink{}
+	for _, objectType := range []string{"cred~link", "cred~backlink"} {
+		iter, err := ctx.GetStub().GetStateByPartialCompositeKey(objectType, []string{credID})
+		if err != nil {
+			return nil, err
+		}
+		for iter.HasNext() {
+			kv, err := iter.Next()
+			if err != nil {
+				iter.Close()
+				return nil, err
+			}
+			bz := kv.Value
+			if objectType == "cred~backlink" {
+				_, attrs, err := ctx.GetStub().SplitCompositeKey(kv.Key)
+				if err != nil {
+					iter.Close()
+					return nil, err
+				}
+				ck, err := compositeKey(ctx, "cred~link", []string{attrs[1], credID})
+				if err != nil {
+					iter.Close()
+					return nil, err
+				}
+				if bz, err = ctx.GetStub().GetState(ck); err != nil {
+					iter.Close()
+					return nil, err
+				}
+			}
+			var link CredentialLink
+			if err := json.Unmarshal(bz, &link); err != nil {
+				iter.Close()
+				return nil, err
+			}
+			links = append(links, link)
+		}
+		iter.Close()
+	}
+	return links, nil
+}
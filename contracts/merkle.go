@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// MerkleProofStep is one sibling on the path from a leaf to the root.
+type MerkleProofStep struct {
+	Hash     string `json:"hash"`     // hex sha256
+	Position string `json:"position"` // left | right: which side the sibling sits on
+}
+
+// Leaves and interior nodes are domain-separated (RFC 6962 style) so an
+// interior node can never be passed off as a leaf.
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// attributeLeaf hashes one credential attribute as committed by the issuer:
+// sha256(0x00 || attrPath || 0x00 || attrHash).
+func attributeLeaf(attrPath, attrHash string) ([]byte, error) {
+	raw, err := hex.DecodeString(attrHash)
+	if err != nil {
+		return nil, fmt.Errorf("attribute hash must be hex: %v", err)
+	}
+	h := sha256.New()
+	h.Write([]byte{merkleLeafPrefix})
+	h.Write([]byte(attrPath))
+	h.Write([]byte{0})
+	h.Write(raw)
+	return h.Sum(nil), nil
+}
+
+// eventLeaf hashes a stored audit event: sha256(0x00 || event JSON).
+func eventLeaf(eventJSON []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{merkleLeafPrefix})
+	h.Write(eventJSON)
+	return h.Sum(nil)
+}
+
+func merkleNode(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{merkleNodePrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// merkleLevels builds the tree bottom-up. An odd node at the end of a level
+// is carried up unchanged rather than duplicated.
+func merkleLevels(leaves [][]byte) [][][]byte {
+	levels := [][][]byte{leaves}
+	for cur := leaves; len(cur) > 1; {
+		next := make([][]byte, 0, (len(cur)+1)/2)
+		for i := 0; i < len(cur); i += 2 {
+			if i+1 == len(cur) {
+				next = append(next, cur[i])
+			} else {
+				next = append(next, merkleNode(cur[i], cur[i+1]))
+			}
+		}
+		levels = append(levels, next)
+		cur = next
+	}
+	return levels
+}
+
+// merkleRoot returns the root over leaves; an empty tree hashes to sha256("").
+func merkleRoot(leaves [][]byte) []byte {
+	if len(leaves) == 0 {
+		sum := sha256.Sum256(nil)
+		return sum[:]
+	}
+	levels := merkleLevels(leaves)
+	return levels[len(levels)-1][0]
+}
+
+// merkleProof returns the sibling path for leaves[idx].
+func merkleProof(leaves [][]byte, idx int) []MerkleProofStep {
+	var proof []MerkleProofStep
+	levels := merkleLevels(leaves)
+	for _, level := range levels[:len(levels)-1] {
+		if idx%2 == 1 {
+			proof = append(proof, MerkleProofStep{Hash: hex.EncodeToString(level[idx-1]), Position: "left"})
+		} else if idx+1 < len(level) {
+			proof = append(proof, MerkleProofStep{Hash: hex.EncodeToString(level[idx+1]), Position: "right"})
+		}
+		idx /= 2
+	}
+	return proof
+}
+
+// verifyMerkleProof folds the proof into leaf and compares with the hex root.
+func verifyMerkleProof(leaf []byte, proof []MerkleProofStep, root string) (bool, error) {
+	want, err := hex.DecodeString(root)
+	if err != nil {
+		return false, fmt.Errorf("merkle root must be hex: %v", err)
+	}
+	cur := leaf
+	for i, step := range proof {
+		sib, err := hex.DecodeString(step.Hash)
+		if err != nil {
+			return false, fmt.Errorf("proof step %d: hash must be hex: %v", i, err)
+		}
+		switch step.Position {
+		case "left":
+			cur = merkleNode(sib, cur)
+		case "right":
+			cur = merkleNode(cur, sib)
+		default:
+			return false, fmt.Errorf("proof step %d: position must be left or right", i)
+		}
+	}
+	return bytes.Equal(cur, want), nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	_ "embed"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+//go:generate go run ./cmd/genmeta -dir . -out META-INF/metadata.json
+
+// contractMetadata is the generated self-description the contract API also
+// loads from META-INF/metadata.json. Regenerate after changing transactions.
+//
+//go:embed META-INF/metadata.json
+var contractMetadata string
+
+// evaluateTransactions are read-only; gateways evaluate rather than submit them.
+var evaluateTransactions = []string{
+	"GetAccessPolicy",
+	"GetAccessReview",
+	"GetActorReputation",
+	"GetAttestations",
+	"GetAuditTrailIntegrityProof",
+	"GetBlockedHolders",
+	"GetBenchmarkReport",
+	"GetBreakGlassQueue",
+	"GetComplianceFindings",
+	"GetConfig",
+	"GetConsents",
+	"GetCounters",
+	"GetCredType",
+	"GetCredTypeHistory",
+	"GetCredentialLinks",
+	"GetCredentialsByHolderType",
+	"GetCredentialsExpiringSoon",
+	"GetCurrentCustodian",
+	"GetCustodyChain",
+	"GetDPACoverage",
+	"GetDelegations",
+	"GetEventWriteCost",
+	"GetEventsByActor",
+	"GetEventsSince",
+	"GetFeatureFlags",
+	"GetHolderBlock",
+	"GetHolderSummary",
+	"GetIndexHealth",
+	"GetIssuer",
+	"GetJurisdictionPolicy",
+	"GetJustification",
+	"GetLatestRevocationSnapshot",
+	"GetMetadata",
+	"GetMirroredRevocation",
+	"GetPendingIssue",
+	"GetPeriodDigest",
+	"GetProposal",
+	"GetRevocationNotices",
+	"GetRevocationSnapshot",
+	"GetTransfer",
+	"GetVerifier",
+	"GetVerifySummaries",
+	"LintState",
+	"QueryAuditTrail",
+	"QueryCredentials",
+	"QueryEventsByTime",
+	"ResolvePseudonym",
+	"VerifyIndexes",
+	"VerifyKeyAttributes",
+}
+
+// GetEvaluateTransactions tells the contract API which transactions are
+// read-only so their metadata is tagged evaluate.
+func (s *ledger) GetEvaluateTransactions() []string {
+	return evaluateTransactions
+}
+
+// GetMetadata returns the contract metadata as JSON: every transaction with
+// its description, parameter schemas and return schema, plus the shared
+// object schemas. Gateways and codegen use it to discover the contract.
+func (s *AdminContract) GetMetadata(ctx contractapi.TransactionContextInterface) (string, error) {
+	return contractMetadata, nil
+}
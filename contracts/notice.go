@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// RevocationNotice records that an issuer notified a relying party of a
+// revocation and, once acknowledged, that the party received it. Some
+// regulations require issuers to prove both; the ledger gives them a
+// record neither side can later dispute.
+type RevocationNotice struct {
+	CredID       string `json:"credId"`
+	RecipientDID string `json:"recipientDid"`
+	IssuerID     string `json:"issuerId"`
+	Status       string `json:"status"`   // Sent | Acknowledged
+	SentBy       string `json:"sentBy"`   // submitting MSP ID
+	SentTxID     string `json:"sentTxId"` // tx that carried the notice
+	SentAt       string `json:"sentAt"`   // RFC3339
+	AckProofHash string `json:"ackProofHash,omitempty"`
+	AckTxID      string `json:"ackTxId,omitempty"`
+	AckedAt      string `json:"ackedAt,omitempty"` // RFC3339
+}
+
+// NotifyRevocation sends a notice of credID's revocation to recipientDID as
+// a RevocationNotice chaincode event, which replaces the transaction's
+// AuditTrail event; the Notify event is still stored in the holder's trail.
+// Only the issuing org may notify, and each recipient once per credential.
+func (s *CredentialContract) NotifyRevocation(ctx contractapi.TransactionContextInterface,
+	credID, recipientDID string) (*RevocationNotice, error) {
+
+	if !didSyntax.MatchString(recipientDID) {
+		return nil, fmt.Errorf("recipient DID %q is not a valid DID", recipientDID)
+	}
+	cred, err := s.getCred(ctx, credID)
+	if err != nil {
+		return nil, err
+	}
+	if cred.Status != "Revoked" {
+		return nil, fmt.Errorf("credential %s is not revoked", credID)
+	}
+	mspID, err := s.requireIssuerMSP(ctx, cred.IssuerID)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := getNotice(ctx, credID, recipientDID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("%s was already notified of credential %s's revocation", recipientDID, credID)
+	}
+
+	n := &RevocationNotice{
+		CredID:       credID,
+		RecipientDID: recipientDID,
+		IssuerID:     cred.IssuerID,
+		Status:       "Sent",
+		SentBy:       mspID,
+		SentTxID:     ctx.GetStub().GetTxID(),
+		SentAt:       nowRFC3339(),
+	}
+	if err := putNotice(ctx, n); err != nil {
+		return nil, err
+	}
+	if err := s.recordEvent(ctx, credID, cred.HolderDID, "Notify", cred.IssuerID, "Success",
+		"revocation notice to "+recipientDID); err != nil {
+		return nil, err
+	}
+	if err := emitEvent(ctx, "RevocationNotice", n); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// AcknowledgeNotice records the recipient's receipt of a revocation notice.
+// For did:key recipients, recipientProof must be a base64 Ed25519 signature
+// over "notice:ack:<credID>"; other methods are checked by the gateway,
+// which alone may submit their acknowledgement (see checkHolderProof).
+func (s *CredentialContract) AcknowledgeNotice(ctx contractapi.TransactionContextInterface,
+	credID, recipientDID, recipientProof string) (*RevocationNotice, error) {
+
+	n, err := getNotice(ctx, credID, recipientDID)
+	if err != nil {
+		return nil, err
+	}
+	if n == nil {
+		return nil, fmt.Errorf("no revocation notice for credential %s to %s", credID, recipientDID)
+	}
+	if n.Status == "Acknowledged" {
+		return nil, fmt.Errorf("notice for credential %s to %s is already acknowledged", credID, recipientDID)
+	}
+	if err := checkHolderProof(ctx, recipientDID, recipientProof, "notice:ack:"+credID); err != nil {
+		return nil, err
+	}
+	cred, err := s.getCred(ctx, credID)
+	if err != nil {
+		return nil, err
+	}
+
+	n.Status = "Acknowledged"
+	n.AckProofHash = proofHash(recipientProof)
+	n.AckTxID = ctx.GetStub().GetTxID()
+	n.AckedAt = nowRFC3339()
+	if err := putNotice(ctx, n); err != nil {
+		return nil, err
+	}
+	if err := s.recordEvent(ctx, credID, cred.HolderDID, "NoticeAck", recipientDID, "Success",
+		"revocation notice acknowledged"); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// GetRevocationNotices lists the notices sent for a credential.
+func (s *CredentialContract) GetRevocationNotices(ctx contractapi.TransactionContextInterface,
+	credID string) ([]RevocationNotice, error) {
+
+	iter, err := ctx.GetStub().GetStateByPartialCompositeKey("notice~cred", []string{credID})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	notices := []RevocationNotice{}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		var n RevocationNotice
+		if err := json.Unmarshal(kv.Value, &n); err != nil {
+			return nil, err
+		}
+		notices = append(notices, n)
+	}
+	return notices, nil
+}
+
+// ===== Helpers =====
+
+func getNotice(ctx contractapi.TransactionContextInterface, credID, recipientDID string) (*RevocationNotice, error) {
+	ck, err := compositeKey(ctx, "notice~cred", []string{credID, recipientDID})
+	if err != nil {
+		return nil, err
+	}
+	bz, err := ctx.GetStub().GetState(ck)
+	if err != nil || bz == nil {
+		return nil, err
+	}
+	var n RevocationNotice
+	if err := json.Unmarshal(bz, &n); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+func putNotice(ctx contractapi.TransactionContextInterface, n *RevocationNotice) error {
+	ck, err := compositeKey(ctx, "notice~cred", []string{n.CredID, n.RecipientDID})
+	if err != nil {
+		return err
+	}
+	bz, _ := json.Marshal(n)
+	return ctx.GetStub().PutState(ck, bz)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// AccessPolicy is a channel-wide list of allow/deny rules checked before
+// every transaction, so consortiums can tighten who calls what without a
+// chaincode upgrade. It is the on-chain counterpart of the gateway's OPA
+// policy (api/policy) and deliberately simpler: rules match on transaction,
+// caller MSP and role attribute only. The first matching rule decides;
+// with none matching, Default applies. No policy set means allow.
+type AccessPolicy struct {
+	Default string       `json:"default"` // allow | deny; empty means allow
+	Rules   []AccessRule `json:"rules"`
+	// Redaction limits how much of event reasons each caller reads; see
+	// redaction.go. Nil shows reasons in full.
+	Redaction *ReasonRedaction `json:"redaction,omitempty"`
+	UpdatedBy string           `json:"updatedBy"` // MSP ID of the admin
+	UpdatedAt string           `json:"updatedAt"` // RFC3339
+}
+
+// AccessRule matches a call when every non-empty field matches.
+type AccessRule struct {
+	ID     string `json:"id"`
+	Effect string `json:"effect"` // allow | deny
+	// Transactions are "<namespace>:<Transaction>", "<namespace>:*", a bare
+	// transaction name in any contract, or "*".
+	Transactions []string `json:"transactions"`
+	MSPs         []string `json:"msps"` // caller MSP IDs; empty matches any
+	Role         string   `json:"role"` // required audittrail.role attribute value
+}
+
+// policyExempt transactions bypass the policy so a bad rule set can always
+// be replaced.
+var policyExempt = map[string]bool{
+	contractNames["AdminContract"] + ":SetAccessPolicy": true,
+	contractNames["AdminContract"] + ":GetAccessPolicy": true,
+}
+
+// SetAccessPolicy replaces the access policy.
+func (s *AdminContract) SetAccessPolicy(ctx contractapi.TransactionContextInterface,
+	policy AccessPolicy) error {
+
+	if err := requireAdmin(ctx); err != nil {
+		return err
+	}
+	if policy.Default != "" && policy.Default != "allow" && policy.Default != "deny" {
+		return fmt.Errorf("default must be allow or deny")
+	}
+	for i, r := range policy.Rules {
+		if r.Effect != "allow" && r.Effect != "deny" {
+			return fmt.Errorf("rule %d (%s): effect must be allow or deny", i, r.ID)
+		}
+		if len(r.Transactions) == 0 {
+			return fmt.Errorf("rule %d (%s): transactions is required", i, r.ID)
+		}
+	}
+	if err := validateRedaction(policy.Redaction); err != nil {
+		return err
+	}
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return err
+	}
+	policy.UpdatedBy = mspID
+	policy.UpdatedAt = nowRFC3339()
+
+	bz, _ := json.Marshal(policy)
+	if err := ctx.GetStub().PutState(accessPolicyKey, bz); err != nil {
+		return err
+	}
+	return emitEvent(ctx, "AccessPolicyChanged", policy)
+}
+
+// GetAccessPolicy returns the access policy; an empty one when none is set.
+func (s *AdminContract) GetAccessPolicy(ctx contractapi.TransactionContextInterface) (*AccessPolicy, error) {
+	p, err := loadAccessPolicy(ctx)
+	if err != nil || p != nil {
+		return p, err
+	}
+	return &AccessPolicy{Rules: []AccessRule{}}, nil
+}
+
+// ===== Helpers =====
+
+const accessPolicyKey = "policy:access"
+
+func loadAccessPolicy(ctx contractapi.TransactionContextInterface) (*AccessPolicy, error) {
+	bz, err := ctx.GetStub().GetState(accessPolicyKey)
+	if err != nil || bz == nil {
+		return nil, err
+	}
+	var p AccessPolicy
+	if err := json.Unmarshal(bz, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// checkAccessPolicy is every contract's BeforeTransaction hook.
+func checkAccessPolicy(ctx contractapi.TransactionContextInterface) error {
+	fn, _ := ctx.GetStub().GetFunctionAndParameters()
+	if !strings.Contains(fn, ":") {
+		fn = contractNames[defaultContract] + ":" + fn
+	}
+	return authorizeCall(ctx, fn)
+}
+
+// authorizeCall applies the policy to fn, "<namespace>:<Transaction>". Atomic
+// runs it for each sub-operation, so bundling calls cannot get around a rule
+// on one of them.
+func authorizeCall(ctx contractapi.TransactionContextInterface, fn string) error {
+	if policyExempt[fn] {
+		return nil
+	}
+	p, err := loadAccessPolicy(ctx)
+	if err != nil || p == nil {
+		return err
+	}
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return err
+	}
+
+	for _, r := range p.Rules {
+		if !r.matches(ctx, fn, mspID) {
+			continue
+		}
+		if r.Effect == "deny" {
+			return fmt.Errorf("access policy rule %s denies %s to %s", r.ID, fn, mspID)
+		}
+		return nil
+	}
+	if p.Default == "deny" {
+		return fmt.Errorf("access policy denies %s to %s", fn, mspID)
+	}
+	return nil
+}
+
+func (r *AccessRule) matches(ctx contractapi.TransactionContextInterface, fn, mspID string) bool {
+	namespace, name, _ := strings.Cut(fn, ":")
+	txMatch := false
+	for _, t := range r.Transactions {
+		if t == "*" || t == fn || t == name || t == namespace+":*" {
+			txMatch = true
+			break
+		}
+	}
+	if !txMatch {
+		return false
+	}
+	if len(r.MSPs) > 0 {
+		found := false
+		for _, m := range r.MSPs {
+			if m == mspID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if r.Role != "" {
+		return ctx.GetClientIdentity().AssertAttributeValue(roleAttr, r.Role) == nil
+	}
+	return true
+}
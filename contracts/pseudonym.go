@@ -0,0 +1,221 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Holder pseudonyms. With PseudonymEpochDays set, events are stored and
+// emitted under a pseudonym of the holder's DID that changes every epoch,
+// so the public trail cannot be correlated across epochs by DID. The
+// pseudonym is an HMAC of the DID and epoch under a key only the gateway
+// holds; it passes the key in the transient field "pseudonymKey", which
+// never reaches the ledger, on every transaction that writes or reads a
+// holder's events. The linkage back to the DID is written to the private
+// data collection auditorLinkage, held only by the auditor orgs' peers
+// (see collections_config.json), and resolved with ResolvePseudonym.
+//
+// Credential records still name their holder; pseudonyms cover the trail.
+
+// PseudonymLinkage ties a pseudonym to the holder DID it stands for.
+type PseudonymLinkage struct {
+	Pseudonym string `json:"pseudonym"`
+	HolderDID string `json:"holderDid"`
+	Epoch     int64  `json:"epoch"`
+	// Salt is derived from the key, so the value's hash, which is public,
+	// cannot be confirmed by guessing DIDs.
+	Salt string `json:"salt"`
+}
+
+const (
+	pseudonymKeyField = "pseudonymKey"
+	pseudonymPrefix   = "pn:"
+	linkageCollection = "auditorLinkage"
+)
+
+// ResolvePseudonym returns the holder behind a pseudonym, for callers with
+// audittrail.role=auditor. It must be evaluated on a peer of an org in the
+// auditorLinkage collection; other peers do not hold the linkage.
+func (s *AuditContract) ResolvePseudonym(ctx contractapi.TransactionContextInterface,
+	pseudonym string) (*PseudonymLinkage, error) {
+
+	if err := ctx.GetClientIdentity().AssertAttributeValue(roleAttr, "auditor"); err != nil {
+		return nil, fmt.Errorf("auditor role required: %v", err)
+	}
+	bz, err := ctx.GetStub().GetPrivateData(linkageCollection, linkageKey(pseudonym))
+	if err != nil {
+		return nil, err
+	}
+	if bz == nil {
+		return nil, fmt.Errorf("no linkage for pseudonym %s on this peer", pseudonym)
+	}
+	var l PseudonymLinkage
+	if err := json.Unmarshal(bz, &l); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// ===== Helpers =====
+
+// pseudonymKey returns the HMAC key passed with the transaction. It is
+// required whenever pseudonyms are on.
+func pseudonymKey(ctx contractapi.TransactionContextInterface) ([]byte, error) {
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, err
+	}
+	key, ok := transient[pseudonymKeyField]
+	if !ok {
+		return nil, fmt.Errorf("transient field %s is required while holder pseudonyms are on", pseudonymKeyField)
+	}
+	if len(key) < 32 {
+		return nil, fmt.Errorf("%s must be at least 32 bytes", pseudonymKeyField)
+	}
+	return key, nil
+}
+
+func pseudonymEpoch(cfg *ContractConfig, t time.Time) int64 {
+	return t.Unix() / int64(cfg.PseudonymEpochDays*24*60*60)
+}
+
+func holderPseudonym(key []byte, holderDID string, epoch int64) string {
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%s|%d", holderDID, epoch)
+	return fmt.Sprintf("%s%d:%s", pseudonymPrefix, epoch, base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16]))
+}
+
+// pseudonymizeEvent replaces the holder's DID on evt, as holder and as
+// actor, with its pseudonym for the epoch the event occurred in, and writes
+// the linkage. Events already carrying a pseudonym are left alone.
+func pseudonymizeEvent(ctx contractapi.TransactionContextInterface, cfg *ContractConfig, evt *AccessEvent) error {
+	if cfg.PseudonymEpochDays == 0 || strings.HasPrefix(evt.HolderDID, pseudonymPrefix) {
+		return nil
+	}
+	key, err := pseudonymKey(ctx)
+	if err != nil {
+		return err
+	}
+	at, err := time.Parse(time.RFC3339, evt.OccurredAt)
+	if err != nil {
+		return err
+	}
+	epoch := pseudonymEpoch(cfg, at)
+	pseudonym := holderPseudonym(key, evt.HolderDID, epoch)
+
+	salt := hmac.New(sha256.New, key)
+	salt.Write([]byte("salt|" + pseudonym))
+	bz, _ := json.Marshal(PseudonymLinkage{
+		Pseudonym: pseudonym,
+		HolderDID: evt.HolderDID,
+		Epoch:     epoch,
+		Salt:      hex.EncodeToString(salt.Sum(nil)),
+	})
+	// A blind write: peers outside the collection can endorse it.
+	if err := ctx.GetStub().PutPrivateData(linkageCollection, linkageKey(pseudonym), bz); err != nil {
+		return err
+	}
+	if evt.ActorID == evt.HolderDID {
+		evt.ActorID = pseudonym
+	}
+	evt.HolderDID = pseudonym
+	return nil
+}
+
+// holderKeys returns the event~holder prefixes of a holder's events, oldest
+// first: the DID itself, for events from before pseudonyms were turned on,
+// then the pseudonym of each epoch since.
+func holderKeys(ctx contractapi.TransactionContextInterface, holderDID string) ([]string, error) {
+	cfg, err := loadConfig(ctx)
+	if err != nil || cfg.PseudonymEpochDays == 0 {
+		return []string{holderDID}, err
+	}
+	key, err := pseudonymKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	since, err := time.Parse(time.RFC3339, cfg.PseudonymsSince)
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{holderDID}
+	for epoch := pseudonymEpoch(cfg, since); epoch <= pseudonymEpoch(cfg, time.Now()); epoch++ {
+		keys = append(keys, holderPseudonym(key, holderDID, epoch))
+	}
+	return keys, nil
+}
+
+// eventHolder returns the DID of the holder evt was recorded for. A
+// pseudonym is matched against the current holder of the event's
+// credential; holder-level events under a pseudonym cannot be resolved
+// without the linkage.
+func (s *ledger) eventHolder(ctx contractapi.TransactionContextInterface, evt *AccessEvent) (string, error) {
+	if !strings.HasPrefix(evt.HolderDID, pseudonymPrefix) {
+		return evt.HolderDID, nil
+	}
+	if evt.CredID != "" {
+		cred, err := s.getCred(ctx, evt.CredID)
+		if err != nil {
+			return "", err
+		}
+		keys, err := holderKeys(ctx, cred.HolderDID)
+		if err != nil {
+			return "", err
+		}
+		for _, key := range keys {
+			if key == evt.HolderDID {
+				return cred.HolderDID, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("cannot resolve the holder of pseudonymous event %s", evt.EventID)
+}
+
+// storedEvent is one event~holder entry.
+type storedEvent struct {
+	Key   string
+	Value []byte
+}
+
+// holderEvents returns a holder's stored events under every prefix from
+// holderPrefixes, optionally narrowed to one credential. A credential's
+// events are not all in its current bucket: those recorded before the
+// holder was bucketed, or before its bucket count grew, stay where they
+// were written, so every prefix is scanned either way.
+func holderEvents(ctx contractapi.TransactionContextInterface, holderDID, credID string) ([]storedEvent, error) {
+	prefixes, err := holderPrefixes(ctx, holderDID)
+	if err != nil {
+		return nil, err
+	}
+	var out []storedEvent
+	for _, key := range prefixes {
+		attrs := []string{key}
+		if credID != "" {
+			attrs = append(attrs, credID)
+		}
+		iter, err := ctx.GetStub().GetStateByPartialCompositeKey("event~holder", attrs)
+		if err != nil {
+			return nil, err
+		}
+		for iter.HasNext() {
+			kv, err := iter.Next()
+			if err != nil {
+				iter.Close()
+				return nil, err
+			}
+			out = append(out, storedEvent{Key: kv.Key, Value: kv.Value})
+		}
+		iter.Close()
+	}
+	return out, nil
+}
+
+func linkageKey(pseudonym string) string { return "linkage:" + pseudonym }
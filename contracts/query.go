@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Rich queries need CouchDB and the indexes shipped in META-INF; without an
+// index CouchDB silently scans every document. Each query shape below names
+// the index it relies on, and GetIndexHealth probes that they all exist.
+// Only JSON-encoded state is visible to rich queries (see StateCodec).
+
+// queryIndex describes one index from META-INF/statedb/couchdb/indexes.
+type queryIndex struct {
+	ddoc  string
+	name  string
+	field string
+}
+
+var queryIndexes = []queryIndex{
+	{"indexStatusDoc", "indexStatus", "status"},
+	{"indexCredTypeDoc", "indexCredType", "credType"},
+	{"indexIssuerDoc", "indexIssuer", "issuerId"},
+	{"indexOccurredAtDoc", "indexOccurredAt", "occurredAt"},
+}
+
+// IndexHealth reports whether the rich-query indexes are usable.
+type IndexHealth struct {
+	CouchDB  bool              `json:"couchDb"`
+	Missing  []string          `json:"missing"`  // index names that failed the probe
+	Warnings map[string]string `json:"warnings"` // index name -> CouchDB error
+}
+
+// CredentialPage is one page of a credential query.
+type CredentialPage struct {
+	Credentials []Credential `json:"credentials"`
+	Bookmark    string       `json:"bookmark"`
+}
+
+// EventPage is one page of an event query.
+type EventPage struct {
+	Events   []AccessEvent `json:"events"`
+	Bookmark string        `json:"bookmark"`
+}
+
+// QueryCredentials filters credentials by status, credType and/or issuerID.
+// At least one filter is required so the query can be served by an index;
+// the most selective given filter picks the index.
+func (s *CredentialContract) QueryCredentials(ctx contractapi.TransactionContextInterface,
+	status, credType, issuerID string, pageSize int32, bookmark string) (*CredentialPage, error) {
+
+	selector := map[string]interface{}{"credId": map[string]bool{"$exists": true}}
+	filters := map[string]string{"issuerId": issuerID, "credType": credType, "status": status}
+	var idx *queryIndex
+	for _, field := range []string{"issuerId", "credType", "status"} {
+		if filters[field] == "" {
+			continue
+		}
+		selector[field] = filters[field]
+		if idx == nil {
+			idx = indexOn(field)
+		}
+	}
+	if idx == nil {
+		return nil, fmt.Errorf("at least one of status, credType or issuerId is required")
+	}
+
+	iter, meta, err := ctx.GetStub().GetQueryResultWithPagination(idx.query(selector), pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	page := &CredentialPage{Credentials: []Credential{}, Bookmark: meta.Bookmark}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		cred, err := decodeCred(ctx, kv.Value)
+		if err != nil {
+			return nil, err
+		}
+		page.Credentials = append(page.Credentials, *cred)
+	}
+	return page, nil
+}
+
+// QueryEventsByTime returns events with occurredAt in [from, to), oldest first.
+func (s *AuditContract) QueryEventsByTime(ctx contractapi.TransactionContextInterface,
+	from, to string, pageSize int32, bookmark string) (*EventPage, error) {
+
+	if _, _, err := parsePeriod(from, to); err != nil {
+		return nil, err
+	}
+	selector := map[string]interface{}{
+		"occurredAt": map[string]string{"$gte": from, "$lt": to},
+		"action":     map[string]bool{"$exists": true},
+	}
+	iter, meta, err := ctx.GetStub().GetQueryResultWithPagination(indexOn("occurredAt").query(selector), pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	page := &EventPage{Events: []AccessEvent{}, Bookmark: meta.Bookmark}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		evt, err := decodeEvent(ctx, kv.Value)
+		if err != nil {
+			return nil, err
+		}
+		page.Events = append(page.Events, *evt)
+	}
+	if err := redactEvents(ctx, page.Events); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// GetEventsByActor returns one page of the events an issuer, verifier or
+// other actor recorded, from the event~actor index. Events recorded before
+// the index existed appear once ReindexEvents has backfilled them.
+func (s *AuditContract) GetEventsByActor(ctx contractapi.TransactionContextInterface,
+	actorID string, pageSize int32, bookmark string) (*EventPage, error) {
+
+	raw, err := decodeBookmark(ctx, "event~actor", bookmark)
+	if err != nil {
+		return nil, err
+	}
+	iter, meta, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(
+		"event~actor", []string{actorID}, pageSize, raw)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	page := &EventPage{Events: []AccessEvent{}}
+	if page.Bookmark, err = encodeBookmark(ctx, "event~actor", meta.Bookmark); err != nil {
+		return nil, err
+	}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		evt, err := eventByIndex(ctx, kv.Key, kv.Value)
+		if err != nil {
+			return nil, err
+		}
+		if evt == nil {
+			continue
+		}
+		page.Events = append(page.Events, *evt)
+	}
+	if err := redactEvents(ctx, page.Events); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// GetIndexHealth probes each shipped index with a query sorted on its field.
+// CouchDB refuses a sort no index can serve, so a failed probe means the
+// index is missing and queries relying on it would fail or full-scan.
+func (s *AdminContract) GetIndexHealth(ctx contractapi.TransactionContextInterface) (*IndexHealth, error) {
+	health := &IndexHealth{CouchDB: true, Missing: []string{}, Warnings: map[string]string{}}
+	for _, idx := range queryIndexes {
+		selector := map[string]interface{}{idx.field: map[string]string{"$gt": ""}}
+		iter, _, err := ctx.GetStub().GetQueryResultWithPagination(idx.query(selector), 1, "")
+		if err != nil {
+			if strings.Contains(strings.ToLower(err.Error()), "leveldb") {
+				health.CouchDB = false
+				health.Warnings["statedb"] = "rich queries are unavailable on LevelDB"
+				return health, nil
+			}
+			health.Missing = append(health.Missing, idx.name)
+			health.Warnings[idx.name] = err.Error()
+			continue
+		}
+		iter.Close()
+	}
+	return health, nil
+}
+
+// ===== Helpers =====
+
+func indexOn(field string) *queryIndex {
+	for i := range queryIndexes {
+		if queryIndexes[i].field == field {
+			return &queryIndexes[i]
+		}
+	}
+	panic("no index on " + field)
+}
+
+// query builds a CouchDB query pinned to the index and sorted on its field.
+func (idx *queryIndex) query(selector map[string]interface{}) string {
+	q := map[string]interface{}{
+		"selector":  selector,
+		"use_index": []string{"_design/" + idx.ddoc, idx.name},
+		"sort":      []map[string]string{{idx.field: "asc"}},
+	}
+	bz, _ := json.Marshal(q)
+	return string(bz)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Reason redaction. Event reasons can be sensitive (why a credential was
+// revoked, why a check was denied), so the access policy may say how much
+// of them each caller sees in event queries (QueryAuditTrail,
+// GetEventsSince, GetEventsByActor, QueryEventsByTime):
+//
+//	full      the reason as recorded
+//	category  only reasonCategory, e.g. issuers and auditors read
+//	          "misconduct under investigation" while verifiers and holders
+//	          read "PrivilegeWithdrawn"
+//	none      neither
+//
+// Stored events are never changed; integrity proofs and exports of stored
+// records still carry the full reason.
+
+// ReasonRedaction is the redaction section of the AccessPolicy.
+type ReasonRedaction struct {
+	Rules   []RedactionRule `json:"rules"`   // first match decides
+	Default string          `json:"default"` // full | category | none; empty means full
+	// Categories classify free-text reasons; the first whose keywords match
+	// names the reasonCategory. Unmatched reasons are "Unspecified".
+	Categories []ReasonCategory `json:"categories"`
+}
+
+// RedactionRule matches a caller and event when every non-empty field matches.
+type RedactionRule struct {
+	ID      string   `json:"id"`
+	Role    string   `json:"role"`    // caller's audittrail.role, e.g. issuer | auditor | verifier | holder
+	MSPs    []string `json:"msps"`    // caller MSP IDs
+	Actions []string `json:"actions"` // event actions, e.g. ["Revoke"]
+	View    string   `json:"view"`    // full | category | none
+}
+
+// ReasonCategory names a class of reasons.
+type ReasonCategory struct {
+	Category string   `json:"category"`
+	Keywords []string `json:"keywords"` // case-insensitive substrings of the reason
+}
+
+const unspecifiedReason = "Unspecified"
+
+var redactionViews = map[string]bool{"full": true, "category": true, "none": true}
+
+// ===== Helpers =====
+
+func validateRedaction(r *ReasonRedaction) error {
+	if r == nil {
+		return nil
+	}
+	if r.Default != "" && !redactionViews[r.Default] {
+		return fmt.Errorf("redaction default must be full, category or none")
+	}
+	for i, rule := range r.Rules {
+		if !redactionViews[rule.View] {
+			return fmt.Errorf("redaction rule %d (%s): view must be full, category or none", i, rule.ID)
+		}
+	}
+	for i, c := range r.Categories {
+		if c.Category == "" || len(c.Keywords) == 0 {
+			return fmt.Errorf("reason category %d: category and keywords are required", i)
+		}
+	}
+	return nil
+}
+
+// reasonRedactor returns a function applying the policy's redaction to
+// events read by the caller. It is a no-op when no redaction is set.
+func reasonRedactor(ctx contractapi.TransactionContextInterface) (func(*AccessEvent), error) {
+	p, err := loadAccessPolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil || p.Redaction == nil {
+		return func(*AccessEvent) {}, nil
+	}
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, err
+	}
+	role, _, err := ctx.GetClientIdentity().GetAttributeValue(roleAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	r := p.Redaction
+	return func(evt *AccessEvent) {
+		if evt.Reason == "" {
+			return
+		}
+		switch r.view(role, mspID, evt.Action) {
+		case "category":
+			evt.ReasonCategory = r.categorize(evt.Reason)
+			evt.Reason = ""
+		case "none":
+			evt.Reason = ""
+		}
+	}, nil
+}
+
+func (r *ReasonRedaction) view(role, mspID, action string) string {
+	for _, rule := range r.Rules {
+		if rule.Role != "" && rule.Role != role {
+			continue
+		}
+		if len(rule.MSPs) > 0 && !slices.Contains(rule.MSPs, mspID) {
+			continue
+		}
+		if len(rule.Actions) > 0 && !slices.Contains(rule.Actions, action) {
+			continue
+		}
+		return rule.View
+	}
+	if r.Default == "" {
+		return "full"
+	}
+	return r.Default
+}
+
+func (r *ReasonRedaction) categorize(reason string) string {
+	lower := strings.ToLower(reason)
+	for _, c := range r.Categories {
+		for _, kw := range c.Keywords {
+			if strings.Contains(lower, strings.ToLower(kw)) {
+				return c.Category
+			}
+		}
+	}
+	return unspecifiedReason
+}
+
+// redactEvents applies the caller's redaction to events in place.
+func redactEvents(ctx contractapi.TransactionContextInterface, events []AccessEvent) error {
+	redact, err := reasonRedactor(ctx)
+	if err != nil {
+		return err
+	}
+	for i := range events {
+		redact(&events[i])
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// RenewCreds extends a credential's validity to newExpiresAt (RFC3339, later
+// than both now and the current expiry) and records a Renew event, so
+// routine renewals keep the credential ID and its history instead of a
+// revoke and reissue. newHash optionally replaces hashedData when the
+// renewed document's content changed. Only the issuing org may renew; an
+// Expired credential becomes Active again, a Revoked one cannot be renewed.
+// newExpiresAt is capped by the credential type's validity from now.
+func (s *CredentialContract) RenewCreds(ctx contractapi.TransactionContextInterface,
+	credID, newExpiresAt, newHash string) error {
+
+	cred, err := s.getCred(ctx, credID)
+	if err != nil {
+		return err
+	}
+	if cred.Status == "Revoked" {
+		return fmt.Errorf("credential %s is revoked", credID)
+	}
+	if _, err := s.requireIssuerMSP(ctx, cred.IssuerID); err != nil {
+		return err
+	}
+
+	exp, err := time.Parse(time.RFC3339, newExpiresAt)
+	if err != nil {
+		return fmt.Errorf("invalid newExpiresAt %q: %v", newExpiresAt, err)
+	}
+	if !exp.After(time.Now().UTC()) {
+		return fmt.Errorf("newExpiresAt %s is not in the future", newExpiresAt)
+	}
+	ct, err := s.getCredType(ctx, cred.CredType)
+	if err != nil {
+		return err
+	}
+	if _, err := applyValidity(ct, time.Now().UTC(), newExpiresAt); err != nil {
+		return err
+	}
+	if cred.ExpiresAt != "" {
+		if cur, err := time.Parse(time.RFC3339, cred.ExpiresAt); err == nil && !exp.After(cur) {
+			return fmt.Errorf("newExpiresAt %s does not extend the current expiry %s", newExpiresAt, cred.ExpiresAt)
+		}
+	}
+
+	prevStatus, prevExpiresAt := cred.Status, cred.ExpiresAt
+	cred.ExpiresAt = newExpiresAt
+	if newHash != "" {
+		cred.HashedData = newHash
+	}
+	if cred.Status == "Expired" {
+		cred.Status = "Active"
+	}
+	cred.UpdatedAt = nowRFC3339()
+
+	if err := s.putCred(ctx, cred); err != nil {
+		return err
+	}
+	if err := moveExpiryIndex(ctx, cred, cred.IssuerID, prevExpiresAt); err != nil {
+		return err
+	}
+	if prevStatus != cred.Status {
+		if err := countTransition(ctx, prevStatus, cred.Status); err != nil {
+			return err
+		}
+	}
+
+	reason := "expires " + newExpiresAt
+	if prevExpiresAt != "" {
+		reason = fmt.Sprintf("expiry %s -> %s", prevExpiresAt, newExpiresAt)
+	}
+	if newHash != "" {
+		reason += "; content hash updated"
+	}
+	return s.recordEvent(ctx, credID, cred.HolderDID, "Renew", cred.IssuerID, "Success", reason)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ActorReputation accumulates how an actor's recorded events turned out, so
+// governance can spot verifiers that misbehave. Ratios are derived on read.
+type ActorReputation struct {
+	ActorID      string  `json:"actorId"`
+	Events       int64   `json:"events"`
+	Failures     int64   `json:"failures"`
+	Denials      int64   `json:"denials"`
+	Disputes     int64   `json:"disputes"`
+	FailureRatio float64 `json:"failureRatio"`
+	DenialRatio  float64 `json:"denialRatio"`
+	UpdatedAt    string  `json:"updatedAt"` // RFC3339
+}
+
+// GetActorReputation returns an actor's score record. Actors with no
+// recorded events get an empty record rather than an error.
+func (s *AuditContract) GetActorReputation(ctx contractapi.TransactionContextInterface,
+	actorID string) (*ActorReputation, error) {
+
+	rep, err := getReputation(ctx, actorID)
+	if err != nil {
+		return nil, err
+	}
+	if rep.Events > 0 {
+		rep.FailureRatio = float64(rep.Failures) / float64(rep.Events)
+		rep.DenialRatio = float64(rep.Denials) / float64(rep.Events)
+	}
+	return rep, nil
+}
+
+// FlagDispute marks an event as disputed, counting against the actor who
+// performed it. Each event can be disputed once.
+func (s *AuditContract) FlagDispute(ctx contractapi.TransactionContextInterface,
+	eventID, reason string) error {
+
+	if err := requireAdmin(ctx); err != nil {
+		return err
+	}
+	evt, _, err := getEvent(ctx, eventID)
+	if err != nil {
+		return err
+	}
+	existing, err := ctx.GetStub().GetState(disputeKey(eventID))
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("event %s is already disputed", eventID)
+	}
+	if err := ctx.GetStub().PutState(disputeKey(eventID), []byte(reason)); err != nil {
+		return err
+	}
+
+	rep, err := getReputation(ctx, evt.ActorID)
+	if err != nil {
+		return err
+	}
+	rep.Disputes++
+	if err := putReputation(ctx, rep); err != nil {
+		return err
+	}
+	return s.recordEvent(ctx, evt.CredID, evt.HolderDID, "Dispute", evt.HolderDID, "Success",
+		fmt.Sprintf("event %s: %s", eventID, reason))
+}
+
+// ===== Helpers =====
+
+// scoreEvent folds one recorded event into its actor's reputation. Like the
+// credential counters, a busy actor's record is a hot key under load.
+func scoreEvent(ctx contractapi.TransactionContextInterface, evt *AccessEvent) error {
+	rep, err := getReputation(ctx, evt.ActorID)
+	if err != nil {
+		return err
+	}
+	rep.Events++
+	switch evt.Outcome {
+	case "Failure":
+		rep.Failures++
+	case "Denied":
+		rep.Denials++
+	}
+	return putReputation(ctx, rep)
+}
+
+func getReputation(ctx contractapi.TransactionContextInterface, actorID string) (*ActorReputation, error) {
+	rep := &ActorReputation{ActorID: actorID}
+	bz, err := batchGet(ctx, reputationKey(actorID))
+	if err != nil {
+		return nil, err
+	}
+	if bz != nil {
+		if err := json.Unmarshal(bz, rep); err != nil {
+			return nil, err
+		}
+	}
+	return rep, nil
+}
+
+func putReputation(ctx contractapi.TransactionContextInterface, rep *ActorReputation) error {
+	rep.FailureRatio, rep.DenialRatio = 0, 0
+	rep.UpdatedAt = nowRFC3339()
+	bz, _ := json.Marshal(rep)
+	return batchPut(ctx, reputationKey(rep.ActorID), bz)
+}
+
+func reputationKey(actorID string) string { return "reputation:" + actorID }
+
+func disputeKey(eventID string) string { return "dispute:" + eventID }
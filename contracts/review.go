@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// AccessReview is one page of an issuer's quarterly access review: every
+// verifier that checked the issuer's credentials in the quarter, with what
+// a sign-off reviewer needs to judge the access. Pages cover a slice of the
+// issuer's credentials; merge VerifierAccess entries by VerifierID (sum the
+// counts, union the lists) across pages.
+type AccessReview struct {
+	IssuerID  string           `json:"issuerId"`
+	Quarter   string           `json:"quarter"` // e.g. 2026-Q3
+	From      string           `json:"from"`    // RFC3339
+	To        string           `json:"to"`      // RFC3339, exclusive
+	Scanned   int32            `json:"scanned"` // credentials on this page
+	Verifiers []VerifierAccess `json:"verifiers"`
+	Bookmark  string           `json:"bookmark"`
+}
+
+// VerifierAccess is one verifier's access to an issuer's credentials.
+// Sampled verifications (see sampling.go) are counted per summary bucket,
+// since buckets do not break their count down by verifier.
+type VerifierAccess struct {
+	VerifierID     string   `json:"verifierId"`
+	Verifications  int      `json:"verifications"`  // Verify, VerifyAttribute and BreakGlassVerify events
+	BreakGlass     int      `json:"breakGlass"`     // of which break-glass
+	Denied         int      `json:"denied"`         // of which denied
+	ConsentCovered int      `json:"consentCovered"` // allowed checks made under a holder consent in force at the time
+	SampledBuckets int      `json:"sampledBuckets"`
+	Holders        []string `json:"holders"`     // holder DIDs checked
+	Credentials    []string `json:"credentials"` // credential IDs checked
+	Purposes       []string `json:"purposes"`    // purposes of the holders' consents to the verifier
+	DPAStatus      string   `json:"dpaStatus"`   // Covered | Expiring | Expired | Unregistered
+}
+
+// GetAccessReview builds a page of issuerID's access review for quarter
+// ("YYYY-Qn"). Only the issuing org or an admin may read it.
+func (s *AuditContract) GetAccessReview(ctx contractapi.TransactionContextInterface,
+	issuerID, quarter string, pageSize int32, bookmark string) (*AccessReview, error) {
+
+	from, to, err := parseQuarter(quarter)
+	if err != nil {
+		return nil, err
+	}
+	if requireAdmin(ctx) != nil {
+		if _, err := s.requireIssuerMSP(ctx, issuerID); err != nil {
+			return nil, err
+		}
+	}
+
+	raw, err := decodeBookmark(ctx, "cred~issuer", bookmark)
+	if err != nil {
+		return nil, err
+	}
+	iter, meta, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(
+		"cred~issuer", []string{issuerID}, pageSize, raw)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+	next, err := encodeBookmark(ctx, "cred~issuer", meta.Bookmark)
+	if err != nil {
+		return nil, err
+	}
+
+	review := &AccessReview{
+		IssuerID:  issuerID,
+		Quarter:   quarter,
+		From:      from.Format(time.RFC3339),
+		To:        to.Format(time.RFC3339),
+		Verifiers: []VerifierAccess{},
+		Bookmark:  next,
+	}
+	byVerifier := map[string]*reviewTally{}
+	tally := func(verifierID string) *reviewTally {
+		t, ok := byVerifier[verifierID]
+		if !ok {
+			t = &reviewTally{holders: map[string]bool{}, creds: map[string]bool{}}
+			byVerifier[verifierID] = t
+		}
+		return t
+	}
+
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, attrs, err := ctx.GetStub().SplitCompositeKey(kv.Key)
+		if err != nil {
+			return nil, err
+		}
+		cred, err := s.getCred(ctx, attrs[1])
+		if err != nil {
+			return nil, err
+		}
+		review.Scanned++
+
+		events, err := credEvents(ctx, cred)
+		if err != nil {
+			return nil, err
+		}
+		for _, evt := range events {
+			if !reviewedActions[evt.Action] || !inWindow(evt.OccurredAt, from, to) {
+				continue
+			}
+			t := tally(evt.ActorID)
+			t.holders[cred.HolderDID] = true // events may carry pseudonyms
+			t.creds[evt.CredID] = true
+			t.access.Verifications++
+			if evt.Action == "BreakGlassVerify" {
+				t.access.BreakGlass++
+			}
+			if evt.Outcome == "Denied" {
+				t.access.Denied++
+				continue
+			}
+			consent, err := getConsent(ctx, cred.HolderDID, evt.ActorID)
+			if err != nil {
+				return nil, err
+			}
+			if consent.coveredAt(evt.OccurredAt) {
+				t.access.ConsentCovered++
+			}
+		}
+
+		summaries, err := s.GetVerifySummaries(ctx, cred.CredID)
+		if err != nil {
+			return nil, err
+		}
+		for _, sum := range summaries {
+			if !inWindow(sum.BucketStart, from, to) {
+				continue
+			}
+			for _, verifierID := range sum.Verifiers {
+				t := tally(verifierID)
+				t.holders[sum.HolderDID] = true
+				t.creds[sum.CredID] = true
+				t.access.SampledBuckets++
+			}
+		}
+	}
+
+	for verifierID, t := range byVerifier {
+		access := t.access
+		access.VerifierID = verifierID
+		access.Holders = sortedKeys(t.holders)
+		access.Credentials = sortedKeys(t.creds)
+		purposes := map[string]bool{}
+		for _, holderDID := range access.Holders {
+			consent, err := getConsent(ctx, holderDID, verifierID)
+			if err != nil {
+				return nil, err
+			}
+			if consent != nil && consent.Purpose != "" {
+				purposes[consent.Purpose] = true
+			}
+		}
+		access.Purposes = sortedKeys(purposes)
+
+		v, err := s.getVerifier(ctx, verifierID)
+		if err != nil {
+			return nil, err
+		}
+		access.DPAStatus = "Unregistered"
+		if v != nil {
+			access.DPAStatus, _ = v.dpaStatus()
+		}
+		review.Verifiers = append(review.Verifiers, access)
+	}
+	sort.Slice(review.Verifiers, func(i, j int) bool {
+		return review.Verifiers[i].VerifierID < review.Verifiers[j].VerifierID
+	})
+	return review, nil
+}
+
+// ===== Helpers =====
+
+var reviewedActions = map[string]bool{"Verify": true, "VerifyAttribute": true, "BreakGlassVerify": true}
+
+type reviewTally struct {
+	access  VerifierAccess
+	holders map[string]bool
+	creds   map[string]bool
+}
+
+// parseQuarter returns the bounds of "YYYY-Qn", the end exclusive.
+func parseQuarter(quarter string) (time.Time, time.Time, error) {
+	var year, q int
+	if _, err := fmt.Sscanf(quarter, "%4d-Q%1d", &year, &q); err != nil ||
+		q < 1 || q > 4 || quarter != strconv.Itoa(year)+"-Q"+strconv.Itoa(q) {
+		return time.Time{}, time.Time{}, fmt.Errorf("quarter must look like 2026-Q3, got %q", quarter)
+	}
+	from := time.Date(year, time.Month(3*(q-1)+1), 1, 0, 0, 0, 0, time.UTC)
+	return from, from.AddDate(0, 3, 0), nil
+}
+
+func inWindow(at string, from, to time.Time) bool {
+	t, err := time.Parse(time.RFC3339, at)
+	return err == nil && !t.Before(from) && t.Before(to)
+}
+
+// credEvents returns the events on cred under its current holder.
+func credEvents(ctx contractapi.TransactionContextInterface, cred *Credential) ([]*AccessEvent, error) {
+	stored, err := holderEvents(ctx, cred.HolderDID, cred.CredID)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []*AccessEvent
+	for _, kv := range stored {
+		evt, err := decodeEvent(ctx, kv.Value)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, evt)
+	}
+	return events, nil
+}
+
+// coveredAt reports whether the consent was in force at the RFC3339 time at.
+func (c *Consent) coveredAt(at string) bool {
+	if c == nil || c.GrantedAt == "" || c.GrantedAt > at {
+		return false
+	}
+	return c.Status == "Granted" || c.RevokedAt > at
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
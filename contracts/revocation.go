@@ -0,0 +1,197 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// RevocationAssertion is the status statement broadcast to sister channels.
+// The chaincode holds no private key, so the assertion leaves the source
+// channel unsigned: the source org's relay (the gateway, api/relay.js)
+// picks up the RevocationBroadcast event, checks Digest and signs it with
+// its Ed25519 key. ImportRevocation accepts it only from a channel listed
+// in ContractConfig.RevocationSources, with a valid signature by the key
+// listed for that channel.
+type RevocationAssertion struct {
+	CredID        string `json:"credId"`
+	IssuerID      string `json:"issuerId"`
+	SourceChannel string `json:"sourceChannel"`
+	TxID          string `json:"txId"`
+	SignerMSP     string `json:"signerMsp"`
+	SignerID      string `json:"signerId"`
+	RevokedAt     string `json:"revokedAt"` // RFC3339
+	Digest        string `json:"digest"`    // hex sha256 of the assertion with Digest and Signature empty
+	// Signature is the relay's base64url Ed25519 signature over Digest (the
+	// hex string's bytes).
+	Signature string `json:"signature,omitempty"`
+}
+
+// RevocationBroadcast is the payload of the RevocationBroadcast chaincode
+// event. It replaces the AuditTrail event for revocations (Fabric keeps one
+// event per transaction), so the audit entry travels along in Event.
+type RevocationBroadcast struct {
+	Assertion RevocationAssertion `json:"assertion"`
+	Event     AccessEvent         `json:"event"`
+}
+
+// MirroredRevocation records a revocation imported from another channel.
+type MirroredRevocation struct {
+	Assertion  RevocationAssertion `json:"assertion"`
+	ImportedAt string              `json:"importedAt"` // RFC3339
+	Applied    bool                `json:"applied"`    // a local copy of the credential was revoked
+}
+
+// ImportRevocation mirrors a revocation asserted on another channel. If the
+// credential also exists here under the same issuer it is revoked locally.
+// The caller needs audittrail.role=relay (or admin), and the assertion must
+// be signed by the relay key of a configured revocation source.
+func (s *CredentialContract) ImportRevocation(ctx contractapi.TransactionContextInterface,
+	assertion RevocationAssertion) error {
+
+	role, _, err := ctx.GetClientIdentity().GetAttributeValue(roleAttr)
+	if err != nil {
+		return err
+	}
+	if role != "relay" && role != "admin" {
+		return fmt.Errorf("relay role required")
+	}
+	if assertion.SourceChannel == ctx.GetStub().GetChannelID() {
+		return fmt.Errorf("assertion originates from this channel")
+	}
+	if want := assertion.digest(); assertion.Digest != want {
+		return fmt.Errorf("assertion digest mismatch")
+	}
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := cfg.checkRevocationSignature(assertion); err != nil {
+		return err
+	}
+
+	key := mirrorKey(assertion.SourceChannel, assertion.CredID)
+	existing, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("revocation of %s from %s already imported", assertion.CredID, assertion.SourceChannel)
+	}
+
+	mirror := &MirroredRevocation{Assertion: assertion, ImportedAt: nowRFC3339()}
+	cred, err := s.getCred(ctx, assertion.CredID)
+	if err == nil && cred.IssuerID == assertion.IssuerID && cred.Status != "Revoked" {
+		prev := cred.Status
+		cred.Status = "Revoked"
+		cred.UpdatedAt = nowRFC3339()
+		if err := s.putCred(ctx, cred); err != nil {
+			return err
+		}
+		if err := countTransition(ctx, prev, "Revoked"); err != nil {
+			return err
+		}
+		if err := putRevokedIndex(ctx, cred); err != nil {
+			return err
+		}
+		reason := fmt.Sprintf("mirrored from %s tx %s", assertion.SourceChannel, assertion.TxID)
+		if err := s.recordEvent(ctx, cred.CredID, cred.HolderDID, "Revoke", "mirror:"+assertion.SourceChannel, "Success", reason); err != nil {
+			return err
+		}
+		mirror.Applied = true
+	}
+
+	bz, _ := json.Marshal(mirror)
+	return ctx.GetStub().PutState(key, bz)
+}
+
+// GetMirroredRevocation returns an imported revocation, if any.
+func (s *CredentialContract) GetMirroredRevocation(ctx contractapi.TransactionContextInterface,
+	sourceChannel, credID string) (*MirroredRevocation, error) {
+
+	bz, err := ctx.GetStub().GetState(mirrorKey(sourceChannel, credID))
+	if err != nil {
+		return nil, err
+	}
+	if bz == nil {
+		return nil, fmt.Errorf("no revocation of %s imported from %s", credID, sourceChannel)
+	}
+	var mirror MirroredRevocation
+	if err := json.Unmarshal(bz, &mirror); err != nil {
+		return nil, err
+	}
+	return &mirror, nil
+}
+
+// ===== Helpers =====
+
+// broadcastRevocation emits the RevocationBroadcast event for a revoked credential.
+func (s *ledger) broadcastRevocation(ctx contractapi.TransactionContextInterface,
+	cred *Credential, evt AccessEvent) error {
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return err
+	}
+	signerID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return err
+	}
+	assertion := RevocationAssertion{
+		CredID:        cred.CredID,
+		IssuerID:      cred.IssuerID,
+		SourceChannel: ctx.GetStub().GetChannelID(),
+		TxID:          ctx.GetStub().GetTxID(),
+		SignerMSP:     mspID,
+		SignerID:      signerID,
+		RevokedAt:     cred.UpdatedAt,
+	}
+	assertion.Digest = assertion.digest()
+
+	return emitEvent(ctx, "RevocationBroadcast", RevocationBroadcast{Assertion: assertion, Event: evt})
+}
+
+// checkRevocationSignature checks that a's source channel is a configured
+// revocation source and that its relay key signed a.Digest.
+func (cfg *ContractConfig) checkRevocationSignature(a RevocationAssertion) error {
+	key, ok := cfg.RevocationSources[a.SourceChannel]
+	if !ok {
+		return fmt.Errorf("channel %s is not a configured revocation source", a.SourceChannel)
+	}
+	pub, err := relayPublicKey(key)
+	if err != nil {
+		return err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(a.Signature)
+	if err != nil || !ed25519.Verify(pub, []byte(a.Digest), sig) {
+		return fmt.Errorf("assertion signature does not verify against the %s relay key", a.SourceChannel)
+	}
+	return nil
+}
+
+// relayPublicKey decodes a revocation source key: a raw Ed25519 public key,
+// base64url without padding, as in the "x" member of a JWK.
+func relayPublicKey(key string) (ed25519.PublicKey, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(key)
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("revocation source key must be a base64url Ed25519 public key")
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+func (a RevocationAssertion) digest() string {
+	a.Digest = ""
+	a.Signature = ""
+	bz, _ := json.Marshal(a)
+	sum := sha256.Sum256(bz)
+	return hex.EncodeToString(sum[:])
+}
+
+func mirrorKey(sourceChannel, credID string) string {
+	return "mirror:" + sourceChannel + ":" + credID
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// VerifySummary aggregates the sampled Verify calls on one credential in one
+// time bucket. Its audit-trail counterpart is a VerifySummary AccessEvent
+// whose ID is fixed to the bucket end, so checkpoints taken while the bucket
+// is still open never cover it.
+type VerifySummary struct {
+	CredID      string   `json:"credId"`
+	HolderDID   string   `json:"holderDid"`
+	BucketStart string   `json:"bucketStart"` // RFC3339
+	BucketEnd   string   `json:"bucketEnd"`   // RFC3339, exclusive
+	Count       int      `json:"count"`
+	Verifiers   []string `json:"verifiers"` // distinct verifier IDs
+	EventID     string   `json:"eventId"`
+}
+
+// GetVerifySummaries returns the sampled verification buckets for a credential.
+func (s *AuditContract) GetVerifySummaries(ctx contractapi.TransactionContextInterface,
+	credID string) ([]VerifySummary, error) {
+
+	iter, err := ctx.GetStub().GetStateByPartialCompositeKey("verify~summary", []string{credID})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	summaries := []VerifySummary{}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		var sum VerifySummary
+		if err := json.Unmarshal(kv.Value, &sum); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, sum)
+	}
+	return summaries, nil
+}
+
+// ===== Helpers =====
+
+// sampleVerify folds a successful verification into the current bucket's
+// summary. It reports false when sampling is off and the caller should
+// record the event itself. Concurrent verifies of the same credential in one
+// block contend on the summary key; losers fail MVCC and are retried by the
+// client like any other conflict.
+func (s *ledger) sampleVerify(ctx contractapi.TransactionContextInterface,
+	cred *Credential, verifierID string) (bool, error) {
+
+	cfg, err := loadConfig(ctx)
+	if err != nil || cfg.VerifySummarySeconds == 0 {
+		return false, err
+	}
+	// A wallet that failed its attestation is worth a look on its own.
+	if att, err := walletAttestation(ctx); err != nil || att != nil && att.Status != "Valid" {
+		return false, err
+	}
+
+	now := time.Now().UTC()
+	bucket := time.Duration(cfg.VerifySummarySeconds) * time.Second
+	start := now.Truncate(bucket)
+	end := start.Add(bucket)
+
+	ck, err := compositeKey(ctx, "verify~summary",
+		[]string{cred.CredID, fmt.Sprintf("%019d", start.UnixNano())})
+	if err != nil {
+		return false, err
+	}
+	bz, err := ctx.GetStub().GetState(ck)
+	if err != nil {
+		return false, err
+	}
+	sum := &VerifySummary{
+		CredID:      cred.CredID,
+		HolderDID:   cred.HolderDID,
+		BucketStart: start.Format(time.RFC3339),
+		BucketEnd:   end.Format(time.RFC3339),
+		Verifiers:   []string{},
+		EventID:     fmt.Sprintf("%d-summary", end.UnixNano()),
+	}
+	if bz != nil {
+		if err := json.Unmarshal(bz, sum); err != nil {
+			return false, err
+		}
+	}
+
+	sum.Count++
+	seen := false
+	for _, v := range sum.Verifiers {
+		if v == verifierID {
+			seen = true
+			break
+		}
+	}
+	if !seen {
+		sum.Verifiers = append(sum.Verifiers, verifierID)
+	}
+	bz, _ = json.Marshal(sum)
+	if err := ctx.GetStub().PutState(ck, bz); err != nil {
+		return false, err
+	}
+
+	evt := AccessEvent{
+		EventID:    sum.EventID,
+		CredID:     cred.CredID,
+		HolderDID:  cred.HolderDID,
+		Action:     "VerifySummary",
+		ActorID:    "system",
+		Outcome:    "Success",
+		Reason:     fmt.Sprintf("%d verifications by %d verifiers", sum.Count, len(sum.Verifiers)),
+		OccurredAt: now.Format(time.RFC3339),
+	}
+	return true, storeEvent(ctx, evt)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Shadow reads. After an upgrade that touches the state codecs (a field
+// added to Credential or AccessEvent, a change to StateCodec), the feature
+// flag "shadow-read" makes every credential and event the chaincode reads
+// go through both codecs: the record is decoded as stored, re-encoded as
+// JSON and as protobuf, decoded again, and compared with what was stored.
+// A field that does not survive a codec, e.g. one missing from
+// protoFields or unknown to this chaincode version, is a divergence; it is
+// reported before a write in the other codec drops it for good. Under
+// strict decoding (strict.go) a record with unknown fields fails to read
+// before it gets here, so those show up only with LenientDecoding on.
+//
+// Divergences are collected per transaction and emitted by the
+// AfterTransaction hook as one ShadowReadDivergence event. Fabric keeps one
+// event per transaction, so that event replaces the transaction's own and
+// carries it along in Event; stage the flag to the orgs whose listeners
+// understand it. Reads are unchanged either way.
+
+// ShadowDivergence is one record that changed on a codec round trip.
+type ShadowDivergence struct {
+	Key    string   `json:"key"`    // "/"-joined for composite keys
+	Stored string   `json:"stored"` // codec the record is stored in: json | proto
+	Via    string   `json:"via"`    // codec of the round trip that diverged
+	Fields []string `json:"fields"` // JSON names of the fields that differ; "(unknown fields)" for undecoded protobuf data
+}
+
+// ShadowReadDivergence is the payload of the ShadowReadDivergence chaincode
+// event.
+type ShadowReadDivergence struct {
+	Divergences []ShadowDivergence `json:"divergences"`
+	EventName   string             `json:"eventName,omitempty"` // the transaction's own event, if it set one
+	Event       json.RawMessage    `json:"event,omitempty"`
+}
+
+// protoRecord is a state record with a protobuf form (see codec.go).
+type protoRecord interface {
+	protoFields() []*string
+}
+
+// shadowRead checks a credential or event record read under key when the
+// shadow-read flag is on for the caller. decode is the record type's
+// decoder and v what it made of bz.
+func shadowRead(ctx contractapi.TransactionContextInterface, key string, bz []byte, v protoRecord,
+	decode func([]byte) (protoRecord, error)) error {
+
+	tc, ok := ctx.(*TxContext)
+	if !ok {
+		return nil
+	}
+	if tc.shadowRead == nil {
+		on, err := featureEnabled(ctx, "shadow-read")
+		if err != nil {
+			return err
+		}
+		tc.shadowRead = &on
+	}
+	if !*tc.shadowRead {
+		return nil
+	}
+	tc.divergences = append(tc.divergences, shadowDiff(printableKey(key), bz, v, decode)...)
+	return nil
+}
+
+// emitShadowDivergences emits the transaction's divergences, if any.
+func emitShadowDivergences(ctx contractapi.TransactionContextInterface) error {
+	tc, ok := ctx.(*TxContext)
+	if !ok || len(tc.divergences) == 0 {
+		return nil
+	}
+	warning := ShadowReadDivergence{Divergences: tc.divergences, EventName: tc.lastEvent}
+	if tc.lastEvent != "" {
+		warning.Event = tc.lastPayload
+	}
+	tc.divergences = nil
+	return emitEvent(ctx, "ShadowReadDivergence", warning)
+}
+
+// ===== Helpers =====
+
+func decodeCredRecord(bz []byte) (protoRecord, error)  { return decodeCredWith(bz, false) }
+func decodeEventRecord(bz []byte) (protoRecord, error) { return decodeEventWith(bz, false) }
+
+func shadowDiff(key string, bz []byte, v protoRecord, decode func([]byte) (protoRecord, error)) []ShadowDivergence {
+	stored := "json"
+	var want map[string]json.RawMessage
+	if bytes.HasPrefix(bz, []byte(protoStateMagic)) {
+		stored = "proto"
+		want = fieldsOf(v)
+	} else if err := json.Unmarshal(bz, &want); err != nil {
+		return []ShadowDivergence{{Key: key, Stored: stored, Via: "json", Fields: []string{"(not a JSON object)"}}}
+	}
+
+	var out []ShadowDivergence
+	jsonBz, _ := canonicalJSON(v)
+	protoBz := encodeProtoState(v.protoFields())
+	for _, via := range []struct {
+		name string
+		bz   []byte
+	}{{"json", jsonBz}, {"proto", protoBz}} {
+		var fields []string
+		if got, err := decode(via.bz); err != nil {
+			fields = []string{"(" + err.Error() + ")"}
+		} else {
+			fields = diffFields(want, fieldsOf(got))
+		}
+		if via.name == "proto" && stored == "proto" && !bytes.Equal(protoBz, bz) {
+			fields = append(fields, "(unknown fields)")
+		}
+		if len(fields) > 0 {
+			out = append(out, ShadowDivergence{Key: key, Stored: stored, Via: via.name, Fields: fields})
+		}
+	}
+	return out
+}
+
+func fieldsOf(v interface{}) map[string]json.RawMessage {
+	bz, _ := json.Marshal(v)
+	m := map[string]json.RawMessage{}
+	json.Unmarshal(bz, &m)
+	return m
+}
+
+// diffFields names the keys whose values differ between a and b. A missing
+// key, null and "" count as the same, since omitempty and proto3 both drop
+// empty strings.
+func diffFields(a, b map[string]json.RawMessage) []string {
+	empty := func(v json.RawMessage) bool {
+		return v == nil || string(v) == "null" || string(v) == `""`
+	}
+	var fields []string
+	seen := map[string]bool{}
+	for _, m := range []map[string]json.RawMessage{a, b} {
+		for k := range m {
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			if empty(a[k]) && empty(b[k]) {
+				continue
+			}
+			ca, errA := canonicalJSON(a[k])
+			cb, errB := canonicalJSON(b[k])
+			if errA != nil || errB != nil || !bytes.Equal(ca, cb) {
+				fields = append(fields, k)
+			}
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Revocation snapshots are CRL-style lists for verifiers that run offline or
+// air-gapped and sync periodically instead of querying status per
+// credential. Each snapshot is dated by the last UTC day it covers and lists
+// the credentials newly revoked since the previous one plus the cumulative
+// set, so a verifier can either apply the delta or replace its list
+// outright. Snapshots chain through PreviousDigest; a verifier that skipped
+// some takes the latest's cumulative list.
+//
+// Revocations are found through cred~revoked [day, credID], day being the
+// UTC date of the revocation. Revocations recorded before the index existed
+// are added by RepairIndexes("cred") and appear in the next snapshot's
+// delta, whatever their date.
+
+// RevocationSnapshot is signed by virtue of being written by an endorsed,
+// committed transaction; Digest lets a verifier that received it out of band
+// detect tampering in transit.
+type RevocationSnapshot struct {
+	SnapshotDate   string   `json:"snapshotDate"` // YYYY-MM-DD, last UTC day covered
+	Sequence       int      `json:"sequence"`     // 1 for the channel's first snapshot
+	Revoked        []string `json:"revoked"`      // credIDs added since the previous snapshot, sorted
+	Cumulative     []string `json:"cumulative"`   // every credID revoked up to SnapshotDate, sorted
+	PreviousDate   string   `json:"previousDate,omitempty"`
+	PreviousDigest string   `json:"previousDigest,omitempty"`
+	GeneratedBy    string   `json:"generatedBy"` // submitting MSP ID
+	TxID           string   `json:"txId"`
+	GeneratedAt    string   `json:"generatedAt"` // RFC3339
+	Digest         string   `json:"digest"`      // hex sha256 of the snapshot with Digest empty
+}
+
+const revocationSnapshotHeadKey = "revsnapshot:head"
+
+// GenerateRevocationSnapshot lists the credentials revoked up to and
+// including snapshotDate (YYYY-MM-DD), which must be a finished UTC day
+// later than the latest snapshot's.
+func (s *AuditContract) GenerateRevocationSnapshot(ctx contractapi.TransactionContextInterface,
+	snapshotDate string) (*RevocationSnapshot, error) {
+
+	date, err := time.Parse(time.DateOnly, snapshotDate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid snapshotDate %q: %v", snapshotDate, err)
+	}
+	if time.Now().UTC().Before(date.AddDate(0, 0, 1)) {
+		return nil, fmt.Errorf("snapshotDate %s has not ended yet", snapshotDate)
+	}
+	prev, err := latestRevocationSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if prev != nil && snapshotDate <= prev.SnapshotDate {
+		return nil, fmt.Errorf("snapshotDate must be after the latest snapshot, %s", prev.SnapshotDate)
+	}
+
+	cumulative, err := revokedThrough(ctx, snapshotDate)
+	if err != nil {
+		return nil, err
+	}
+	listed := map[string]bool{}
+	snap := &RevocationSnapshot{SnapshotDate: snapshotDate, Sequence: 1, Revoked: []string{}, Cumulative: cumulative}
+	if prev != nil {
+		for _, id := range prev.Cumulative {
+			listed[id] = true
+		}
+		snap.Sequence = prev.Sequence + 1
+		snap.PreviousDate, snap.PreviousDigest = prev.SnapshotDate, prev.Digest
+	}
+	for _, id := range cumulative {
+		if !listed[id] {
+			snap.Revoked = append(snap.Revoked, id)
+		}
+	}
+
+	if snap.GeneratedBy, err = ctx.GetClientIdentity().GetMSPID(); err != nil {
+		return nil, err
+	}
+	snap.TxID = ctx.GetStub().GetTxID()
+	snap.GeneratedAt = nowRFC3339()
+	snap.Digest = snap.digest()
+
+	bz, _ := json.Marshal(snap)
+	if err := ctx.GetStub().PutState(revocationSnapshotKey(snapshotDate), bz); err != nil {
+		return nil, err
+	}
+	if err := ctx.GetStub().PutState(revocationSnapshotHeadKey, []byte(snapshotDate)); err != nil {
+		return nil, err
+	}
+	// The event carries the delta only; the cumulative list can be large.
+	summary := *snap
+	summary.Cumulative = nil
+	if err := emitEvent(ctx, "RevocationSnapshot", summary); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// GetRevocationSnapshot returns the snapshot dated snapshotDate.
+func (s *AuditContract) GetRevocationSnapshot(ctx contractapi.TransactionContextInterface,
+	snapshotDate string) (*RevocationSnapshot, error) {
+
+	snap, err := getRevocationSnapshot(ctx, snapshotDate)
+	if err != nil {
+		return nil, err
+	}
+	if snap == nil {
+		return nil, fmt.Errorf("no revocation snapshot dated %s", snapshotDate)
+	}
+	return snap, nil
+}
+
+// GetLatestRevocationSnapshot returns the most recent snapshot.
+func (s *AuditContract) GetLatestRevocationSnapshot(ctx contractapi.TransactionContextInterface) (*RevocationSnapshot, error) {
+	snap, err := latestRevocationSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if snap == nil {
+		return nil, fmt.Errorf("no revocation snapshot has been generated")
+	}
+	return snap, nil
+}
+
+// ===== Helpers =====
+
+func (snap RevocationSnapshot) digest() string {
+	snap.Digest = ""
+	bz, _ := json.Marshal(snap)
+	sum := sha256.Sum256(bz)
+	return hex.EncodeToString(sum[:])
+}
+
+// revokedDay is the cred~revoked day for a revoked credential.
+func revokedDay(cred *Credential) string {
+	at, err := time.Parse(time.RFC3339, cred.UpdatedAt)
+	if err != nil {
+		return ""
+	}
+	return at.UTC().Format(time.DateOnly)
+}
+
+func putRevokedIndex(ctx contractapi.TransactionContextInterface, cred *Credential) error {
+	return putIndexKey(ctx, "cred~revoked", revokedDay(cred), cred.CredID)
+}
+
+// revokedThrough returns the sorted IDs of credentials revoked on or before
+// day. cred~revoked iterates in day order, so the scan stops at the first
+// later day.
+func revokedThrough(ctx contractapi.TransactionContextInterface, day string) ([]string, error) {
+	iter, err := ctx.GetStub().GetStateByPartialCompositeKey("cred~revoked", []string{})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	ids := []string{}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, attrs, err := ctx.GetStub().SplitCompositeKey(kv.Key)
+		if err != nil {
+			return nil, err
+		}
+		if attrs[0] > day {
+			break
+		}
+		ids = append(ids, attrs[1])
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func getRevocationSnapshot(ctx contractapi.TransactionContextInterface, snapshotDate string) (*RevocationSnapshot, error) {
+	bz, err := ctx.GetStub().GetState(revocationSnapshotKey(snapshotDate))
+	if err != nil || bz == nil {
+		return nil, err
+	}
+	var snap RevocationSnapshot
+	if err := json.Unmarshal(bz, &snap); err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
+
+func latestRevocationSnapshot(ctx contractapi.TransactionContextInterface) (*RevocationSnapshot, error) {
+	head, err := ctx.GetStub().GetState(revocationSnapshotHeadKey)
+	if err != nil || head == nil {
+		return nil, err
+	}
+	return getRevocationSnapshot(ctx, string(head))
+}
+
+func revocationSnapshotKey(snapshotDate string) string { return "revsnapshot:" + snapshotDate }
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Strict decoding. Credentials and events are decoded with unknown fields
+// rejected, JSON and protobuf alike, so a record carrying a field this
+// chaincode version has no place for fails to read instead of losing the
+// field silently when it is next written back. When that is expected, as
+// after rolling back an upgrade that added a field, an admin sets
+// ContractConfig.LenientDecoding until the records are dealt with. The
+// config record itself is always decoded leniently, so the override can
+// still be set.
+//
+// LintState finds the records that strict decoding would reject, and those
+// that break the current schema in other ways, one page at a time.
+
+// LintViolation is a record that does not fit the current schema.
+type LintViolation struct {
+	Key      string   `json:"key"` // "/"-joined for composite keys
+	Problems []string `json:"problems"`
+}
+
+// LintReport is one page of a LintState scan.
+type LintReport struct {
+	Kind       string          `json:"kind"` // cred | event
+	Scanned    int32           `json:"scanned"`
+	Violations []LintViolation `json:"violations"`
+	Bookmark   string          `json:"bookmark"`
+}
+
+var (
+	credStatuses  = []string{"PendingAcceptance", "Active", "Revoked", "Expired"}
+	eventOutcomes = []string{"Success", "Failure", "Denied"}
+)
+
+// LintState checks one page of stored credentials (kind "cred") or events
+// (kind "event") against the current schema: unknown fields, missing
+// required fields, values outside their enumerations and malformed
+// timestamps. Resume from the returned bookmark until it is empty.
+func (s *AdminContract) LintState(ctx contractapi.TransactionContextInterface,
+	kind string, pageSize int32, bookmark string) (*LintReport, error) {
+
+	report := &LintReport{Kind: kind, Violations: []LintViolation{}}
+	switch kind {
+	case "cred":
+		iter, meta, err := ctx.GetStub().GetStateByRangeWithPagination("cred:", "cred;", pageSize, bookmark)
+		if err != nil {
+			return nil, err
+		}
+		defer iter.Close()
+		report.Bookmark = meta.Bookmark
+		for iter.HasNext() {
+			kv, err := iter.Next()
+			if err != nil {
+				return nil, err
+			}
+			report.Scanned++
+			if problems := lintCred(strings.TrimPrefix(kv.Key, "cred:"), kv.Value); len(problems) > 0 {
+				report.Violations = append(report.Violations, LintViolation{Key: kv.Key, Problems: problems})
+			}
+		}
+	case "event":
+		raw, err := decodeBookmark(ctx, "event~holder", bookmark)
+		if err != nil {
+			return nil, err
+		}
+		iter, meta, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination("event~holder", []string{}, pageSize, raw)
+		if err != nil {
+			return nil, err
+		}
+		defer iter.Close()
+		if report.Bookmark, err = encodeBookmark(ctx, "event~holder", meta.Bookmark); err != nil {
+			return nil, err
+		}
+		for iter.HasNext() {
+			kv, err := iter.Next()
+			if err != nil {
+				return nil, err
+			}
+			report.Scanned++
+			_, attrs, err := ctx.GetStub().SplitCompositeKey(kv.Key)
+			if err != nil {
+				return nil, err
+			}
+			if problems := lintEvent(attrs[len(attrs)-1], kv.Value); len(problems) > 0 {
+				report.Violations = append(report.Violations, LintViolation{Key: printableKey(kv.Key), Problems: problems})
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown kind %q; use cred or event", kind)
+	}
+	return report, nil
+}
+
+// ===== Helpers =====
+
+// strictDecoding reports whether records are decoded strictly in this
+// transaction.
+func strictDecoding(ctx contractapi.TransactionContextInterface) (bool, error) {
+	tc, ok := ctx.(*TxContext)
+	if ok && tc.strict != nil {
+		return *tc.strict, nil
+	}
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		return false, err
+	}
+	strict := !cfg.LenientDecoding
+	if ok {
+		tc.strict = &strict
+	}
+	return strict, nil
+}
+
+func lintCred(credID string, bz []byte) []string {
+	var problems []string
+	if _, err := decodeCredWith(bz, true); err != nil {
+		problems = append(problems, err.Error())
+	}
+	cred, err := decodeCredWith(bz, false)
+	if err != nil {
+		return problems
+	}
+	if cred.CredID != credID {
+		problems = append(problems, fmt.Sprintf("credId %q does not match its key", cred.CredID))
+	}
+	problems = append(problems, missing([][2]string{
+		{"holderDid", cred.HolderDID},
+		{"credType", cred.CredType},
+		{"issuerId", cred.IssuerID},
+		{"status", cred.Status},
+	})...)
+	problems = append(problems, outside("status", cred.Status, credStatuses)...)
+	problems = append(problems, badTimes([][2]string{
+		{"createdAt", cred.CreatedAt},
+		{"updatedAt", cred.UpdatedAt},
+		{"expiresAt", cred.ExpiresAt},
+	})...)
+	return problems
+}
+
+func lintEvent(eventID string, bz []byte) []string {
+	var problems []string
+	if _, err := decodeEventWith(bz, true); err != nil {
+		problems = append(problems, err.Error())
+	}
+	evt, err := decodeEventWith(bz, false)
+	if err != nil {
+		return problems
+	}
+	if evt.EventID != eventID {
+		problems = append(problems, fmt.Sprintf("eventId %q does not match its key", evt.EventID))
+	}
+	problems = append(problems, missing([][2]string{
+		{"action", evt.Action},
+		{"outcome", evt.Outcome},
+		{"occurredAt", evt.OccurredAt},
+	})...)
+	problems = append(problems, outside("outcome", evt.Outcome, eventOutcomes)...)
+	problems = append(problems, badTimes([][2]string{{"occurredAt", evt.OccurredAt}})...)
+	return problems
+}
+
+// missing reports the required fields, {name, value} pairs, left empty.
+func missing(fields [][2]string) []string {
+	var out []string
+	for _, f := range fields {
+		if f[1] == "" {
+			out = append(out, f[0]+" is missing")
+		}
+	}
+	return out
+}
+
+func outside(name, v string, allowed []string) []string {
+	if v == "" {
+		return nil
+	}
+	for _, a := range allowed {
+		if v == a {
+			return nil
+		}
+	}
+	return []string{fmt.Sprintf("%s %q is not one of %s", name, v, strings.Join(allowed, ", "))}
+}
+
+// badTimes reports fields set to something other than an RFC3339 time.
+func badTimes(fields [][2]string) []string {
+	var out []string
+	for _, f := range fields {
+		if f[1] == "" {
+			continue
+		}
+		if _, err := time.Parse(time.RFC3339, f[1]); err != nil {
+			out = append(out, fmt.Sprintf("%s %q is not RFC3339", f[0], f[1]))
+		}
+	}
+	return out
+}
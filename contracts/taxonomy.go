@@ -0,0 +1,57 @@
+package main
+
+// Event taxonomy. Every AccessEvent is classified when stored so the trail
+// maps onto enterprise audit schemas (NIST SP 800-92 / ISO 27001 log
+// content, ECS, CEF) without each consumer re-deriving it from Action.
+//
+//	eventCategory   CredentialLifecycle | Access | Consent | Review
+//	severity        RFC 5424 names: Informational | Notice | Warning | Critical
+//	sourceComponent contract namespace that recorded the event
+var eventTaxonomy = map[string]struct{ category, component string }{
+	"Issue":            {"CredentialLifecycle", contractNames["CredentialContract"]},
+	"Accept":           {"CredentialLifecycle", contractNames["CredentialContract"]},
+	"Renew":            {"CredentialLifecycle", contractNames["CredentialContract"]},
+	"Revoke":           {"CredentialLifecycle", contractNames["CredentialContract"]},
+	"Expire":           {"CredentialLifecycle", contractNames["CredentialContract"]},
+	"Transfer":         {"CredentialLifecycle", contractNames["CredentialContract"]},
+	"CustodyTransfer":  {"CredentialLifecycle", contractNames["CredentialContract"]},
+	"Notify":           {"CredentialLifecycle", contractNames["CredentialContract"]},
+	"NoticeAck":        {"CredentialLifecycle", contractNames["CredentialContract"]},
+	"Link":             {"CredentialLifecycle", contractNames["CredentialContract"]},
+	"Verify":           {"Access", contractNames["CredentialContract"]},
+	"VerifyAttribute":  {"Access", contractNames["CredentialContract"]},
+	"VerifySummary":    {"Access", contractNames["CredentialContract"]},
+	"BreakGlassVerify": {"Access", contractNames["CredentialContract"]},
+	"ConsentGrant":     {"Consent", contractNames["CredentialContract"]},
+	"ConsentRevoke":    {"Consent", contractNames["CredentialContract"]},
+	"Justify":          {"Review", contractNames["AuditContract"]},
+	"Dispute":          {"Review", contractNames["AuditContract"]},
+	"BreakGlassReview": {"Review", contractNames["AuditContract"]},
+	"HolderBlock":      {"Review", contractNames["AdminContract"]},
+	"HolderUnblock":    {"Review", contractNames["AdminContract"]},
+}
+
+// classifyEvent fills the taxonomy fields callers left empty. Unknown
+// actions get no category rather than a guess.
+func classifyEvent(evt *AccessEvent) {
+	t, known := eventTaxonomy[evt.Action]
+	if evt.EventCategory == "" && known {
+		evt.EventCategory = t.category
+	}
+	if evt.SourceComponent == "" && known {
+		evt.SourceComponent = t.component
+	}
+	if evt.Severity == "" {
+		switch {
+		case evt.Action == "BreakGlassVerify":
+			evt.Severity = "Critical"
+		case evt.Outcome == "Denied" || evt.Outcome == "Failure" || evt.Action == "Dispute":
+			evt.Severity = "Warning"
+		case evt.Action == "Revoke" || evt.Action == "Transfer" || evt.Action == "ConsentRevoke" ||
+			evt.Action == "HolderBlock":
+			evt.Severity = "Notice"
+		default:
+			evt.Severity = "Informational"
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// TransferPackage moves a page of an issuer's credential portfolio to another
+// issuer. Credential IDs are kept, so each credential's historical events stay
+// linked, and every moved credential gets a Transfer event on its trail.
+type TransferPackage struct {
+	TransferID   string   `json:"transferId"`
+	FromIssuerID string   `json:"fromIssuerId"`
+	ToIssuerID   string   `json:"toIssuerId"`
+	CredIDs      []string `json:"credIds"`
+	Digest       string   `json:"digest"` // hex sha256 of newline-joined CredIDs
+	NextBookmark string   `json:"nextBookmark"`
+	Status       string   `json:"status"` // Exported | Imported
+	ExportedBy   string   `json:"exportedBy"`
+	ExportedAt   string   `json:"exportedAt"` // RFC3339
+	ImportedBy   string   `json:"importedBy,omitempty"`
+	ImportedAt   string   `json:"importedAt,omitempty"` // RFC3339
+}
+
+// ExportForTransfer snapshots one page of fromIssuerID's credentials into a
+// transfer package. Call again with NextBookmark for larger portfolios.
+func (s *CredentialContract) ExportForTransfer(ctx contractapi.TransactionContextInterface,
+	transferID, fromIssuerID, toIssuerID string, pageSize int32, bookmark string) (*TransferPackage, error) {
+
+	if fromIssuerID == toIssuerID {
+		return nil, fmt.Errorf("cannot transfer to the same issuer")
+	}
+	existing, err := ctx.GetStub().GetState(transferKey(transferID))
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("transfer %s already exists", transferID)
+	}
+	mspID, err := s.requireIssuerMSP(ctx, fromIssuerID)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := decodeBookmark(ctx, "cred~issuer", bookmark)
+	if err != nil {
+		return nil, err
+	}
+	iter, meta, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(
+		"cred~issuer", []string{fromIssuerID}, pageSize, raw)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+	next, err := encodeBookmark(ctx, "cred~issuer", meta.Bookmark)
+	if err != nil {
+		return nil, err
+	}
+
+	pkg := &TransferPackage{
+		TransferID:   transferID,
+		FromIssuerID: fromIssuerID,
+		ToIssuerID:   toIssuerID,
+		CredIDs:      []string{},
+		NextBookmark: next,
+		Status:       "Exported",
+		ExportedBy:   mspID,
+		ExportedAt:   nowRFC3339(),
+	}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, attrs, err := ctx.GetStub().SplitCompositeKey(kv.Key)
+		if err != nil {
+			return nil, err
+		}
+		pkg.CredIDs = append(pkg.CredIDs, attrs[1])
+	}
+	sum := sha256.Sum256([]byte(strings.Join(pkg.CredIDs, "\n")))
+	pkg.Digest = hex.EncodeToString(sum[:])
+
+	if err := putTransfer(ctx, pkg); err != nil {
+		return nil, err
+	}
+	return pkg, nil
+}
+
+// ImportTransferred reassigns the package's credentials to the receiving issuer.
+func (s *CredentialContract) ImportTransferred(ctx contractapi.TransactionContextInterface,
+	transferID, toIssuerID string) (*TransferPackage, error) {
+
+	pkg, err := s.GetTransfer(ctx, transferID)
+	if err != nil {
+		return nil, err
+	}
+	if pkg.Status != "Exported" {
+		return nil, fmt.Errorf("transfer %s is %s", transferID, pkg.Status)
+	}
+	if pkg.ToIssuerID != toIssuerID {
+		return nil, fmt.Errorf("transfer %s is addressed to %s", transferID, pkg.ToIssuerID)
+	}
+	mspID, err := s.requireIssuerMSP(ctx, toIssuerID)
+	if err != nil {
+		return nil, err
+	}
+
+	reason := fmt.Sprintf("transfer %s from %s", transferID, pkg.FromIssuerID)
+	for _, credID := range pkg.CredIDs {
+		cred, err := s.getCred(ctx, credID)
+		if err != nil {
+			return nil, err
+		}
+		if cred.IssuerID != pkg.FromIssuerID {
+			return nil, fmt.Errorf("credential %s changed issuer since export", credID)
+		}
+		oldIndex, err := ctx.GetStub().CreateCompositeKey("cred~issuer", []string{pkg.FromIssuerID, credID})
+		if err != nil {
+			return nil, err
+		}
+		if err := ctx.GetStub().DelState(oldIndex); err != nil {
+			return nil, err
+		}
+		if err := putIndexKey(ctx, "cred~issuer", toIssuerID, credID); err != nil {
+			return nil, err
+		}
+
+		cred.IssuerID = toIssuerID
+		cred.UpdatedAt = nowRFC3339()
+		if err := s.putCred(ctx, cred); err != nil {
+			return nil, err
+		}
+		if err := moveExpiryIndex(ctx, cred, pkg.FromIssuerID, cred.ExpiresAt); err != nil {
+			return nil, err
+		}
+		if err := incrCounter(ctx, "issuer", pkg.FromIssuerID, -1); err != nil {
+			return nil, err
+		}
+		if err := incrCounter(ctx, "issuer", toIssuerID, 1); err != nil {
+			return nil, err
+		}
+		if err := s.recordEvent(ctx, credID, cred.HolderDID, "Transfer", toIssuerID, "Success", reason); err != nil {
+			return nil, err
+		}
+	}
+
+	pkg.Status = "Imported"
+	pkg.ImportedBy = mspID
+	pkg.ImportedAt = nowRFC3339()
+	if err := putTransfer(ctx, pkg); err != nil {
+		return nil, err
+	}
+	return pkg, nil
+}
+
+// GetTransfer returns a transfer package.
+func (s *CredentialContract) GetTransfer(ctx contractapi.TransactionContextInterface,
+	transferID string) (*TransferPackage, error) {
+
+	bz, err := ctx.GetStub().GetState(transferKey(transferID))
+	if err != nil {
+		return nil, err
+	}
+	if bz == nil {
+		return nil, fmt.Errorf("transfer %s not found", transferID)
+	}
+	var pkg TransferPackage
+	if err := json.Unmarshal(bz, &pkg); err != nil {
+		return nil, err
+	}
+	return &pkg, nil
+}
+
+// ===== Helpers =====
+
+// requireIssuerMSP returns the caller's MSP ID, checking it against the
+// issuer's registered MSP when the issuer is in the registry.
+func (s *ledger) requireIssuerMSP(ctx contractapi.TransactionContextInterface, issuerID string) (string, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", err
+	}
+	issuer, err := s.getIssuer(ctx, issuerID)
+	if err != nil {
+		return "", err
+	}
+	if issuer != nil && issuer.MSPID != mspID {
+		return "", fmt.Errorf("caller from %s cannot act for issuer %s", mspID, issuerID)
+	}
+	return mspID, nil
+}
+
+func putTransfer(ctx contractapi.TransactionContextInterface, pkg *TransferPackage) error {
+	bz, _ := json.Marshal(pkg)
+	if err := ctx.GetStub().PutState(transferKey(pkg.TransferID), bz); err != nil {
+		return err
+	}
+	return emitEvent(ctx, "CredentialTransfer", pkg)
+}
+
+func transferKey(transferID string) string { return "transfer:" + transferID }
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// TxContext is the transaction context every contract runs with. The
+// contract API builds a fresh one per transaction, so it can carry
+// per-transaction state such as the chaincode event counter, the pending
+// event writes (see writes.go), shadow-read divergences (see shadow.go) and
+// the decoding mode (see strict.go).
+type TxContext struct {
+	contractapi.TransactionContext
+	events      int
+	writes      *writeBatch
+	lastEvent   string // name and payload of the event set last
+	lastPayload []byte
+	shadowRead  *bool // shadow-read flag, looked up on first read
+	divergences []ShadowDivergence
+	strict      *bool // strict decoding, looked up on first decode
+}
+
+// emitEvent sets the chaincode event with v's fields plus a dedupeKey of
+// "<txID>:<index>", index counting the events emitted so far in the
+// transaction. Peers deliver only a transaction's last event, and may
+// deliver it again after reconnects, so consumers treat the key as an
+// idempotency key. v must encode as a JSON object.
+func emitEvent(ctx contractapi.TransactionContextInterface, name string, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return fmt.Errorf("event %s payload must be a JSON object: %v", name, err)
+	}
+
+	index := 0
+	tc, ok := ctx.(*TxContext)
+	if ok {
+		index = tc.events
+		tc.events++
+	}
+	key, _ := json.Marshal(fmt.Sprintf("%s:%d", ctx.GetStub().GetTxID(), index))
+	fields["dedupeKey"] = key
+
+	payload, err := canonicalJSON(fields)
+	if err != nil {
+		return err
+	}
+	if ok {
+		tc.lastEvent, tc.lastPayload = name, payload
+	}
+	return ctx.GetStub().SetEvent(name, payload)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Validity policy per credential type. A type's Validity is an ISO 8601
+// duration such as P2Y, P6M or P90D: a credential of the type issued
+// without an expiry expires that long after issuance, and an expiry given
+// at issuance, or a renewal's new expiry, may not lie further out than that
+// from the time of the transaction. Issuers keep choosing shorter terms.
+//
+// Years, months and days are calendar units applied before the time part,
+// as time.AddDate does, so P1M from January 31 ends on March 3 (March 2 in
+// leap years); weeks are seven days. Fractions are not accepted.
+
+var isoDuration = regexp.MustCompile(`^P(?:(\d+)Y)?(?:(\d+)M)?(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// SetCredTypeValidity sets the validity of credType's credentials as an
+// ISO 8601 duration. An empty validity removes the policy. Admin only.
+func (s *RegistryContract) SetCredTypeValidity(ctx contractapi.TransactionContextInterface,
+	credType, validity, actorID string) error {
+
+	if err := requireAdmin(ctx); err != nil {
+		return err
+	}
+	if validity != "" {
+		if _, err := addISODuration(time.Now().UTC(), validity); err != nil {
+			return err
+		}
+	}
+	ct, err := s.mustGetCredType(ctx, credType)
+	if err != nil {
+		return err
+	}
+
+	ct.Validity = validity
+	ct.UpdatedAt = nowRFC3339()
+	if err := s.putCredType(ctx, ct); err != nil {
+		return err
+	}
+	return s.recordCredTypeEvent(ctx, credType, "ValidityPolicy", actorID, "validity="+validity)
+}
+
+// ===== Helpers =====
+
+// addISODuration returns t plus the ISO 8601 duration d.
+func addISODuration(t time.Time, d string) (time.Time, error) {
+	m := isoDuration.FindStringSubmatch(d)
+	if m == nil || d == "P" || d[len(d)-1] == 'T' {
+		return time.Time{}, fmt.Errorf("invalid ISO 8601 duration %q", d)
+	}
+	n := make([]int, len(m))
+	for i, part := range m[1:] {
+		if part == "" {
+			continue
+		}
+		v, err := strconv.Atoi(part)
+		if err != nil || v > 1000000 {
+			return time.Time{}, fmt.Errorf("ISO 8601 duration %q is out of range", d)
+		}
+		n[i+1] = v
+	}
+	out := t.AddDate(n[1], n[2], 7*n[3]+n[4]).
+		Add(time.Duration(n[5])*time.Hour + time.Duration(n[6])*time.Minute + time.Duration(n[7])*time.Second)
+	if !out.After(t) {
+		return time.Time{}, fmt.Errorf("ISO 8601 duration %q is zero", d)
+	}
+	return out, nil
+}
+
+// applyValidity returns the expiry for a credential of type ct issued or
+// renewed at now with the requested expiresAt (RFC3339, possibly empty):
+// the type's default when none was requested, and an error when the
+// request exceeds it. Types without a validity policy leave expiresAt as is.
+func applyValidity(ct *CredentialType, now time.Time, expiresAt string) (string, error) {
+	if ct == nil || ct.Validity == "" {
+		return expiresAt, nil
+	}
+	limit, err := addISODuration(now, ct.Validity)
+	if err != nil {
+		return "", err
+	}
+	if expiresAt == "" {
+		return limit.UTC().Format(time.RFC3339), nil
+	}
+	exp, err := time.Parse(time.RFC3339, expiresAt)
+	if err != nil {
+		return "", err
+	}
+	if exp.After(limit) {
+		return "", fmt.Errorf("expiry %s exceeds the %s validity of credential type %s (at most %s)",
+			expiresAt, ct.Validity, ct.CredType, limit.UTC().Format(time.RFC3339))
+	}
+	return expiresAt, nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Verifier is a registry entry for an organization that checks credentials,
+// with a reference to the data processing agreement it operates under. The
+// agreement itself stays off chain; only its hash is recorded.
+type Verifier struct {
+	VerifierID   string `json:"verifierId"`
+	MSPID        string `json:"mspId"`
+	DPAHash      string `json:"dpaHash"`      // hex sha256 of the signed DPA
+	DPAExpiresAt string `json:"dpaExpiresAt"` // RFC3339
+	RegisteredAt string `json:"registeredAt"` // RFC3339
+	UpdatedAt    string `json:"updatedAt"`    // RFC3339
+}
+
+// RegisterVerifier adds a verifier with the DPA it has signed. Only
+// admins may register verifiers.
+func (s *RegistryContract) RegisterVerifier(ctx contractapi.TransactionContextInterface,
+	verifierID, mspID, dpaHash, dpaExpiresAt string) error {
+
+	if err := requireAdmin(ctx); err != nil {
+		return err
+	}
+	existing, err := s.getVerifier(ctx, verifierID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("verifier %s already registered", verifierID)
+	}
+	if mspID == "" {
+		return fmt.Errorf("mspId is required")
+	}
+	if err := validateDPA(dpaHash, dpaExpiresAt); err != nil {
+		return err
+	}
+
+	v := &Verifier{
+		VerifierID:   verifierID,
+		MSPID:        mspID,
+		DPAHash:      dpaHash,
+		DPAExpiresAt: dpaExpiresAt,
+		RegisteredAt: nowRFC3339(),
+		UpdatedAt:    nowRFC3339(),
+	}
+	bz, _ := json.Marshal(v)
+	return ctx.GetStub().PutState(verifierKey(verifierID), bz)
+}
+
+// RenewVerifierDPA records a new or extended DPA. Only the verifier's own
+// MSP may renew.
+func (s *RegistryContract) RenewVerifierDPA(ctx contractapi.TransactionContextInterface,
+	verifierID, dpaHash, dpaExpiresAt string) error {
+
+	v, err := s.getVerifier(ctx, verifierID)
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		return fmt.Errorf("verifier %s not registered", verifierID)
+	}
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return err
+	}
+	if mspID != v.MSPID {
+		return fmt.Errorf("caller MSP %s does not match verifier MSP %s", mspID, v.MSPID)
+	}
+	if err := validateDPA(dpaHash, dpaExpiresAt); err != nil {
+		return err
+	}
+
+	v.DPAHash = dpaHash
+	v.DPAExpiresAt = dpaExpiresAt
+	v.UpdatedAt = nowRFC3339()
+	bz, _ := json.Marshal(v)
+	return ctx.GetStub().PutState(verifierKey(verifierID), bz)
+}
+
+// GetVerifier returns the registry entry for a verifier.
+func (s *RegistryContract) GetVerifier(ctx contractapi.TransactionContextInterface,
+	verifierID string) (*Verifier, error) {
+
+	v, err := s.getVerifier(ctx, verifierID)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, fmt.Errorf("verifier %s not registered", verifierID)
+	}
+	return v, nil
+}
+
+// ===== Helpers =====
+
+func validateDPA(dpaHash, dpaExpiresAt string) error {
+	if raw, err := hex.DecodeString(dpaHash); err != nil || len(raw) != sha256.Size {
+		return fmt.Errorf("dpaHash must be a hex sha256 digest")
+	}
+	exp, err := time.Parse(time.RFC3339, dpaExpiresAt)
+	if err != nil {
+		return fmt.Errorf("invalid dpaExpiresAt %q: %v", dpaExpiresAt, err)
+	}
+	if !exp.After(time.Now()) {
+		return fmt.Errorf("dpaExpiresAt %s is not in the future", dpaExpiresAt)
+	}
+	return nil
+}
+
+func (v *Verifier) dpaExpired() bool {
+	exp, err := time.Parse(time.RFC3339, v.DPAExpiresAt)
+	return err != nil || !time.Now().UTC().Before(exp)
+}
+
+// checkVerifier returns a denial reason when the verifier may not check the
+// credential: its holder is blocked, it is not registered or the caller is
+// not its MSP, its DPA has lapsed, the channel requires an attested wallet
+// and none was presented, or the jurisdiction policy excludes it.
+// Unregistered verifiers are denied unless the channel config sets
+// allowUnregisteredVerifiers; they may then only check credentials without
+// a jurisdiction.
+func (s *ledger) checkVerifier(ctx contractapi.TransactionContextInterface,
+	cred *Credential, verifierID string) (string, error) {
+
+	if denial, err := holderBlockDenial(ctx, cred.HolderDID); err != nil || denial != "" {
+		return denial, err
+	}
+	v, err := s.getVerifier(ctx, verifierID)
+	if err != nil {
+		return "", err
+	}
+	if v == nil {
+		cfg, err := loadConfig(ctx)
+		if err != nil {
+			return "", err
+		}
+		if !cfg.AllowUnregisteredVerifiers {
+			return fmt.Sprintf("verifier %s not registered", verifierID), nil
+		}
+	} else {
+		mspID, err := ctx.GetClientIdentity().GetMSPID()
+		if err != nil {
+			return "", err
+		}
+		if mspID != v.MSPID {
+			return fmt.Sprintf("caller MSP %s does not match verifier %s MSP %s", mspID, verifierID, v.MSPID), nil
+		}
+		if v.dpaExpired() {
+			return fmt.Sprintf("verifier %s data processing agreement expired at %s", verifierID, v.DPAExpiresAt), nil
+		}
+	}
+	if denial, err := checkWalletAttestation(ctx); err != nil || denial != "" {
+		return denial, err
+	}
+	if denial, err := consentDenial(ctx, cred, verifierID); err != nil || denial != "" {
+		return denial, err
+	}
+	return s.checkJurisdiction(ctx, cred, verifierID)
+}
+
+func (s *ledger) getVerifier(ctx contractapi.TransactionContextInterface, verifierID string) (*Verifier, error) {
+	bz, err := ctx.GetStub().GetState(verifierKey(verifierID))
+	if err != nil || bz == nil {
+		return nil, err
+	}
+	var v Verifier
+	if err := json.Unmarshal(bz, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func verifierKey(verifierID string) string { return "verifier:" + verifierID }
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// WalletAttestation is the gateway's check of the device attestation token
+// (Play Integrity, App Attest, ...) the presenting wallet sent. Those tokens
+// are verified against Google's and Apple's services, which chaincode cannot
+// reach, so the gateway passes only the outcome, in the transient field
+// "walletAttestation" of VerifyCreds and VerifyAttribute, keeping their
+// signatures unchanged. The outcome is recorded on the verification event.
+type WalletAttestation struct {
+	Status   string `json:"status"`   // Valid | Invalid | Unverified (the gateway could not check it)
+	Platform string `json:"platform"` // e.g. play-integrity, app-attest
+}
+
+const walletAttestationField = "walletAttestation"
+
+// ===== Helpers =====
+
+// walletAttestation returns the attestation passed with the transaction, or
+// nil when there is none.
+func walletAttestation(ctx contractapi.TransactionContextInterface) (*WalletAttestation, error) {
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, err
+	}
+	raw, ok := transient[walletAttestationField]
+	if !ok {
+		return nil, nil
+	}
+	var att WalletAttestation
+	if err := json.Unmarshal(raw, &att); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", walletAttestationField, err)
+	}
+	switch att.Status {
+	case "Valid", "Invalid", "Unverified":
+	default:
+		return nil, fmt.Errorf("invalid %s status %q", walletAttestationField, att.Status)
+	}
+	return &att, nil
+}
+
+// checkWalletAttestation returns a denial reason when the channel requires
+// attested wallets and the presentation did not come from one.
+func checkWalletAttestation(ctx contractapi.TransactionContextInterface) (string, error) {
+	cfg, err := loadConfig(ctx)
+	if err != nil || !cfg.RequireWalletAttestation {
+		return "", err
+	}
+	att, err := walletAttestation(ctx)
+	if err != nil {
+		return "", err
+	}
+	if att == nil {
+		return "wallet attestation required", nil
+	}
+	if att.Status != "Valid" {
+		return fmt.Sprintf("wallet attestation %s", att.Status), nil
+	}
+	return "", nil
+}
+
+// stampWalletAttestation copies the transaction's attestation onto a
+// verification event.
+func stampWalletAttestation(ctx contractapi.TransactionContextInterface, evt *AccessEvent) error {
+	if evt.Action != "Verify" && evt.Action != "VerifyAttribute" && evt.Action != "BreakGlassVerify" {
+		return nil
+	}
+	att, err := walletAttestation(ctx)
+	if err != nil || att == nil {
+		return err
+	}
+	evt.WalletAttestation = att.Status
+	evt.WalletPlatform = att.Platform
+	return nil
+}
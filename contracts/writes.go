@@ -0,0 +1,147 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Event recording, credential state and counters write through a
+// per-transaction batch on the TxContext, flushed by the AfterTransaction
+// hook (flushWrites). Fabric does not let a transaction read its own writes,
+// so without it a second event in one transaction re-read the actor's
+// reputation as committed and overwrote the first event's update, and an
+// Atomic transaction could not revoke a credential and then link to it; the
+// batch serves those reads from the pending write. It also records what
+// recording costs in the read-write set, which GetEventWriteCost reports,
+// so an index proposal can be weighed by the bytes it adds to every event.
+//
+// Each event is written once, under event~holder. Its ID pointer holds that
+// key; every other lookup entry (event~actor, event~since, ...) is value-less and is
+// resolved through the ID pointer, since index keys already carry the event
+// ID and repeating the full event key in each of them grew every event's
+// write set by one key per index.
+
+// WriteSetCost is what batched writes added to a transaction's read-write set.
+type WriteSetCost struct {
+	Writes     int `json:"writes"`
+	Reads      int `json:"reads"`
+	KeyBytes   int `json:"keyBytes"`
+	ValueBytes int `json:"valueBytes"`
+	// ByKeyspace is key plus value bytes per composite key object type or
+	// key prefix ("event~holder", "eventid", "reputation", ...).
+	ByKeyspace map[string]int `json:"byKeyspace"`
+}
+
+// GetEventWriteCost records an event as the given call would, without
+// committing it, and reports the writes it takes under the current
+// configuration and indexes. Evaluate it; nothing is kept.
+func (s *AdminContract) GetEventWriteCost(ctx contractapi.TransactionContextInterface,
+	credID, holderDID, action, actorID, outcome, reason string) (*WriteSetCost, error) {
+
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if _, err := s.writeEvent(ctx, credID, holderDID, action, actorID, outcome, reason); err != nil {
+		return nil, err
+	}
+	b := batchOf(ctx)
+	if b == nil {
+		return &WriteSetCost{ByKeyspace: map[string]int{}}, nil
+	}
+	cost := b.cost()
+	*b = writeBatch{}
+	return cost, nil
+}
+
+// indexMarker is the value of a value-less index entry; Fabric treats an
+// empty value as a delete.
+var indexMarker = []byte{0}
+
+// ===== Helpers =====
+
+type writeBatch struct {
+	order  []string
+	values map[string][]byte
+	reads  map[string]bool
+}
+
+func batchOf(ctx contractapi.TransactionContextInterface) *writeBatch {
+	tc, ok := ctx.(*TxContext)
+	if !ok {
+		return nil
+	}
+	if tc.writes == nil {
+		tc.writes = &writeBatch{}
+	}
+	return tc.writes
+}
+
+// batchPut queues a write; a later write to the same key replaces it.
+func batchPut(ctx contractapi.TransactionContextInterface, key string, value []byte) error {
+	b := batchOf(ctx)
+	if b == nil {
+		return ctx.GetStub().PutState(key, value)
+	}
+	if b.values == nil {
+		b.values = map[string][]byte{}
+	}
+	if _, queued := b.values[key]; !queued {
+		b.order = append(b.order, key)
+	}
+	b.values[key] = value
+	return nil
+}
+
+// batchGet reads key as this transaction left it so far.
+func batchGet(ctx contractapi.TransactionContextInterface, key string) ([]byte, error) {
+	b := batchOf(ctx)
+	if b != nil {
+		if v, queued := b.values[key]; queued {
+			return v, nil
+		}
+		if b.reads == nil {
+			b.reads = map[string]bool{}
+		}
+		b.reads[key] = true
+	}
+	return ctx.GetStub().GetState(key)
+}
+
+// flushWrites is every contract's AfterTransaction hook. It only runs for
+// transactions that succeeded. It also emits shadow-read divergences, last,
+// so their event wraps the transaction's own.
+func flushWrites(ctx contractapi.TransactionContextInterface) error {
+	b := batchOf(ctx)
+	if b == nil {
+		return nil
+	}
+	for _, key := range b.order {
+		if err := ctx.GetStub().PutState(key, b.values[key]); err != nil {
+			return err
+		}
+	}
+	*b = writeBatch{}
+	return emitShadowDivergences(ctx)
+}
+
+func (b *writeBatch) cost() *WriteSetCost {
+	c := &WriteSetCost{Writes: len(b.order), Reads: len(b.reads), ByKeyspace: map[string]int{}}
+	for _, key := range b.order {
+		c.KeyBytes += len(key)
+		c.ValueBytes += len(b.values[key])
+		c.ByKeyspace[keyspace(key)] += len(key) + len(b.values[key])
+	}
+	return c
+}
+
+// keyspace names the object type of a composite key, or the prefix of a
+// plain "<prefix>:<id>" key.
+func keyspace(key string) string {
+	if strings.HasPrefix(key, "\x00") {
+		objectType, _, _ := strings.Cut(key[1:], "\x00")
+		return objectType
+	}
+	prefix, _, _ := strings.Cut(key, ":")
+	return prefix
+}
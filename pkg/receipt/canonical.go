@@ -0,0 +1,26 @@
+package receipt
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// canonicalJSON re-encodes a JSON document with object keys sorted and no
+// insignificant whitespace, as the gateway's canonicalJson and the
+// chaincode's canonicalJSON do before signing or hashing a record.
+func canonicalJSON(doc []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+	// encoding/json writes map keys in sorted order.
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(generic); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
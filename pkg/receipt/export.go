@@ -0,0 +1,126 @@
+package receipt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// ChainFormat names the hash-chained export format: JSON Lines where line i
+// is {"seq": i, "event": ..., "chainHash": ...} and chainHash is
+// hex(sha256(previous chainHash bytes || canonical JSON of the event)),
+// starting from 32 zero bytes, followed by one {"manifest": ...,
+// "signature": ...} line whose manifest carries the event count and final
+// chain hash and is signed with a detached JWS over its canonical JSON.
+const ChainFormat = "audittrail-chain-v1"
+
+// ExportManifest is the signed last line of a chained export.
+type ExportManifest struct {
+	Format       string `json:"format"`
+	JobID        string `json:"jobId,omitempty"`
+	Category     string `json:"category,omitempty"` // set on per-category parts of encrypted exports
+	EventCount   int    `json:"eventCount"`
+	FirstEventID string `json:"firstEventId"`
+	LastEventID  string `json:"lastEventId"`
+	ChainHead    string `json:"chainHead"`
+	GeneratedAt  string `json:"generatedAt"`
+}
+
+// ChainedExport is an export whose chain and manifest verified.
+type ChainedExport struct {
+	Manifest ExportManifest
+	Org      string // MSP ID of the org whose gateway signed the manifest
+	KeyID    string
+	// Events are the exported events in order, as they appear in the file,
+	// so callers can also check each one's own signature with VerifyEvent.
+	Events []json.RawMessage
+}
+
+// VerifyChainedExport reads a chained export and checks that every line's
+// chain hash follows from the lines before it, that the manifest closes
+// the chain at the right count and head, and that the gateway signed the
+// manifest. Any edited, dropped, added or reordered line fails it.
+func VerifyChainedExport(r io.Reader, keys *KeySet) (*ChainedExport, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	prev := make([]byte, sha256.Size)
+	out := &ChainedExport{}
+	var manifestLine []byte
+	for n := 1; sc.Scan(); n++ {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if manifestLine != nil {
+			return nil, fmt.Errorf("receipt: line %d follows the manifest", n)
+		}
+		var entry struct {
+			Seq       int             `json:"seq"`
+			Event     json.RawMessage `json:"event"`
+			ChainHash string          `json:"chainHash"`
+			Manifest  json.RawMessage `json:"manifest"`
+		}
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, fmt.Errorf("receipt: line %d is not JSON: %v", n, err)
+		}
+		if entry.Manifest != nil {
+			manifestLine = append([]byte(nil), line...)
+			continue
+		}
+		if entry.Seq != len(out.Events)+1 {
+			return nil, fmt.Errorf("receipt: line %d has seq %d, want %d", n, entry.Seq, len(out.Events)+1)
+		}
+		canonical, err := canonicalJSON(entry.Event)
+		if err != nil {
+			return nil, fmt.Errorf("receipt: line %d: %v", n, err)
+		}
+		sum := sha256.Sum256(append(append([]byte(nil), prev...), canonical...))
+		if hex.EncodeToString(sum[:]) != entry.ChainHash {
+			return nil, fmt.Errorf("receipt: chain breaks at seq %d", entry.Seq)
+		}
+		prev = sum[:]
+		out.Events = append(out.Events, entry.Event)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if manifestLine == nil {
+		return nil, fmt.Errorf("receipt: export has no manifest; it may be truncated")
+	}
+
+	var last struct {
+		Manifest  json.RawMessage `json:"manifest"`
+		Signature string          `json:"signature"`
+	}
+	if err := json.Unmarshal(manifestLine, &last); err != nil {
+		return nil, err
+	}
+	payload, err := canonicalJSON(last.Manifest)
+	if err != nil {
+		return nil, err
+	}
+	h, err := verifyDetached(last.Signature, payload, keys)
+	if err != nil {
+		return nil, err
+	}
+	out.Org, out.KeyID = h.Org, h.Kid
+	if err := json.Unmarshal(last.Manifest, &out.Manifest); err != nil {
+		return nil, err
+	}
+	m := &out.Manifest
+	if m.Format != ChainFormat {
+		return nil, fmt.Errorf("receipt: export format %q, want %s", m.Format, ChainFormat)
+	}
+	if m.EventCount != len(out.Events) {
+		return nil, fmt.Errorf("receipt: manifest counts %d events, file has %d", m.EventCount, len(out.Events))
+	}
+	if m.ChainHead != hex.EncodeToString(prev) {
+		return nil, fmt.Errorf("receipt: manifest chain head does not match the events")
+	}
+	return out, nil
+}
@@ -0,0 +1,3 @@
+module audittrail/pkg/receipt
+
+go 1.21
@@ -0,0 +1,118 @@
+package receipt
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrBadSignature is returned when a signature does not verify under the
+// key it names.
+var ErrBadSignature = errors.New("receipt: signature does not verify")
+
+// JWK is one key of a JSON Web Key Set. Only Ed25519 (OKP) keys are used.
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+}
+
+// KeySet is a gateway's /.well-known/jwks.json, saved for offline checks.
+type KeySet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// ParseKeySet reads a JWKS document.
+func ParseKeySet(data []byte) (*KeySet, error) {
+	var ks KeySet
+	if err := json.Unmarshal(data, &ks); err != nil {
+		return nil, fmt.Errorf("receipt: invalid key set: %v", err)
+	}
+	return &ks, nil
+}
+
+func (ks *KeySet) key(kid string) (ed25519.PublicKey, error) {
+	for _, k := range ks.Keys {
+		if k.Kid != kid {
+			continue
+		}
+		if k.Kty != "OKP" || k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("receipt: key %s is not an Ed25519 key", kid)
+		}
+		raw, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(raw) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("receipt: key %s is malformed", kid)
+		}
+		return ed25519.PublicKey(raw), nil
+	}
+	return nil, fmt.Errorf("receipt: no key %q in key set", kid)
+}
+
+// Header is the protected header of a gateway JWS.
+type Header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ,omitempty"`
+	Org string `json:"org,omitempty"` // signing org's MSP ID, on event signatures
+}
+
+// VerifyJWS checks a compact JWS signed with the gateway key (consent
+// receipts, export manifests, access reviews) and returns its header and
+// payload.
+func VerifyJWS(token string, keys *KeySet) (*Header, json.RawMessage, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, nil, fmt.Errorf("receipt: JWS must have three parts")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, fmt.Errorf("receipt: JWS payload is not base64url")
+	}
+	h, err := verifyParts(parts[0], parts[1], parts[2], keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	return h, payload, nil
+}
+
+// verifyDetached checks a JWS with detached payload, "<header>..<sig>",
+// over payload (RFC 7515 appendix F).
+func verifyDetached(jws string, payload []byte, keys *KeySet) (*Header, error) {
+	parts := strings.Split(jws, ".")
+	if len(parts) != 3 || parts[1] != "" {
+		return nil, fmt.Errorf("receipt: detached JWS must look like <header>..<signature>")
+	}
+	return verifyParts(parts[0], base64.RawURLEncoding.EncodeToString(payload), parts[2], keys)
+}
+
+func verifyParts(header, payload, sig string, keys *KeySet) (*Header, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(header)
+	if err != nil {
+		return nil, fmt.Errorf("receipt: JWS header is not base64url")
+	}
+	var h Header
+	if err := json.Unmarshal(raw, &h); err != nil {
+		return nil, fmt.Errorf("receipt: JWS header is not JSON")
+	}
+	if h.Alg != "EdDSA" {
+		return nil, fmt.Errorf("receipt: unsupported JWS alg %q", h.Alg)
+	}
+	key, err := keys.key(h.Kid)
+	if err != nil {
+		return nil, err
+	}
+	s, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return nil, fmt.Errorf("receipt: JWS signature is not base64url")
+	}
+	if !ed25519.Verify(key, []byte(header+"."+payload), s) {
+		return nil, ErrBadSignature
+	}
+	return &h, nil
+}
@@ -0,0 +1,99 @@
+package receipt
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// The hashing below mirrors contracts/merkle.go: leaves and interior nodes
+// are domain-separated (RFC 6962 style) and an odd node is carried up.
+const (
+	leafPrefix = 0x00
+	nodePrefix = 0x01
+)
+
+// ProofStep is one sibling on the path from a leaf to the root.
+type ProofStep struct {
+	Hash     string `json:"hash"`     // hex sha256
+	Position string `json:"position"` // left | right
+}
+
+// Checkpoint is the anchored Merkle root over a holder's audit trail.
+type Checkpoint struct {
+	CheckpointID string `json:"checkpointId"`
+	HolderDID    string `json:"holderDid"`
+	Root         string `json:"root"`
+	EventCount   int    `json:"eventCount"`
+	CutoffNanos  int64  `json:"cutoffNanos"`
+	TxID         string `json:"txId"`
+	CreatedAt    string `json:"createdAt"`
+}
+
+// InclusionProof is the chaincode's GetAuditTrailIntegrityProof result.
+type InclusionProof struct {
+	EventJSON  string      `json:"eventJson"` // exact JSON the leaf is computed over
+	Leaf       string      `json:"leaf"`
+	Proof      []ProofStep `json:"proof"`
+	Checkpoint Checkpoint  `json:"checkpoint"`
+}
+
+// VerifyInclusion checks that the proof's event hashes to its leaf and that
+// the path leads to trustedRoot, and returns the event. The root must come
+// from a source the caller trusts, e.g. the CheckpointCreated chaincode
+// event or a block it has validated; pass "" to accept the root the proof
+// carries, which only shows the proof is self-consistent.
+func VerifyInclusion(p *InclusionProof, trustedRoot string) (*Event, error) {
+	if trustedRoot == "" {
+		trustedRoot = p.Checkpoint.Root
+	} else if trustedRoot != p.Checkpoint.Root {
+		return nil, fmt.Errorf("receipt: proof is for root %s, not %s", p.Checkpoint.Root, trustedRoot)
+	}
+	want, err := hex.DecodeString(trustedRoot)
+	if err != nil {
+		return nil, fmt.Errorf("receipt: root must be hex: %v", err)
+	}
+
+	h := sha256.New()
+	h.Write([]byte{leafPrefix})
+	h.Write([]byte(p.EventJSON))
+	leaf := h.Sum(nil)
+	if hex.EncodeToString(leaf) != p.Leaf {
+		return nil, fmt.Errorf("receipt: event does not hash to the proof's leaf")
+	}
+
+	cur := leaf
+	for i, step := range p.Proof {
+		sib, err := hex.DecodeString(step.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("receipt: proof step %d: hash must be hex: %v", i, err)
+		}
+		switch step.Position {
+		case "left":
+			cur = node(sib, cur)
+		case "right":
+			cur = node(cur, sib)
+		default:
+			return nil, fmt.Errorf("receipt: proof step %d: position must be left or right", i)
+		}
+	}
+	if !bytes.Equal(cur, want) {
+		return nil, fmt.Errorf("receipt: proof does not lead to root %s", trustedRoot)
+	}
+
+	var evt Event
+	if err := json.Unmarshal([]byte(p.EventJSON), &evt); err != nil {
+		return nil, fmt.Errorf("receipt: proof event is not JSON: %v", err)
+	}
+	return &evt, nil
+}
+
+func node(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{nodePrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
@@ -0,0 +1,123 @@
+// Package receipt checks AuditTrail evidence offline: gateway-signed audit
+// events and verification receipts, compact JWS documents (consent
+// receipts, export manifests, access reviews), hash-chained exports, offline
+// verification bundles and Merkle inclusion proofs of events under a
+// holder's audit-trail checkpoint. It needs only the gateway's
+// /.well-known/jwks.json, saved beforehand, and has no Fabric
+// dependency, so relying parties and auditors can check what they were
+// handed without access to the consortium.
+package receipt
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Event is an audit event as the gateway returns and exports it.
+type Event struct {
+	EventID           string `json:"eventId"`
+	CredID            string `json:"credId"`
+	HolderDID         string `json:"holderDid"`
+	Action            string `json:"action"`
+	ActorID           string `json:"actorId"`
+	Outcome           string `json:"outcome"`
+	Reason            string `json:"reason"`
+	OccurredAt        string `json:"occurredAt"`
+	EventCategory     string `json:"eventCategory,omitempty"`
+	Severity          string `json:"severity,omitempty"`
+	SourceComponent   string `json:"sourceComponent,omitempty"`
+	WalletAttestation string `json:"walletAttestation,omitempty"`
+	WalletPlatform    string `json:"walletPlatform,omitempty"`
+	Signature         string `json:"signature,omitempty"`
+}
+
+// SignedEvent is an event whose signature verified.
+type SignedEvent struct {
+	Event
+	Org   string // MSP ID of the org whose gateway signed it
+	KeyID string
+}
+
+// VerifyEvent checks the detached signature an event carries, made over the
+// canonical JSON of the event without its signature field. Pass the event
+// exactly as received: every field it has is covered, including ones this
+// package does not know.
+func VerifyEvent(eventJSON []byte, keys *KeySet) (*SignedEvent, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(eventJSON, &fields); err != nil {
+		return nil, fmt.Errorf("receipt: event is not a JSON object: %v", err)
+	}
+	var sig string
+	if err := json.Unmarshal(fields["signature"], &sig); err != nil || sig == "" {
+		return nil, fmt.Errorf("receipt: event has no signature")
+	}
+	delete(fields, "signature")
+	unsigned, _ := json.Marshal(fields)
+	payload, err := canonicalJSON(unsigned)
+	if err != nil {
+		return nil, err
+	}
+	h, err := verifyDetached(sig, payload, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &SignedEvent{Org: h.Org, KeyID: h.Kid}
+	if err := json.Unmarshal(eventJSON, &out.Event); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// VerificationResult is the result half of a verification receipt.
+type VerificationResult struct {
+	CredID                  string `json:"credId"`
+	AttrPath                string `json:"attrPath,omitempty"`
+	IsActive                bool   `json:"isActive"`
+	HashMatches             bool   `json:"hashMatches"`
+	Denied                  bool   `json:"denied"`
+	DenialReason            string `json:"denialReason,omitempty"`
+	CheckedAt               string `json:"checkedAt"`
+	RecommendedRecheckAfter string `json:"recommendedRecheckAfter,omitempty"`
+	ValidAsOfBlock          uint64 `json:"validAsOfBlock,omitempty"`
+}
+
+// VerificationReceipt is what a verifier keeps from POST /v1/verify: the
+// result and the signed audit event that recorded the check.
+type VerificationReceipt struct {
+	Result VerificationResult `json:"result"`
+	Event  SignedEvent        `json:"-"`
+}
+
+// verificationActions are the event actions that record a credential check.
+var verificationActions = map[string]bool{"Verify": true, "VerifyAttribute": true, "BreakGlassVerify": true}
+
+// VerifyReceipt checks a saved POST /v1/verify response body ({"result":
+// ..., "event": ...}): the event's signature, that it records a check of
+// the same credential, and that its outcome agrees with the result.
+func VerifyReceipt(data []byte, keys *KeySet) (*VerificationReceipt, error) {
+	var raw struct {
+		Result VerificationResult `json:"result"`
+		Event  json.RawMessage    `json:"event"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("receipt: invalid verification receipt: %v", err)
+	}
+	if len(raw.Event) == 0 {
+		return nil, fmt.Errorf("receipt: verification receipt has no event")
+	}
+	evt, err := VerifyEvent(raw.Event, keys)
+	if err != nil {
+		return nil, err
+	}
+	if !verificationActions[evt.Action] {
+		return nil, fmt.Errorf("receipt: event %s records %s, not a verification", evt.EventID, evt.Action)
+	}
+	if evt.CredID != raw.Result.CredID {
+		return nil, fmt.Errorf("receipt: event %s is for credential %s, not %s", evt.EventID, evt.CredID, raw.Result.CredID)
+	}
+	if raw.Result.Denied != (evt.Outcome == "Denied") {
+		return nil, fmt.Errorf("receipt: result and event %s disagree on the outcome", evt.EventID)
+	}
+	return &VerificationReceipt{Result: raw.Result, Event: *evt}, nil
+}